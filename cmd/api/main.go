@@ -1,19 +1,26 @@
 package main
 
 import (
+	"crypto/ed25519"
 	"log"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
 	"wallet/internal/cache"
 	"wallet/internal/database"
+	"wallet/internal/fx"
 	"wallet/internal/handlers"
 	"wallet/internal/middleware"
 	"wallet/internal/migrations"
+	"wallet/internal/pubsub"
 	"wallet/internal/repositories"
 	"wallet/internal/services"
+	"wallet/internal/sms"
 )
 
 func main() {
@@ -36,12 +43,325 @@ func main() {
 	userTokenRepo := repositories.NewUserTokenRepository(db)
 	walletRepo := repositories.NewWalletRepository(db)
 	transactionRepo := repositories.NewTransactionRepository(db)
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	idempotencyKeyRepo := repositories.NewIdempotencyKeyRepository(db)
+	fxQuoteRepo := repositories.NewFXQuoteRepository(db)
+	holdRepo := repositories.NewHoldRepository(db)
+	limitRepo := repositories.NewLimitRepository(db)
+	scheduleRepo := repositories.NewScheduleRepository(db)
+	paymentRequestRepo := repositories.NewPaymentRequestRepository(db)
+	payoutRepo := repositories.NewPayoutRepository(db)
+	interestRepo := repositories.NewInterestRepository(db)
+	statementRepo := repositories.NewStatementRepository(db)
+	reconciliationRepo := repositories.NewReconciliationRepository(db)
+	transactionLogRepo := repositories.NewTransactionLogRepository(db)
+	outboxRepo := repositories.NewOutboxRepository(db)
+	webhookRepo := repositories.NewWebhookRepository(db)
 	cache := cache.NewInMemoryCache()
 
-	service := services.NewWalletService(walletRepo, transactionRepo, cache)
+	// Streams on every instance are notified of changes through Postgres,
+	// unless the service runs as a single instance
+	var pubSub pubsub.PubSub
+	switch driver := os.Getenv("PUBSUB_DRIVER"); driver {
+	case "", "postgres":
+		pubSub = pubsub.NewPostgresPubSub(db, database.DSN())
+	case "memory":
+		pubSub = pubsub.NewInMemoryPubSub()
+	default:
+		log.Fatalf("unknown PUBSUB_DRIVER %q", driver)
+	}
+
+	idempotencyKeyTTL := services.DefaultIdempotencyKeyTTL
+	if ttl := os.Getenv("IDEMPOTENCY_KEY_TTL"); ttl != "" {
+		idempotencyKeyTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	ratesFile := os.Getenv("FX_RATES_FILE")
+	if ratesFile == "" {
+		ratesFile = "rates.json"
+	}
+	rateProvider, err := fx.NewStaticRateProvider(ratesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fxSpreadBps := int64(services.DefaultFXSpreadBps)
+	if spread := os.Getenv("FX_SPREAD_BPS"); spread != "" {
+		fxSpreadBps, err = strconv.ParseInt(spread, 10, 64)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fxQuoteTTL := services.DefaultFXQuoteTTL
+	if ttl := os.Getenv("FX_QUOTE_TTL"); ttl != "" {
+		fxQuoteTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	holdTTL := services.DefaultHoldTTL
+	if ttl := os.Getenv("HOLD_TTL"); ttl != "" {
+		holdTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	limitsFile := os.Getenv("LIMITS_FILE")
+	if limitsFile == "" {
+		limitsFile = "limits.json"
+	}
+	limits, err := services.LoadLimits(limitsFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	feesFile := os.Getenv("FEES_FILE")
+	if feesFile == "" {
+		feesFile = "fees.json"
+	}
+	fees, err := services.LoadFees(feesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	service := services.NewWalletService(walletRepo, transactionRepo, ledgerRepo, idempotencyKeyRepo, fxQuoteRepo, holdRepo, limitRepo, userRepo, outboxRepo, cache, pubSub, idempotencyKeyTTL, holdTTL, limits, fees)
+	// Verification codes are logged until an SMS provider is configured
+	userService := services.NewUserService(userRepo, sms.NewLogSender())
+	fxService := services.NewFXService(fxQuoteRepo, rateProvider, fxSpreadBps, fxQuoteTTL)
+
+	scheduleRetryInterval := services.DefaultScheduleRetryInterval
+	if interval := os.Getenv("SCHEDULE_RETRY_INTERVAL"); interval != "" {
+		scheduleRetryInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	scheduleMaxAttempts := services.DefaultScheduleMaxAttempts
+	if attempts := os.Getenv("SCHEDULE_MAX_ATTEMPTS"); attempts != "" {
+		scheduleMaxAttempts, err = strconv.Atoi(attempts)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	scheduleService := services.NewScheduleService(scheduleRepo, idempotencyKeyRepo, service, scheduleRetryInterval, scheduleMaxAttempts)
+
+	paymentRequestTTL := services.DefaultPaymentRequestTTL
+	if ttl := os.Getenv("PAYMENT_REQUEST_TTL"); ttl != "" {
+		paymentRequestTTL, err = time.ParseDuration(ttl)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	paymentRequestService := services.NewPaymentRequestService(paymentRequestRepo, idempotencyKeyRepo, service, paymentRequestTTL)
+	payoutService := services.NewPayoutService(payoutRepo, idempotencyKeyRepo, service, userService)
+
+	interestRatesFile := os.Getenv("INTEREST_RATES_FILE")
+	if interestRatesFile == "" {
+		interestRatesFile = "interest.json"
+	}
+	interestRates, err := services.LoadInterestRates(interestRatesFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	interestService := services.NewInterestService(interestRepo, walletRepo, transactionRepo, ledgerRepo, outboxRepo, cache, pubSub, interestRates)
+	statementService := services.NewStatementService(statementRepo, walletRepo, transactionRepo, ledgerRepo)
+	reconciliationService := services.NewReconciliationService(reconciliationRepo, walletRepo, transactionRepo, ledgerRepo, service)
+
+	var transactionLogKey ed25519.PrivateKey
+	if key := os.Getenv("TRANSACTION_LOG_SIGNING_KEY"); key != "" {
+		transactionLogKey, err = services.ParseTransactionLogKey(key)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	transactionLogService := services.NewTransactionLogService(transactionLogRepo, transactionRepo, pubSub, transactionLogKey)
+
+	reconciliationInterval := services.DefaultReconciliationInterval
+	if interval := os.Getenv("RECONCILIATION_INTERVAL"); interval != "" {
+		reconciliationInterval, err = time.ParseDuration(interval)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	reconciliationAutoFreeze := false
+	if autoFreeze := os.Getenv("RECONCILIATION_AUTO_FREEZE"); autoFreeze != "" {
+		reconciliationAutoFreeze, err = strconv.ParseBool(autoFreeze)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	webhookMaxAttempts := services.DefaultWebhookMaxAttempts
+	if attempts := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); attempts != "" {
+		webhookMaxAttempts, err = strconv.Atoi(attempts)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	webhookTimeout := services.DefaultWebhookTimeout
+	if timeout := os.Getenv("WEBHOOK_TIMEOUT"); timeout != "" {
+		webhookTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	streamService := services.NewStreamService(service, transactionRepo, transactionLogRepo, pubSub)
+
+	webhookService := services.NewWebhookService(webhookRepo, outboxRepo, &http.Client{Timeout: webhookTimeout}, webhookMaxAttempts)
+
+	// Purge expired idempotency keys in the background
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, err := idempotencyKeyRepo.DeleteExpired("", "", time.Now()); err != nil {
+				log.Println("failed to purge idempotency keys:", err)
+			}
+		}
+	}()
+
+	// Expire holds whose TTL has passed
+	go func() {
+		for range time.Tick(time.Minute) {
+			if _, apiErr := service.ExpireHolds(); apiErr != nil {
+				log.Println("failed to expire holds:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Execute scheduled transfers as they fall due
+	go func() {
+		for range time.Tick(time.Minute) {
+			if _, apiErr := scheduleService.RunDue(); apiErr != nil {
+				log.Println("failed to run scheduled transfers:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Expire payment requests nobody acted on
+	go func() {
+		for range time.Tick(time.Minute) {
+			if _, apiErr := paymentRequestService.ExpireRequests(); apiErr != nil {
+				log.Println("failed to expire payment requests:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Pay uploaded payout files a few seconds after they are accepted
+	go func() {
+		for range time.Tick(5 * time.Second) {
+			if _, apiErr := payoutService.RunPending(); apiErr != nil {
+				log.Println("failed to run payouts:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Accrue interest for each day that has ended, paying it out at month end
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, apiErr := interestService.AccrueInterest(); apiErr != nil {
+				log.Println("failed to accrue interest:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Checkpoint each day's closing balances once the day has settled
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, apiErr := service.CheckpointBalances(); apiErr != nil {
+				log.Println("failed to checkpoint balances:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Generate every wallet's statement once a month has ended
+	go func() {
+		for range time.Tick(time.Hour) {
+			if _, apiErr := statementService.GenerateMonthlyStatements(); apiErr != nil {
+				log.Println("failed to generate statements:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Check every wallet's balance against its transactions and ledger
+	go func() {
+		for range time.Tick(reconciliationInterval) {
+			run, apiErr := reconciliationService.Reconcile(reconciliationAutoFreeze)
+			if apiErr != nil {
+				log.Println("failed to reconcile balances:", apiErr.Message)
+				continue
+			}
+			if run.Mismatched > 0 {
+				log.Printf("reconciliation %s: %d of %d wallets do not reconcile, %d frozen", run.ID, run.Mismatched, run.WalletsChecked, run.Frozen)
+			}
+		}
+	}()
+
+	// Wake up the streams of users whose balance or transactions changed
+	go func() {
+		if err := streamService.Run(); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	// Append committed versions of transactions to the transaction log
+	go func() {
+		for range time.Tick(time.Second) {
+			if _, apiErr := transactionLogService.AppendPending(); apiErr != nil {
+				log.Println("failed to append to transaction log:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Queue outbox events for their webhook endpoints and send due deliveries
+	go func() {
+		for range time.Tick(5 * time.Second) {
+			if _, apiErr := webhookService.DispatchEvents(); apiErr != nil {
+				log.Println("failed to dispatch events:", apiErr.Message)
+			}
+			if _, apiErr := webhookService.DeliverDue(); apiErr != nil {
+				log.Println("failed to deliver webhooks:", apiErr.Message)
+			}
+		}
+	}()
+
+	// Sign the head of the transaction log, if there is a key to sign with
+	if transactionLogKey == nil {
+		log.Println("TRANSACTION_LOG_SIGNING_KEY is not set, the transaction log will not be checkpointed")
+	} else {
+		go func() {
+			for range time.Tick(time.Hour) {
+				if _, apiErr := transactionLogService.Checkpoint(); apiErr != nil {
+					log.Println("failed to checkpoint transaction log:", apiErr.Message)
+				}
+			}
+		}()
+	}
 
 	userHandler := handlers.NewUserHandler(userRepo, userTokenRepo, walletRepo)
-	walletHandler := handlers.NewWalletHandler(service)
+	walletHandler := handlers.NewWalletHandler(service, userService)
+	fxHandler := handlers.NewFXHandler(fxService)
+	scheduleHandler := handlers.NewScheduleHandler(scheduleService, userService)
+	recipientHandler := handlers.NewRecipientHandler(userService)
+	profileHandler := handlers.NewProfileHandler(userService)
+	paymentRequestHandler := handlers.NewPaymentRequestHandler(paymentRequestService, userService)
+	payoutHandler := handlers.NewPayoutHandler(payoutService)
+	interestHandler := handlers.NewInterestHandler(interestService)
+	statementHandler := handlers.NewStatementHandler(statementService)
+	adminHandler := handlers.NewAdminHandler(service)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	streamHandler := handlers.NewStreamHandler(streamService)
 	authMiddleware := middleware.NewAuthMiddleware(userTokenRepo, userRepo)
 
 	r := gin.Default()
@@ -54,11 +374,70 @@ func main() {
 	protected := r.Group("/api")
 	protected.Use(authMiddleware.AuthMiddleware())
 	{
+		protected.PATCH("/me", profileHandler.UpdateProfile)
+		protected.POST("/me/phone/verify", profileHandler.VerifyPhone)
+		protected.GET("/recipients", recipientHandler.LookupRecipient)
 		protected.POST("/deposit", walletHandler.Deposit)
 		protected.POST("/withdraw", walletHandler.Withdraw)
 		protected.POST("/transfer", walletHandler.Transfer)
+		protected.POST("/batch-transfers", walletHandler.BatchTransfer)
+		protected.GET("/batch-transfers/:id", walletHandler.GetBatch)
 		protected.GET("/balance", walletHandler.GetBalance)
+		protected.GET("/balances", walletHandler.GetBalances)
+		protected.GET("/balance/daily", walletHandler.GetDailyBalances)
+		protected.GET("/stream", streamHandler.Stream)
+		protected.GET("/limits", walletHandler.GetLimits)
+		protected.POST("/fees/preview", walletHandler.PreviewFee)
+		protected.GET("/interest", interestHandler.GetInterest)
+		protected.GET("/statements", statementHandler.ListStatements)
+		protected.GET("/statements/:period", statementHandler.GetStatement)
+		protected.POST("/wallets", walletHandler.OpenWallet)
+		protected.POST("/authorizations", walletHandler.AuthorizeTransfer)
+		protected.POST("/authorizations/:id/capture", walletHandler.CaptureTransfer)
+		protected.POST("/authorizations/:id/void", walletHandler.VoidTransfer)
+		protected.POST("/fx/quotes", fxHandler.CreateQuote)
+		protected.POST("/schedules", scheduleHandler.CreateSchedule)
+		protected.GET("/schedules", scheduleHandler.ListSchedules)
+		protected.POST("/schedules/:id/cancel", scheduleHandler.CancelSchedule)
+		protected.GET("/schedules/:id/executions", scheduleHandler.ListExecutions)
+		protected.POST("/payment-requests", paymentRequestHandler.CreateRequest)
+		protected.GET("/payment-requests/incoming", paymentRequestHandler.ListIncoming)
+		protected.GET("/payment-requests/outgoing", paymentRequestHandler.ListOutgoing)
+		protected.POST("/payment-requests/:id/accept", paymentRequestHandler.AcceptRequest)
+		protected.POST("/payment-requests/:id/decline", paymentRequestHandler.DeclineRequest)
+		protected.POST("/payment-requests/:id/cancel", paymentRequestHandler.CancelRequest)
+		protected.POST("/payouts", payoutHandler.CreateJob)
+		protected.GET("/payouts", payoutHandler.ListJobs)
+		protected.GET("/payouts/:id", payoutHandler.GetJob)
+		protected.GET("/payouts/:id/results", payoutHandler.GetResults)
 		protected.GET("/transactions", walletHandler.GetTransactionHistory)
+		protected.GET("/transactions/export", walletHandler.ExportTransactions)
+		protected.POST("/transactions/:id/reverse", walletHandler.ReverseTransaction)
+		protected.POST("/transactions/:id/refund", walletHandler.RefundTransaction)
+	}
+
+	// Admin routes
+	admin := r.Group("/api/admin")
+	admin.Use(authMiddleware.AuthMiddleware(), authMiddleware.RequireAdmin())
+	{
+		admin.POST("/wallets/:id/status", adminHandler.SetWalletStatus)
+		admin.POST("/wallets/:id/close", adminHandler.CloseWallet)
+		admin.GET("/wallets/:id/events", adminHandler.ListWalletStatusEvents)
+		admin.GET("/users/:id/wallets", adminHandler.ListUserWallets)
+		admin.GET("/users/:id/limits", adminHandler.ListLimitOverrides)
+		admin.PUT("/users/:id/limits/:operation/:currency", adminHandler.SetLimitOverride)
+		admin.DELETE("/users/:id/limits/:operation/:currency", adminHandler.DeleteLimitOverride)
+		admin.POST("/users/:id/holds", adminHandler.PlaceHold)
+		admin.POST("/holds/:id/release", adminHandler.ReleaseHold)
+		admin.POST("/holds/:id/convert", adminHandler.ConvertHold)
+		admin.POST("/reconciliations", reconciliationHandler.Reconcile)
+		admin.GET("/reconciliations", reconciliationHandler.ListRuns)
+		admin.GET("/reconciliations/:id", reconciliationHandler.GetRun)
+		admin.POST("/webhooks", webhookHandler.CreateEndpoint)
+		admin.GET("/webhooks", webhookHandler.ListEndpoints)
+		admin.DELETE("/webhooks/:id", webhookHandler.DisableEndpoint)
+		admin.GET("/webhooks/deliveries", webhookHandler.ListDeliveries)
+		admin.POST("/webhooks/deliveries/:id/redeliver", webhookHandler.Redeliver)
 	}
 
 	port := os.Getenv("PORT")
@@ -0,0 +1,186 @@
+// Command payouts uploads a payout CSV file to the wallet API, waits for the
+// job to finish while reporting its progress, and saves the result file.
+//
+// Usage:
+//
+//	payouts -currency USD -out results.csv payouts.csv
+//
+// The API address and bearer token are read from WALLET_API_URL and
+// WALLET_TOKEN, or given with -url and -token.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+)
+
+func main() {
+	apiURL := flag.String("url", envOr("WALLET_API_URL", "http://localhost:8888"), "base URL of the wallet API")
+	token := flag.String("token", os.Getenv("WALLET_TOKEN"), "bearer token from /api/login")
+	currency := flag.String("currency", "USD", "currency to pay in")
+	out := flag.String("out", "", "where to save the result file (default stdout)")
+	interval := flag.Duration("interval", 2*time.Second, "how often to poll the job's progress")
+	flag.Parse()
+
+	if flag.NArg() != 1 || *token == "" {
+		fmt.Fprintln(os.Stderr, "usage: payouts [-url URL] [-token TOKEN] [-currency USD] [-out results.csv] payouts.csv")
+		os.Exit(2)
+	}
+
+	client := &apiClient{baseURL: *apiURL, token: *token}
+
+	job, err := client.upload(flag.Arg(0), *currency)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("job %s accepted: %d rows, %s %s", job.ID, job.TotalRows, job.TotalAmount, job.Currency)
+
+	for job.Status != models.PayoutJobStatusCompleted && job.Status != models.PayoutJobStatusFailed {
+		time.Sleep(*interval)
+		if err := client.get("/api/payouts/"+job.ID, &struct {
+			Job *models.PayoutJob `json:"job"`
+		}{job}); err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%d/%d rows processed, %d failed", job.ProcessedRows, job.TotalRows, job.FailedRows)
+	}
+
+	results := os.Stdout
+	if *out != "" {
+		if results, err = os.Create(*out); err != nil {
+			log.Fatal(err)
+		}
+		defer results.Close()
+	}
+	if err := client.download("/api/payouts/"+job.ID+"/results", results); err != nil {
+		log.Fatal(err)
+	}
+	if job.FailedRows > 0 {
+		log.Printf("%d of %d rows failed, see the result file", job.FailedRows, job.TotalRows)
+	}
+	if job.Status == models.PayoutJobStatusFailed {
+		log.Fatalf("job failed, %d rows were not paid: %s", job.TotalRows-job.ProcessedRows, job.Error)
+	}
+}
+
+type apiClient struct {
+	baseURL string
+	token   string
+}
+
+// upload sends a payout file and returns the job it created. A rejected file
+// is reported line by line.
+func (c *apiClient) upload(path, currency string) (*models.PayoutJob, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return nil, err
+	}
+	if err := form.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.baseURL+"/api/payouts?currency="+url.QueryEscape(currency), &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		var rejected struct {
+			Error string                    `json:"error"`
+			Rows  []services.PayoutRowError `json:"rows"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&rejected); err != nil {
+			return nil, fmt.Errorf("upload failed: %s", resp.Status)
+		}
+		for _, row := range rejected.Rows {
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", row.Line, row.Error)
+		}
+		return nil, fmt.Errorf("upload failed: %s", rejected.Error)
+	}
+
+	var accepted struct {
+		Job *models.PayoutJob `json:"job"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		return nil, err
+	}
+	return accepted.Job, nil
+}
+
+func (c *apiClient) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *apiClient) download(path string, w io.Writer) error {
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", path, resp.Status)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (c *apiClient) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	return http.DefaultClient.Do(req)
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
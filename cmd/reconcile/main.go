@@ -0,0 +1,88 @@
+// Command reconcile recomputes every wallet's balance from its transactions
+// and ledger account, reports the wallets that do not reconcile, and records
+// the run for the admin API. It connects to the database configured in .env,
+// which must already have been migrated by the API.
+//
+// Usage:
+//
+//	reconcile [-freeze]
+//
+// With -freeze, drifted wallets are frozen for debits. The exit status is 1
+// when any wallet does not reconcile.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"wallet/internal/cache"
+	"wallet/internal/database"
+	"wallet/internal/pubsub"
+	"wallet/internal/repositories"
+	"wallet/internal/services"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	freeze := flag.Bool("freeze", false, "freeze drifted wallets for debits")
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: reconcile [-freeze]")
+		os.Exit(2)
+	}
+
+	db, err := database.NewDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db = db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Warn)})
+
+	walletRepo := repositories.NewWalletRepository(db)
+	transactionRepo := repositories.NewTransactionRepository(db)
+	ledgerRepo := repositories.NewLedgerRepository(db)
+
+	// Only SetWalletStatus is used, which needs none of the limits or fees
+	walletService := services.NewWalletService(
+		walletRepo, transactionRepo, ledgerRepo,
+		repositories.NewIdempotencyKeyRepository(db),
+		repositories.NewFXQuoteRepository(db),
+		repositories.NewHoldRepository(db),
+		repositories.NewLimitRepository(db),
+		repositories.NewUserRepository(db),
+		repositories.NewOutboxRepository(db),
+		cache.NewInMemoryCache(),
+		pubsub.NewPostgresPubSub(db, database.DSN()),
+		services.DefaultIdempotencyKeyTTL, services.DefaultHoldTTL, nil, nil,
+	)
+	reconciliationService := services.NewReconciliationService(repositories.NewReconciliationRepository(db), walletRepo, transactionRepo, ledgerRepo, walletService)
+
+	run, apiErr := reconciliationService.Reconcile(*freeze)
+	if apiErr != nil {
+		if run != nil {
+			log.Printf("run %s failed after %d wallets", run.ID, run.WalletsChecked)
+		}
+		log.Fatal(apiErr.Message)
+	}
+	log.Printf("run %s: %d wallets checked, %d do not reconcile, %d frozen", run.ID, run.WalletsChecked, run.Mismatched, run.Frozen)
+	if run.Mismatched == 0 {
+		return
+	}
+
+	details, apiErr := reconciliationService.GetRun(run.ID)
+	if apiErr != nil {
+		log.Fatal(apiErr.Message)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "wallet\tuser\tcurrency\tbalance\ttransactions\tledger\tdifference\tfrozen\t")
+	for _, m := range details.Mismatches {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\t\n", m.WalletID, m.UserID, m.Currency, m.Balance, m.TransactionBalance, m.LedgerBalance, m.Difference, m.Frozen)
+	}
+	w.Flush()
+	os.Exit(1)
+}
@@ -0,0 +1,79 @@
+// Command verifylog walks the tamper-evident transaction log from its first
+// entry, checks every link, signed checkpoint and transaction against it, and
+// reports the first link that is broken. It connects to the database
+// configured in .env.
+//
+// Usage:
+//
+//	verifylog [-public-key KEY]
+//
+// Checkpoint signatures are checked with the base64 Ed25519 public key given,
+// or with the key derived from TRANSACTION_LOG_SIGNING_KEY. The exit status
+// is 1 when the log is broken.
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"wallet/internal/database"
+	"wallet/internal/repositories"
+	"wallet/internal/services"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func main() {
+	publicKeyFlag := flag.String("public-key", "", "base64 Ed25519 public key that signed the checkpoints")
+	flag.Parse()
+
+	if flag.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "usage: verifylog [-public-key KEY]")
+		os.Exit(2)
+	}
+
+	db, err := database.NewDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db = db.Session(&gorm.Session{Logger: db.Logger.LogMode(logger.Warn)})
+
+	var publicKey ed25519.PublicKey
+	if *publicKeyFlag != "" {
+		key, err := base64.StdEncoding.DecodeString(*publicKeyFlag)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			log.Fatal("invalid public key")
+		}
+		publicKey = key
+	} else if key := os.Getenv("TRANSACTION_LOG_SIGNING_KEY"); key != "" {
+		privateKey, err := services.ParseTransactionLogKey(key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		publicKey = privateKey.Public().(ed25519.PublicKey)
+	} else {
+		log.Println("no public key, checkpoint signatures will not be checked")
+	}
+
+	service := services.NewTransactionLogService(repositories.NewTransactionLogRepository(db), repositories.NewTransactionRepository(db), nil, nil)
+	result, apiErr := service.Verify(publicKey)
+	if apiErr != nil {
+		log.Fatal(apiErr.Message)
+	}
+
+	log.Printf("%d entries, %d transactions and %d checkpoints checked", result.Entries, result.Transactions, result.Checkpoints)
+	if result.Broken != nil {
+		fmt.Printf("broken at entry %d", result.Broken.Sequence)
+		if result.Broken.TransactionID != "" {
+			fmt.Printf(" (transaction %s)", result.Broken.TransactionID)
+		}
+		fmt.Printf(": %s\n", result.Broken.Reason)
+		os.Exit(1)
+	}
+	fmt.Printf("log is intact up to entry %d, head %s\n", result.HeadSequence, result.HeadHash)
+}
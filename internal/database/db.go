@@ -16,7 +16,7 @@ func NewDB() (*gorm.DB, error) {
 		return nil, err
 	}
 
-	dsn := "postgresql://" + os.Getenv("DB_USER") + ":" + os.Getenv("DB_PASSWORD") + "@" + os.Getenv("DB_HOST") + ":" + os.Getenv("DB_PORT") + "/" + os.Getenv("DB_NAME") + "?sslmode=" + os.Getenv("DB_SSLMODE")
+	dsn := DSN()
 	newLogger := logger.New(
 		log.New(os.Stdout, "", log.LstdFlags),
 		logger.Config{
@@ -36,3 +36,9 @@ func NewDB() (*gorm.DB, error) {
 
 	return db, nil
 }
+
+// DSN returns the connection string of the database configured in the
+// environment.
+func DSN() string {
+	return "postgresql://" + os.Getenv("DB_USER") + ":" + os.Getenv("DB_PASSWORD") + "@" + os.Getenv("DB_HOST") + ":" + os.Getenv("DB_PORT") + "/" + os.Getenv("DB_NAME") + "?sslmode=" + os.Getenv("DB_SSLMODE")
+}
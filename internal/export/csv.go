@@ -0,0 +1,37 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := &csvWriter{w: csv.NewWriter(w)}
+	header := []string{"date", "transaction_id", "type", "status", "amount", "currency", "counterparty"}
+	if err := writer.w.Write(header); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (c *csvWriter) Write(entry Entry) error {
+	return c.w.Write([]string{
+		entry.Date.UTC().Format(time.RFC3339),
+		entry.TransactionID,
+		entry.Type,
+		entry.Status,
+		entry.Amount.String(),
+		entry.Currency,
+		entry.Counterparty,
+	})
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,75 @@
+// Package export writes a user's transaction history in formats that
+// spreadsheets and personal-finance apps can import. Writers take one entry
+// at a time, so a history of any length can be streamed.
+package export
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"wallet/internal/money"
+)
+
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+	FormatQIF = "qif"
+)
+
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// Entry is one movement of money seen from the account holder's side: Amount
+// is positive for money received and negative for money paid.
+type Entry struct {
+	Date          time.Time
+	TransactionID string
+	Type          string
+	Status        string
+	Amount        money.Amount
+	Currency      string
+	Counterparty  string
+}
+
+// Account describes the account an export covers. OFX needs all of it: the
+// statement period and the ledger balance at its end. CSV and QIF ignore it.
+type Account struct {
+	ID       string
+	Currency string
+	Start    time.Time
+	End      time.Time
+	Balance  money.Amount
+}
+
+// Writer writes entries to an export file. Close finishes the file and must
+// be called once all entries have been written.
+type Writer interface {
+	Write(entry Entry) error
+	Close() error
+}
+
+// NewWriter starts an export file in the given format on w.
+func NewWriter(format string, w io.Writer, account Account) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w)
+	case FormatOFX:
+		return newOFXWriter(w, account)
+	case FormatQIF:
+		return newQIFWriter(w)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// ContentType returns the MIME type of an export format.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv"
+	case FormatOFX:
+		return "application/x-ofx"
+	case FormatQIF:
+		return "application/qif"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,86 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testEntries = []Entry{
+	{
+		Date:          time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC),
+		TransactionID: "tx1",
+		Type:          "deposit",
+		Status:        "success",
+		Amount:        money.FromMajor(100),
+		Currency:      "USD",
+	},
+	{
+		Date:          time.Date(2025, time.March, 4, 12, 30, 0, 0, time.UTC),
+		TransactionID: "tx2",
+		Type:          "transfer",
+		Status:        "success",
+		Amount:        -money.MustParse("12.50"),
+		Currency:      "USD",
+		Counterparty:  "Smith & Sons",
+	},
+}
+
+func writeAll(t *testing.T, format string, account Account) string {
+	var buf bytes.Buffer
+	writer, err := NewWriter(format, &buf, account)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, entry := range testEntries {
+		assert.NoError(t, writer.Write(entry))
+	}
+	assert.NoError(t, writer.Close())
+	return buf.String()
+}
+
+func TestCSV(t *testing.T) {
+	got := writeAll(t, FormatCSV, Account{})
+
+	assert.Equal(t, "date,transaction_id,type,status,amount,currency,counterparty\n"+
+		"2025-03-03T10:00:00Z,tx1,deposit,success,100.00,USD,\n"+
+		"2025-03-04T12:30:00Z,tx2,transfer,success,-12.50,USD,Smith & Sons\n", got)
+}
+
+func TestQIF(t *testing.T) {
+	got := writeAll(t, FormatQIF, Account{})
+
+	assert.Equal(t, "!Type:Bank\n"+
+		"D03/03/2025\nT100.00\nNtx1\nPdeposit\nMdeposit\n^\n"+
+		"D03/04/2025\nT-12.50\nNtx2\nPSmith & Sons\nMtransfer\n^\n", got)
+}
+
+func TestOFX(t *testing.T) {
+	got := writeAll(t, FormatOFX, Account{
+		ID:       "wallet1",
+		Currency: "USD",
+		Start:    time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC),
+		End:      time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC),
+		Balance:  money.MustParse("87.50"),
+	})
+
+	assert.True(t, strings.HasPrefix(got, `<?xml version="1.0"`))
+	assert.Contains(t, got, "<CURDEF>USD</CURDEF>")
+	assert.Contains(t, got, "<ACCTID>wallet1</ACCTID>")
+	assert.Contains(t, got, "<DTSTART>20250301000000[0:UTC]</DTSTART><DTEND>20250401000000[0:UTC]</DTEND>")
+	assert.Contains(t, got, "<STMTTRN><TRNTYPE>DEP</TRNTYPE><DTPOSTED>20250303100000[0:UTC]</DTPOSTED><TRNAMT>100.00</TRNAMT><FITID>tx1</FITID>")
+	assert.Contains(t, got, "<TRNTYPE>XFER</TRNTYPE><DTPOSTED>20250304123000[0:UTC]</DTPOSTED><TRNAMT>-12.50</TRNAMT><FITID>tx2</FITID><NAME>Smith &amp; Sons</NAME>")
+	assert.Contains(t, got, "<LEDGERBAL><BALAMT>87.50</BALAMT>")
+	assert.True(t, strings.HasSuffix(got, "</OFX>\n"))
+}
+
+func TestNewWriter_UnsupportedFormat(t *testing.T) {
+	_, err := NewWriter("xlsx", &bytes.Buffer{}, Account{})
+
+	assert.ErrorIs(t, err, ErrUnsupportedFormat)
+}
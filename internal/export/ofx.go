@@ -0,0 +1,102 @@
+package export
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ofxTimeLayout is OFX's date-time format, written in UTC.
+const ofxTimeLayout = "20060102150405"
+
+// ofxNameLength is the longest payee name OFX allows.
+const ofxNameLength = 32
+
+// ofxWriter writes an OFX 2.2 bank statement. The statement's period and
+// currency come first and its closing balance last, so entries can be
+// written as they are read.
+type ofxWriter struct {
+	w       *bufio.Writer
+	account Account
+}
+
+func newOFXWriter(w io.Writer, account Account) (*ofxWriter, error) {
+	writer := &ofxWriter{w: bufio.NewWriter(w), account: account}
+	now := ofxTime(time.Now())
+	_, err := fmt.Fprintf(writer.w, `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>
+<OFX>
+<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>
+<BANKMSGSRSV1><STMTTRNRS><TRNUID>0</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>
+<STMTRS><CURDEF>%s</CURDEF>
+<BANKACCTFROM><BANKID>wallet</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>
+<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>
+`, now, ofxText(account.Currency), ofxText(account.ID), ofxTime(account.Start), ofxTime(account.End))
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (o *ofxWriter) Write(entry Entry) error {
+	name := entry.Counterparty
+	if name == "" {
+		name = entry.Type
+	}
+	if len(name) > ofxNameLength {
+		name = name[:ofxNameLength]
+	}
+	_, err := fmt.Fprintf(o.w, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%s</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+		ofxTransactionType(entry),
+		ofxTime(entry.Date),
+		entry.Amount,
+		ofxText(entry.TransactionID),
+		ofxText(name),
+		ofxText(entry.Type),
+	)
+	return err
+}
+
+func (o *ofxWriter) Close() error {
+	_, err := fmt.Fprintf(o.w, `</BANKTRANLIST>
+<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>
+</STMTRS></STMTTRNRS></BANKMSGSRSV1>
+</OFX>
+`, o.account.Balance, ofxTime(o.account.End))
+	if err != nil {
+		return err
+	}
+	return o.w.Flush()
+}
+
+// ofxTransactionType maps a transaction type to the closest OFX type, falling
+// back to a plain credit or debit.
+func ofxTransactionType(entry Entry) string {
+	switch entry.Type {
+	case "deposit":
+		return "DEP"
+	case "transfer":
+		return "XFER"
+	case "fee":
+		return "FEE"
+	case "interest":
+		return "INT"
+	}
+	if entry.Amount < 0 {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+func ofxTime(t time.Time) string {
+	return t.UTC().Format(ofxTimeLayout) + "[0:UTC]"
+}
+
+func ofxText(value string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(value))
+	return escaped.String()
+}
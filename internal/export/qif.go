@@ -0,0 +1,51 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// qifWriter writes a Quicken Interchange Format bank account file. QIF has no
+// currency or transaction ID fields, so the ID goes in the number field and
+// the type in the memo.
+type qifWriter struct {
+	w *bufio.Writer
+}
+
+func newQIFWriter(w io.Writer) (*qifWriter, error) {
+	writer := &qifWriter{w: bufio.NewWriter(w)}
+	if _, err := writer.w.WriteString("!Type:Bank\n"); err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (q *qifWriter) Write(entry Entry) error {
+	payee := entry.Counterparty
+	if payee == "" {
+		payee = entry.Type
+	}
+	_, err := fmt.Fprintf(q.w, "D%s\nT%s\nN%s\nP%s\nM%s\n^\n",
+		entry.Date.UTC().Format("01/02/2006"),
+		entry.Amount,
+		qifField(entry.TransactionID),
+		qifField(payee),
+		qifField(entry.Type),
+	)
+	return err
+}
+
+func (q *qifWriter) Close() error {
+	return q.w.Flush()
+}
+
+// qifField keeps a value on one line, since QIF fields are line based.
+func qifField(value string) string {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\n' || value[i] == '\r' {
+			return value[:i]
+		}
+	}
+	return value
+}
@@ -0,0 +1,14 @@
+package fx
+
+import (
+	"errors"
+	"math/big"
+)
+
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// RateProvider returns mid-market exchange rates. Rate(from, to) is the
+// number of units of to that one unit of from buys.
+type RateProvider interface {
+	Rate(from, to string) (*big.Rat, error)
+}
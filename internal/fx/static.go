@@ -0,0 +1,63 @@
+package fx
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// StaticRateProvider serves fixed rates loaded from a JSON file, for local
+// development and tests. The file lists how many units of each currency one
+// unit of the base currency buys:
+//
+//	{"base": "USD", "rates": {"EUR": "0.92", "JPY": "157.3"}}
+//
+// Rates between two non-base currencies are crossed through the base.
+type StaticRateProvider struct {
+	base  string
+	rates map[string]*big.Rat
+}
+
+type staticRatesFile struct {
+	Base  string            `json:"base"`
+	Rates map[string]string `json:"rates"`
+}
+
+func NewStaticRateProvider(path string) (*StaticRateProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file staticRatesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("fx: invalid rates file %s: %w", path, err)
+	}
+
+	provider := &StaticRateProvider{
+		base:  strings.ToUpper(file.Base),
+		rates: map[string]*big.Rat{strings.ToUpper(file.Base): big.NewRat(1, 1)},
+	}
+	for currency, value := range file.Rates {
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("fx: invalid rate %q for %s", value, currency)
+		}
+		provider.rates[strings.ToUpper(currency)] = rate
+	}
+	return provider, nil
+}
+
+func (p *StaticRateProvider) Rate(from, to string) (*big.Rat, error) {
+	fromRate, ok := p.rates[strings.ToUpper(from)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRateUnavailable, from)
+	}
+	toRate, ok := p.rates[strings.ToUpper(to)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRateUnavailable, to)
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler serves the support endpoints for managing wallets. Its routes
+// are only reachable by admin users.
+type AdminHandler struct {
+	WalletService services.WalletService
+}
+
+type WalletStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason" binding:"required"`
+}
+
+type CloseWalletRequest struct {
+	Reason        string `json:"reason" binding:"required"`
+	SweepToUserID string `json:"sweep_to_user_id"`
+}
+
+type WalletsResponse struct {
+	Wallets []models.Wallet `json:"wallets"`
+}
+
+type WalletStatusEventsResponse struct {
+	Events []models.WalletStatusEvent `json:"events"`
+}
+
+type LimitOverrideResponse struct {
+	Override *models.LimitOverride `json:"override"`
+}
+
+type LimitOverridesResponse struct {
+	Overrides []models.LimitOverride `json:"overrides"`
+}
+
+// PlaceHoldRequest reserves Amount of a user's wallet in Currency. A hold
+// without TTLSeconds lasts until it is released or converted.
+type PlaceHoldRequest struct {
+	Amount     money.Amount `json:"amount"`
+	Currency   string       `json:"currency"`
+	Reason     string       `json:"reason" binding:"required"`
+	TTLSeconds int64        `json:"ttl_seconds" binding:"min=0"`
+}
+
+// ConvertHoldRequest books a hold as a transfer to ToUserID, or as a
+// withdrawal when ToUserID is empty.
+type ConvertHoldRequest struct {
+	ToUserID string `json:"to_user_id"`
+}
+
+type HoldResponse struct {
+	Hold *models.Hold `json:"hold"`
+}
+
+type HoldTransactionResponse struct {
+	Transaction *models.Transaction `json:"transaction"`
+}
+
+func NewAdminHandler(walletService services.WalletService) *AdminHandler {
+	return &AdminHandler{
+		WalletService: walletService,
+	}
+}
+
+// SetWalletStatus freezes or unfreezes a wallet.
+func (h *AdminHandler) SetWalletStatus(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req WalletStatusRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wallet, err := h.WalletService.SetWalletStatus(user.ID, c.Param("id"), req.Status, req.Reason)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WalletResponse{Wallet: wallet})
+}
+
+// CloseWallet closes a wallet, sweeping any remaining balance to the wallet
+// of sweep_to_user_id.
+func (h *AdminHandler) CloseWallet(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req CloseWalletRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	wallet, err := h.WalletService.CloseWallet(user.ID, c.Param("id"), req.SweepToUserID, req.Reason)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WalletResponse{Wallet: wallet})
+}
+
+func (h *AdminHandler) ListWalletStatusEvents(c *gin.Context) {
+	events, err := h.WalletService.ListWalletStatusEvents(c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WalletStatusEventsResponse{Events: events})
+}
+
+// ListUserWallets lists a user's wallets, including frozen and closed ones.
+func (h *AdminHandler) ListUserWallets(c *gin.Context) {
+	wallets, err := h.WalletService.ListWallets(c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WalletsResponse{Wallets: wallets})
+}
+
+// ListLimitOverrides lists the limits a user has in place of the defaults.
+func (h *AdminHandler) ListLimitOverrides(c *gin.Context) {
+	overrides, err := h.WalletService.ListLimitOverrides(c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LimitOverridesResponse{Overrides: overrides})
+}
+
+// SetLimitOverride replaces a user's limits for an operation and currency
+// with the limits in the body. Caps left out are lifted.
+func (h *AdminHandler) SetLimitOverride(c *gin.Context) {
+	var limits models.Limits
+
+	if err := c.ShouldBindJSON(&limits); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	override, err := h.WalletService.SetLimitOverride(c.Param("id"), c.Param("operation"), c.Param("currency"), limits)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LimitOverrideResponse{Override: override})
+}
+
+// DeleteLimitOverride puts a user back on the default limits for an
+// operation and currency.
+func (h *AdminHandler) DeleteLimitOverride(c *gin.Context) {
+	if err := h.WalletService.DeleteLimitOverride(c.Param("id"), c.Param("operation"), c.Param("currency")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// PlaceHold reserves funds of a user's wallet for an internal process, such
+// as a dispute or a pending payout.
+func (h *AdminHandler) PlaceHold(c *gin.Context) {
+	var req PlaceHoldRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	hold, err := h.WalletService.PlaceHold(c.Param("id"), req.Currency, req.Amount, req.Reason, ttl)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, HoldResponse{Hold: hold})
+}
+
+// ReleaseHold makes the funds of a hold available again.
+func (h *AdminHandler) ReleaseHold(c *gin.Context) {
+	if err := h.WalletService.ReleaseHold(c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ConvertHold books the held amount as a withdrawal, or as a transfer to
+// to_user_id.
+func (h *AdminHandler) ConvertHold(c *gin.Context) {
+	var req ConvertHoldRequest
+
+	// The body is optional; an empty one converts into a withdrawal
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	transaction, err := h.WalletService.ConvertHold(c.Param("id"), req.ToUserID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, HoldTransactionResponse{Transaction: transaction})
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuthorizeRequest reserves Amount in Currency for a later capture by the
+// recipient.
+type AuthorizeRequest struct {
+	RecipientFields
+	Amount   money.Amount `json:"amount"`
+	Currency string       `json:"currency"`
+}
+
+// CaptureRequest captures Amount of an authorization, or all of it when
+// Amount is omitted.
+type CaptureRequest struct {
+	Amount money.Amount `json:"amount"`
+}
+
+type AuthorizationResponse struct {
+	Authorization *models.Hold `json:"authorization"`
+}
+
+func (h *WalletHandler) AuthorizeTransfer(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req AuthorizeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	toUserID, err := resolveRecipient(h.UserService, req.RecipientFields)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	hold, err := h.WalletService.AuthorizeTransfer(user.ID, toUserID, req.Amount, req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, AuthorizationResponse{
+		Authorization: hold,
+	})
+}
+
+func (h *WalletHandler) CaptureTransfer(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	transactionID := c.Param("id")
+	var req CaptureRequest
+
+	// The body is optional; an empty one captures the full amount
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	idem, idemErr := idempotencyFromRequest(c, gin.H{"transaction_id": transactionID, "amount": req.Amount})
+	if idemErr != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": idemErr.Error()})
+		return
+	}
+
+	balance, err := h.WalletService.CaptureTransfer(user.ID, transactionID, req.Amount, idem)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TransactionResponse{
+		Balance: balance,
+	})
+}
+
+func (h *WalletHandler) VoidTransfer(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	if err := h.WalletService.VoidTransfer(user.ID, c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchTransferRequest pays every recipient in Transfers from the sender's
+// Currency wallet, all or nothing.
+type BatchTransferRequest struct {
+	Currency  string              `json:"currency"`
+	Transfers []BatchTransferItem `json:"transfers"`
+}
+
+type BatchTransferItem struct {
+	RecipientFields
+	Amount money.Amount `json:"amount"`
+}
+
+type BatchTransferResponse struct {
+	Balance money.Amount          `json:"balance"`
+	Batch   *models.TransferBatch `json:"batch"`
+}
+
+type BatchResponse struct {
+	Batch *models.TransferBatch `json:"batch"`
+}
+
+func (h *WalletHandler) BatchTransfer(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req BatchTransferRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	idem, idemErr := idempotencyFromRequest(c, req)
+	if idemErr != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": idemErr.Error()})
+		return
+	}
+
+	if err := services.CheckBatchSize(len(req.Transfers)); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	items := make([]services.BatchTransferItem, 0, len(req.Transfers))
+	for _, transfer := range req.Transfers {
+		toUserID, err := resolveRecipient(h.UserService, transfer.RecipientFields)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+		items = append(items, services.BatchTransferItem{
+			ToUserID: toUserID,
+			Amount:   transfer.Amount,
+		})
+	}
+
+	balance, batch, err := h.WalletService.BatchTransfer(user.ID, req.Currency, items, idem)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, BatchTransferResponse{
+		Balance: balance,
+		Batch:   batch,
+	})
+}
+
+func (h *WalletHandler) GetBatch(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	batch, err := h.WalletService.GetBatch(user.ID, c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, BatchResponse{
+		Batch: batch,
+	})
+}
@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// abortWithError responds with a service error. Errors a client can act on
+// also carry a machine-readable code and their details, e.g.
+// {"error": "...", "code": "limit_exceeded", "details": {"limit": "daily_amount", ...}}.
+func abortWithError(c *gin.Context, err *services.APIError) {
+	body := gin.H{"error": err.Message}
+	if err.Reason != "" {
+		body["code"] = err.Reason
+	}
+	if err.Details != nil {
+		body["details"] = err.Details
+	}
+	c.AbortWithStatusJSON(err.Code, body)
+}
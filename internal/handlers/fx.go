@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FXHandler struct {
+	FXService services.FXService
+}
+
+type QuoteRequest struct {
+	FromCurrency string       `json:"from_currency"`
+	ToCurrency   string       `json:"to_currency"`
+	Amount       money.Amount `json:"amount"`
+}
+
+type QuoteResponse struct {
+	Quote *models.FXQuote `json:"quote"`
+}
+
+func NewFXHandler(fxService services.FXService) *FXHandler {
+	return &FXHandler{
+		FXService: fxService,
+	}
+}
+
+func (h *FXHandler) CreateQuote(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req QuoteRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate required fields
+	if req.FromCurrency == "" || req.ToCurrency == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "from_currency and to_currency are required fields"})
+		return
+	}
+
+	quote, err := h.FXService.CreateQuote(user.ID, req.FromCurrency, req.ToCurrency, req.Amount)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, QuoteResponse{Quote: quote})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	maxIdempotencyKeyLen = 255
+)
+
+// idempotencyFromRequest reads the Idempotency-Key header and fingerprints the
+// decoded request, so that a key reused with a different payload (or on a
+// different endpoint) can be told apart from a genuine retry. Fingerprinting
+// the decoded request rather than the raw body means whitespace or key order
+// changes in a retried body are still treated as the same request.
+func idempotencyFromRequest(c *gin.Context, req interface{}) (services.Idempotency, error) {
+	key := c.GetHeader(IdempotencyKeyHeader)
+	if key == "" {
+		return services.Idempotency{}, nil
+	}
+	if len(key) > maxIdempotencyKeyLen {
+		return services.Idempotency{}, errors.New("Idempotency-Key must be at most 255 characters")
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return services.Idempotency{}, err
+	}
+
+	hash := sha256.New()
+	hash.Write([]byte(c.Request.Method + " " + c.FullPath() + "\n"))
+	hash.Write(payload)
+
+	return services.Idempotency{
+		Key:         key,
+		Fingerprint: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
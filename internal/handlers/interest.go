@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type InterestHandler struct {
+	InterestService services.InterestService
+}
+
+type InterestResponse struct {
+	Interest []models.InterestSummary `json:"interest"`
+}
+
+func NewInterestHandler(interestService services.InterestService) *InterestHandler {
+	return &InterestHandler{
+		InterestService: interestService,
+	}
+}
+
+// GetInterest shows the rate each of the user's wallets earns and the interest
+// accrued since the last payout.
+func (h *InterestHandler) GetInterest(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	interest, err := h.InterestService.GetInterest(user.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, InterestResponse{Interest: interest})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentRequestHandler struct {
+	PaymentRequestService services.PaymentRequestService
+	UserService           services.UserService
+}
+
+// CreatePaymentRequestRequest asks the user addressed by the recipient fields
+// to pay Amount in Currency.
+type CreatePaymentRequestRequest struct {
+	RecipientFields
+	Amount   money.Amount `json:"amount"`
+	Currency string       `json:"currency"`
+	Note     string       `json:"note"`
+}
+
+type PaymentRequestResponse struct {
+	PaymentRequest *models.PaymentRequest `json:"payment_request"`
+}
+
+type PaymentRequestsResponse struct {
+	PaymentRequests []models.PaymentRequest `json:"payment_requests"`
+}
+
+func NewPaymentRequestHandler(paymentRequestService services.PaymentRequestService, userService services.UserService) *PaymentRequestHandler {
+	return &PaymentRequestHandler{
+		PaymentRequestService: paymentRequestService,
+		UserService:           userService,
+	}
+}
+
+func (h *PaymentRequestHandler) CreateRequest(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req CreatePaymentRequestRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	payerID, err := resolveRecipient(h.UserService, req.RecipientFields)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	request, err := h.PaymentRequestService.CreateRequest(user.ID, payerID, req.Amount, req.Currency, req.Note)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, PaymentRequestResponse{
+		PaymentRequest: request,
+	})
+}
+
+// ListIncoming is the payer's inbox. It accepts an optional status filter.
+func (h *PaymentRequestHandler) ListIncoming(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	requests, err := h.PaymentRequestService.ListIncoming(user.ID, c.Query("status"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PaymentRequestsResponse{
+		PaymentRequests: requests,
+	})
+}
+
+func (h *PaymentRequestHandler) ListOutgoing(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	requests, err := h.PaymentRequestService.ListOutgoing(user.ID, c.Query("status"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PaymentRequestsResponse{
+		PaymentRequests: requests,
+	})
+}
+
+func (h *PaymentRequestHandler) AcceptRequest(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	request, err := h.PaymentRequestService.AcceptRequest(user.ID, c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PaymentRequestResponse{
+		PaymentRequest: request,
+	})
+}
+
+func (h *PaymentRequestHandler) DeclineRequest(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	if err := h.PaymentRequestService.DeclineRequest(user.ID, c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *PaymentRequestHandler) CancelRequest(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	if err := h.PaymentRequestService.CancelRequest(user.ID, c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPayoutFileSize caps the size of an uploaded payout file.
+const maxPayoutFileSize = 5 << 20
+
+type PayoutHandler struct {
+	PayoutService services.PayoutService
+}
+
+type PayoutJobResponse struct {
+	Job *models.PayoutJob `json:"job"`
+}
+
+type PayoutJobsResponse struct {
+	Jobs []models.PayoutJob `json:"jobs"`
+}
+
+// PayoutErrorsResponse lists every line of a rejected payout file.
+type PayoutErrorsResponse struct {
+	Error string                    `json:"error"`
+	Rows  []services.PayoutRowError `json:"rows"`
+}
+
+func NewPayoutHandler(payoutService services.PayoutService) *PayoutHandler {
+	return &PayoutHandler{
+		PayoutService: payoutService,
+	}
+}
+
+// CreateJob accepts a payout file either as the "file" field of a multipart
+// form or as a text/csv request body, and the currency as a query parameter.
+func (h *PayoutHandler) CreateJob(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPayoutFileSize)
+
+	var file io.Reader = c.Request.Body
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		upload, err := header.Open()
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer upload.Close()
+		file = upload
+	}
+
+	job, rowErrors, err := h.PayoutService.CreateJob(user.ID, c.Query("currency"), file)
+	if err != nil {
+		if len(rowErrors) > 0 {
+			c.AbortWithStatusJSON(err.Code, PayoutErrorsResponse{
+				Error: err.Message,
+				Rows:  rowErrors,
+			})
+			return
+		}
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, PayoutJobResponse{
+		Job: job,
+	})
+}
+
+func (h *PayoutHandler) ListJobs(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	jobs, err := h.PayoutService.ListJobs(user.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PayoutJobsResponse{
+		Jobs: jobs,
+	})
+}
+
+func (h *PayoutHandler) GetJob(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	job, err := h.PayoutService.GetJob(user.ID, c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, PayoutJobResponse{
+		Job: job,
+	})
+}
+
+// GetResults returns a CSV file mapping each line of the uploaded file to its
+// transaction or failure reason. Rows not paid yet are listed as pending.
+func (h *PayoutHandler) GetResults(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	items, err := h.PayoutService.ListItems(user.ID, c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="payout-`+c.Param("id")+`-results.csv"`)
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"line", "recipient", "amount", "reference", "status", "transaction_id", "error"})
+	for _, item := range items {
+		writer.Write([]string{
+			strconv.Itoa(item.Line),
+			item.Recipient,
+			item.Amount.String(),
+			item.Reference,
+			item.Status,
+			item.TransactionID,
+			item.Error,
+		})
+	}
+	writer.Flush()
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateProfileRequest changes the fields that are set; an empty string
+// clears a field.
+type UpdateProfileRequest struct {
+	Handle *string `json:"handle"`
+	Phone  *string `json:"phone"`
+}
+
+// VerifyPhoneRequest confirms a new phone number with the code texted to it.
+type VerifyPhoneRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type ProfileResponse struct {
+	User *models.User `json:"user"`
+}
+
+type ProfileHandler struct {
+	UserService services.UserService
+}
+
+func NewProfileHandler(userService services.UserService) *ProfileHandler {
+	return &ProfileHandler{
+		UserService: userService,
+	}
+}
+
+func (h *ProfileHandler) UpdateProfile(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req UpdateProfileRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.UserService.UpdateProfile(user.ID, req.Handle, req.Phone)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ProfileResponse{
+		User: updated,
+	})
+}
+
+// VerifyPhone sets the user's phone number to the one waiting to be
+// verified, once they enter the code texted to it.
+func (h *ProfileHandler) VerifyPhone(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req VerifyPhoneRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated, err := h.UserService.VerifyPhone(user.ID, req.Code)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ProfileResponse{
+		User: updated,
+	})
+}
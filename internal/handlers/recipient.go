@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RecipientFields addresses the recipient of a transfer. Exactly one of them
+// must be set.
+type RecipientFields struct {
+	ToUserID string `json:"to_user_id"`
+	ToEmail  string `json:"to_email"`
+	ToPhone  string `json:"to_phone"`
+	ToHandle string `json:"to_handle"`
+}
+
+type RecipientLookupRequest struct {
+	Email  string `form:"email"`
+	Phone  string `form:"phone"`
+	Handle string `form:"handle"`
+}
+
+type RecipientLookupResponse struct {
+	DisplayName string `json:"display_name"`
+}
+
+type RecipientHandler struct {
+	UserService services.UserService
+}
+
+func NewRecipientHandler(userService services.UserService) *RecipientHandler {
+	return &RecipientHandler{
+		UserService: userService,
+	}
+}
+
+// LookupRecipient shows the masked name of the user behind an email, phone
+// number or handle, so the sender can check it before paying them.
+func (h *RecipientHandler) LookupRecipient(c *gin.Context) {
+	var req RecipientLookupRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	displayName, err := h.UserService.LookupRecipient(services.Recipient{
+		Email:  req.Email,
+		Phone:  req.Phone,
+		Handle: req.Handle,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, RecipientLookupResponse{
+		DisplayName: displayName,
+	})
+}
+
+// resolveRecipient returns the ID of the user a request is addressed to.
+func resolveRecipient(userService services.UserService, fields RecipientFields) (string, *services.APIError) {
+	user, err := userService.ResolveRecipient(services.Recipient{
+		UserID: fields.ToUserID,
+		Email:  fields.ToEmail,
+		Phone:  fields.ToPhone,
+		Handle: fields.ToHandle,
+	})
+	if err != nil {
+		return "", err
+	}
+	return user.ID, nil
+}
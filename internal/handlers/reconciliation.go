@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReconciliationHandler serves the admin endpoints for balance
+// reconciliation.
+type ReconciliationHandler struct {
+	ReconciliationService services.ReconciliationService
+}
+
+type ReconcileRequest struct {
+	AutoFreeze bool `json:"auto_freeze"`
+}
+
+type ReconciliationRunResponse struct {
+	Run *models.ReconciliationRun `json:"run"`
+}
+
+type ReconciliationRunsResponse struct {
+	Runs []models.ReconciliationRun `json:"runs"`
+}
+
+func NewReconciliationHandler(reconciliationService services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{
+		ReconciliationService: reconciliationService,
+	}
+}
+
+// Reconcile runs a reconciliation of every wallet now and returns its result
+// once it has finished.
+func (h *ReconciliationHandler) Reconcile(c *gin.Context) {
+	var req ReconcileRequest
+
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	run, err := h.ReconciliationService.Reconcile(req.AutoFreeze)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ReconciliationRunResponse{Run: run})
+}
+
+// ListRuns lists the most recent reconciliation runs.
+func (h *ReconciliationHandler) ListRuns(c *gin.Context) {
+	runs, err := h.ReconciliationService.ListRuns()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ReconciliationRunsResponse{Runs: runs})
+}
+
+// GetRun returns a reconciliation run with the details of every wallet that
+// did not reconcile.
+func (h *ReconciliationHandler) GetRun(c *gin.Context) {
+	run, err := h.ReconciliationService.GetRun(c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ReconciliationRunResponse{Run: run})
+}
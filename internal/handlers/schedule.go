@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScheduleHandler struct {
+	ScheduleService services.ScheduleService
+	UserService     services.UserService
+}
+
+type ScheduleRequest struct {
+	RecipientFields
+	Amount              money.Amount `json:"amount"`
+	Currency            string       `json:"currency"`
+	Frequency           string       `json:"frequency"`
+	StartAt             time.Time    `json:"start_at"`
+	EndAt               *time.Time   `json:"end_at"`
+	MaxOccurrences      int          `json:"max_occurrences"`
+	OnInsufficientFunds string       `json:"on_insufficient_funds"`
+}
+
+type ScheduleResponse struct {
+	Schedule *models.Schedule `json:"schedule"`
+}
+
+type SchedulesResponse struct {
+	Schedules []models.Schedule `json:"schedules"`
+}
+
+type ScheduleExecutionsResponse struct {
+	Executions []models.ScheduleExecution `json:"executions"`
+}
+
+func NewScheduleHandler(scheduleService services.ScheduleService, userService services.UserService) *ScheduleHandler {
+	return &ScheduleHandler{
+		ScheduleService: scheduleService,
+		UserService:     userService,
+	}
+}
+
+func (h *ScheduleHandler) CreateSchedule(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req ScheduleRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	toUserID, err := resolveRecipient(h.UserService, req.RecipientFields)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	schedule, err := h.ScheduleService.CreateSchedule(user.ID, services.NewSchedule{
+		ToUserID:            toUserID,
+		Amount:              req.Amount,
+		Currency:            req.Currency,
+		Frequency:           req.Frequency,
+		StartAt:             req.StartAt,
+		EndAt:               req.EndAt,
+		MaxOccurrences:      req.MaxOccurrences,
+		OnInsufficientFunds: req.OnInsufficientFunds,
+	})
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ScheduleResponse{
+		Schedule: schedule,
+	})
+}
+
+func (h *ScheduleHandler) ListSchedules(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	schedules, err := h.ScheduleService.ListSchedules(user.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SchedulesResponse{
+		Schedules: schedules,
+	})
+}
+
+func (h *ScheduleHandler) CancelSchedule(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	if err := h.ScheduleService.CancelSchedule(user.ID, c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *ScheduleHandler) ListExecutions(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	executions, err := h.ScheduleService.ListExecutions(user.ID, c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ScheduleExecutionsResponse{
+		Executions: executions,
+	})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatementHandler struct {
+	StatementService services.StatementService
+}
+
+// StatementRequest picks the wallet of a statement and how it is rendered:
+// "json" (the default) or "html" for a downloadable document.
+type StatementRequest struct {
+	Currency string `form:"currency"`
+	Format   string `form:"format"`
+}
+
+type StatementResponse struct {
+	Statement *models.Statement `json:"statement"`
+}
+
+type StatementsResponse struct {
+	Statements []models.Statement `json:"statements"`
+}
+
+// statementTemplate renders a statement as a standalone HTML document that
+// can be printed or saved as a PDF.
+var statementTemplate = template.Must(template.New("statement").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Currency}} statement {{.Period}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border-bottom: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+td.amount, th.amount { text-align: right; }
+</style>
+</head>
+<body>
+<h1>{{.Currency}} account statement</h1>
+<p>Period: {{.PeriodStart.Format "2 January 2006"}} to {{(.PeriodEnd.AddDate 0 0 -1).Format "2 January 2006"}} (UTC){{if .Provisional}}, provisional{{end}}</p>
+<p>Account holder: {{.UserID}}</p>
+<table>
+<tr><th>Opening balance</th><td class="amount">{{.OpeningBalance}}</td></tr>
+<tr><th>Closing balance</th><td class="amount">{{.ClosingBalance}}</td></tr>
+</table>
+<h2>Transactions</h2>
+<table>
+<tr><th>Date</th><th>Type</th><th>Counterparty</th><th>Transaction</th><th class="amount">Amount</th><th class="amount">Balance</th></tr>
+{{range .Lines}}<tr><td>{{.Date.UTC.Format "2006-01-02 15:04"}}</td><td>{{.Type}}</td><td>{{.Counterparty}}</td><td>{{.TransactionID}}</td><td class="amount">{{.Amount}}</td><td class="amount">{{.Balance}}</td></tr>
+{{else}}<tr><td colspan="6">No transactions in this period</td></tr>
+{{end}}</table>
+<h2>Totals by type</h2>
+<table>
+<tr><th>Type</th><th class="amount">Count</th><th class="amount">Money in</th><th class="amount">Money out</th></tr>
+{{range .Totals}}<tr><td>{{.Type}}</td><td class="amount">{{.Count}}</td><td class="amount">{{.Credits}}</td><td class="amount">{{.Debits}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func NewStatementHandler(statementService services.StatementService) *StatementHandler {
+	return &StatementHandler{
+		StatementService: statementService,
+	}
+}
+
+// GetStatement returns the user's statement for a month (YYYY-MM) as JSON, or
+// as an HTML file to download.
+func (h *StatementHandler) GetStatement(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req StatementRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Format != "" && req.Format != "json" && req.Format != "html" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Unsupported format"})
+		return
+	}
+
+	statement, err := h.StatementService.GetStatement(user.ID, req.Currency, c.Param("period"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	if req.Format != "html" {
+		c.JSON(http.StatusOK, StatementResponse{Statement: statement})
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="statement-`+statement.Currency+"-"+statement.Period+`.html"`)
+	c.Status(http.StatusOK)
+	statementTemplate.Execute(c.Writer, statement)
+}
+
+// ListStatements lists the user's stored statements.
+func (h *StatementHandler) ListStatements(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	statements, err := h.StatementService.ListStatements(user.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, StatementsResponse{Statements: statements})
+}
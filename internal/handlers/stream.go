@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StreamHandler struct {
+	StreamService services.StreamService
+}
+
+func NewStreamHandler(streamService services.StreamService) *StreamHandler {
+	return &StreamHandler{
+		StreamService: streamService,
+	}
+}
+
+// Stream pushes the user's balance changes and transactions as Server-Sent
+// Events. A client resumes with the Last-Event-ID header, which browsers
+// send when they reconnect, or with the last_event_id query parameter. A
+// stream still replaying what the client missed wakes itself after each
+// batch, so every batch is flushed as soon as it is read.
+func (h *StreamHandler) Stream(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	stream, err := h.StreamService.Open(user.ID, lastEventID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+	defer stream.Close()
+
+	events, err := stream.Poll()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	writeStreamEvents(c.Writer, events)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(services.StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stream.Wake():
+		case <-heartbeat.C:
+			io.WriteString(c.Writer, ": heartbeat\n\n")
+		}
+
+		events, err := stream.Poll()
+		if err != nil {
+			// The client reconnects and resumes from its last event
+			writeStreamEvent(c.Writer, models.StreamEvent{Type: "error", Data: gin.H{"error": err.Message}})
+			c.Writer.Flush()
+			return
+		}
+		writeStreamEvents(c.Writer, events)
+		c.Writer.Flush()
+	}
+}
+
+func writeStreamEvents(w io.Writer, events []models.StreamEvent) {
+	for _, event := range events {
+		writeStreamEvent(w, event)
+	}
+}
+
+// writeStreamEvent writes an event in the text/event-stream format, with its
+// data as a single line of JSON.
+func writeStreamEvent(w io.Writer, event models.StreamEvent) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return
+	}
+	if event.ID != "" {
+		fmt.Fprintf(w, "id: %s\n", event.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"wallet/internal/models"
+	"wallet/internal/money"
 	"wallet/internal/repositories"
 
 	"github.com/gin-gonic/gin"
@@ -79,6 +80,7 @@ func (h *UserHandler) Login(c *gin.Context) {
 		wallet := &models.Wallet{
 			ID:        uuid.New().String(),
 			UserID:    user.ID,
+			Currency:  money.DefaultCurrency,
 			Balance:   0,
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
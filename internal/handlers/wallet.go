@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
+	"strings"
+	"time"
 
+	"wallet/internal/export"
 	"wallet/internal/models"
+	"wallet/internal/money"
 	"wallet/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -11,17 +16,57 @@ import (
 
 type WalletHandler struct {
 	WalletService services.WalletService
+	UserService   services.UserService
+}
+
+type OpenWalletRequest struct {
+	Currency string `json:"currency"`
 }
 
 type DepositRequest struct {
-	Amount float64 `json:"amount"`
+	Amount   money.Amount `json:"amount"`
+	Currency string       `json:"currency"`
 }
 type WithdrawRequest struct {
-	Amount float64 `json:"amount"`
+	Amount   money.Amount `json:"amount"`
+	Currency string       `json:"currency"`
 }
+
+// TransferRequest moves Amount in Currency to another user. When QuoteID is
+// set the transfer is converted at the quoted rate instead, and the amount and
+// currencies are taken from the quote.
 type TransferRequest struct {
-	ToUserID string  `json:"to_user_id"`
-	Amount   float64 `json:"amount"`
+	RecipientFields
+	Amount   money.Amount `json:"amount"`
+	Currency string       `json:"currency"`
+	QuoteID  string       `json:"quote_id"`
+}
+
+// RefundRequest returns Amount of a received transfer to its sender.
+type RefundRequest struct {
+	Amount money.Amount `json:"amount"`
+}
+
+// FeePreviewRequest asks what a withdrawal or transfer of Amount would cost.
+type FeePreviewRequest struct {
+	Operation string       `json:"operation"`
+	Amount    money.Amount `json:"amount"`
+	Currency  string       `json:"currency"`
+}
+
+// BalanceRequest asks for a wallet's balance. AsOf, an RFC 3339 time or a
+// date meaning the end of that day (UTC), asks for its balance in the past.
+type BalanceRequest struct {
+	Currency string `form:"currency"`
+	AsOf     string `form:"as_of"`
+}
+
+// DailyBalancesRequest asks for a wallet's closing balance on each day from
+// From through To, both dates.
+type DailyBalancesRequest struct {
+	Currency string `form:"currency"`
+	From     string `form:"from" binding:"required"`
+	To       string `form:"to" binding:"required"`
 }
 
 type TransactionHistoryRequest struct {
@@ -31,21 +76,76 @@ type TransactionHistoryRequest struct {
 	Status   string `form:"status"`
 }
 
+// ExportRequest filters a transaction history export. Format is "csv" (the
+// default), "ofx" or "qif"; From and To are dates and both are included.
+type ExportRequest struct {
+	Format   string `form:"format"`
+	Type     string `form:"type"`
+	Status   string `form:"status"`
+	Currency string `form:"currency"`
+	From     string `form:"from"`
+	To       string `form:"to"`
+}
+
+// BalanceResponse reports a wallet's status and its ledger, held and
+// available balances. Balance repeats the ledger balance for clients that
+// predate holds.
 type BalanceResponse struct {
-	Balance float64 `json:"balance"`
+	Currency         string       `json:"currency"`
+	Status           string       `json:"status"`
+	Balance          money.Amount `json:"balance"`
+	LedgerBalance    money.Amount `json:"ledger_balance"`
+	HeldAmount       money.Amount `json:"held_amount"`
+	AvailableBalance money.Amount `json:"available_balance"`
+}
+
+type BalancesResponse struct {
+	Balances []BalanceResponse `json:"balances"`
+}
+
+type HistoricalBalanceResponse struct {
+	Balance *models.HistoricalBalance `json:"balance"`
+}
+
+type DailyBalancesResponse struct {
+	Balances []models.DailyBalance `json:"balances"`
+}
+
+type WalletResponse struct {
+	Wallet *models.Wallet `json:"wallet"`
 }
 
 type TransactionResponse struct {
-	Balance float64 `json:"balance"`
+	Balance money.Amount `json:"balance"`
+}
+
+type LimitsResponse struct {
+	Limits []models.LimitStatus `json:"limits"`
+}
+
+type FeePreviewResponse struct {
+	Preview *models.FeePreview `json:"preview"`
 }
 
 type TransactionHistoryResponse struct {
 	Transactions []models.Transaction `json:"transactions"`
 }
 
-func NewWalletHandler(walletService services.WalletService) *WalletHandler {
+func newBalanceResponse(balance models.Balance) BalanceResponse {
+	return BalanceResponse{
+		Currency:         balance.Currency,
+		Status:           balance.Status,
+		Balance:          balance.LedgerBalance,
+		LedgerBalance:    balance.LedgerBalance,
+		HeldAmount:       balance.Held,
+		AvailableBalance: balance.Available,
+	}
+}
+
+func NewWalletHandler(walletService services.WalletService, userService services.UserService) *WalletHandler {
 	return &WalletHandler{
 		WalletService: walletService,
+		UserService:   userService,
 	}
 }
 
@@ -58,9 +158,15 @@ func (h *WalletHandler) Deposit(c *gin.Context) {
 		return
 	}
 
-	balance, err := h.WalletService.Deposit(user.ID, req.Amount)
+	idem, idemErr := idempotencyFromRequest(c, req)
+	if idemErr != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": idemErr.Error()})
+		return
+	}
+
+	balance, err := h.WalletService.Deposit(user.ID, req.Amount, req.Currency, idem)
 	if err != nil {
-		c.AbortWithStatusJSON(err.Code, gin.H{"error": err.Message})
+		abortWithError(c, err)
 		return
 	}
 
@@ -78,9 +184,15 @@ func (h *WalletHandler) Withdraw(c *gin.Context) {
 		return
 	}
 
-	balance, err := h.WalletService.Withdraw(user.ID, req.Amount)
+	idem, idemErr := idempotencyFromRequest(c, req)
+	if idemErr != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": idemErr.Error()})
+		return
+	}
+
+	balance, err := h.WalletService.Withdraw(user.ID, req.Amount, req.Currency, idem)
 	if err != nil {
-		c.AbortWithStatusJSON(err.Code, gin.H{"error": err.Message})
+		abortWithError(c, err)
 		return
 	}
 
@@ -98,9 +210,30 @@ func (h *WalletHandler) Transfer(c *gin.Context) {
 		return
 	}
 
-	balance, err := h.WalletService.Transfer(user.ID, req.ToUserID, req.Amount)
+	idem, idemErr := idempotencyFromRequest(c, req)
+	if idemErr != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": idemErr.Error()})
+		return
+	}
+
+	toUserID, err := resolveRecipient(h.UserService, req.RecipientFields)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	var balance money.Amount
+	if req.QuoteID != "" {
+		if req.Amount != 0 || req.Currency != "" {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "amount and currency are taken from the quote and must not be set"})
+			return
+		}
+		balance, err = h.WalletService.TransferWithQuote(user.ID, toUserID, req.QuoteID, idem)
+	} else {
+		balance, err = h.WalletService.Transfer(user.ID, toUserID, req.Amount, req.Currency, idem)
+	}
 	if err != nil {
-		c.AbortWithStatusJSON(err.Code, gin.H{"error": err.Message})
+		abortWithError(c, err)
 		return
 	}
 
@@ -109,11 +242,46 @@ func (h *WalletHandler) Transfer(c *gin.Context) {
 	})
 }
 
-func (h *WalletHandler) GetBalance(c *gin.Context) {
+func (h *WalletHandler) ReverseTransaction(c *gin.Context) {
 	user := c.MustGet("user").(*models.User)
-	balance, err := h.WalletService.GetBalance(user.ID)
+	transactionID := c.Param("id")
+
+	idem, idemErr := idempotencyFromRequest(c, gin.H{"transaction_id": transactionID})
+	if idemErr != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": idemErr.Error()})
+		return
+	}
+
+	balance, err := h.WalletService.ReverseTransaction(user.ID, transactionID, idem)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TransactionResponse{
+		Balance: balance,
+	})
+}
+
+func (h *WalletHandler) RefundTransaction(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	transactionID := c.Param("id")
+	var req RefundRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	idem, idemErr := idempotencyFromRequest(c, gin.H{"transaction_id": transactionID, "amount": req.Amount})
+	if idemErr != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": idemErr.Error()})
+		return
+	}
+
+	balance, err := h.WalletService.RefundTransaction(user.ID, transactionID, req.Amount, idem)
 	if err != nil {
-		c.AbortWithStatusJSON(err.Code, gin.H{"error": err.Message})
+		abortWithError(c, err)
 		return
 	}
 
@@ -122,6 +290,149 @@ func (h *WalletHandler) GetBalance(c *gin.Context) {
 	})
 }
 
+func (h *WalletHandler) GetBalance(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req BalanceRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.AsOf != "" {
+		at, parseErr := parseAsOf(req.AsOf)
+		if parseErr != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid as_of"})
+			return
+		}
+
+		balance, err := h.WalletService.GetBalanceAsOf(user.ID, req.Currency, at)
+		if err != nil {
+			abortWithError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, HistoricalBalanceResponse{Balance: balance})
+		return
+	}
+
+	balance, err := h.WalletService.GetBalance(user.ID, req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, newBalanceResponse(*balance))
+}
+
+// GetDailyBalances returns the wallet's closing balance on each day of a date
+// range.
+func (h *WalletHandler) GetDailyBalances(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req DailyBalancesRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	balances, err := h.WalletService.GetDailyBalances(user.ID, req.Currency, req.From, req.To)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, DailyBalancesResponse{Balances: balances})
+}
+
+// parseAsOf reads an RFC 3339 time, or a date meaning the end of that day
+// (UTC), so that "2025-03-31" is the closing balance on March 31st.
+func parseAsOf(value string) (time.Time, error) {
+	if day, err := time.Parse("2006-01-02", value); err == nil {
+		return day.AddDate(0, 0, 1), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func (h *WalletHandler) GetBalances(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	walletBalances, err := h.WalletService.GetBalances(user.ID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	balances := make([]BalanceResponse, 0, len(walletBalances))
+	for _, balance := range walletBalances {
+		balances = append(balances, newBalanceResponse(balance))
+	}
+
+	c.JSON(http.StatusOK, BalancesResponse{Balances: balances})
+}
+
+// GetLimits shows the user's withdrawal and transfer limits in a currency and
+// the allowance left in each rolling window.
+func (h *WalletHandler) GetLimits(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req BalanceRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limits, err := h.WalletService.GetLimits(user.ID, req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, LimitsResponse{Limits: limits})
+}
+
+// PreviewFee shows the fee a withdrawal or transfer would be charged, so the
+// user can confirm it before making it.
+func (h *WalletHandler) PreviewFee(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req FeePreviewRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.WalletService.PreviewFee(user.ID, req.Operation, req.Amount, req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, FeePreviewResponse{Preview: preview})
+}
+
+func (h *WalletHandler) OpenWallet(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req OpenWalletRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Currency == "" {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Currency is a required field"})
+		return
+	}
+
+	wallet, err := h.WalletService.OpenWallet(user.ID, req.Currency)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, WalletResponse{Wallet: wallet})
+}
+
 func (h *WalletHandler) GetTransactionHistory(c *gin.Context) {
 	user := c.MustGet("user").(*models.User)
 	var req TransactionHistoryRequest
@@ -133,9 +444,50 @@ func (h *WalletHandler) GetTransactionHistory(c *gin.Context) {
 
 	transactions, err := h.WalletService.GetTransactionHistory(user.ID, req.Page, req.PageSize, req.Type, req.Status)
 	if err != nil {
-		c.AbortWithStatusJSON(err.Code, gin.H{"error": err.Message})
+		abortWithError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, TransactionHistoryResponse{Transactions: transactions})
 }
+
+// ExportTransactions streams the user's filtered transaction history as a
+// file to download.
+func (h *WalletHandler) ExportTransactions(c *gin.Context) {
+	user := c.MustGet("user").(*models.User)
+	var req ExportRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	options := services.ExportOptions{
+		Format:   req.Format,
+		Type:     req.Type,
+		Status:   req.Status,
+		Currency: req.Currency,
+		From:     req.From,
+		To:       req.To,
+	}
+	err := h.WalletService.ExportTransactions(user.ID, options, func() io.Writer {
+		format := strings.ToLower(req.Format)
+		if format == "" {
+			format = export.FormatCSV
+		}
+		c.Header("Content-Type", export.ContentType(format))
+		c.Header("Content-Disposition", `attachment; filename="transactions.`+format+`"`)
+		c.Status(http.StatusOK)
+		return c.Writer
+	})
+	if err != nil {
+		// Once the file has started there is no way to report the error
+		// other than cutting it short
+		if c.Writer.Written() {
+			return
+		}
+		c.Writer.Header().Del("Content-Type")
+		c.Writer.Header().Del("Content-Disposition")
+		abortWithError(c, err)
+	}
+}
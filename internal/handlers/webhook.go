@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler serves the admin endpoints for webhook endpoints and their
+// deliveries.
+type WebhookHandler struct {
+	WebhookService services.WebhookService
+}
+
+// CreateWebhookRequest registers a URL for the given event types, or for
+// every event if none are given.
+type CreateWebhookRequest struct {
+	URL        string   `json:"url" binding:"required"`
+	EventTypes []string `json:"event_types"`
+}
+
+// ListDeliveriesRequest filters deliveries by status and endpoint.
+type ListDeliveriesRequest struct {
+	Status     string `form:"status"`
+	EndpointID string `form:"endpoint_id"`
+}
+
+type WebhookEndpointResponse struct {
+	Endpoint *models.WebhookEndpoint `json:"endpoint"`
+}
+
+type WebhookEndpointsResponse struct {
+	Endpoints []models.WebhookEndpoint `json:"endpoints"`
+}
+
+type WebhookDeliveryResponse struct {
+	Delivery *models.WebhookDelivery `json:"delivery"`
+}
+
+type WebhookDeliveriesResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+}
+
+func NewWebhookHandler(webhookService services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		WebhookService: webhookService,
+	}
+}
+
+// CreateEndpoint registers a webhook endpoint and returns it with its
+// signing secret, which is only shown here.
+func (h *WebhookHandler) CreateEndpoint(c *gin.Context) {
+	var req CreateWebhookRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	endpoint, err := h.WebhookService.CreateEndpoint(req.URL, req.EventTypes)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebhookEndpointResponse{Endpoint: endpoint})
+}
+
+// ListEndpoints lists every webhook endpoint.
+func (h *WebhookHandler) ListEndpoints(c *gin.Context) {
+	endpoints, err := h.WebhookService.ListEndpoints()
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebhookEndpointsResponse{Endpoints: endpoints})
+}
+
+// DisableEndpoint stops delivering events to a webhook endpoint.
+func (h *WebhookHandler) DisableEndpoint(c *gin.Context) {
+	if err := h.WebhookService.DisableEndpoint(c.Param("id")); err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries lists the latest webhook deliveries, e.g. the dead-lettered
+// ones with ?status=dead.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var req ListDeliveriesRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deliveries, err := h.WebhookService.ListDeliveries(req.Status, req.EndpointID)
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebhookDeliveriesResponse{Deliveries: deliveries})
+}
+
+// Redeliver queues a webhook delivery to be sent again.
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	delivery, err := h.WebhookService.Redeliver(c.Param("id"))
+	if err != nil {
+		abortWithError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, WebhookDeliveryResponse{Delivery: delivery})
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"time"
 
+	"wallet/internal/models"
 	"wallet/internal/repositories"
 
 	"github.com/gin-gonic/gin"
@@ -55,3 +56,16 @@ func (m *AuthMiddleware) AuthMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireAdmin only lets admin users through. It must run after
+// AuthMiddleware.
+func (m *AuthMiddleware) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.User)
+		if user.Role != models.UserRoleAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -1,12 +1,19 @@
 package migrations
 
 import (
+	"fmt"
+	"time"
+
 	"wallet/internal/models"
+	"wallet/internal/money"
 
 	"github.com/go-gormigrate/gormigrate/v2"
 	"gorm.io/gorm"
 )
 
+// minorUnits is the number of minor units per major unit for money columns.
+var minorUnits = money.FromMajor(1).Minor()
+
 func NewMigrator(db *gorm.DB) *gormigrate.Gormigrate {
 	return gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
 		{
@@ -45,5 +52,504 @@ func NewMigrator(db *gorm.DB) *gormigrate.Gormigrate {
 				return nil
 			},
 		},
+		{
+			// Money columns move from DECIMAL major units to BIGINT minor units.
+			ID: "20250615100000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := convertToMinorUnits(tx, "wallets", "balance"); err != nil {
+					return err
+				}
+				return convertToMinorUnits(tx, "transactions", "amount")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := convertToMajorUnits(tx, "wallets", "balance"); err != nil {
+					return err
+				}
+				return convertToMajorUnits(tx, "transactions", "amount")
+			},
+		},
+		{
+			ID: "20250618100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.IdempotencyKey{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("idempotency_keys")
+			},
+		},
+		{
+			// Ledger accounts and postings, with every existing wallet balance
+			// booked as an opening balance so the ledger starts in agreement.
+			ID: "20250622100000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.LedgerAccount{}); err != nil {
+					return err
+				}
+				if err := tx.AutoMigrate(&models.LedgerPosting{}); err != nil {
+					return err
+				}
+
+				if err := tx.Exec(`INSERT INTO ledger_accounts (code, type, wallet_id, created_at)
+					SELECT 'wallets/' || id, ?, id, NOW() FROM wallets
+					ON CONFLICT DO NOTHING`, models.LedgerAccountTypeWallet).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`INSERT INTO ledger_accounts (code, type, wallet_id, created_at)
+					VALUES (?, ?, '', NOW())
+					ON CONFLICT DO NOTHING`, models.LedgerAccountOpeningBalances, models.LedgerAccountTypeSystem).Error; err != nil {
+					return err
+				}
+				if err := tx.Exec(`INSERT INTO ledger_postings (id, transaction_id, account_code, amount, created_at)
+					SELECT gen_random_uuid()::text, '', 'wallets/' || id, balance, NOW() FROM wallets WHERE balance <> 0`).Error; err != nil {
+					return err
+				}
+				return tx.Exec(`INSERT INTO ledger_postings (id, transaction_id, account_code, amount, created_at)
+					SELECT gen_random_uuid()::text, '', ?, -balance, NOW() FROM wallets WHERE balance <> 0`, models.LedgerAccountOpeningBalances).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("ledger_postings"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("ledger_accounts")
+			},
+		},
+		{
+			// Wallets are keyed by (user, currency). Existing wallets, transactions
+			// and postings are all in the default currency.
+			ID: "20250626100000",
+			Migrate: func(tx *gorm.DB) error {
+				for _, model := range []interface{}{&models.Wallet{}, &models.Transaction{}, &models.LedgerPosting{}} {
+					if err := addCurrencyColumn(tx, model); err != nil {
+						return err
+					}
+				}
+
+				if !tx.Migrator().HasIndex(&models.Wallet{}, "idx_wallet_user_id_currency") {
+					if err := tx.Migrator().CreateIndex(&models.Wallet{}, "idx_wallet_user_id_currency"); err != nil {
+						return err
+					}
+				}
+				if tx.Migrator().HasIndex("wallets", "idx_wallet_user_id") {
+					return tx.Migrator().DropIndex("wallets", "idx_wallet_user_id")
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Exec("CREATE INDEX IF NOT EXISTS idx_wallet_user_id ON wallets (user_id)").Error; err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropIndex(&models.Wallet{}, "idx_wallet_user_id_currency"); err != nil {
+					return err
+				}
+				for _, table := range []string{"ledger_postings", "transactions", "wallets"} {
+					if err := tx.Migrator().DropColumn(table, "currency"); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20250701100000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.FXQuote{}); err != nil {
+					return err
+				}
+				return tx.AutoMigrate(&models.Transaction{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"quote_id", "exchange_rate", "to_currency", "to_amount"} {
+					if err := tx.Migrator().DropColumn("transactions", column); err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().DropTable("fx_quotes")
+			},
+		},
+		{
+			// Link refunds and reversals to the transfer they compensate.
+			ID: "20250705100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Transaction{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropIndex(&models.Transaction{}, "idx_transaction_original_transaction_id"); err != nil {
+					return err
+				}
+				for _, column := range []string{"refunded_amount", "original_transaction_id"} {
+					if err := tx.Migrator().DropColumn("transactions", column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20250708100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Hold{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("holds")
+			},
+		},
+		{
+			// Let holds be placed by internal services, not just authorizations.
+			ID: "20250712100000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.Hold{}); err != nil {
+					return err
+				}
+				if err := tx.Exec("UPDATE holds SET user_id = wallets.user_id FROM wallets WHERE holds.wallet_id = wallets.id AND (holds.user_id IS NULL OR holds.user_id = '')").Error; err != nil {
+					return err
+				}
+				return tx.Model(&models.Hold{}).
+					Where("reason IS NULL OR reason = ''").
+					Update("reason", models.HoldReasonAuthorization).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropIndex(&models.Hold{}, "idx_hold_user_id"); err != nil {
+					return err
+				}
+				for _, column := range []string{"reason", "user_id"} {
+					if err := tx.Migrator().DropColumn("holds", column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "20250715100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Schedule{}, &models.ScheduleExecution{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("schedule_executions", "schedules")
+			},
+		},
+		{
+			// Phone numbers and handles to address transfers by
+			ID: "20250718100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.User{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, index := range []string{"idx_user_handle", "idx_user_phone"} {
+					if err := tx.Migrator().DropIndex(&models.User{}, index); err != nil {
+						return err
+					}
+				}
+				for _, column := range []string{"handle", "phone"} {
+					if err := tx.Migrator().DropColumn("users", column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			// Payment requests between users
+			ID: "20250722100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.PaymentRequest{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("payment_requests")
+			},
+		},
+		{
+			// Batch transfers and the link from their transactions
+			ID: "20250726100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.TransferBatch{}, &models.Transaction{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropIndex(&models.Transaction{}, "idx_transaction_batch_id"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn("transactions", "batch_id"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("transfer_batches")
+			},
+		},
+		{
+			// Bulk payouts from uploaded CSV files
+			ID: "20250729100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.PayoutJob{}, &models.PayoutItem{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("payout_items", "payout_jobs")
+			},
+		},
+		{
+			// Per-user overrides of the default spending limits
+			ID: "20250802100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.LimitOverride{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("limit_overrides")
+			},
+		},
+		{
+			// User plans for fee rules, and the link from fees to the
+			// transaction they were charged for
+			ID: "20250806100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.User{}, &models.Transaction{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropIndex(&models.Transaction{}, "idx_transaction_parent_transaction_id"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn("transactions", "parent_transaction_id"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn("users", "plan")
+			},
+		},
+		{
+			// Daily interest accruals and the days the accrual job has finished
+			ID: "20250810100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.InterestAccrual{}, &models.InterestRun{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("interest_runs", "interest_accruals")
+			},
+		},
+		{
+			// Wallet statuses, their history and admin users
+			ID: "20250814100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Wallet{}, &models.User{}, &models.WalletStatusEvent{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("wallet_status_events"); err != nil {
+					return err
+				}
+				for _, column := range []string{"status", "status_reason", "closed_at"} {
+					if err := tx.Migrator().DropColumn("wallets", column); err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().DropColumn("users", "role")
+			},
+		},
+		{
+			// Daily closing balances of ledger accounts, and an index to sum an
+			// account's postings over a time range
+			ID: "20250818100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.LedgerPosting{}, &models.BalanceCheckpoint{}, &models.BalanceCheckpointRun{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropIndex(&models.LedgerPosting{}, "idx_ledger_posting_account_code_created_at"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("balance_checkpoint_runs", "balance_checkpoints")
+			},
+		},
+		{
+			// Stored monthly statements and the months already generated
+			ID: "20250822100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.Statement{}, &models.StatementLine{}, &models.StatementRun{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("statement_runs", "statement_lines", "statements")
+			},
+		},
+		{
+			// Balance reconciliation runs and the wallets that did not reconcile
+			ID: "20250826100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.ReconciliationRun{}, &models.ReconciliationMismatch{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("reconciliation_mismatches", "reconciliation_runs")
+			},
+		},
+		{
+			// Tamper-evident transaction log, started with every existing
+			// transaction in the order it was created
+			ID: "20250830100000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.Transaction{}, &models.TransactionLogEntry{}, &models.TransactionLogHead{}, &models.TransactionLogCheckpoint{}); err != nil {
+					return err
+				}
+				return backfillTransactionLog(tx)
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("transaction_log_checkpoints", "transaction_log_heads", "transaction_log_entries"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(&models.Transaction{}, "PrevHash"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&models.Transaction{}, "Hash")
+			},
+		},
+		{
+			// Outbox of domain events and their delivery to webhook endpoints
+			ID: "20250903100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.OutboxEvent{}, &models.WebhookEndpoint{}, &models.WebhookDelivery{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("webhook_deliveries", "webhook_endpoints", "outbox_events")
+			},
+		},
+		{
+			// Versions of transactions waiting for the sequencer to append
+			// them to the transaction log
+			ID: "20250906100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.PendingTransactionLogEntry{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("pending_transaction_log_entries")
+			},
+		},
+		{
+			// Leases on webhook deliveries being sent
+			ID: "20250908100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.WebhookDelivery{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropColumn(&models.WebhookDelivery{}, "LeaseUntil")
+			},
+		},
+		{
+			// Phone number verification. Numbers set before it were never
+			// verified, so they stop addressing transfers until they are
+			ID: "20250909100000",
+			Migrate: func(tx *gorm.DB) error {
+				if err := tx.AutoMigrate(&models.User{}); err != nil {
+					return err
+				}
+				return tx.Exec("UPDATE users SET pending_phone = phone, phone = NULL WHERE phone IS NOT NULL").Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"phone_code_attempts", "phone_code_expires_at", "phone_code_hash", "pending_phone"} {
+					if err := tx.Migrator().DropColumn("users", column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			// Backoff and a retry cap for payout jobs
+			ID: "20250910100000",
+			Migrate: func(tx *gorm.DB) error {
+				return tx.AutoMigrate(&models.PayoutJob{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"error", "next_attempt_at", "attempts"} {
+					if err := tx.Migrator().DropColumn("payout_jobs", column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
 	})
 }
+
+// transactionLogBatchSize is how many existing transactions are loaded at a
+// time while starting the transaction log.
+const transactionLogBatchSize = 500
+
+// backfillTransactionLog appends every existing transaction to the new
+// transaction log, oldest first, and creates the log head.
+func backfillTransactionLog(tx *gorm.DB) error {
+	head := models.TransactionLogHead{ID: models.TransactionLogHeadID, UpdatedAt: time.Now()}
+
+	for offset := 0; ; offset += transactionLogBatchSize {
+		var transactions []models.Transaction
+		if err := tx.Order("created_at, id").Offset(offset).Limit(transactionLogBatchSize).Find(&transactions).Error; err != nil {
+			return err
+		}
+
+		for i := range transactions {
+			transaction := &transactions[i]
+			entry := models.TransactionLogEntry{
+				Sequence:      head.Sequence + 1,
+				TransactionID: transaction.ID,
+				Operation:     models.TransactionLogOperationCreate,
+				Payload:       transaction.LogPayload(),
+				PrevHash:      head.Hash,
+				CreatedAt:     time.Now(),
+			}
+			entry.Hash = models.TransactionLogHash(entry.PrevHash, entry.Sequence, entry.Operation, entry.Payload)
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(transaction).UpdateColumns(map[string]interface{}{"hash": entry.Hash, "prev_hash": entry.PrevHash}).Error; err != nil {
+				return err
+			}
+			head.Sequence = entry.Sequence
+			head.Hash = entry.Hash
+		}
+
+		if len(transactions) < transactionLogBatchSize {
+			break
+		}
+	}
+	return tx.Create(&head).Error
+}
+
+// addCurrencyColumn adds the currency column to a model's table if it is
+// missing and backfills rows without one with the default currency.
+func addCurrencyColumn(tx *gorm.DB, model interface{}) error {
+	if !tx.Migrator().HasColumn(model, "Currency") {
+		if err := tx.Migrator().AddColumn(model, "Currency"); err != nil {
+			return err
+		}
+	}
+	return tx.Model(model).
+		Where("currency IS NULL OR currency = ''").
+		Update("currency", money.DefaultCurrency).Error
+}
+
+// convertToMinorUnits rewrites a DECIMAL column of major units as a BIGINT
+// column of minor units. Databases created after the money type was
+// introduced already have BIGINT columns and are left untouched.
+func convertToMinorUnits(tx *gorm.DB, table, column string) error {
+	dataType, err := columnDataType(tx, table, column)
+	if err != nil {
+		return err
+	}
+	if dataType == "bigint" {
+		return nil
+	}
+
+	// Float arithmetic used to leave residue such as 0.30000000000000004 in
+	// these columns, which rounds cleanly to a cent. Anything further from a
+	// whole cent is real sub-cent data, and we refuse to silently drop it.
+	var lossy int64
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE ABS(%s * %d - ROUND(%s * %d)) > 0.000001", table, column, minorUnits, column, minorUnits)
+	if err := tx.Raw(query).Scan(&lossy).Error; err != nil {
+		return err
+	}
+	if lossy > 0 {
+		return fmt.Errorf("%d rows in %s.%s have sub-cent precision and cannot be converted without loss", lossy, table, column)
+	}
+
+	return tx.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE bigint USING ROUND(%s * %d)::bigint", table, column, column, minorUnits)).Error
+}
+
+func convertToMajorUnits(tx *gorm.DB, table, column string) error {
+	return tx.Exec(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE decimal USING %s::decimal / %d", table, column, column, minorUnits)).Error
+}
+
+func columnDataType(tx *gorm.DB, table, column string) (string, error) {
+	var dataType string
+	err := tx.Raw("SELECT data_type FROM information_schema.columns WHERE table_name = ? AND column_name = ?", table, column).Scan(&dataType).Error
+	return dataType, err
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// Balance breaks a wallet's balance down by what can be spent. LedgerBalance
+// is everything booked to the wallet, Held is the total of its active holds,
+// and Available is the ledger balance less the held amount. Status is the
+// wallet's status, so users can see when it has been frozen.
+type Balance struct {
+	Currency      string       `json:"currency"`
+	Status        string       `json:"status"`
+	LedgerBalance money.Amount `json:"ledger_balance"`
+	Held          money.Amount `json:"held_amount"`
+	Available     money.Amount `json:"available_balance"`
+}
+
+// HistoricalBalance is a wallet's ledger balance at a point in the past.
+type HistoricalBalance struct {
+	Currency string       `json:"currency"`
+	AsOf     time.Time    `json:"as_of"`
+	Balance  money.Amount `json:"balance"`
+}
+
+// DailyBalance is a wallet's closing ledger balance on a day (UTC).
+type DailyBalance struct {
+	Date    string       `json:"date"`
+	Balance money.Amount `json:"balance"`
+}
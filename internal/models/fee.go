@@ -0,0 +1,38 @@
+package models
+
+import (
+	"wallet/internal/money"
+)
+
+// FeeRule is one rule of the fee schedule. It applies to amounts between
+// MinAmount and MaxAmount inclusive, for users on Plan; a zero bound or an
+// empty plan matches anything. The fee is Flat plus PercentBps basis points
+// of the amount, kept between MinFee and MaxFee where those are set.
+type FeeRule struct {
+	Plan       string       `json:"plan,omitempty"`
+	MinAmount  money.Amount `json:"min_amount,omitempty"`
+	MaxAmount  money.Amount `json:"max_amount,omitempty"`
+	Flat       money.Amount `json:"flat,omitempty"`
+	PercentBps int64        `json:"percent_bps,omitempty"`
+	MinFee     money.Amount `json:"min_fee,omitempty"`
+	MaxFee     money.Amount `json:"max_fee,omitempty"`
+}
+
+// FeePreview is what a withdrawal or transfer would cost, shown to the user
+// before they make it. Total is the amount plus the fee.
+type FeePreview struct {
+	Operation string       `json:"operation"`
+	Amount    money.Amount `json:"amount"`
+	Currency  string       `json:"currency"`
+	Fee       money.Amount `json:"fee"`
+	Total     money.Amount `json:"total"`
+}
+
+// HouseFeesUserID owns the house's fee wallets, one per currency, which every
+// fee is credited to. It is not a user anyone can log in as.
+const HouseFeesUserID = "house-fees"
+
+const (
+	FeeOperationWithdraw = "withdraw"
+	FeeOperationTransfer = "transfer"
+)
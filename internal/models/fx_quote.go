@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// FXQuote locks an exchange rate for a user for a short time. Rates are
+// stored as decimal strings so they are applied exactly as quoted.
+type FXQuote struct {
+	ID            string       `json:"id"`
+	UserID        string       `json:"user_id" gorm:"index:idx_fx_quote_user_id"`
+	FromCurrency  string       `json:"from_currency"`
+	ToCurrency    string       `json:"to_currency"`
+	FromAmount    money.Amount `json:"from_amount"`
+	ToAmount      money.Amount `json:"to_amount"`
+	MidRate       string       `json:"mid_rate"`
+	SpreadBps     int64        `json:"spread_bps"`
+	Rate          string       `json:"rate"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	UsedAt        *time.Time   `json:"used_at,omitempty"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// Hold reserves part of a wallet's balance until it is captured, released or
+// expires. Held money stays in the wallet and on its ledger account; a hold
+// only reduces what the wallet can still spend. Reason records who placed the
+// hold, such as an authorization, a dispute or a pending payout. Holds without
+// an ExpiresAt last until they are released or captured.
+type Hold struct {
+	ID            string       `json:"id"`
+	UserID        string       `json:"user_id" gorm:"index:idx_hold_user_id"`
+	WalletID      string       `json:"wallet_id" gorm:"index:idx_hold_wallet_id_status"`
+	TransactionID string       `json:"transaction_id,omitempty" gorm:"index:idx_hold_transaction_id"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	Reason        string       `json:"reason"`
+	Status        string       `json:"status" gorm:"index:idx_hold_wallet_id_status"`
+	ExpiresAt     *time.Time   `json:"expires_at,omitempty" gorm:"index:idx_hold_expires_at"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+const (
+	HoldStatusActive   = "active"
+	HoldStatusCaptured = "captured"
+	HoldStatusReleased = "released"
+	HoldStatusExpired  = "expired"
+
+	// HoldReasonAuthorization marks holds placed by AuthorizeTransfer. These
+	// are captured and voided through their transaction.
+	HoldReasonAuthorization = "authorization"
+)
+
+// Expired reports whether the hold's expiry has passed at now.
+func (h *Hold) Expired(now time.Time) bool {
+	return h.ExpiresAt != nil && !now.Before(*h.ExpiresAt)
+}
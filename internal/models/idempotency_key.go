@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey records a client-supplied Idempotency-Key together with a
+// fingerprint of the request it was first used with and the response that
+// request produced, so that retries can be answered without re-executing.
+type IdempotencyKey struct {
+	ID           string    `json:"id"`
+	UserID       string    `json:"user_id" gorm:"index:idx_idempotency_key_user_id_key,unique"`
+	Key          string    `json:"key" gorm:"index:idx_idempotency_key_user_id_key,unique"`
+	Fingerprint  string    `json:"fingerprint"`
+	ResponseCode int       `json:"response_code"`
+	ResponseBody string    `json:"response_body"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"index:idx_idempotency_key_expires_at"`
+}
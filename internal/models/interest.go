@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// InterestRate is the annual rate paid on positive balances in a currency,
+// e.g. "0.02" for 2%, and the day-count convention that turns it into a
+// daily rate.
+type InterestRate struct {
+	AnnualRate string `json:"annual_rate"`
+	DayCount   string `json:"day_count"`
+}
+
+// InterestAccrual is the interest one wallet earned on one day, on its ledger
+// balance at the end of that day (UTC). Amount is kept to 12 decimal places
+// so that daily fractions of a cent add up; accruals are paid out in whole
+// minor units once a month and then carry the interest TransactionID.
+type InterestAccrual struct {
+	ID            string       `json:"id"`
+	WalletID      string       `json:"wallet_id" gorm:"index:idx_interest_accrual_wallet_id_date,unique"`
+	UserID        string       `json:"user_id"`
+	Currency      string       `json:"currency"`
+	Date          string       `json:"date" gorm:"index:idx_interest_accrual_wallet_id_date,unique"`
+	Balance       money.Amount `json:"balance"`
+	AnnualRate    string       `json:"annual_rate"`
+	DayCount      string       `json:"day_count"`
+	Amount        string       `json:"amount"`
+	TransactionID string       `json:"transaction_id,omitempty" gorm:"index:idx_interest_accrual_transaction_id"`
+	PostedAt      *time.Time   `json:"posted_at,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// InterestRun records a day the accrual job has finished, so that it knows
+// where to pick up after downtime.
+type InterestRun struct {
+	Date        string    `json:"date" gorm:"primaryKey"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// InterestSummary shows a user what one of their wallets earns and the
+// interest accrued but not yet paid, rounded to the currency's minor unit.
+type InterestSummary struct {
+	Currency   string       `json:"currency"`
+	AnnualRate string       `json:"annual_rate"`
+	DayCount   string       `json:"day_count"`
+	Accrued    money.Amount `json:"accrued"`
+}
+
+const (
+	DayCountActual365    = "actual/365"
+	DayCountActual360    = "actual/360"
+	DayCountActualActual = "actual/actual"
+)
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// LedgerAccount is an account in the double-entry ledger. Every wallet has
+// its own account, and money entering or leaving the system is booked
+// against system accounts.
+type LedgerAccount struct {
+	Code      string    `json:"code" gorm:"primaryKey"`
+	Type      string    `json:"type"`
+	WalletID  string    `json:"wallet_id,omitempty" gorm:"index:idx_ledger_account_wallet_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LedgerPosting is one immutable leg of a ledger entry. Credits are positive
+// and debits negative, and the postings of a transaction always sum to zero
+// in each currency.
+// Opening balance postings written when the ledger was introduced have no
+// transaction ID.
+type LedgerPosting struct {
+	ID            string       `json:"id"`
+	TransactionID string       `json:"transaction_id" gorm:"index:idx_ledger_posting_transaction_id"`
+	AccountCode   string       `json:"account_code" gorm:"index:idx_ledger_posting_account_code;index:idx_ledger_posting_account_code_created_at,priority:1"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	CreatedAt     time.Time    `json:"created_at" gorm:"index:idx_ledger_posting_account_code_created_at,priority:2"`
+}
+
+const (
+	LedgerAccountTypeWallet = "wallet"
+	LedgerAccountTypeSystem = "system"
+
+	LedgerAccountWorldDeposits    = "world/deposits"
+	LedgerAccountWorldWithdrawals = "world/withdrawals"
+	// LedgerAccountHouseFees holds the fees charged before they were
+	// credited to the house fee wallets
+	LedgerAccountHouseFees       = "house/fees"
+	LedgerAccountHouseFX         = "house/fx"
+	LedgerAccountHouseInterest   = "house/interest"
+	LedgerAccountOpeningBalances = "equity/opening-balances"
+)
+
+// WalletLedgerAccount returns the ledger account code of a wallet.
+func WalletLedgerAccount(walletID string) string {
+	return "wallets/" + walletID
+}
+
+// BalanceCheckpoint is the closing balance of a ledger account at the end of
+// a day (UTC), so that historical balances only replay the postings booked
+// since. Days on which an account had no postings have no checkpoint.
+type BalanceCheckpoint struct {
+	AccountCode string       `json:"account_code" gorm:"primaryKey"`
+	Date        string       `json:"date" gorm:"primaryKey"`
+	Balance     money.Amount `json:"balance"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// BalanceCheckpointRun records a day the checkpoint job has finished.
+type BalanceCheckpointRun struct {
+	Date        string    `json:"date" gorm:"primaryKey"`
+	CompletedAt time.Time `json:"completed_at"`
+}
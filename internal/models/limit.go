@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// Limits caps how much a user can move out of a wallet in one currency. The
+// per-transaction bounds apply to each withdrawal or transfer, and the
+// amount and count caps to the rolling day, week and month before it. Zero
+// means no limit.
+type Limits struct {
+	MinAmount     money.Amount `json:"min_amount,omitempty"`
+	MaxAmount     money.Amount `json:"max_amount,omitempty"`
+	DailyAmount   money.Amount `json:"daily_amount,omitempty"`
+	WeeklyAmount  money.Amount `json:"weekly_amount,omitempty"`
+	MonthlyAmount money.Amount `json:"monthly_amount,omitempty"`
+	DailyCount    int          `json:"daily_count,omitempty"`
+	WeeklyCount   int          `json:"weekly_count,omitempty"`
+	MonthlyCount  int          `json:"monthly_count,omitempty"`
+}
+
+// LimitOverride replaces the default limits of one operation and currency for
+// one user, for example to let an operations account pay out more.
+type LimitOverride struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id" gorm:"index:idx_limit_override_user_id_operation_currency,unique"`
+	Operation string    `json:"operation" gorm:"index:idx_limit_override_user_id_operation_currency,unique"`
+	Currency  string    `json:"currency" gorm:"index:idx_limit_override_user_id_operation_currency,unique"`
+	Limits    Limits    `json:"limits" gorm:"embedded"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LimitStatus shows a user's limits for one operation and currency and how
+// much of each rolling window is left.
+type LimitStatus struct {
+	Operation string        `json:"operation"`
+	Currency  string        `json:"currency"`
+	MinAmount money.Amount  `json:"min_amount,omitempty"`
+	MaxAmount money.Amount  `json:"max_amount,omitempty"`
+	Windows   []LimitWindow `json:"windows"`
+}
+
+// LimitWindow is the usage of one rolling window. Limits and remaining
+// allowances are omitted when there is no cap.
+type LimitWindow struct {
+	Window          string        `json:"window"`
+	AmountLimit     *money.Amount `json:"amount_limit,omitempty"`
+	AmountUsed      money.Amount  `json:"amount_used"`
+	AmountRemaining *money.Amount `json:"amount_remaining,omitempty"`
+	CountLimit      *int          `json:"count_limit,omitempty"`
+	CountUsed       int           `json:"count_used"`
+	CountRemaining  *int          `json:"count_remaining,omitempty"`
+}
+
+// LimitExceeded says which limit a withdrawal or transfer would break. Limit
+// names the cap as in Limits, e.g. max_amount or daily_count, and Window is
+// set for the caps of a rolling window, which free up as it moves on.
+type LimitExceeded struct {
+	Operation       string        `json:"operation"`
+	Currency        string        `json:"currency"`
+	Limit           string        `json:"limit"`
+	Window          string        `json:"window,omitempty"`
+	AmountLimit     *money.Amount `json:"amount_limit,omitempty"`
+	AmountRemaining *money.Amount `json:"amount_remaining,omitempty"`
+	CountLimit      *int          `json:"count_limit,omitempty"`
+	CountRemaining  *int          `json:"count_remaining,omitempty"`
+}
+
+const (
+	LimitOperationWithdraw = "withdraw"
+	LimitOperationTransfer = "transfer"
+
+	LimitWindowDaily   = "daily"
+	LimitWindowWeekly  = "weekly"
+	LimitWindowMonthly = "monthly"
+)
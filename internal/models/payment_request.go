@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// PaymentRequest asks PayerID to pay Amount in Currency to RequesterID. It
+// stays pending until the payer accepts or declines it, the requester cancels
+// it, or it expires. Accepting it pays it through an ordinary transfer, which
+// TransactionID links to.
+type PaymentRequest struct {
+	ID            string       `json:"id"`
+	RequesterID   string       `json:"requester_id" gorm:"index:idx_payment_request_requester_id"`
+	PayerID       string       `json:"payer_id" gorm:"index:idx_payment_request_payer_id"`
+	Amount        money.Amount `json:"amount"`
+	Currency      string       `json:"currency"`
+	Note          string       `json:"note,omitempty"`
+	Status        string       `json:"status" gorm:"index:idx_payment_request_status_expires_at"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	ExpiresAt     time.Time    `json:"expires_at" gorm:"index:idx_payment_request_status_expires_at"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+const (
+	PaymentRequestStatusPending   = "pending"
+	PaymentRequestStatusAccepted  = "accepted"
+	PaymentRequestStatusDeclined  = "declined"
+	PaymentRequestStatusCancelled = "cancelled"
+	PaymentRequestStatusExpired   = "expired"
+)
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// PayoutJob pays every row of an uploaded CSV file from UserID's Currency
+// wallet. Rows are validated when the file is uploaded and paid one by one
+// in the background; the counters track the job's progress. A job whose next
+// row cannot be paid yet backs off until NextAttemptAt. Attempts counts the
+// server errors on that row, and Error holds the latest reason.
+type PayoutJob struct {
+	ID            string       `json:"id"`
+	UserID        string       `json:"user_id" gorm:"index:idx_payout_job_user_id"`
+	Currency      string       `json:"currency"`
+	TotalAmount   money.Amount `json:"total_amount"`
+	TotalRows     int          `json:"total_rows"`
+	ProcessedRows int          `json:"processed_rows"`
+	SucceededRows int          `json:"succeeded_rows"`
+	FailedRows    int          `json:"failed_rows"`
+	Status        string       `json:"status" gorm:"index:idx_payout_job_status"`
+	Attempts      int          `json:"attempts"`
+	NextAttemptAt *time.Time   `json:"next_attempt_at,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	CompletedAt   *time.Time   `json:"completed_at,omitempty"`
+}
+
+// PayoutItem is one row of a payout job. Line is the row's line number in the
+// uploaded file, and Recipient the recipient exactly as it was written there.
+type PayoutItem struct {
+	ID            string       `json:"id"`
+	JobID         string       `json:"job_id" gorm:"index:idx_payout_item_job_id_line"`
+	Line          int          `json:"line" gorm:"index:idx_payout_item_job_id_line"`
+	Recipient     string       `json:"recipient"`
+	ToUserID      string       `json:"to_user_id"`
+	Amount        money.Amount `json:"amount"`
+	Reference     string       `json:"reference,omitempty"`
+	Status        string       `json:"status"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	Error         string       `json:"error,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+}
+
+const (
+	PayoutJobStatusPending   = "pending"
+	PayoutJobStatusRunning   = "running"
+	PayoutJobStatusCompleted = "completed"
+	PayoutJobStatusFailed    = "failed"
+
+	PayoutItemStatusPending = "pending"
+	PayoutItemStatusSuccess = "success"
+	PayoutItemStatusFailed  = "failed"
+)
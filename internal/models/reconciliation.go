@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// ReconciliationRun is one pass recomputing every wallet's balance from its
+// transactions and its ledger account. Mismatches are only loaded when a
+// single run is fetched.
+type ReconciliationRun struct {
+	ID             string                   `json:"id"`
+	Status         string                   `json:"status"`
+	AutoFreeze     bool                     `json:"auto_freeze"`
+	WalletsChecked int                      `json:"wallets_checked"`
+	Mismatched     int                      `json:"mismatched"`
+	Frozen         int                      `json:"frozen"`
+	Error          string                   `json:"error,omitempty"`
+	StartedAt      time.Time                `json:"started_at" gorm:"index:idx_reconciliation_run_started_at"`
+	CompletedAt    *time.Time               `json:"completed_at,omitempty"`
+	Mismatches     []ReconciliationMismatch `json:"mismatches,omitempty" gorm:"-"`
+}
+
+// ReconciliationMismatch records a wallet whose stored balance disagreed with
+// the balance recomputed from its successful transactions, with its ledger
+// account, or with both. Difference is the stored balance minus the
+// transaction balance.
+type ReconciliationMismatch struct {
+	ID                 string       `json:"id"`
+	RunID              string       `json:"run_id" gorm:"index:idx_reconciliation_mismatch_run_id"`
+	WalletID           string       `json:"wallet_id"`
+	UserID             string       `json:"user_id"`
+	Currency           string       `json:"currency"`
+	WalletStatus       string       `json:"wallet_status"`
+	Balance            money.Amount `json:"balance"`
+	TransactionBalance money.Amount `json:"transaction_balance"`
+	LedgerBalance      money.Amount `json:"ledger_balance"`
+	Difference         money.Amount `json:"difference"`
+	TransactionCount   int          `json:"transaction_count"`
+	Frozen             bool         `json:"frozen"`
+	CreatedAt          time.Time    `json:"created_at"`
+}
+
+const (
+	ReconciliationStatusRunning   = "running"
+	ReconciliationStatusCompleted = "completed"
+	ReconciliationStatusFailed    = "failed"
+)
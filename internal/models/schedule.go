@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// Schedule is a transfer that runs at StartAt and then, unless it is a
+// one-off, repeats at Frequency until EndAt or MaxOccurrences is reached.
+// Occurrences counts the occurrences dealt with so far, paid or skipped, and
+// Attempts the failed attempts at the current one. NextRunAt is cleared once
+// the schedule stops.
+type Schedule struct {
+	ID                  string       `json:"id"`
+	UserID              string       `json:"user_id" gorm:"index:idx_schedule_user_id"`
+	ToUserID            string       `json:"to_user_id"`
+	Amount              money.Amount `json:"amount"`
+	Currency            string       `json:"currency"`
+	Frequency           string       `json:"frequency"`
+	StartAt             time.Time    `json:"start_at"`
+	EndAt               *time.Time   `json:"end_at,omitempty"`
+	MaxOccurrences      int          `json:"max_occurrences,omitempty"`
+	OnInsufficientFunds string       `json:"on_insufficient_funds"`
+	Occurrences         int          `json:"occurrences"`
+	Attempts            int          `json:"attempts"`
+	NextRunAt           *time.Time   `json:"next_run_at,omitempty" gorm:"index:idx_schedule_status_next_run_at"`
+	Status              string       `json:"status" gorm:"index:idx_schedule_status_next_run_at"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+}
+
+// ScheduleExecution records one attempt at paying an occurrence of a
+// schedule, and the transaction it produced if it succeeded.
+type ScheduleExecution struct {
+	ID            string    `json:"id"`
+	ScheduleID    string    `json:"schedule_id" gorm:"index:idx_schedule_execution_schedule_id"`
+	Occurrence    int       `json:"occurrence"`
+	ScheduledFor  time.Time `json:"scheduled_for"`
+	Attempt       int       `json:"attempt"`
+	Status        string    `json:"status"`
+	TransactionID string    `json:"transaction_id,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+const (
+	ScheduleFrequencyOnce    = "once"
+	ScheduleFrequencyDaily   = "daily"
+	ScheduleFrequencyWeekly  = "weekly"
+	ScheduleFrequencyMonthly = "monthly"
+
+	ScheduleStatusActive    = "active"
+	ScheduleStatusCompleted = "completed"
+	ScheduleStatusCancelled = "cancelled"
+
+	// On insufficient funds a schedule either retries the occurrence later
+	// or skips straight to the next one.
+	ScheduleOnInsufficientFundsRetry = "retry"
+	ScheduleOnInsufficientFundsSkip  = "skip"
+
+	ScheduleExecutionStatusSuccess = "success"
+	ScheduleExecutionStatusFailed  = "failed"
+	ScheduleExecutionStatusSkipped = "skipped"
+)
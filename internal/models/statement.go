@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// Statement is a wallet's account statement for a calendar month (UTC):
+// its opening balance, every ledger movement with the balance after it, and
+// its closing balance. Statements for months that have ended are stored once
+// and never change; the current month's statement is provisional and is
+// generated on every request.
+type Statement struct {
+	ID             string           `json:"id"`
+	UserID         string           `json:"user_id" gorm:"index:idx_statement_user_id_currency_period,unique"`
+	WalletID       string           `json:"wallet_id"`
+	Currency       string           `json:"currency" gorm:"index:idx_statement_user_id_currency_period,unique"`
+	Period         string           `json:"period" gorm:"index:idx_statement_user_id_currency_period,unique"`
+	PeriodStart    time.Time        `json:"period_start"`
+	PeriodEnd      time.Time        `json:"period_end"`
+	OpeningBalance money.Amount     `json:"opening_balance"`
+	ClosingBalance money.Amount     `json:"closing_balance"`
+	Provisional    bool             `json:"provisional" gorm:"-"`
+	Lines          []StatementLine  `json:"lines,omitempty" gorm:"-"`
+	Totals         []StatementTotal `json:"totals,omitempty" gorm:"-"`
+	CreatedAt      time.Time        `json:"created_at"`
+}
+
+// StatementLine is one movement on a statement. Amount is signed, negative
+// for money leaving the wallet, and Balance is the running balance after it.
+// Counterparty is the other user of a transfer, if there was one.
+type StatementLine struct {
+	ID            string       `json:"-"`
+	StatementID   string       `json:"-" gorm:"index:idx_statement_line_statement_id_position"`
+	Position      int          `json:"-" gorm:"index:idx_statement_line_statement_id_position"`
+	Date          time.Time    `json:"date"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	Type          string       `json:"type"`
+	Counterparty  string       `json:"counterparty,omitempty"`
+	Amount        money.Amount `json:"amount"`
+	Balance       money.Amount `json:"balance"`
+}
+
+// StatementTotal adds up a statement's movements of one transaction type.
+type StatementTotal struct {
+	Type    string       `json:"type"`
+	Count   int          `json:"count"`
+	Credits money.Amount `json:"credits"`
+	Debits  money.Amount `json:"debits"`
+}
+
+// StatementRun records a month whose statements have all been generated.
+type StatementRun struct {
+	Period      string    `json:"period" gorm:"primaryKey"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// StatementLineTypeAdjustment labels ledger postings made outside any
+// transaction, such as the opening balances written when the ledger was
+// introduced.
+const StatementLineTypeAdjustment = "adjustment"
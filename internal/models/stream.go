@@ -0,0 +1,17 @@
+package models
+
+// StreamEvent is an event sent on a user's real-time stream. A transaction
+// event carries the transaction as it is now, with the transaction log
+// sequence of the change as its ID, so that a client can resume the stream
+// after it. A balance event is a snapshot of all of the user's balances and
+// has no ID.
+type StreamEvent struct {
+	ID   string
+	Type string
+	Data interface{}
+}
+
+const (
+	StreamEventTransaction = "transaction"
+	StreamEventBalance     = "balance"
+)
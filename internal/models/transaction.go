@@ -2,25 +2,86 @@ package models
 
 import (
 	"time"
+
+	"wallet/internal/money"
 )
 
+// Transaction records a movement of money. Amount and Currency are what left
+// the sender (or arrived, for deposits). Cross-currency transfers also record
+// the amount and currency the recipient received and the rate applied.
+// Refunds and reversals link back to the transaction they compensate through
+// OriginalTransactionID, and the original keeps a running RefundedAmount.
+// Transfers made as part of a multi-recipient payment carry the BatchID of
+// their TransferBatch. Fees are booked as transactions of their own, linked to
+// the withdrawal or transfer they were charged for by ParentTransactionID.
+// Hash and PrevHash link the transaction's current version into the
+// tamper-evident transaction log; see TransactionLogEntry.
 type Transaction struct {
-	ID         string     `json:"id"`
-	FromUserID string     `json:"from_user_id" gorm:"index:idx_transaction_from_user_id"`
-	ToUserID   string     `json:"to_user_id" gorm:"index:idx_transaction_to_user_id"`
-	Amount     float64    `json:"amount"`
-	Type       string     `json:"type"`
-	Status     string     `json:"status"`
-	CreatedAt  time.Time  `json:"created_at"`
-	UpdatedAt  time.Time  `json:"updated_at"`
-	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	ID                    string       `json:"id"`
+	FromUserID            string       `json:"from_user_id" gorm:"index:idx_transaction_from_user_id"`
+	ToUserID              string       `json:"to_user_id" gorm:"index:idx_transaction_to_user_id"`
+	Amount                money.Amount `json:"amount"`
+	Currency              string       `json:"currency"`
+	ToAmount              money.Amount `json:"to_amount,omitempty"`
+	ToCurrency            string       `json:"to_currency,omitempty"`
+	ExchangeRate          string       `json:"exchange_rate,omitempty"`
+	QuoteID               string       `json:"quote_id,omitempty"`
+	OriginalTransactionID string       `json:"original_transaction_id,omitempty" gorm:"index:idx_transaction_original_transaction_id"`
+	RefundedAmount        money.Amount `json:"refunded_amount,omitempty"`
+	BatchID               string       `json:"batch_id,omitempty" gorm:"index:idx_transaction_batch_id"`
+	ParentTransactionID   string       `json:"parent_transaction_id,omitempty" gorm:"index:idx_transaction_parent_transaction_id"`
+	Type                  string       `json:"type"`
+	Status                string       `json:"status"`
+	CreatedAt             time.Time    `json:"created_at"`
+	UpdatedAt             time.Time    `json:"updated_at"`
+	DeletedAt             *time.Time   `json:"deleted_at,omitempty"`
+	Hash                  string       `json:"hash,omitempty"`
+	PrevHash              string       `json:"prev_hash,omitempty"`
 }
 
 const (
 	TransactionTypeDeposit   = "deposit"
 	TransactionTypeWithdraw  = "withdraw"
 	TransactionTypeTransfer  = "transfer"
+	TransactionTypeRefund    = "refund"
+	TransactionTypeReversal  = "reversal"
+	TransactionTypeFee       = "fee"
+	TransactionTypeInterest  = "interest"
+	TransactionTypeSweep     = "sweep"
 	TransactionStatusPending = "pending"
 	TransactionStatusSuccess = "success"
 	TransactionStatusFailed  = "failed"
+
+	TransactionStatusPartiallyRefunded = "partially_refunded"
+	TransactionStatusReversed          = "reversed"
+	TransactionStatusVoided            = "voided"
+	TransactionStatusExpired           = "expired"
 )
+
+// SettledTransactionStatuses are the statuses of transactions that moved
+// money. A refunded or reversed transaction still moved it; the refund that
+// moved it back is a transaction of its own.
+var SettledTransactionStatuses = []string{
+	TransactionStatusSuccess,
+	TransactionStatusPartiallyRefunded,
+	TransactionStatusReversed,
+}
+
+// CreditAmount returns the amount and currency credited to the recipient.
+func (t *Transaction) CreditAmount() (money.Amount, string) {
+	if t.ToCurrency != "" {
+		return t.ToAmount, t.ToCurrency
+	}
+	return t.Amount, t.Currency
+}
+
+// TransactionFilter narrows a user's transaction history. Empty fields match
+// everything; Currency matches either side of a cross-currency transfer, and
+// From and To bound CreatedAt as [From, To).
+type TransactionFilter struct {
+	Type     string
+	Status   string
+	Currency string
+	From     *time.Time
+	To       *time.Time
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// TransactionLogEntry is one link of the tamper-evident transaction log.
+// Every version of every transaction is appended once it has committed, and
+// each entry's hash covers its content and the hash of the entry before it, so
+// editing, removing or reordering any entry breaks every hash after it.
+// Sequence numbers have no gaps.
+type TransactionLogEntry struct {
+	Sequence      int64     `json:"sequence" gorm:"primaryKey;autoIncrement:false"`
+	TransactionID string    `json:"transaction_id" gorm:"index:idx_transaction_log_entry_transaction_id_sequence"`
+	Operation     string    `json:"operation"`
+	Payload       string    `json:"payload"`
+	PrevHash      string    `json:"prev_hash"`
+	Hash          string    `json:"hash" gorm:"index:idx_transaction_log_entry_hash"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TransactionLogHead is the single row holding the latest entry of the log.
+// The sequencer locks it while appending, which puts every entry in one
+// order.
+type TransactionLogHead struct {
+	ID        int       `gorm:"primaryKey;autoIncrement:false"`
+	Sequence  int64     `json:"sequence"`
+	Hash      string    `json:"hash"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PendingTransactionLogEntry is a version of a transaction that has been
+// written but not yet appended to the log. It is inserted in the database
+// transaction that writes the version, without locking anything, and the
+// sequencer appends pending entries to the log in ID order once they have
+// committed.
+type PendingTransactionLogEntry struct {
+	ID            int64     `json:"id" gorm:"primaryKey"`
+	TransactionID string    `json:"transaction_id" gorm:"index"`
+	Operation     string    `json:"operation"`
+	Payload       string    `json:"payload"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// TransactionLogCheckpoint is a signed statement of the log's head at a point
+// in time. Someone who rewrites the log and recomputes every hash after their
+// edit still cannot produce checkpoints that match without the signing key.
+type TransactionLogCheckpoint struct {
+	Sequence  int64     `json:"sequence" gorm:"primaryKey;autoIncrement:false"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TransactionLogBreak pinpoints where verification of the log failed: the
+// sequence of the first entry that cannot be trusted and why.
+type TransactionLogBreak struct {
+	Sequence      int64  `json:"sequence"`
+	TransactionID string `json:"transaction_id,omitempty"`
+	Reason        string `json:"reason"`
+}
+
+// TransactionLogVerification is the result of walking the whole log. Broken
+// is nil when the log, the transactions and the checkpoints all agree.
+type TransactionLogVerification struct {
+	Entries            int64                `json:"entries"`
+	Transactions       int64                `json:"transactions"`
+	Checkpoints        int                  `json:"checkpoints"`
+	SignaturesVerified bool                 `json:"signatures_verified"`
+	HeadSequence       int64                `json:"head_sequence"`
+	HeadHash           string               `json:"head_hash"`
+	Broken             *TransactionLogBreak `json:"broken,omitempty"`
+}
+
+const (
+	TransactionLogOperationCreate = "create"
+	TransactionLogOperationUpdate = "update"
+	TransactionLogOperationDelete = "delete"
+)
+
+// TransactionLogHeadID is the ID of the only TransactionLogHead row.
+const TransactionLogHeadID = 1
+
+// transactionLogPayload is the content of a transaction that the log hashes.
+// Amounts are minor units and times are UTC to the microsecond, the precision
+// they are stored with, so that a transaction read back from the database
+// gives the same payload as when it was written.
+type transactionLogPayload struct {
+	ID                    string  `json:"id"`
+	FromUserID            string  `json:"from_user_id"`
+	ToUserID              string  `json:"to_user_id"`
+	Amount                int64   `json:"amount"`
+	Currency              string  `json:"currency"`
+	ToAmount              int64   `json:"to_amount"`
+	ToCurrency            string  `json:"to_currency"`
+	ExchangeRate          string  `json:"exchange_rate"`
+	QuoteID               string  `json:"quote_id"`
+	OriginalTransactionID string  `json:"original_transaction_id"`
+	RefundedAmount        int64   `json:"refunded_amount"`
+	BatchID               string  `json:"batch_id"`
+	ParentTransactionID   string  `json:"parent_transaction_id"`
+	Type                  string  `json:"type"`
+	Status                string  `json:"status"`
+	CreatedAt             string  `json:"created_at"`
+	UpdatedAt             string  `json:"updated_at"`
+	DeletedAt             *string `json:"deleted_at"`
+}
+
+// LogPayload returns the canonical form of the transaction that the log
+// hashes. Its hashes are not part of it.
+func (t *Transaction) LogPayload() string {
+	payload := transactionLogPayload{
+		ID:                    t.ID,
+		FromUserID:            t.FromUserID,
+		ToUserID:              t.ToUserID,
+		Amount:                t.Amount.Minor(),
+		Currency:              t.Currency,
+		ToAmount:              t.ToAmount.Minor(),
+		ToCurrency:            t.ToCurrency,
+		ExchangeRate:          t.ExchangeRate,
+		QuoteID:               t.QuoteID,
+		OriginalTransactionID: t.OriginalTransactionID,
+		RefundedAmount:        t.RefundedAmount.Minor(),
+		BatchID:               t.BatchID,
+		ParentTransactionID:   t.ParentTransactionID,
+		Type:                  t.Type,
+		Status:                t.Status,
+		CreatedAt:             logTime(t.CreatedAt),
+		UpdatedAt:             logTime(t.UpdatedAt),
+	}
+	if t.DeletedAt != nil {
+		deletedAt := logTime(*t.DeletedAt)
+		payload.DeletedAt = &deletedAt
+	}
+	// A struct of strings and integers always marshals
+	data, _ := json.Marshal(payload)
+	return string(data)
+}
+
+// TruncateTimes drops the transaction's times to the microsecond, the
+// precision the database keeps, so that the payload logged when it is
+// written matches what is stored.
+func (t *Transaction) TruncateTimes() {
+	t.CreatedAt = t.CreatedAt.Truncate(time.Microsecond)
+	t.UpdatedAt = t.UpdatedAt.Truncate(time.Microsecond)
+	if t.DeletedAt != nil {
+		deletedAt := t.DeletedAt.Truncate(time.Microsecond)
+		t.DeletedAt = &deletedAt
+	}
+}
+
+func logTime(t time.Time) string {
+	return t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+}
+
+// TransactionLogHash returns the hash of a log entry, chained to the hash of
+// the entry before it. The first entry's previous hash is empty.
+func TransactionLogHash(prevHash string, sequence int64, operation, payload string) string {
+	h := sha256.New()
+	h.Write([]byte(prevHash))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(strconv.FormatInt(sequence, 10)))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(operation))
+	h.Write([]byte{'\n'})
+	h.Write([]byte(payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// SignedMessage returns the bytes a checkpoint's signature covers.
+func (c *TransactionLogCheckpoint) SignedMessage() []byte {
+	return []byte(strconv.FormatInt(c.Sequence, 10) + "\n" + c.Hash)
+}
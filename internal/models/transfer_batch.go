@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"wallet/internal/money"
+)
+
+// TransferBatch groups the transfers of one multi-recipient payment. The
+// sender is debited once for TotalAmount and each recipient is credited by
+// its own child Transaction, which points back through BatchID. The batch
+// and all of its transfers commit together or not at all.
+type TransferBatch struct {
+	ID           string        `json:"id"`
+	FromUserID   string        `json:"from_user_id" gorm:"index:idx_transfer_batch_from_user_id"`
+	Currency     string        `json:"currency"`
+	TotalAmount  money.Amount  `json:"total_amount"`
+	Count        int           `json:"count"`
+	Status       string        `json:"status"`
+	Transactions []Transaction `json:"transactions" gorm:"-"`
+	CreatedAt    time.Time     `json:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
@@ -4,11 +4,36 @@ import (
 	"time"
 )
 
+// User is an account holder. Besides their email, a user can be found by an
+// optional phone number (E.164) and a unique handle they choose; both are
+// stored normalized, and left NULL when unset so they stay unique. Plan
+// selects which fee rules apply to them, and Role whether they can use the
+// admin API.
+//
+// Phone only ever holds a verified number. A new number waits in
+// PendingPhone until the user enters the code texted to it, whose SHA-256
+// hash is PhoneCodeHash.
 type User struct {
-	ID        string     `json:"id"`
-	Name      string     `json:"name"`
-	Email     string     `json:"email" gorm:"index:idx_user_email,unique"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ID                 string     `json:"id"`
+	Name               string     `json:"name"`
+	Email              string     `json:"email" gorm:"index:idx_user_email,unique"`
+	Phone              *string    `json:"phone,omitempty" gorm:"index:idx_user_phone,unique"`
+	PendingPhone       *string    `json:"pending_phone,omitempty"`
+	PhoneCodeHash      string     `json:"-"`
+	PhoneCodeExpiresAt *time.Time `json:"-"`
+	PhoneCodeAttempts  int        `json:"-"`
+	Handle             *string    `json:"handle,omitempty" gorm:"index:idx_user_handle,unique"`
+	Plan               string     `json:"plan" gorm:"default:standard"`
+	Role               string     `json:"role" gorm:"default:user"`
+	CreatedAt          time.Time  `json:"created_at"`
+	UpdatedAt          time.Time  `json:"updated_at"`
+	DeletedAt          *time.Time `json:"deleted_at,omitempty"`
 }
+
+// UserPlanStandard is the plan every user starts on.
+const UserPlanStandard = "standard"
+
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
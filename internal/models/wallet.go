@@ -2,13 +2,52 @@ package models
 
 import (
 	"time"
+
+	"wallet/internal/money"
 )
 
+// Wallet holds a user's balance in one currency. Support can freeze a wallet
+// under investigation, stopping money leaving it (frozen_debits) or moving at
+// all (frozen_all), and a closed wallet never moves money again. An empty
+// Status is treated as active.
 type Wallet struct {
-	ID        string     `json:"id"`
-	UserID    string     `json:"user_id" gorm:"index:idx_wallet_user_id"`
-	Balance   float64    `json:"balance"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	ID           string       `json:"id"`
+	UserID       string       `json:"user_id" gorm:"index:idx_wallet_user_id_currency,unique"`
+	Currency     string       `json:"currency" gorm:"index:idx_wallet_user_id_currency,unique"`
+	Balance      money.Amount `json:"balance"`
+	Status       string       `json:"status" gorm:"default:active"`
+	StatusReason string       `json:"status_reason,omitempty"`
+	ClosedAt     *time.Time   `json:"closed_at,omitempty"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	DeletedAt    *time.Time   `json:"deleted_at,omitempty"`
+}
+
+// WalletStatusEvent records a change of a wallet's status, who made it and
+// why.
+type WalletStatusEvent struct {
+	ID         string    `json:"id"`
+	WalletID   string    `json:"wallet_id" gorm:"index:idx_wallet_status_event_wallet_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason"`
+	ActorID    string    `json:"actor_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+const (
+	WalletStatusActive       = "active"
+	WalletStatusFrozenDebits = "frozen_debits"
+	WalletStatusFrozenAll    = "frozen_all"
+	WalletStatusClosed       = "closed"
+)
+
+// CanDebit reports whether money may leave the wallet.
+func (w *Wallet) CanDebit() bool {
+	return w.Status == "" || w.Status == WalletStatusActive
+}
+
+// CanCredit reports whether money may enter the wallet.
+func (w *Wallet) CanCredit() bool {
+	return w.CanDebit() || w.Status == WalletStatusFrozenDebits
 }
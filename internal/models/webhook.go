@@ -0,0 +1,106 @@
+package models
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// OutboxEvent is a domain event written in the same database transaction as
+// the change it describes, so that an event is recorded if and only if the
+// change commits. Payload is the JSON of the event's data, usually the
+// transaction, hold or wallet concerned. DispatchedAt is set once a delivery
+// has been queued for every webhook endpoint subscribed to it.
+type OutboxEvent struct {
+	ID           string     `json:"id"`
+	Type         string     `json:"type"`
+	Payload      string     `json:"-"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"index:idx_outbox_event_dispatched_at_created_at,priority:2"`
+	DispatchedAt *time.Time `json:"dispatched_at,omitempty" gorm:"index:idx_outbox_event_dispatched_at_created_at,priority:1"`
+}
+
+// WebhookEndpoint is a URL that events are delivered to. EventTypes is a
+// comma-separated list of the event types it receives, or empty for all of
+// them. Secret signs every delivery and is only shown when the endpoint is
+// created.
+type WebhookEndpoint struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes string    `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Subscribes reports whether the endpoint receives events of eventType.
+func (e *WebhookEndpoint) Subscribes(eventType string) bool {
+	if e.EventTypes == "" {
+		return true
+	}
+	return slices.Contains(strings.Split(e.EventTypes, ","), eventType)
+}
+
+// WebhookDelivery tracks the delivery of one event to one endpoint. A failed
+// attempt is retried at NextAttemptAt with exponential backoff, until the
+// delivery succeeds or runs out of attempts and is dead-lettered. LeaseUntil
+// is set while a dispatcher is sending the delivery; the dispatcher only
+// records its result if the lease is still the one it took.
+type WebhookDelivery struct {
+	ID             string     `json:"id"`
+	EventID        string     `json:"event_id" gorm:"index:idx_webhook_delivery_event_id_endpoint_id,unique"`
+	EndpointID     string     `json:"endpoint_id" gorm:"index:idx_webhook_delivery_event_id_endpoint_id,unique"`
+	EventType      string     `json:"event_type"`
+	Status         string     `json:"status" gorm:"index:idx_webhook_delivery_status_next_attempt_at,priority:1"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index:idx_webhook_delivery_status_next_attempt_at,priority:2"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	LeaseUntil     *time.Time `json:"-"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+const (
+	WebhookDeliveryStatusPending   = "pending"
+	WebhookDeliveryStatusDelivered = "delivered"
+	WebhookDeliveryStatusDead      = "dead"
+)
+
+const (
+	EventDepositCompleted    = "deposit.completed"
+	EventWithdrawalCompleted = "withdrawal.completed"
+	EventTransferCompleted   = "transfer.completed"
+	EventTransferAuthorized  = "transfer.authorized"
+	EventTransferVoided      = "transfer.voided"
+	EventTransferExpired     = "transfer.expired"
+	EventRefundCompleted     = "refund.completed"
+	EventReversalCompleted   = "reversal.completed"
+	EventFeeCharged          = "fee.charged"
+	EventSweepCompleted      = "sweep.completed"
+	EventInterestPaid        = "interest.paid"
+	EventHoldPlaced          = "hold.placed"
+	EventHoldReleased        = "hold.released"
+	EventHoldExpired         = "hold.expired"
+	EventWalletStatusChanged = "wallet.status_changed"
+)
+
+// EventTypes lists every event type that can be subscribed to.
+var EventTypes = []string{
+	EventDepositCompleted,
+	EventWithdrawalCompleted,
+	EventTransferCompleted,
+	EventTransferAuthorized,
+	EventTransferVoided,
+	EventTransferExpired,
+	EventRefundCompleted,
+	EventReversalCompleted,
+	EventFeeCharged,
+	EventSweepCompleted,
+	EventInterestPaid,
+	EventHoldPlaced,
+	EventHoldReleased,
+	EventHoldExpired,
+	EventWalletStatusChanged,
+}
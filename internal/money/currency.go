@@ -0,0 +1,84 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// DefaultCurrency is used for wallets and requests that do not name a currency.
+const DefaultCurrency = "USD"
+
+var ErrUnsupportedCurrency = errors.New("unsupported currency")
+
+// Currency is an ISO-4217 currency and the number of decimal places its
+// minor unit allows. Currencies with more decimal places than Scale cannot be
+// represented exactly by Amount and are therefore not supported.
+type Currency struct {
+	Code     string
+	Exponent int
+}
+
+var currencies = map[string]Currency{
+	"AUD": {Code: "AUD", Exponent: 2},
+	"CAD": {Code: "CAD", Exponent: 2},
+	"CHF": {Code: "CHF", Exponent: 2},
+	"CNY": {Code: "CNY", Exponent: 2},
+	"EUR": {Code: "EUR", Exponent: 2},
+	"GBP": {Code: "GBP", Exponent: 2},
+	"HKD": {Code: "HKD", Exponent: 2},
+	"IDR": {Code: "IDR", Exponent: 2},
+	"INR": {Code: "INR", Exponent: 2},
+	"JPY": {Code: "JPY", Exponent: 0},
+	"KRW": {Code: "KRW", Exponent: 0},
+	"MYR": {Code: "MYR", Exponent: 2},
+	"NZD": {Code: "NZD", Exponent: 2},
+	"PHP": {Code: "PHP", Exponent: 2},
+	"SGD": {Code: "SGD", Exponent: 2},
+	"THB": {Code: "THB", Exponent: 2},
+	"USD": {Code: "USD", Exponent: 2},
+	"VND": {Code: "VND", Exponent: 0},
+}
+
+// LookupCurrency returns the supported currency for an ISO-4217 code.
+// Codes are matched case-insensitively.
+func LookupCurrency(code string) (Currency, error) {
+	currency, ok := currencies[strings.ToUpper(code)]
+	if !ok {
+		return Currency{}, fmt.Errorf("%w: %q", ErrUnsupportedCurrency, code)
+	}
+	return currency, nil
+}
+
+// Validate checks that the amount has no more decimal places than the
+// currency's minor unit allows, e.g. JPY amounts must be whole yen.
+func (c Currency) Validate(a Amount) error {
+	if int64(a)%c.step() != 0 {
+		return fmt.Errorf("%s amounts cannot have more than %d decimal places", c.Code, c.Exponent)
+	}
+	return nil
+}
+
+// Round rounds the amount to the currency's minor unit, e.g. to whole yen.
+func (c Currency) Round(a Amount, mode RoundingMode) Amount {
+	step := c.step()
+	return Amount(Round(big.NewRat(int64(a), step), mode) * step)
+}
+
+// FromRat rounds an exact number of major units to the currency's minor unit
+// in a single step, e.g. to whole yen.
+func (c Currency) FromRat(r *big.Rat, mode RoundingMode) Amount {
+	step := c.step()
+	minor := Round(new(big.Rat).Mul(r, big.NewRat(unit/step, 1)), mode)
+	return Amount(minor * step)
+}
+
+// step is the number of Amount minor units in one minor unit of the currency.
+func (c Currency) step() int64 {
+	step := int64(1)
+	for i := c.Exponent; i < Scale; i++ {
+		step *= 10
+	}
+	return step
+}
@@ -0,0 +1,241 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places held by an Amount.
+const Scale = 2
+
+// unit is the number of minor units in one major unit (10^Scale).
+const unit = 100
+
+var (
+	ErrInvalidAmount   = errors.New("invalid amount")
+	ErrExcessPrecision = fmt.Errorf("amount has more than %d decimal places", Scale)
+	ErrOverflow        = errors.New("amount out of range")
+)
+
+// Amount is a monetary value stored as an integer number of minor units
+// (e.g. cents). It is never converted through floating point.
+type Amount int64
+
+// Zero is the zero amount.
+const Zero Amount = 0
+
+// FromMinor returns the Amount for the given number of minor units.
+func FromMinor(minor int64) Amount {
+	return Amount(minor)
+}
+
+// FromMajor returns the Amount for the given number of whole major units.
+func FromMajor(major int64) Amount {
+	return Amount(major * unit)
+}
+
+// Parse parses a decimal string such as "10", "10.5" or "-0.05".
+// Strings with more than Scale decimal places are rejected rather than rounded.
+func Parse(s string) (Amount, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, ErrInvalidAmount
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, hasPoint := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, ErrInvalidAmount
+	}
+	if hasPoint && fracPart == "" {
+		return 0, ErrInvalidAmount
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return 0, ErrInvalidAmount
+	}
+
+	// Trailing zeros never add precision, so "1.500" is accepted as 1.50.
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > Scale {
+		return 0, ErrExcessPrecision
+	}
+	fracPart += strings.Repeat("0", Scale-len(fracPart))
+
+	if intPart == "" {
+		intPart = "0"
+	}
+	major, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || major > math.MaxInt64/unit {
+		return 0, ErrOverflow
+	}
+	minor, _ := strconv.ParseInt(fracPart, 10, 64)
+
+	value := major*unit + minor
+	if value < 0 {
+		return 0, ErrOverflow
+	}
+	if negative {
+		value = -value
+	}
+	return Amount(value), nil
+}
+
+// MustParse is like Parse but panics on error. Intended for constants and tests.
+func MustParse(s string) Amount {
+	a, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Minor returns the amount as an integer number of minor units.
+func (a Amount) Minor() int64 {
+	return int64(a)
+}
+
+// String formats the amount with exactly Scale decimal places.
+func (a Amount) String() string {
+	v := int64(a)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+	}
+	// Work in uint64 so that math.MinInt64 does not overflow on negation.
+	abs := uint64(v)
+	if v < 0 {
+		abs = uint64(-(v + 1)) + 1
+	}
+	return fmt.Sprintf("%s%d.%0*d", sign, abs/unit, Scale, abs%unit)
+}
+
+func (a Amount) Add(b Amount) Amount {
+	return a + b
+}
+
+func (a Amount) Sub(b Amount) Amount {
+	return a - b
+}
+
+func (a Amount) Neg() Amount {
+	return -a
+}
+
+func (a Amount) Abs() Amount {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+func (a Amount) IsZero() bool {
+	return a == 0
+}
+
+func (a Amount) IsPositive() bool {
+	return a > 0
+}
+
+func (a Amount) IsNegative() bool {
+	return a < 0
+}
+
+// Rat returns the amount in major units as an exact rational number.
+func (a Amount) Rat() *big.Rat {
+	return big.NewRat(int64(a), unit)
+}
+
+// Mul multiplies the amount by r and rounds the result back to minor units
+// using the given rounding mode.
+func (a Amount) Mul(r *big.Rat, mode RoundingMode) Amount {
+	product := new(big.Rat).Mul(new(big.Rat).SetInt64(int64(a)), r)
+	return Amount(Round(product, mode))
+}
+
+// MarshalJSON encodes the amount as a decimal string, e.g. "10.50", so that
+// clients never have to go through a binary float to read it.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON accepts either a decimal string or a bare JSON number. Numbers
+// are parsed from their literal text, so excess precision is still rejected.
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else if strings.ContainsAny(s, "eE") {
+		// Exponent notation is valid JSON but never a sensible way to send money.
+		return ErrInvalidAmount
+	}
+
+	parsed, err := Parse(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+// Value stores the amount as a BIGINT of minor units.
+func (a Amount) Value() (driver.Value, error) {
+	return int64(a), nil
+}
+
+// Scan reads an amount stored as a BIGINT of minor units.
+func (a *Amount) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case int64:
+		*a = Amount(v)
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return fmt.Errorf("money: cannot scan %q: %w", v, err)
+		}
+		*a = Amount(n)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("money: cannot scan %q: %w", v, err)
+		}
+		*a = Amount(n)
+	case nil:
+		*a = 0
+	default:
+		return fmt.Errorf("money: cannot scan %T", src)
+	}
+	return nil
+}
+
+// GormDataType tells GORM to create amount columns as BIGINT.
+func (Amount) GormDataType() string {
+	return "bigint"
+}
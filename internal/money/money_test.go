@@ -0,0 +1,142 @@
+package money
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Amount
+		err  error
+	}{
+		{in: "10", want: 1000},
+		{in: "10.5", want: 1050},
+		{in: "10.50", want: 1050},
+		{in: "1.500", want: 150},
+		{in: "0.01", want: 1},
+		{in: ".25", want: 25},
+		{in: "-0.05", want: -5},
+		{in: "0.001", err: ErrExcessPrecision},
+		{in: "10.", err: ErrInvalidAmount},
+		{in: "abc", err: ErrInvalidAmount},
+		{in: "", err: ErrInvalidAmount},
+		{in: "1e3", err: ErrInvalidAmount},
+		{in: "92233720368547758.08", err: ErrOverflow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Parse(tt.in)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAmount_String(t *testing.T) {
+	assert.Equal(t, "0.00", Zero.String())
+	assert.Equal(t, "10.05", FromMinor(1005).String())
+	assert.Equal(t, "-0.05", FromMinor(-5).String())
+}
+
+func TestAmount_RepeatedAddition(t *testing.T) {
+	total := Zero
+	for i := 0; i < 10; i++ {
+		total = total.Add(MustParse("0.1"))
+	}
+	assert.Equal(t, FromMajor(1), total)
+}
+
+func TestAmount_JSON(t *testing.T) {
+	t.Run("encodes as string", func(t *testing.T) {
+		data, err := json.Marshal(struct {
+			Amount Amount `json:"amount"`
+		}{MustParse("12.3")})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"amount":"12.30"}`, string(data))
+	})
+
+	t.Run("decodes strings and numbers", func(t *testing.T) {
+		var req struct {
+			A Amount `json:"a"`
+			B Amount `json:"b"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(`{"a":"0.10","b":800}`), &req))
+		assert.Equal(t, FromMinor(10), req.A)
+		assert.Equal(t, FromMajor(800), req.B)
+	})
+
+	t.Run("rejects excess precision", func(t *testing.T) {
+		var req struct {
+			A Amount `json:"a"`
+		}
+		err := json.Unmarshal([]byte(`{"a":0.333}`), &req)
+		assert.ErrorIs(t, err, ErrExcessPrecision)
+	})
+}
+
+func TestAmount_Mul(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount Amount
+		rate   *big.Rat
+		mode   RoundingMode
+		want   Amount
+	}{
+		{"half even rounds tie down", FromMinor(25), big.NewRat(1, 10), RoundHalfEven, FromMinor(2)},
+		{"half even rounds tie up", FromMinor(35), big.NewRat(1, 10), RoundHalfEven, FromMinor(4)},
+		{"half up", FromMinor(25), big.NewRat(1, 10), RoundHalfUp, FromMinor(3)},
+		{"down", FromMinor(29), big.NewRat(1, 10), RoundDown, FromMinor(2)},
+		{"up", FromMinor(21), big.NewRat(1, 10), RoundUp, FromMinor(3)},
+		{"negative half up", FromMinor(-25), big.NewRat(1, 10), RoundHalfUp, FromMinor(-3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.amount.Mul(tt.rate, tt.mode))
+		})
+	}
+}
+
+func TestCurrency_Validate(t *testing.T) {
+	usd, err := LookupCurrency("usd")
+	assert.NoError(t, err)
+	assert.NoError(t, usd.Validate(MustParse("10.25")))
+
+	jpy, err := LookupCurrency("JPY")
+	assert.NoError(t, err)
+	assert.NoError(t, jpy.Validate(MustParse("1500")))
+	assert.Error(t, jpy.Validate(MustParse("1500.50")))
+
+	_, err = LookupCurrency("XYZ")
+	assert.ErrorIs(t, err, ErrUnsupportedCurrency)
+}
+
+func TestCurrency_Round(t *testing.T) {
+	jpy, _ := LookupCurrency("JPY")
+	assert.Equal(t, MustParse("1500"), jpy.Round(MustParse("1500.49"), RoundHalfUp))
+	assert.Equal(t, MustParse("1501"), jpy.Round(MustParse("1500.50"), RoundHalfUp))
+	assert.Equal(t, MustParse("1500"), jpy.Round(MustParse("1500.99"), RoundDown))
+
+	usd, _ := LookupCurrency("USD")
+	assert.Equal(t, MustParse("10.99"), usd.Round(MustParse("10.99"), RoundDown))
+}
+
+func TestCurrency_FromRat(t *testing.T) {
+	jpy, _ := LookupCurrency("JPY")
+	assert.Equal(t, MustParse("0"), jpy.FromRat(big.NewRat(4995, 10000), RoundHalfEven))
+	assert.Equal(t, MustParse("2"), jpy.FromRat(big.NewRat(3, 2), RoundHalfEven))
+
+	usd, _ := LookupCurrency("USD")
+	assert.Equal(t, MustParse("0.05"), usd.FromRat(big.NewRat(548, 10000), RoundHalfEven))
+	assert.Equal(t, MustParse("0.12"), usd.FromRat(big.NewRat(125, 1000), RoundHalfEven))
+}
@@ -0,0 +1,62 @@
+package money
+
+import (
+	"math/big"
+)
+
+// RoundingMode controls how fractional minor units are resolved when an
+// amount is scaled by a rate (fees, interest, FX conversion).
+type RoundingMode int
+
+const (
+	// RoundHalfEven rounds to the nearest value, ties to even (banker's rounding).
+	RoundHalfEven RoundingMode = iota
+	// RoundHalfUp rounds to the nearest value, ties away from zero.
+	RoundHalfUp
+	// RoundDown truncates towards zero.
+	RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp
+)
+
+// Round rounds r to an integer using the given mode.
+func Round(r *big.Rat, mode RoundingMode) int64 {
+	num := new(big.Int).Set(r.Num())
+	den := r.Denom()
+
+	negative := num.Sign() < 0
+	num.Abs(num)
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		// Compare twice the remainder against the denominator to locate the half.
+		half := new(big.Int).Lsh(rem, 1).Cmp(den)
+		switch mode {
+		case RoundHalfEven:
+			if half > 0 || (half == 0 && quo.Bit(0) == 1) {
+				quo.Add(quo, big.NewInt(1))
+			}
+		case RoundHalfUp:
+			if half >= 0 {
+				quo.Add(quo, big.NewInt(1))
+			}
+		case RoundUp:
+			quo.Add(quo, big.NewInt(1))
+		case RoundDown:
+		}
+	}
+
+	if negative {
+		quo.Neg(quo)
+	}
+	return quo.Int64()
+}
+
+// ParseRate parses a decimal rate such as "0.015" or "1.0842" exactly.
+func ParseRate(s string) (*big.Rat, error) {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, ErrInvalidAmount
+	}
+	return r, nil
+}
@@ -0,0 +1,16 @@
+package pubsub
+
+// PubSub delivers every message published on a channel to the current
+// subscribers of that channel. Delivery is best effort: a subscriber that
+// falls behind, or is not connected when a message is published, misses it.
+type PubSub interface {
+	Publish(channel, message string) error
+	Subscribe(channel string) (Subscription, error)
+}
+
+// Subscription receives the messages published on a channel until it is
+// closed, which also closes Messages.
+type Subscription interface {
+	Messages() <-chan string
+	Close()
+}
@@ -0,0 +1,75 @@
+package pubsub
+
+import "sync"
+
+// subscriptionBuffer is how many messages a subscriber can fall behind by
+// before further messages to it are dropped.
+const subscriptionBuffer = 64
+
+// InMemoryPubSub delivers messages within the process. It only reaches the
+// subscribers of a single instance of the service.
+type InMemoryPubSub struct {
+	mu          sync.Mutex
+	subscribers map[string]map[*inMemorySubscription]struct{}
+}
+
+func NewInMemoryPubSub() *InMemoryPubSub {
+	return &InMemoryPubSub{
+		subscribers: make(map[string]map[*inMemorySubscription]struct{}),
+	}
+}
+
+// Publish hands message to every subscriber of channel without waiting for
+// any of them.
+func (p *InMemoryPubSub) Publish(channel, message string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for subscription := range p.subscribers[channel] {
+		select {
+		case subscription.messages <- message:
+		default:
+		}
+	}
+	return nil
+}
+
+func (p *InMemoryPubSub) Subscribe(channel string) (Subscription, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	subscription := &inMemorySubscription{
+		pubsub:   p,
+		channel:  channel,
+		messages: make(chan string, subscriptionBuffer),
+	}
+	if p.subscribers[channel] == nil {
+		p.subscribers[channel] = make(map[*inMemorySubscription]struct{})
+	}
+	p.subscribers[channel][subscription] = struct{}{}
+	return subscription, nil
+}
+
+type inMemorySubscription struct {
+	pubsub   *InMemoryPubSub
+	channel  string
+	messages chan string
+	once     sync.Once
+}
+
+func (s *inMemorySubscription) Messages() <-chan string {
+	return s.messages
+}
+
+func (s *inMemorySubscription) Close() {
+	s.once.Do(func() {
+		s.pubsub.mu.Lock()
+		defer s.pubsub.mu.Unlock()
+
+		delete(s.pubsub.subscribers[s.channel], s)
+		if len(s.pubsub.subscribers[s.channel]) == 0 {
+			delete(s.pubsub.subscribers, s.channel)
+		}
+		close(s.messages)
+	})
+}
@@ -0,0 +1,90 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receive returns the messages that can be read from subscription without
+// waiting.
+func receive(subscription Subscription) []string {
+	var messages []string
+	for {
+		select {
+		case message, ok := <-subscription.Messages():
+			if !ok {
+				return messages
+			}
+			messages = append(messages, message)
+		default:
+			return messages
+		}
+	}
+}
+
+func TestInMemoryPubSub(t *testing.T) {
+	t.Run("fans a message out to every subscriber of its channel", func(t *testing.T) {
+		p := NewInMemoryPubSub()
+		first, err := p.Subscribe("changes")
+		require.NoError(t, err)
+		defer first.Close()
+		second, err := p.Subscribe("changes")
+		require.NoError(t, err)
+		defer second.Close()
+		other, err := p.Subscribe("other")
+		require.NoError(t, err)
+		defer other.Close()
+
+		assert.NoError(t, p.Publish("changes", "user1"))
+		assert.NoError(t, p.Publish("changes", "user2"))
+
+		assert.Equal(t, []string{"user1", "user2"}, receive(first))
+		assert.Equal(t, []string{"user1", "user2"}, receive(second))
+		assert.Empty(t, receive(other))
+	})
+
+	t.Run("drops messages to a slow subscriber without blocking the others", func(t *testing.T) {
+		p := NewInMemoryPubSub()
+		slow, err := p.Subscribe("changes")
+		require.NoError(t, err)
+		defer slow.Close()
+		fast, err := p.Subscribe("changes")
+		require.NoError(t, err)
+		defer fast.Close()
+
+		published := make(chan struct{})
+		var received []string
+		go func() {
+			defer close(published)
+			for i := 0; i < subscriptionBuffer*2; i++ {
+				p.Publish("changes", "user1")
+				received = append(received, <-fast.Messages())
+			}
+		}()
+		select {
+		case <-published:
+		case <-time.After(time.Second):
+			t.Fatal("publishing waited for a slow subscriber")
+		}
+
+		assert.Len(t, received, subscriptionBuffer*2)
+		assert.Len(t, receive(slow), subscriptionBuffer)
+	})
+
+	t.Run("stops delivering to a closed subscription", func(t *testing.T) {
+		p := NewInMemoryPubSub()
+		subscription, err := p.Subscribe("changes")
+		require.NoError(t, err)
+
+		subscription.Close()
+		subscription.Close()
+
+		assert.NoError(t, p.Publish("changes", "user1"))
+		_, ok := <-subscription.Messages()
+		assert.False(t, ok)
+		assert.Empty(t, p.subscribers)
+	})
+}
@@ -0,0 +1,91 @@
+package pubsub
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"gorm.io/gorm"
+)
+
+// listenRetryDelay is how long to wait before reconnecting a listener whose
+// connection failed.
+const listenRetryDelay = 5 * time.Second
+
+// PostgresPubSub delivers messages with Postgres NOTIFY, so that they reach
+// the subscribers of every instance of the service connected to the same
+// database. Each instance holds one connection per channel it listens on,
+// and fans the notifications out to its local subscribers. Messages sent
+// while a listener is reconnecting are missed.
+type PostgresPubSub struct {
+	db         *gorm.DB
+	dsn        string
+	local      *InMemoryPubSub
+	retryDelay time.Duration
+
+	mu        sync.Mutex
+	listening map[string]bool
+}
+
+// NewPostgresPubSub publishes through db and listens with dedicated
+// connections to dsn.
+func NewPostgresPubSub(db *gorm.DB, dsn string) *PostgresPubSub {
+	return &PostgresPubSub{
+		db:         db,
+		dsn:        dsn,
+		local:      NewInMemoryPubSub(),
+		retryDelay: listenRetryDelay,
+		listening:  make(map[string]bool),
+	}
+}
+
+// Publish sends message to the listeners of channel. Postgres limits a
+// message to 8000 bytes.
+func (p *PostgresPubSub) Publish(channel, message string) error {
+	return p.db.Exec("SELECT pg_notify(?, ?)", channel, message).Error
+}
+
+// Subscribe starts listening on channel the first time it is subscribed to.
+func (p *PostgresPubSub) Subscribe(channel string) (Subscription, error) {
+	p.mu.Lock()
+	if !p.listening[channel] {
+		p.listening[channel] = true
+		go p.listen(channel)
+	}
+	p.mu.Unlock()
+
+	return p.local.Subscribe(channel)
+}
+
+// listen relays the notifications on channel to local subscribers for the
+// life of the process, reconnecting whenever the connection fails.
+func (p *PostgresPubSub) listen(channel string) {
+	for {
+		if err := p.relay(channel); err != nil {
+			log.Printf("pubsub: listening on %s: %v", channel, err)
+		}
+		time.Sleep(p.retryDelay)
+	}
+}
+
+func (p *PostgresPubSub) relay(channel string) error {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, p.dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		return err
+	}
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		p.local.Publish(notification.Channel, notification.Payload)
+	}
+}
@@ -0,0 +1,83 @@
+package pubsub
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupPostgres connects to the database named by TEST_DATABASE_DSN. NOTIFY
+// can only be exercised against a real Postgres, so the test is skipped when
+// the variable is not set.
+func setupPostgres(t *testing.T) (*gorm.DB, string) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	return db, dsn
+}
+
+// publishUntilReceived publishes message until subscription receives it, as
+// the listener connects in the background.
+func publishUntilReceived(t *testing.T, p *PostgresPubSub, channel, message string, subscription Subscription) {
+	assert.Eventually(t, func() bool {
+		assert.NoError(t, p.Publish(channel, message))
+		select {
+		case received := <-subscription.Messages():
+			return received == message
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, time.Millisecond)
+}
+
+func TestPostgresPubSub(t *testing.T) {
+	db, dsn := setupPostgres(t)
+	p := NewPostgresPubSub(db, dsn)
+	p.retryDelay = 10 * time.Millisecond
+
+	// A channel of its own, so that runs against the same database do not
+	// see each other's messages
+	channel := "pubsub_test_" + uuid.New().String()[:8]
+	first, err := p.Subscribe(channel)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := p.Subscribe(channel)
+	require.NoError(t, err)
+	defer second.Close()
+
+	t.Run("delivers a notification to every local subscriber", func(t *testing.T) {
+		publishUntilReceived(t, p, channel, "user1", first)
+		select {
+		case message := <-second.Messages():
+			assert.Equal(t, "user1", message)
+		case <-time.After(time.Second):
+			t.Error("the second subscriber missed the notification")
+		}
+	})
+
+	t.Run("reconnects a listener whose connection was terminated", func(t *testing.T) {
+		var terminated []bool
+		require.NoError(t, db.Raw(
+			"SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query = ? AND pid <> pg_backend_pid()",
+			`LISTEN "`+channel+`"`,
+		).Scan(&terminated).Error)
+		assert.Equal(t, []bool{true}, terminated)
+
+		receive(first)
+		receive(second)
+		publishUntilReceived(t, p, channel, "user2", first)
+	})
+}
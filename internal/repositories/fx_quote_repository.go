@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type fxQuoteRepository struct {
+	db *gorm.DB
+}
+
+func NewFXQuoteRepository(db *gorm.DB) FXQuoteRepository {
+	return &fxQuoteRepository{db: db}
+}
+
+func (r *fxQuoteRepository) Create(quote *models.FXQuote) error {
+	return r.db.Create(quote).Error
+}
+
+func (r *fxQuoteRepository) FindByID(id string) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	if err := r.db.Where("id = ?", id).First(&quote).Error; err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// FindByIDForUpdate loads the quote with a row lock so that it can only be
+// executed once.
+func (r *fxQuoteRepository) FindByIDForUpdate(id string) (*models.FXQuote, error) {
+	var quote models.FXQuote
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&quote).Error; err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+func (r *fxQuoteRepository) Update(quote *models.FXQuote) error {
+	return r.db.Save(quote).Error
+}
+
+func (r *fxQuoteRepository) WithTx(tx interface{}) FXQuoteRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &fxQuoteRepository{db: txDB}
+}
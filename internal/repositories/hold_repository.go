@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type holdRepository struct {
+	db *gorm.DB
+}
+
+func NewHoldRepository(db *gorm.DB) HoldRepository {
+	return &holdRepository{db: db}
+}
+
+func (r *holdRepository) Create(hold *models.Hold) error {
+	return r.db.Create(hold).Error
+}
+
+func (r *holdRepository) FindByIDForUpdate(id string) (*models.Hold, error) {
+	var hold models.Hold
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&hold).Error; err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *holdRepository) FindByTransactionIDForUpdate(transactionID string) (*models.Hold, error) {
+	var hold models.Hold
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("transaction_id = ?", transactionID).First(&hold).Error; err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (r *holdRepository) Update(hold *models.Hold) error {
+	return r.db.Save(hold).Error
+}
+
+// SumActiveByWalletID returns the total of a wallet's holds that are active
+// and not yet past their expiry. Holds that have expired but not yet been
+// swept no longer count against the wallet.
+func (r *holdRepository) SumActiveByWalletID(walletID string, now time.Time) (money.Amount, error) {
+	var held money.Amount
+	err := r.db.Model(&models.Hold{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("wallet_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)", walletID, models.HoldStatusActive, now).
+		Scan(&held).Error
+	return held, err
+}
+
+// FindExpired returns up to limit active holds whose expiry has passed.
+func (r *holdRepository) FindExpired(now time.Time, limit int) ([]models.Hold, error) {
+	var holds []models.Hold
+	err := r.db.Where("status = ? AND expires_at <= ?", models.HoldStatusActive, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&holds).Error
+	return holds, err
+}
+
+func (r *holdRepository) WithTx(tx interface{}) HoldRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &holdRepository{db: txDB}
+}
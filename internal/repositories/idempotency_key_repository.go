@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type idempotencyKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) IdempotencyKeyRepository {
+	return &idempotencyKeyRepository{db: db}
+}
+
+// CreateIfNotExists inserts the key unless the user already has one with the
+// same value. In Postgres a concurrent insert of the same key blocks until the
+// other transaction finishes, so a false result always means the existing row
+// has been committed.
+func (r *idempotencyKeyRepository) CreateIfNotExists(key *models.IdempotencyKey) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *idempotencyKeyRepository) FindByUserIDAndKey(userID, key string) (*models.IdempotencyKey, error) {
+	var idempotencyKey models.IdempotencyKey
+	if err := r.db.Where("user_id = ? AND key = ?", userID, key).First(&idempotencyKey).Error; err != nil {
+		return nil, err
+	}
+	return &idempotencyKey, nil
+}
+
+func (r *idempotencyKeyRepository) Update(key *models.IdempotencyKey) error {
+	return r.db.Save(key).Error
+}
+
+// DeleteExpired removes keys that expired before the given time. When userID
+// and key are set only that key is removed.
+func (r *idempotencyKeyRepository) DeleteExpired(userID, key string, before time.Time) (int64, error) {
+	query := r.db.Where("expires_at < ?", before)
+	if userID != "" {
+		query = query.Where("user_id = ? AND key = ?", userID, key)
+	}
+	result := query.Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
+
+func (r *idempotencyKeyRepository) WithTx(tx interface{}) IdempotencyKeyRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &idempotencyKeyRepository{db: txDB}
+}
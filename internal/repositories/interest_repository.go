@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type interestRepository struct {
+	db *gorm.DB
+}
+
+func NewInterestRepository(db *gorm.DB) InterestRepository {
+	return &interestRepository{db: db}
+}
+
+// LastRun returns the latest day the accrual job has finished.
+func (r *interestRepository) LastRun() (*models.InterestRun, error) {
+	var run models.InterestRun
+	if err := r.db.Order("date DESC").First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *interestRepository) CreateRun(run *models.InterestRun) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(run).Error
+}
+
+// CreateAccrual stores a day's accrual for a wallet and reports whether it was
+// new. A wallet that has already accrued that day is left untouched.
+func (r *interestRepository) CreateAccrual(accrual *models.InterestAccrual) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(accrual)
+	return result.RowsAffected > 0, result.Error
+}
+
+// FindUnpostedWallets returns the wallet, user and currency of every wallet
+// with interest accrued up to throughDate that has not been paid yet.
+func (r *interestRepository) FindUnpostedWallets(throughDate string) ([]models.InterestAccrual, error) {
+	var wallets []models.InterestAccrual
+	err := r.db.Model(&models.InterestAccrual{}).
+		Distinct("wallet_id", "user_id", "currency").
+		Where("posted_at IS NULL AND date <= ?", throughDate).
+		Order("wallet_id").
+		Find(&wallets).Error
+	return wallets, err
+}
+
+func (r *interestRepository) FindUnpostedByWalletID(walletID, throughDate string) ([]models.InterestAccrual, error) {
+	var accruals []models.InterestAccrual
+	err := r.db.Where("wallet_id = ? AND posted_at IS NULL AND date <= ?", walletID, throughDate).
+		Order("date").
+		Find(&accruals).Error
+	return accruals, err
+}
+
+// MarkPosted records the interest transaction that paid out the accruals.
+func (r *interestRepository) MarkPosted(ids []string, transactionID string, postedAt time.Time) error {
+	return r.db.Model(&models.InterestAccrual{}).
+		Where("id IN ?", ids).
+		Updates(map[string]interface{}{"transaction_id": transactionID, "posted_at": postedAt}).Error
+}
+
+func (r *interestRepository) WithTx(tx interface{}) InterestRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &interestRepository{db: txDB}
+}
+
+// DB returns the underlying GORM DB instance
+func (r *interestRepository) DB() *gorm.DB {
+	return r.db
+}
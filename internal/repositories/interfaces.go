@@ -1,7 +1,10 @@
 package repositories
 
 import (
+	"time"
+
 	"wallet/internal/models"
+	"wallet/internal/money"
 
 	"gorm.io/gorm"
 )
@@ -9,6 +12,8 @@ import (
 type UserRepository interface {
 	Create(user *models.User) error
 	FindByEmail(email string) (*models.User, error)
+	FindByPhone(phone string) (*models.User, error)
+	FindByHandle(handle string) (*models.User, error)
 	FindByID(id string) (*models.User, error)
 	Update(user *models.User) error
 	Delete(id string) error
@@ -24,17 +29,190 @@ type UserTokenRepository interface {
 
 type WalletRepository interface {
 	Create(wallet *models.Wallet) error
-	FindByUserID(userID string) (*models.Wallet, error)
+	CreateIfMissing(wallet *models.Wallet) error
+	FindByID(id string) (*models.Wallet, error)
+	FindByIDForUpdate(id string) (*models.Wallet, error)
+	FindByUserID(userID, currency string) (*models.Wallet, error)
+	FindByUserIDForUpdate(userID, currency string) (*models.Wallet, error)
+	ListByUserID(userID string) ([]models.Wallet, error)
+	FindByCurrency(currency, afterID string, limit int) ([]models.Wallet, error)
+	FindAll(afterID string, limit int) ([]models.Wallet, error)
 	Update(wallet *models.Wallet) error
 	Delete(id string) error
+	CreateStatusEvent(event *models.WalletStatusEvent) error
+	FindStatusEvents(walletID string) ([]models.WalletStatusEvent, error)
 	WithTx(tx interface{}) WalletRepository
 	DB() *gorm.DB
 }
 
 type TransactionRepository interface {
 	Create(transaction *models.Transaction) error
+	FindByID(id string) (*models.Transaction, error)
+	FindByIDForUpdate(id string) (*models.Transaction, error)
+	FindByIDs(ids []string) ([]models.Transaction, error)
 	FindByUserID(userID string, page, pageSize int, transactionType, status string) ([]models.Transaction, error)
+	EachByUserID(userID string, filter models.TransactionFilter, fn func(transaction *models.Transaction) error) error
+	Each(fn func(transaction *models.Transaction) error) error
+	FindByBatchID(batchID string) ([]models.Transaction, error)
+	SumOutgoingSince(userID, transactionType, currency string, since time.Time) (money.Amount, int, error)
+	SumSettled(userID, currency string) (money.Amount, int, error)
 	Update(transaction *models.Transaction) error
 	Delete(id string) error
+	CreateBatch(batch *models.TransferBatch) error
+	FindBatchByID(id string) (*models.TransferBatch, error)
 	WithTx(tx interface{}) TransactionRepository
 }
+
+type IdempotencyKeyRepository interface {
+	CreateIfNotExists(key *models.IdempotencyKey) (bool, error)
+	FindByUserIDAndKey(userID, key string) (*models.IdempotencyKey, error)
+	Update(key *models.IdempotencyKey) error
+	DeleteExpired(userID, key string, before time.Time) (int64, error)
+	WithTx(tx interface{}) IdempotencyKeyRepository
+}
+
+type LedgerRepository interface {
+	EnsureAccounts(accounts []models.LedgerAccount) error
+	CreatePostings(postings []models.LedgerPosting) error
+	FindPostingsByTransactionID(transactionID string) ([]models.LedgerPosting, error)
+	FindPostingsByAccount(accountCode string, from, to time.Time) ([]models.LedgerPosting, error)
+	LedgerStart() (time.Time, error)
+	BalanceOf(accountCode string) (money.Amount, error)
+	BalanceAsOf(accountCode string, at time.Time) (money.Amount, error)
+	DailyTotals(accountCode string, from, to time.Time) (map[string]money.Amount, error)
+	FindAccountsPostedBetween(from, to time.Time) ([]string, error)
+	CreateCheckpoint(checkpoint *models.BalanceCheckpoint) error
+	LastCheckpointRun() (*models.BalanceCheckpointRun, error)
+	CreateCheckpointRun(run *models.BalanceCheckpointRun) error
+	WithTx(tx interface{}) LedgerRepository
+}
+
+type FXQuoteRepository interface {
+	Create(quote *models.FXQuote) error
+	FindByID(id string) (*models.FXQuote, error)
+	FindByIDForUpdate(id string) (*models.FXQuote, error)
+	Update(quote *models.FXQuote) error
+	WithTx(tx interface{}) FXQuoteRepository
+}
+
+type HoldRepository interface {
+	Create(hold *models.Hold) error
+	FindByIDForUpdate(id string) (*models.Hold, error)
+	FindByTransactionIDForUpdate(transactionID string) (*models.Hold, error)
+	Update(hold *models.Hold) error
+	SumActiveByWalletID(walletID string, now time.Time) (money.Amount, error)
+	FindExpired(now time.Time, limit int) ([]models.Hold, error)
+	WithTx(tx interface{}) HoldRepository
+}
+
+type ScheduleRepository interface {
+	Create(schedule *models.Schedule) error
+	FindByID(id string) (*models.Schedule, error)
+	FindByIDForUpdate(id string) (*models.Schedule, error)
+	FindByUserID(userID string) ([]models.Schedule, error)
+	FindDue(now time.Time, limit int) ([]models.Schedule, error)
+	Update(schedule *models.Schedule) error
+	CreateExecution(execution *models.ScheduleExecution) error
+	FindExecutionsByScheduleID(scheduleID string) ([]models.ScheduleExecution, error)
+	DB() *gorm.DB
+	WithTx(tx interface{}) ScheduleRepository
+}
+
+type PaymentRequestRepository interface {
+	Create(request *models.PaymentRequest) error
+	FindByIDForUpdate(id string) (*models.PaymentRequest, error)
+	FindByPayerID(payerID, status string) ([]models.PaymentRequest, error)
+	FindByRequesterID(requesterID, status string) ([]models.PaymentRequest, error)
+	Update(request *models.PaymentRequest) error
+	ExpirePending(now time.Time) (int64, error)
+	DB() *gorm.DB
+	WithTx(tx interface{}) PaymentRequestRepository
+}
+
+type PayoutRepository interface {
+	CreateJob(job *models.PayoutJob) error
+	CreateItems(items []models.PayoutItem) error
+	FindJobByID(id string) (*models.PayoutJob, error)
+	FindJobByIDForUpdate(id string) (*models.PayoutJob, error)
+	FindJobsByUserID(userID string) ([]models.PayoutJob, error)
+	FindDueJobs(now time.Time, limit int) ([]models.PayoutJob, error)
+	UpdateJob(job *models.PayoutJob) error
+	FindItemsByJobID(jobID string) ([]models.PayoutItem, error)
+	FindItemByID(id string) (*models.PayoutItem, error)
+	FindPendingItems(jobID string, limit int) ([]models.PayoutItem, error)
+	UpdateItem(item *models.PayoutItem) error
+	DB() *gorm.DB
+	WithTx(tx interface{}) PayoutRepository
+}
+
+type LimitRepository interface {
+	FindOverride(userID, operation, currency string) (*models.LimitOverride, error)
+	FindOverridesByUserID(userID string) ([]models.LimitOverride, error)
+	SaveOverride(override *models.LimitOverride) error
+	DeleteOverride(userID, operation, currency string) (bool, error)
+	WithTx(tx interface{}) LimitRepository
+}
+
+type InterestRepository interface {
+	LastRun() (*models.InterestRun, error)
+	CreateRun(run *models.InterestRun) error
+	CreateAccrual(accrual *models.InterestAccrual) (bool, error)
+	FindUnpostedWallets(throughDate string) ([]models.InterestAccrual, error)
+	FindUnpostedByWalletID(walletID, throughDate string) ([]models.InterestAccrual, error)
+	MarkPosted(ids []string, transactionID string, postedAt time.Time) error
+	WithTx(tx interface{}) InterestRepository
+	DB() *gorm.DB
+}
+
+type OutboxRepository interface {
+	Create(event *models.OutboxEvent) error
+	FindUndispatchedForUpdate(limit int) ([]models.OutboxEvent, error)
+	MarkDispatched(ids []string, at time.Time) error
+	FindByIDs(ids []string) ([]models.OutboxEvent, error)
+	DB() *gorm.DB
+	WithTx(tx interface{}) OutboxRepository
+}
+
+type WebhookRepository interface {
+	CreateEndpoint(endpoint *models.WebhookEndpoint) error
+	UpdateEndpoint(endpoint *models.WebhookEndpoint) error
+	FindEndpointByID(id string) (*models.WebhookEndpoint, error)
+	FindEndpoints() ([]models.WebhookEndpoint, error)
+	CreateDeliveries(deliveries []models.WebhookDelivery) error
+	FindDueDeliveriesForUpdate(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	FindDeliveryByID(id string) (*models.WebhookDelivery, error)
+	FindDeliveries(status, endpointID string, limit int) ([]models.WebhookDelivery, error)
+	UpdateDelivery(delivery *models.WebhookDelivery) error
+	UpdateLeasedDelivery(delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error)
+	DB() *gorm.DB
+	WithTx(tx interface{}) WebhookRepository
+}
+
+type TransactionLogRepository interface {
+	Head() (*models.TransactionLogHead, error)
+	AppendPending(limit int) ([]models.TransactionLogEntry, error)
+	HasPendingEntries(transactionID string) (bool, error)
+	EachEntry(fn func(entry *models.TransactionLogEntry) error) error
+	FindLatestEntry(transactionID string) (*models.TransactionLogEntry, error)
+	FindFirstMissingTransaction() (*models.TransactionLogEntry, error)
+	FindEntriesByUserID(userID string, after, until int64, limit int) ([]models.TransactionLogEntry, error)
+	CreateCheckpoint(checkpoint *models.TransactionLogCheckpoint) error
+	LastCheckpoint() (*models.TransactionLogCheckpoint, error)
+	FindCheckpoints() ([]models.TransactionLogCheckpoint, error)
+}
+
+type ReconciliationRepository interface {
+	CreateRun(run *models.ReconciliationRun) error
+	UpdateRun(run *models.ReconciliationRun) error
+	CreateMismatch(mismatch *models.ReconciliationMismatch) error
+	FindRuns(limit int) ([]models.ReconciliationRun, error)
+	FindRunByID(id string) (*models.ReconciliationRun, error)
+}
+
+type StatementRepository interface {
+	Create(statement *models.Statement) (bool, error)
+	FindByPeriod(userID, currency, period string) (*models.Statement, error)
+	FindByUserID(userID string) ([]models.Statement, error)
+	FindRun(period string) (*models.StatementRun, error)
+	CreateRun(run *models.StatementRun) error
+}
@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+// EnsureAccounts creates any of the given accounts that do not exist yet.
+func (r *ledgerRepository) EnsureAccounts(accounts []models.LedgerAccount) error {
+	if len(accounts) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&accounts).Error
+}
+
+// CreatePostings appends postings to the ledger. Postings are never updated
+// or deleted; corrections are booked as new postings.
+func (r *ledgerRepository) CreatePostings(postings []models.LedgerPosting) error {
+	if len(postings) == 0 {
+		return nil
+	}
+	return r.db.Create(&postings).Error
+}
+
+func (r *ledgerRepository) FindPostingsByTransactionID(transactionID string) ([]models.LedgerPosting, error) {
+	var postings []models.LedgerPosting
+	if err := r.db.Where("transaction_id = ?", transactionID).Order("amount").Find(&postings).Error; err != nil {
+		return nil, err
+	}
+	return postings, nil
+}
+
+// FindPostingsByAccount returns the postings booked to an account between
+// from and to, in the order they were booked.
+func (r *ledgerRepository) FindPostingsByAccount(accountCode string, from, to time.Time) ([]models.LedgerPosting, error) {
+	var postings []models.LedgerPosting
+	err := r.db.Where("account_code = ? AND created_at >= ? AND created_at < ?", accountCode, from, to).
+		Order("created_at, id").
+		Find(&postings).Error
+	return postings, err
+}
+
+// LedgerStart returns when the ledger was introduced: the time the balances
+// wallets already had were booked as opening balances. It returns
+// gorm.ErrRecordNotFound if there were none to book.
+func (r *ledgerRepository) LedgerStart() (time.Time, error) {
+	var posting models.LedgerPosting
+	err := r.db.Where("account_code = ? AND transaction_id = ''", models.LedgerAccountOpeningBalances).
+		Order("created_at").
+		First(&posting).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+	return posting.CreatedAt, nil
+}
+
+// BalanceOf returns the sum of all postings booked to an account.
+func (r *ledgerRepository) BalanceOf(accountCode string) (money.Amount, error) {
+	var balance money.Amount
+	err := r.db.Model(&models.LedgerPosting{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("account_code = ?", accountCode).
+		Scan(&balance).Error
+	return balance, err
+}
+
+// checkpointDateLayout is how checkpoint days are written: UTC calendar dates.
+const checkpointDateLayout = "2006-01-02"
+
+// BalanceAsOf returns the sum of the postings booked to an account before at.
+// It starts from the account's latest checkpoint for a day that ended by at,
+// so only the postings booked since are summed.
+func (r *ledgerRepository) BalanceAsOf(accountCode string, at time.Time) (money.Amount, error) {
+	at = at.UTC()
+	today := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, time.UTC)
+
+	var checkpoints []models.BalanceCheckpoint
+	err := r.db.Where("account_code = ? AND date < ?", accountCode, today.Format(checkpointDateLayout)).
+		Order("date DESC").
+		Limit(1).
+		Find(&checkpoints).Error
+	if err != nil {
+		return 0, err
+	}
+
+	query := r.db.Model(&models.LedgerPosting{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("account_code = ? AND created_at < ?", accountCode, at)
+	var opening money.Amount
+	if len(checkpoints) > 0 {
+		day, err := time.Parse(checkpointDateLayout, checkpoints[0].Date)
+		if err != nil {
+			return 0, err
+		}
+		query = query.Where("created_at >= ?", day.AddDate(0, 0, 1))
+		opening = checkpoints[0].Balance
+	}
+
+	var balance money.Amount
+	if err := query.Scan(&balance).Error; err != nil {
+		return 0, err
+	}
+	return opening + balance, nil
+}
+
+// DailyTotals returns the net amount booked to an account on each UTC day
+// between from and to. Days without postings are left out.
+func (r *ledgerRepository) DailyTotals(accountCode string, from, to time.Time) (map[string]money.Amount, error) {
+	var rows []struct {
+		Day   time.Time
+		Total money.Amount
+	}
+	err := r.db.Model(&models.LedgerPosting{}).
+		Select("DATE(created_at AT TIME ZONE 'UTC') AS day, SUM(amount) AS total").
+		Where("account_code = ? AND created_at >= ? AND created_at < ?", accountCode, from, to).
+		Group("day").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]money.Amount, len(rows))
+	for _, row := range rows {
+		totals[row.Day.Format(checkpointDateLayout)] = row.Total
+	}
+	return totals, nil
+}
+
+// FindAccountsPostedBetween returns the codes of the accounts with postings
+// booked between from and to.
+func (r *ledgerRepository) FindAccountsPostedBetween(from, to time.Time) ([]string, error) {
+	var codes []string
+	err := r.db.Model(&models.LedgerPosting{}).
+		Distinct("account_code").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Order("account_code").
+		Pluck("account_code", &codes).Error
+	return codes, err
+}
+
+// CreateCheckpoint stores an account's closing balance for a day. A day that
+// has already been checkpointed is left untouched.
+func (r *ledgerRepository) CreateCheckpoint(checkpoint *models.BalanceCheckpoint) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(checkpoint).Error
+}
+
+// LastCheckpointRun returns the latest day the checkpoint job has finished.
+func (r *ledgerRepository) LastCheckpointRun() (*models.BalanceCheckpointRun, error) {
+	var run models.BalanceCheckpointRun
+	if err := r.db.Order("date DESC").First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *ledgerRepository) CreateCheckpointRun(run *models.BalanceCheckpointRun) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(run).Error
+}
+
+func (r *ledgerRepository) WithTx(tx interface{}) LedgerRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &ledgerRepository{db: txDB}
+}
@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type limitRepository struct {
+	db *gorm.DB
+}
+
+func NewLimitRepository(db *gorm.DB) LimitRepository {
+	return &limitRepository{db: db}
+}
+
+func (r *limitRepository) FindOverride(userID, operation, currency string) (*models.LimitOverride, error) {
+	var override models.LimitOverride
+	err := r.db.Where("user_id = ? AND operation = ? AND currency = ?", userID, operation, currency).First(&override).Error
+	if err != nil {
+		return nil, err
+	}
+	return &override, nil
+}
+
+// FindOverridesByUserID returns a user's overrides ordered by operation and
+// currency.
+func (r *limitRepository) FindOverridesByUserID(userID string) ([]models.LimitOverride, error) {
+	var overrides []models.LimitOverride
+	if err := r.db.Where("user_id = ?", userID).Order("operation, currency").Find(&overrides).Error; err != nil {
+		return nil, err
+	}
+	return overrides, nil
+}
+
+// SaveOverride creates an override, or replaces the limits of the user's
+// existing one for the same operation and currency.
+func (r *limitRepository) SaveOverride(override *models.LimitOverride) error {
+	return r.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "operation"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"min_amount", "max_amount", "daily_amount", "weekly_amount", "monthly_amount",
+			"daily_count", "weekly_count", "monthly_count", "updated_at",
+		}),
+	}).Create(override).Error
+}
+
+// DeleteOverride removes a user's override and reports whether there was
+// one.
+func (r *limitRepository) DeleteOverride(userID, operation, currency string) (bool, error) {
+	result := r.db.Where("user_id = ? AND operation = ? AND currency = ?", userID, operation, currency).Delete(&models.LimitOverride{})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *limitRepository) WithTx(tx interface{}) LimitRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &limitRepository{db: txDB}
+}
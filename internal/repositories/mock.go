@@ -1,22 +1,82 @@
 package repositories
 
 import (
+	"time"
+
 	"wallet/internal/models"
+	"wallet/internal/money"
 
 	"gorm.io/gorm"
 )
 
+// MockUserRepository is a mock implementation of UserRepository
+type MockUserRepository struct {
+	UserRepository
+	FindByEmailFunc  func(email string) (*models.User, error)
+	FindByPhoneFunc  func(phone string) (*models.User, error)
+	FindByHandleFunc func(handle string) (*models.User, error)
+	FindByIDFunc     func(id string) (*models.User, error)
+	UpdateFunc       func(user *models.User) error
+}
+
+func (m *MockUserRepository) FindByEmail(email string) (*models.User, error) {
+	if m.FindByEmailFunc != nil {
+		return m.FindByEmailFunc(email)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockUserRepository) FindByPhone(phone string) (*models.User, error) {
+	if m.FindByPhoneFunc != nil {
+		return m.FindByPhoneFunc(phone)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockUserRepository) FindByHandle(handle string) (*models.User, error) {
+	if m.FindByHandleFunc != nil {
+		return m.FindByHandleFunc(handle)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockUserRepository) FindByID(id string) (*models.User, error) {
+	if m.FindByIDFunc != nil {
+		return m.FindByIDFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockUserRepository) Update(user *models.User) error {
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(user)
+	}
+	return nil
+}
+
 // MockWalletRepository is a mock implementation of WalletRepository
 // Manual mocks for repositories
 
 type MockWalletRepository struct {
 	WalletRepository
-	FindByUserIDFunc func(userID string) (*models.Wallet, error)
-	UpdateFunc       func(wallet *models.Wallet) error
-	CreateFunc       func(wallet *models.Wallet) error
-	DeleteFunc       func(id string) error
-	WithTxFunc       func(tx interface{}) WalletRepository
-	DBFunc           func() *gorm.DB
+	FindByIDFunc              func(id string) (*models.Wallet, error)
+	FindByIDForUpdateFunc     func(id string) (*models.Wallet, error)
+	FindByUserIDFunc          func(userID, currency string) (*models.Wallet, error)
+	FindByUserIDForUpdateFunc func(userID, currency string) (*models.Wallet, error)
+	ListByUserIDFunc          func(userID string) ([]models.Wallet, error)
+	FindByCurrencyFunc        func(currency, afterID string, limit int) ([]models.Wallet, error)
+	FindAllFunc               func(afterID string, limit int) ([]models.Wallet, error)
+	UpdateFunc                func(wallet *models.Wallet) error
+	CreateFunc                func(wallet *models.Wallet) error
+	CreateIfMissingFunc       func(wallet *models.Wallet) error
+	DeleteFunc                func(id string) error
+	CreateStatusEventFunc     func(event *models.WalletStatusEvent) error
+	FindStatusEventsFunc      func(walletID string) ([]models.WalletStatusEvent, error)
+	WithTxFunc                func(tx interface{}) WalletRepository
+	DBFunc                    func() *gorm.DB
+
+	// Updated records every wallet passed to Update, in call order
+	Updated []models.Wallet
 }
 
 func (m *MockWalletRepository) DB() *gorm.DB {
@@ -40,14 +100,71 @@ func (m *MockWalletRepository) Delete(id string) error {
 	return nil
 }
 
-func (m *MockWalletRepository) FindByUserID(userID string) (*models.Wallet, error) {
+func (m *MockWalletRepository) FindByUserID(userID, currency string) (*models.Wallet, error) {
 	if m.FindByUserIDFunc != nil {
-		return m.FindByUserIDFunc(userID)
+		return m.FindByUserIDFunc(userID, currency)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) FindByUserIDForUpdate(userID, currency string) (*models.Wallet, error) {
+	if m.FindByUserIDForUpdateFunc != nil {
+		return m.FindByUserIDForUpdateFunc(userID, currency)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) ListByUserID(userID string) ([]models.Wallet, error) {
+	if m.ListByUserIDFunc != nil {
+		return m.ListByUserIDFunc(userID)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) FindByID(id string) (*models.Wallet, error) {
+	if m.FindByIDFunc != nil {
+		return m.FindByIDFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockWalletRepository) FindAll(afterID string, limit int) ([]models.Wallet, error) {
+	if m.FindAllFunc != nil {
+		return m.FindAllFunc(afterID, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) FindByIDForUpdate(id string) (*models.Wallet, error) {
+	if m.FindByIDForUpdateFunc != nil {
+		return m.FindByIDForUpdateFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockWalletRepository) CreateStatusEvent(event *models.WalletStatusEvent) error {
+	if m.CreateStatusEventFunc != nil {
+		return m.CreateStatusEventFunc(event)
+	}
+	return nil
+}
+
+func (m *MockWalletRepository) FindStatusEvents(walletID string) ([]models.WalletStatusEvent, error) {
+	if m.FindStatusEventsFunc != nil {
+		return m.FindStatusEventsFunc(walletID)
+	}
+	return nil, nil
+}
+
+func (m *MockWalletRepository) FindByCurrency(currency, afterID string, limit int) ([]models.Wallet, error) {
+	if m.FindByCurrencyFunc != nil {
+		return m.FindByCurrencyFunc(currency, afterID, limit)
 	}
 	return nil, nil
 }
 
 func (m *MockWalletRepository) Update(wallet *models.Wallet) error {
+	m.Updated = append(m.Updated, *wallet)
 	if m.UpdateFunc != nil {
 		return m.UpdateFunc(wallet)
 	}
@@ -61,13 +178,31 @@ func (m *MockWalletRepository) Create(wallet *models.Wallet) error {
 	return nil
 }
 
+func (m *MockWalletRepository) CreateIfMissing(wallet *models.Wallet) error {
+	if m.CreateIfMissingFunc != nil {
+		return m.CreateIfMissingFunc(wallet)
+	}
+	return nil
+}
+
 // MockTransactionRepository is a mock implementation of TransactionRepository
 type MockTransactionRepository struct {
 	TransactionRepository
-	CreateFunc       func(transaction *models.Transaction) error
-	FindByUserIDFunc func(userID string, page, pageSize int, transactionType, status string) ([]models.Transaction, error)
-	DeleteFunc       func(id string) error
-	WithTxFunc       func(tx interface{}) TransactionRepository
+	CreateFunc            func(transaction *models.Transaction) error
+	FindByIDFunc          func(id string) (*models.Transaction, error)
+	FindByIDForUpdateFunc func(id string) (*models.Transaction, error)
+	FindByIDsFunc         func(ids []string) ([]models.Transaction, error)
+	FindByUserIDFunc      func(userID string, page, pageSize int, transactionType, status string) ([]models.Transaction, error)
+	EachByUserIDFunc      func(userID string, filter models.TransactionFilter, fn func(transaction *models.Transaction) error) error
+	EachFunc              func(fn func(transaction *models.Transaction) error) error
+	FindByBatchIDFunc     func(batchID string) ([]models.Transaction, error)
+	SumOutgoingSinceFunc  func(userID, transactionType, currency string, since time.Time) (money.Amount, int, error)
+	SumSettledFunc        func(userID, currency string) (money.Amount, int, error)
+	UpdateFunc            func(transaction *models.Transaction) error
+	DeleteFunc            func(id string) error
+	CreateBatchFunc       func(batch *models.TransferBatch) error
+	FindBatchByIDFunc     func(id string) (*models.TransferBatch, error)
+	WithTxFunc            func(tx interface{}) TransactionRepository
 }
 
 func (m *MockTransactionRepository) WithTx(tx interface{}) TransactionRepository {
@@ -84,6 +219,34 @@ func (m *MockTransactionRepository) Create(transaction *models.Transaction) erro
 	return nil
 }
 
+func (m *MockTransactionRepository) FindByID(id string) (*models.Transaction, error) {
+	if m.FindByIDFunc != nil {
+		return m.FindByIDFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *MockTransactionRepository) FindByIDForUpdate(id string) (*models.Transaction, error) {
+	if m.FindByIDForUpdateFunc != nil {
+		return m.FindByIDForUpdateFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *MockTransactionRepository) FindByIDs(ids []string) ([]models.Transaction, error) {
+	if m.FindByIDsFunc != nil {
+		return m.FindByIDsFunc(ids)
+	}
+	return nil, nil
+}
+
+func (m *MockTransactionRepository) Update(transaction *models.Transaction) error {
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(transaction)
+	}
+	return nil
+}
+
 func (m *MockTransactionRepository) FindByUserID(userID string, page, pageSize int, transactionType, status string) ([]models.Transaction, error) {
 	if m.FindByUserIDFunc != nil {
 		return m.FindByUserIDFunc(userID, page, pageSize, transactionType, status)
@@ -97,3 +260,1021 @@ func (m *MockTransactionRepository) Delete(id string) error {
 	}
 	return nil
 }
+
+func (m *MockTransactionRepository) EachByUserID(userID string, filter models.TransactionFilter, fn func(transaction *models.Transaction) error) error {
+	if m.EachByUserIDFunc != nil {
+		return m.EachByUserIDFunc(userID, filter, fn)
+	}
+	return nil
+}
+
+func (m *MockTransactionRepository) Each(fn func(transaction *models.Transaction) error) error {
+	if m.EachFunc != nil {
+		return m.EachFunc(fn)
+	}
+	return nil
+}
+
+func (m *MockTransactionRepository) FindByBatchID(batchID string) ([]models.Transaction, error) {
+	if m.FindByBatchIDFunc != nil {
+		return m.FindByBatchIDFunc(batchID)
+	}
+	return nil, nil
+}
+
+func (m *MockTransactionRepository) SumOutgoingSince(userID, transactionType, currency string, since time.Time) (money.Amount, int, error) {
+	if m.SumOutgoingSinceFunc != nil {
+		return m.SumOutgoingSinceFunc(userID, transactionType, currency, since)
+	}
+	return 0, 0, nil
+}
+
+func (m *MockTransactionRepository) SumSettled(userID, currency string) (money.Amount, int, error) {
+	if m.SumSettledFunc != nil {
+		return m.SumSettledFunc(userID, currency)
+	}
+	return 0, 0, nil
+}
+
+func (m *MockTransactionRepository) CreateBatch(batch *models.TransferBatch) error {
+	if m.CreateBatchFunc != nil {
+		return m.CreateBatchFunc(batch)
+	}
+	return nil
+}
+
+func (m *MockTransactionRepository) FindBatchByID(id string) (*models.TransferBatch, error) {
+	if m.FindBatchByIDFunc != nil {
+		return m.FindBatchByIDFunc(id)
+	}
+	return nil, nil
+}
+
+// MockIdempotencyKeyRepository is a mock implementation of IdempotencyKeyRepository
+type MockIdempotencyKeyRepository struct {
+	IdempotencyKeyRepository
+	CreateIfNotExistsFunc  func(key *models.IdempotencyKey) (bool, error)
+	FindByUserIDAndKeyFunc func(userID, key string) (*models.IdempotencyKey, error)
+	UpdateFunc             func(key *models.IdempotencyKey) error
+	DeleteExpiredFunc      func(userID, key string, before time.Time) (int64, error)
+	WithTxFunc             func(tx interface{}) IdempotencyKeyRepository
+}
+
+func (m *MockIdempotencyKeyRepository) WithTx(tx interface{}) IdempotencyKeyRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockIdempotencyKeyRepository) CreateIfNotExists(key *models.IdempotencyKey) (bool, error) {
+	if m.CreateIfNotExistsFunc != nil {
+		return m.CreateIfNotExistsFunc(key)
+	}
+	return true, nil
+}
+
+func (m *MockIdempotencyKeyRepository) FindByUserIDAndKey(userID, key string) (*models.IdempotencyKey, error) {
+	if m.FindByUserIDAndKeyFunc != nil {
+		return m.FindByUserIDAndKeyFunc(userID, key)
+	}
+	return nil, nil
+}
+
+func (m *MockIdempotencyKeyRepository) Update(key *models.IdempotencyKey) error {
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(key)
+	}
+	return nil
+}
+
+func (m *MockIdempotencyKeyRepository) DeleteExpired(userID, key string, before time.Time) (int64, error) {
+	if m.DeleteExpiredFunc != nil {
+		return m.DeleteExpiredFunc(userID, key, before)
+	}
+	return 0, nil
+}
+
+// MockLedgerRepository is a mock implementation of LedgerRepository
+type MockLedgerRepository struct {
+	LedgerRepository
+	EnsureAccountsFunc              func(accounts []models.LedgerAccount) error
+	CreatePostingsFunc              func(postings []models.LedgerPosting) error
+	FindPostingsByTransactionIDFunc func(transactionID string) ([]models.LedgerPosting, error)
+	FindPostingsByAccountFunc       func(accountCode string, from, to time.Time) ([]models.LedgerPosting, error)
+	LedgerStartFunc                 func() (time.Time, error)
+	BalanceOfFunc                   func(accountCode string) (money.Amount, error)
+	BalanceAsOfFunc                 func(accountCode string, at time.Time) (money.Amount, error)
+	DailyTotalsFunc                 func(accountCode string, from, to time.Time) (map[string]money.Amount, error)
+	FindAccountsPostedBetweenFunc   func(from, to time.Time) ([]string, error)
+	CreateCheckpointFunc            func(checkpoint *models.BalanceCheckpoint) error
+	LastCheckpointRunFunc           func() (*models.BalanceCheckpointRun, error)
+	CreateCheckpointRunFunc         func(run *models.BalanceCheckpointRun) error
+	WithTxFunc                      func(tx interface{}) LedgerRepository
+}
+
+func (m *MockLedgerRepository) WithTx(tx interface{}) LedgerRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockLedgerRepository) EnsureAccounts(accounts []models.LedgerAccount) error {
+	if m.EnsureAccountsFunc != nil {
+		return m.EnsureAccountsFunc(accounts)
+	}
+	return nil
+}
+
+func (m *MockLedgerRepository) CreatePostings(postings []models.LedgerPosting) error {
+	if m.CreatePostingsFunc != nil {
+		return m.CreatePostingsFunc(postings)
+	}
+	return nil
+}
+
+func (m *MockLedgerRepository) FindPostingsByTransactionID(transactionID string) ([]models.LedgerPosting, error) {
+	if m.FindPostingsByTransactionIDFunc != nil {
+		return m.FindPostingsByTransactionIDFunc(transactionID)
+	}
+	return nil, nil
+}
+
+func (m *MockLedgerRepository) FindPostingsByAccount(accountCode string, from, to time.Time) ([]models.LedgerPosting, error) {
+	if m.FindPostingsByAccountFunc != nil {
+		return m.FindPostingsByAccountFunc(accountCode, from, to)
+	}
+	return nil, nil
+}
+
+func (m *MockLedgerRepository) LedgerStart() (time.Time, error) {
+	if m.LedgerStartFunc != nil {
+		return m.LedgerStartFunc()
+	}
+	return time.Time{}, gorm.ErrRecordNotFound
+}
+
+func (m *MockLedgerRepository) BalanceOf(accountCode string) (money.Amount, error) {
+	if m.BalanceOfFunc != nil {
+		return m.BalanceOfFunc(accountCode)
+	}
+	return 0, nil
+}
+
+func (m *MockLedgerRepository) BalanceAsOf(accountCode string, at time.Time) (money.Amount, error) {
+	if m.BalanceAsOfFunc != nil {
+		return m.BalanceAsOfFunc(accountCode, at)
+	}
+	return 0, nil
+}
+
+func (m *MockLedgerRepository) DailyTotals(accountCode string, from, to time.Time) (map[string]money.Amount, error) {
+	if m.DailyTotalsFunc != nil {
+		return m.DailyTotalsFunc(accountCode, from, to)
+	}
+	return nil, nil
+}
+
+func (m *MockLedgerRepository) FindAccountsPostedBetween(from, to time.Time) ([]string, error) {
+	if m.FindAccountsPostedBetweenFunc != nil {
+		return m.FindAccountsPostedBetweenFunc(from, to)
+	}
+	return nil, nil
+}
+
+func (m *MockLedgerRepository) CreateCheckpoint(checkpoint *models.BalanceCheckpoint) error {
+	if m.CreateCheckpointFunc != nil {
+		return m.CreateCheckpointFunc(checkpoint)
+	}
+	return nil
+}
+
+func (m *MockLedgerRepository) LastCheckpointRun() (*models.BalanceCheckpointRun, error) {
+	if m.LastCheckpointRunFunc != nil {
+		return m.LastCheckpointRunFunc()
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockLedgerRepository) CreateCheckpointRun(run *models.BalanceCheckpointRun) error {
+	if m.CreateCheckpointRunFunc != nil {
+		return m.CreateCheckpointRunFunc(run)
+	}
+	return nil
+}
+
+// MockFXQuoteRepository is a mock implementation of FXQuoteRepository
+type MockFXQuoteRepository struct {
+	FXQuoteRepository
+	CreateFunc            func(quote *models.FXQuote) error
+	FindByIDFunc          func(id string) (*models.FXQuote, error)
+	FindByIDForUpdateFunc func(id string) (*models.FXQuote, error)
+	UpdateFunc            func(quote *models.FXQuote) error
+	WithTxFunc            func(tx interface{}) FXQuoteRepository
+}
+
+func (m *MockFXQuoteRepository) WithTx(tx interface{}) FXQuoteRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockFXQuoteRepository) Create(quote *models.FXQuote) error {
+	if m.CreateFunc != nil {
+		return m.CreateFunc(quote)
+	}
+	return nil
+}
+
+func (m *MockFXQuoteRepository) FindByID(id string) (*models.FXQuote, error) {
+	if m.FindByIDFunc != nil {
+		return m.FindByIDFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *MockFXQuoteRepository) FindByIDForUpdate(id string) (*models.FXQuote, error) {
+	if m.FindByIDForUpdateFunc != nil {
+		return m.FindByIDForUpdateFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *MockFXQuoteRepository) Update(quote *models.FXQuote) error {
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(quote)
+	}
+	return nil
+}
+
+// MockHoldRepository is a mock implementation of HoldRepository
+type MockHoldRepository struct {
+	HoldRepository
+	CreateFunc                       func(hold *models.Hold) error
+	FindByIDForUpdateFunc            func(id string) (*models.Hold, error)
+	FindByTransactionIDForUpdateFunc func(transactionID string) (*models.Hold, error)
+	UpdateFunc                       func(hold *models.Hold) error
+	SumActiveByWalletIDFunc          func(walletID string, now time.Time) (money.Amount, error)
+	FindExpiredFunc                  func(now time.Time, limit int) ([]models.Hold, error)
+	WithTxFunc                       func(tx interface{}) HoldRepository
+}
+
+func (m *MockHoldRepository) WithTx(tx interface{}) HoldRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockHoldRepository) Create(hold *models.Hold) error {
+	if m.CreateFunc != nil {
+		return m.CreateFunc(hold)
+	}
+	return nil
+}
+
+func (m *MockHoldRepository) FindByIDForUpdate(id string) (*models.Hold, error) {
+	if m.FindByIDForUpdateFunc != nil {
+		return m.FindByIDForUpdateFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *MockHoldRepository) FindByTransactionIDForUpdate(transactionID string) (*models.Hold, error) {
+	if m.FindByTransactionIDForUpdateFunc != nil {
+		return m.FindByTransactionIDForUpdateFunc(transactionID)
+	}
+	return nil, nil
+}
+
+func (m *MockHoldRepository) Update(hold *models.Hold) error {
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(hold)
+	}
+	return nil
+}
+
+func (m *MockHoldRepository) SumActiveByWalletID(walletID string, now time.Time) (money.Amount, error) {
+	if m.SumActiveByWalletIDFunc != nil {
+		return m.SumActiveByWalletIDFunc(walletID, now)
+	}
+	return 0, nil
+}
+
+func (m *MockHoldRepository) FindExpired(now time.Time, limit int) ([]models.Hold, error) {
+	if m.FindExpiredFunc != nil {
+		return m.FindExpiredFunc(now, limit)
+	}
+	return nil, nil
+}
+
+// MockScheduleRepository is a mock implementation of ScheduleRepository
+type MockScheduleRepository struct {
+	ScheduleRepository
+	CreateFunc                     func(schedule *models.Schedule) error
+	FindByIDFunc                   func(id string) (*models.Schedule, error)
+	FindByIDForUpdateFunc          func(id string) (*models.Schedule, error)
+	FindByUserIDFunc               func(userID string) ([]models.Schedule, error)
+	FindDueFunc                    func(now time.Time, limit int) ([]models.Schedule, error)
+	UpdateFunc                     func(schedule *models.Schedule) error
+	CreateExecutionFunc            func(execution *models.ScheduleExecution) error
+	FindExecutionsByScheduleIDFunc func(scheduleID string) ([]models.ScheduleExecution, error)
+	DBFunc                         func() *gorm.DB
+	WithTxFunc                     func(tx interface{}) ScheduleRepository
+}
+
+func (m *MockScheduleRepository) WithTx(tx interface{}) ScheduleRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockScheduleRepository) DB() *gorm.DB {
+	if m.DBFunc != nil {
+		return m.DBFunc()
+	}
+	return nil
+}
+
+func (m *MockScheduleRepository) Create(schedule *models.Schedule) error {
+	if m.CreateFunc != nil {
+		return m.CreateFunc(schedule)
+	}
+	return nil
+}
+
+func (m *MockScheduleRepository) FindByID(id string) (*models.Schedule, error) {
+	if m.FindByIDFunc != nil {
+		return m.FindByIDFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleRepository) FindByIDForUpdate(id string) (*models.Schedule, error) {
+	if m.FindByIDForUpdateFunc != nil {
+		return m.FindByIDForUpdateFunc(id)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleRepository) FindByUserID(userID string) ([]models.Schedule, error) {
+	if m.FindByUserIDFunc != nil {
+		return m.FindByUserIDFunc(userID)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleRepository) FindDue(now time.Time, limit int) ([]models.Schedule, error) {
+	if m.FindDueFunc != nil {
+		return m.FindDueFunc(now, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockScheduleRepository) Update(schedule *models.Schedule) error {
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(schedule)
+	}
+	return nil
+}
+
+func (m *MockScheduleRepository) CreateExecution(execution *models.ScheduleExecution) error {
+	if m.CreateExecutionFunc != nil {
+		return m.CreateExecutionFunc(execution)
+	}
+	return nil
+}
+
+func (m *MockScheduleRepository) FindExecutionsByScheduleID(scheduleID string) ([]models.ScheduleExecution, error) {
+	if m.FindExecutionsByScheduleIDFunc != nil {
+		return m.FindExecutionsByScheduleIDFunc(scheduleID)
+	}
+	return nil, nil
+}
+
+// MockPaymentRequestRepository is a mock implementation of PaymentRequestRepository
+type MockPaymentRequestRepository struct {
+	PaymentRequestRepository
+	CreateFunc            func(request *models.PaymentRequest) error
+	FindByIDForUpdateFunc func(id string) (*models.PaymentRequest, error)
+	FindByPayerIDFunc     func(payerID, status string) ([]models.PaymentRequest, error)
+	FindByRequesterIDFunc func(requesterID, status string) ([]models.PaymentRequest, error)
+	UpdateFunc            func(request *models.PaymentRequest) error
+	ExpirePendingFunc     func(now time.Time) (int64, error)
+	DBFunc                func() *gorm.DB
+	WithTxFunc            func(tx interface{}) PaymentRequestRepository
+}
+
+func (m *MockPaymentRequestRepository) WithTx(tx interface{}) PaymentRequestRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockPaymentRequestRepository) DB() *gorm.DB {
+	if m.DBFunc != nil {
+		return m.DBFunc()
+	}
+	return nil
+}
+
+func (m *MockPaymentRequestRepository) Create(request *models.PaymentRequest) error {
+	if m.CreateFunc != nil {
+		return m.CreateFunc(request)
+	}
+	return nil
+}
+
+func (m *MockPaymentRequestRepository) FindByIDForUpdate(id string) (*models.PaymentRequest, error) {
+	if m.FindByIDForUpdateFunc != nil {
+		return m.FindByIDForUpdateFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockPaymentRequestRepository) FindByPayerID(payerID, status string) ([]models.PaymentRequest, error) {
+	if m.FindByPayerIDFunc != nil {
+		return m.FindByPayerIDFunc(payerID, status)
+	}
+	return nil, nil
+}
+
+func (m *MockPaymentRequestRepository) FindByRequesterID(requesterID, status string) ([]models.PaymentRequest, error) {
+	if m.FindByRequesterIDFunc != nil {
+		return m.FindByRequesterIDFunc(requesterID, status)
+	}
+	return nil, nil
+}
+
+func (m *MockPaymentRequestRepository) Update(request *models.PaymentRequest) error {
+	if m.UpdateFunc != nil {
+		return m.UpdateFunc(request)
+	}
+	return nil
+}
+
+func (m *MockPaymentRequestRepository) ExpirePending(now time.Time) (int64, error) {
+	if m.ExpirePendingFunc != nil {
+		return m.ExpirePendingFunc(now)
+	}
+	return 0, nil
+}
+
+// MockPayoutRepository is a mock implementation of PayoutRepository
+type MockPayoutRepository struct {
+	PayoutRepository
+	CreateJobFunc            func(job *models.PayoutJob) error
+	CreateItemsFunc          func(items []models.PayoutItem) error
+	FindJobByIDFunc          func(id string) (*models.PayoutJob, error)
+	FindJobByIDForUpdateFunc func(id string) (*models.PayoutJob, error)
+	FindJobsByUserIDFunc     func(userID string) ([]models.PayoutJob, error)
+	FindDueJobsFunc          func(now time.Time, limit int) ([]models.PayoutJob, error)
+	UpdateJobFunc            func(job *models.PayoutJob) error
+	FindItemsByJobIDFunc     func(jobID string) ([]models.PayoutItem, error)
+	FindItemByIDFunc         func(id string) (*models.PayoutItem, error)
+	FindPendingItemsFunc     func(jobID string, limit int) ([]models.PayoutItem, error)
+	UpdateItemFunc           func(item *models.PayoutItem) error
+	DBFunc                   func() *gorm.DB
+	WithTxFunc               func(tx interface{}) PayoutRepository
+}
+
+func (m *MockPayoutRepository) WithTx(tx interface{}) PayoutRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockPayoutRepository) DB() *gorm.DB {
+	if m.DBFunc != nil {
+		return m.DBFunc()
+	}
+	return nil
+}
+
+func (m *MockPayoutRepository) CreateJob(job *models.PayoutJob) error {
+	if m.CreateJobFunc != nil {
+		return m.CreateJobFunc(job)
+	}
+	return nil
+}
+
+func (m *MockPayoutRepository) CreateItems(items []models.PayoutItem) error {
+	if m.CreateItemsFunc != nil {
+		return m.CreateItemsFunc(items)
+	}
+	return nil
+}
+
+func (m *MockPayoutRepository) FindJobByID(id string) (*models.PayoutJob, error) {
+	if m.FindJobByIDFunc != nil {
+		return m.FindJobByIDFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockPayoutRepository) FindJobByIDForUpdate(id string) (*models.PayoutJob, error) {
+	if m.FindJobByIDForUpdateFunc != nil {
+		return m.FindJobByIDForUpdateFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockPayoutRepository) FindJobsByUserID(userID string) ([]models.PayoutJob, error) {
+	if m.FindJobsByUserIDFunc != nil {
+		return m.FindJobsByUserIDFunc(userID)
+	}
+	return nil, nil
+}
+
+func (m *MockPayoutRepository) FindDueJobs(now time.Time, limit int) ([]models.PayoutJob, error) {
+	if m.FindDueJobsFunc != nil {
+		return m.FindDueJobsFunc(now, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockPayoutRepository) UpdateJob(job *models.PayoutJob) error {
+	if m.UpdateJobFunc != nil {
+		return m.UpdateJobFunc(job)
+	}
+	return nil
+}
+
+func (m *MockPayoutRepository) FindItemsByJobID(jobID string) ([]models.PayoutItem, error) {
+	if m.FindItemsByJobIDFunc != nil {
+		return m.FindItemsByJobIDFunc(jobID)
+	}
+	return nil, nil
+}
+
+func (m *MockPayoutRepository) FindItemByID(id string) (*models.PayoutItem, error) {
+	if m.FindItemByIDFunc != nil {
+		return m.FindItemByIDFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockPayoutRepository) FindPendingItems(jobID string, limit int) ([]models.PayoutItem, error) {
+	if m.FindPendingItemsFunc != nil {
+		return m.FindPendingItemsFunc(jobID, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockPayoutRepository) UpdateItem(item *models.PayoutItem) error {
+	if m.UpdateItemFunc != nil {
+		return m.UpdateItemFunc(item)
+	}
+	return nil
+}
+
+// MockLimitRepository is a mock implementation of LimitRepository
+type MockLimitRepository struct {
+	LimitRepository
+	FindOverrideFunc          func(userID, operation, currency string) (*models.LimitOverride, error)
+	FindOverridesByUserIDFunc func(userID string) ([]models.LimitOverride, error)
+	SaveOverrideFunc          func(override *models.LimitOverride) error
+	DeleteOverrideFunc        func(userID, operation, currency string) (bool, error)
+	WithTxFunc                func(tx interface{}) LimitRepository
+}
+
+func (m *MockLimitRepository) WithTx(tx interface{}) LimitRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockLimitRepository) FindOverride(userID, operation, currency string) (*models.LimitOverride, error) {
+	if m.FindOverrideFunc != nil {
+		return m.FindOverrideFunc(userID, operation, currency)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockLimitRepository) FindOverridesByUserID(userID string) ([]models.LimitOverride, error) {
+	if m.FindOverridesByUserIDFunc != nil {
+		return m.FindOverridesByUserIDFunc(userID)
+	}
+	return nil, nil
+}
+
+func (m *MockLimitRepository) SaveOverride(override *models.LimitOverride) error {
+	if m.SaveOverrideFunc != nil {
+		return m.SaveOverrideFunc(override)
+	}
+	return nil
+}
+
+func (m *MockLimitRepository) DeleteOverride(userID, operation, currency string) (bool, error) {
+	if m.DeleteOverrideFunc != nil {
+		return m.DeleteOverrideFunc(userID, operation, currency)
+	}
+	return false, nil
+}
+
+// MockInterestRepository is a mock implementation of InterestRepository
+type MockInterestRepository struct {
+	InterestRepository
+	LastRunFunc                func() (*models.InterestRun, error)
+	CreateRunFunc              func(run *models.InterestRun) error
+	CreateAccrualFunc          func(accrual *models.InterestAccrual) (bool, error)
+	FindUnpostedWalletsFunc    func(throughDate string) ([]models.InterestAccrual, error)
+	FindUnpostedByWalletIDFunc func(walletID, throughDate string) ([]models.InterestAccrual, error)
+	MarkPostedFunc             func(ids []string, transactionID string, postedAt time.Time) error
+	DBFunc                     func() *gorm.DB
+	WithTxFunc                 func(tx interface{}) InterestRepository
+}
+
+func (m *MockInterestRepository) DB() *gorm.DB {
+	if m.DBFunc != nil {
+		return m.DBFunc()
+	}
+	return nil
+}
+
+func (m *MockInterestRepository) WithTx(tx interface{}) InterestRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+func (m *MockInterestRepository) LastRun() (*models.InterestRun, error) {
+	if m.LastRunFunc != nil {
+		return m.LastRunFunc()
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockInterestRepository) CreateRun(run *models.InterestRun) error {
+	if m.CreateRunFunc != nil {
+		return m.CreateRunFunc(run)
+	}
+	return nil
+}
+
+func (m *MockInterestRepository) CreateAccrual(accrual *models.InterestAccrual) (bool, error) {
+	if m.CreateAccrualFunc != nil {
+		return m.CreateAccrualFunc(accrual)
+	}
+	return true, nil
+}
+
+func (m *MockInterestRepository) FindUnpostedWallets(throughDate string) ([]models.InterestAccrual, error) {
+	if m.FindUnpostedWalletsFunc != nil {
+		return m.FindUnpostedWalletsFunc(throughDate)
+	}
+	return nil, nil
+}
+
+func (m *MockInterestRepository) FindUnpostedByWalletID(walletID, throughDate string) ([]models.InterestAccrual, error) {
+	if m.FindUnpostedByWalletIDFunc != nil {
+		return m.FindUnpostedByWalletIDFunc(walletID, throughDate)
+	}
+	return nil, nil
+}
+
+func (m *MockInterestRepository) MarkPosted(ids []string, transactionID string, postedAt time.Time) error {
+	if m.MarkPostedFunc != nil {
+		return m.MarkPostedFunc(ids, transactionID, postedAt)
+	}
+	return nil
+}
+
+// MockStatementRepository is a mock implementation of StatementRepository
+type MockStatementRepository struct {
+	StatementRepository
+	CreateFunc       func(statement *models.Statement) (bool, error)
+	FindByPeriodFunc func(userID, currency, period string) (*models.Statement, error)
+	FindByUserIDFunc func(userID string) ([]models.Statement, error)
+	FindRunFunc      func(period string) (*models.StatementRun, error)
+	CreateRunFunc    func(run *models.StatementRun) error
+}
+
+func (m *MockStatementRepository) Create(statement *models.Statement) (bool, error) {
+	if m.CreateFunc != nil {
+		return m.CreateFunc(statement)
+	}
+	return true, nil
+}
+
+func (m *MockStatementRepository) FindByPeriod(userID, currency, period string) (*models.Statement, error) {
+	if m.FindByPeriodFunc != nil {
+		return m.FindByPeriodFunc(userID, currency, period)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockStatementRepository) FindByUserID(userID string) ([]models.Statement, error) {
+	if m.FindByUserIDFunc != nil {
+		return m.FindByUserIDFunc(userID)
+	}
+	return nil, nil
+}
+
+func (m *MockStatementRepository) FindRun(period string) (*models.StatementRun, error) {
+	if m.FindRunFunc != nil {
+		return m.FindRunFunc(period)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockStatementRepository) CreateRun(run *models.StatementRun) error {
+	if m.CreateRunFunc != nil {
+		return m.CreateRunFunc(run)
+	}
+	return nil
+}
+
+// MockTransactionLogRepository is a mock implementation of TransactionLogRepository
+type MockTransactionLogRepository struct {
+	TransactionLogRepository
+	HeadFunc                        func() (*models.TransactionLogHead, error)
+	AppendPendingFunc               func(limit int) ([]models.TransactionLogEntry, error)
+	HasPendingEntriesFunc           func(transactionID string) (bool, error)
+	EachEntryFunc                   func(fn func(entry *models.TransactionLogEntry) error) error
+	FindLatestEntryFunc             func(transactionID string) (*models.TransactionLogEntry, error)
+	FindFirstMissingTransactionFunc func() (*models.TransactionLogEntry, error)
+	FindEntriesByUserIDFunc         func(userID string, after, until int64, limit int) ([]models.TransactionLogEntry, error)
+	CreateCheckpointFunc            func(checkpoint *models.TransactionLogCheckpoint) error
+	LastCheckpointFunc              func() (*models.TransactionLogCheckpoint, error)
+	FindCheckpointsFunc             func() ([]models.TransactionLogCheckpoint, error)
+}
+
+func (m *MockTransactionLogRepository) Head() (*models.TransactionLogHead, error) {
+	if m.HeadFunc != nil {
+		return m.HeadFunc()
+	}
+	return &models.TransactionLogHead{ID: models.TransactionLogHeadID}, nil
+}
+
+func (m *MockTransactionLogRepository) AppendPending(limit int) ([]models.TransactionLogEntry, error) {
+	if m.AppendPendingFunc != nil {
+		return m.AppendPendingFunc(limit)
+	}
+	return nil, nil
+}
+
+func (m *MockTransactionLogRepository) HasPendingEntries(transactionID string) (bool, error) {
+	if m.HasPendingEntriesFunc != nil {
+		return m.HasPendingEntriesFunc(transactionID)
+	}
+	return false, nil
+}
+
+func (m *MockTransactionLogRepository) EachEntry(fn func(entry *models.TransactionLogEntry) error) error {
+	if m.EachEntryFunc != nil {
+		return m.EachEntryFunc(fn)
+	}
+	return nil
+}
+
+func (m *MockTransactionLogRepository) FindLatestEntry(transactionID string) (*models.TransactionLogEntry, error) {
+	if m.FindLatestEntryFunc != nil {
+		return m.FindLatestEntryFunc(transactionID)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockTransactionLogRepository) FindFirstMissingTransaction() (*models.TransactionLogEntry, error) {
+	if m.FindFirstMissingTransactionFunc != nil {
+		return m.FindFirstMissingTransactionFunc()
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockTransactionLogRepository) FindEntriesByUserID(userID string, after, until int64, limit int) ([]models.TransactionLogEntry, error) {
+	if m.FindEntriesByUserIDFunc != nil {
+		return m.FindEntriesByUserIDFunc(userID, after, until, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockTransactionLogRepository) CreateCheckpoint(checkpoint *models.TransactionLogCheckpoint) error {
+	if m.CreateCheckpointFunc != nil {
+		return m.CreateCheckpointFunc(checkpoint)
+	}
+	return nil
+}
+
+func (m *MockTransactionLogRepository) LastCheckpoint() (*models.TransactionLogCheckpoint, error) {
+	if m.LastCheckpointFunc != nil {
+		return m.LastCheckpointFunc()
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockTransactionLogRepository) FindCheckpoints() ([]models.TransactionLogCheckpoint, error) {
+	if m.FindCheckpointsFunc != nil {
+		return m.FindCheckpointsFunc()
+	}
+	return nil, nil
+}
+
+// MockReconciliationRepository is a mock implementation of ReconciliationRepository
+type MockReconciliationRepository struct {
+	ReconciliationRepository
+	CreateRunFunc      func(run *models.ReconciliationRun) error
+	UpdateRunFunc      func(run *models.ReconciliationRun) error
+	CreateMismatchFunc func(mismatch *models.ReconciliationMismatch) error
+	FindRunsFunc       func(limit int) ([]models.ReconciliationRun, error)
+	FindRunByIDFunc    func(id string) (*models.ReconciliationRun, error)
+}
+
+func (m *MockReconciliationRepository) CreateRun(run *models.ReconciliationRun) error {
+	if m.CreateRunFunc != nil {
+		return m.CreateRunFunc(run)
+	}
+	return nil
+}
+
+func (m *MockReconciliationRepository) UpdateRun(run *models.ReconciliationRun) error {
+	if m.UpdateRunFunc != nil {
+		return m.UpdateRunFunc(run)
+	}
+	return nil
+}
+
+func (m *MockReconciliationRepository) CreateMismatch(mismatch *models.ReconciliationMismatch) error {
+	if m.CreateMismatchFunc != nil {
+		return m.CreateMismatchFunc(mismatch)
+	}
+	return nil
+}
+
+func (m *MockReconciliationRepository) FindRuns(limit int) ([]models.ReconciliationRun, error) {
+	if m.FindRunsFunc != nil {
+		return m.FindRunsFunc(limit)
+	}
+	return nil, nil
+}
+
+func (m *MockReconciliationRepository) FindRunByID(id string) (*models.ReconciliationRun, error) {
+	if m.FindRunByIDFunc != nil {
+		return m.FindRunByIDFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// MockOutboxRepository is a mock implementation of OutboxRepository. Events
+// records every event created through it.
+type MockOutboxRepository struct {
+	OutboxRepository
+	Events                        []*models.OutboxEvent
+	CreateFunc                    func(event *models.OutboxEvent) error
+	FindUndispatchedForUpdateFunc func(limit int) ([]models.OutboxEvent, error)
+	MarkDispatchedFunc            func(ids []string, at time.Time) error
+	FindByIDsFunc                 func(ids []string) ([]models.OutboxEvent, error)
+	DBFunc                        func() *gorm.DB
+	WithTxFunc                    func(tx interface{}) OutboxRepository
+}
+
+func (m *MockOutboxRepository) Create(event *models.OutboxEvent) error {
+	m.Events = append(m.Events, event)
+	if m.CreateFunc != nil {
+		return m.CreateFunc(event)
+	}
+	return nil
+}
+
+func (m *MockOutboxRepository) FindUndispatchedForUpdate(limit int) ([]models.OutboxEvent, error) {
+	if m.FindUndispatchedForUpdateFunc != nil {
+		return m.FindUndispatchedForUpdateFunc(limit)
+	}
+	return nil, nil
+}
+
+func (m *MockOutboxRepository) MarkDispatched(ids []string, at time.Time) error {
+	if m.MarkDispatchedFunc != nil {
+		return m.MarkDispatchedFunc(ids, at)
+	}
+	return nil
+}
+
+func (m *MockOutboxRepository) FindByIDs(ids []string) ([]models.OutboxEvent, error) {
+	if m.FindByIDsFunc != nil {
+		return m.FindByIDsFunc(ids)
+	}
+	return nil, nil
+}
+
+func (m *MockOutboxRepository) DB() *gorm.DB {
+	if m.DBFunc != nil {
+		return m.DBFunc()
+	}
+	return nil
+}
+
+func (m *MockOutboxRepository) WithTx(tx interface{}) OutboxRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
+
+// MockWebhookRepository is a mock implementation of WebhookRepository
+type MockWebhookRepository struct {
+	WebhookRepository
+	CreateEndpointFunc             func(endpoint *models.WebhookEndpoint) error
+	UpdateEndpointFunc             func(endpoint *models.WebhookEndpoint) error
+	FindEndpointByIDFunc           func(id string) (*models.WebhookEndpoint, error)
+	FindEndpointsFunc              func() ([]models.WebhookEndpoint, error)
+	CreateDeliveriesFunc           func(deliveries []models.WebhookDelivery) error
+	FindDueDeliveriesForUpdateFunc func(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	FindDeliveryByIDFunc           func(id string) (*models.WebhookDelivery, error)
+	FindDeliveriesFunc             func(status, endpointID string, limit int) ([]models.WebhookDelivery, error)
+	UpdateDeliveryFunc             func(delivery *models.WebhookDelivery) error
+	UpdateLeasedDeliveryFunc       func(delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error)
+	DBFunc                         func() *gorm.DB
+	WithTxFunc                     func(tx interface{}) WebhookRepository
+}
+
+func (m *MockWebhookRepository) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	if m.CreateEndpointFunc != nil {
+		return m.CreateEndpointFunc(endpoint)
+	}
+	return nil
+}
+
+func (m *MockWebhookRepository) UpdateEndpoint(endpoint *models.WebhookEndpoint) error {
+	if m.UpdateEndpointFunc != nil {
+		return m.UpdateEndpointFunc(endpoint)
+	}
+	return nil
+}
+
+func (m *MockWebhookRepository) FindEndpointByID(id string) (*models.WebhookEndpoint, error) {
+	if m.FindEndpointByIDFunc != nil {
+		return m.FindEndpointByIDFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockWebhookRepository) FindEndpoints() ([]models.WebhookEndpoint, error) {
+	if m.FindEndpointsFunc != nil {
+		return m.FindEndpointsFunc()
+	}
+	return nil, nil
+}
+
+func (m *MockWebhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	if m.CreateDeliveriesFunc != nil {
+		return m.CreateDeliveriesFunc(deliveries)
+	}
+	return nil
+}
+
+func (m *MockWebhookRepository) FindDueDeliveriesForUpdate(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	if m.FindDueDeliveriesForUpdateFunc != nil {
+		return m.FindDueDeliveriesForUpdateFunc(now, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockWebhookRepository) FindDeliveryByID(id string) (*models.WebhookDelivery, error) {
+	if m.FindDeliveryByIDFunc != nil {
+		return m.FindDeliveryByIDFunc(id)
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *MockWebhookRepository) FindDeliveries(status, endpointID string, limit int) ([]models.WebhookDelivery, error) {
+	if m.FindDeliveriesFunc != nil {
+		return m.FindDeliveriesFunc(status, endpointID, limit)
+	}
+	return nil, nil
+}
+
+func (m *MockWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	if m.UpdateDeliveryFunc != nil {
+		return m.UpdateDeliveryFunc(delivery)
+	}
+	return nil
+}
+
+func (m *MockWebhookRepository) UpdateLeasedDelivery(delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error) {
+	if m.UpdateLeasedDeliveryFunc != nil {
+		return m.UpdateLeasedDeliveryFunc(delivery, leaseUntil)
+	}
+	return true, nil
+}
+
+func (m *MockWebhookRepository) DB() *gorm.DB {
+	if m.DBFunc != nil {
+		return m.DBFunc()
+	}
+	return nil
+}
+
+func (m *MockWebhookRepository) WithTx(tx interface{}) WebhookRepository {
+	if m.WithTxFunc != nil {
+		return m.WithTxFunc(tx)
+	}
+	return m
+}
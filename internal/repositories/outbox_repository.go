@@ -0,0 +1,67 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) Create(event *models.OutboxEvent) error {
+	return r.db.Create(event).Error
+}
+
+// FindUndispatchedForUpdate locks the oldest events not yet dispatched.
+// Events locked by another dispatcher are skipped.
+func (r *outboxRepository) FindUndispatchedForUpdate(limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("dispatched_at IS NULL").
+		Order("created_at, id").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) MarkDispatched(ids []string, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return r.db.Model(&models.OutboxEvent{}).Where("id IN ?", ids).Update("dispatched_at", at).Error
+}
+
+func (r *outboxRepository) FindByIDs(ids []string) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	if len(ids) == 0 {
+		return events, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func (r *outboxRepository) DB() *gorm.DB {
+	return r.db
+}
+
+func (r *outboxRepository) WithTx(tx interface{}) OutboxRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &outboxRepository{db: txDB}
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type paymentRequestRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentRequestRepository(db *gorm.DB) PaymentRequestRepository {
+	return &paymentRequestRepository{db: db}
+}
+
+func (r *paymentRequestRepository) Create(request *models.PaymentRequest) error {
+	return r.db.Create(request).Error
+}
+
+func (r *paymentRequestRepository) FindByIDForUpdate(id string) (*models.PaymentRequest, error) {
+	var request models.PaymentRequest
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&request).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// FindByPayerID returns the requests addressed to a payer, newest first,
+// optionally only those with the given status.
+func (r *paymentRequestRepository) FindByPayerID(payerID, status string) ([]models.PaymentRequest, error) {
+	return r.find("payer_id", payerID, status)
+}
+
+// FindByRequesterID returns the requests a user has sent, newest first,
+// optionally only those with the given status.
+func (r *paymentRequestRepository) FindByRequesterID(requesterID, status string) ([]models.PaymentRequest, error) {
+	return r.find("requester_id", requesterID, status)
+}
+
+func (r *paymentRequestRepository) find(column, userID, status string) ([]models.PaymentRequest, error) {
+	var requests []models.PaymentRequest
+	query := r.db.Where(column+" = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+func (r *paymentRequestRepository) Update(request *models.PaymentRequest) error {
+	return r.db.Save(request).Error
+}
+
+// ExpirePending marks every pending request that expired at or before now as
+// expired and returns how many there were.
+func (r *paymentRequestRepository) ExpirePending(now time.Time) (int64, error) {
+	result := r.db.Model(&models.PaymentRequest{}).
+		Where("status = ? AND expires_at <= ?", models.PaymentRequestStatusPending, now).
+		Updates(map[string]interface{}{"status": models.PaymentRequestStatusExpired, "updated_at": now})
+	return result.RowsAffected, result.Error
+}
+
+func (r *paymentRequestRepository) DB() *gorm.DB {
+	return r.db
+}
+
+func (r *paymentRequestRepository) WithTx(tx interface{}) PaymentRequestRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &paymentRequestRepository{db: txDB}
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// payoutItemBatchSize is how many payout items are inserted per statement.
+const payoutItemBatchSize = 500
+
+type payoutRepository struct {
+	db *gorm.DB
+}
+
+func NewPayoutRepository(db *gorm.DB) PayoutRepository {
+	return &payoutRepository{db: db}
+}
+
+func (r *payoutRepository) CreateJob(job *models.PayoutJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *payoutRepository) CreateItems(items []models.PayoutItem) error {
+	return r.db.CreateInBatches(items, payoutItemBatchSize).Error
+}
+
+func (r *payoutRepository) FindJobByID(id string) (*models.PayoutJob, error) {
+	var job models.PayoutJob
+	if err := r.db.Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *payoutRepository) FindJobByIDForUpdate(id string) (*models.PayoutJob, error) {
+	var job models.PayoutJob
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&job).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (r *payoutRepository) FindJobsByUserID(userID string) ([]models.PayoutJob, error) {
+	var jobs []models.PayoutJob
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	return jobs, nil
+}
+
+// FindDueJobs returns up to limit pending or running jobs that are not backing
+// off at now, in the order they became due. A job that backs off goes behind
+// the jobs that were waiting while it did.
+func (r *payoutRepository) FindDueJobs(now time.Time, limit int) ([]models.PayoutJob, error) {
+	var jobs []models.PayoutJob
+	err := r.db.Where("status IN ?", []string{models.PayoutJobStatusPending, models.PayoutJobStatusRunning}).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Order("COALESCE(next_attempt_at, created_at), created_at").
+		Limit(limit).
+		Find(&jobs).Error
+	return jobs, err
+}
+
+func (r *payoutRepository) UpdateJob(job *models.PayoutJob) error {
+	return r.db.Save(job).Error
+}
+
+// FindItemsByJobID returns every item of a job in file order.
+func (r *payoutRepository) FindItemsByJobID(jobID string) ([]models.PayoutItem, error) {
+	var items []models.PayoutItem
+	if err := r.db.Where("job_id = ?", jobID).Order("line").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func (r *payoutRepository) FindItemByID(id string) (*models.PayoutItem, error) {
+	var item models.PayoutItem
+	if err := r.db.Where("id = ?", id).First(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// FindPendingItems returns up to limit unpaid items of a job in file order.
+func (r *payoutRepository) FindPendingItems(jobID string, limit int) ([]models.PayoutItem, error) {
+	var items []models.PayoutItem
+	err := r.db.Where("job_id = ? AND status = ?", jobID, models.PayoutItemStatusPending).
+		Order("line").
+		Limit(limit).
+		Find(&items).Error
+	return items, err
+}
+
+func (r *payoutRepository) UpdateItem(item *models.PayoutItem) error {
+	return r.db.Save(item).Error
+}
+
+func (r *payoutRepository) DB() *gorm.DB {
+	return r.db
+}
+
+func (r *payoutRepository) WithTx(tx interface{}) PayoutRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &payoutRepository{db: txDB}
+}
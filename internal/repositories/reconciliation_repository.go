@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+)
+
+type reconciliationRepository struct {
+	db *gorm.DB
+}
+
+func NewReconciliationRepository(db *gorm.DB) ReconciliationRepository {
+	return &reconciliationRepository{db: db}
+}
+
+func (r *reconciliationRepository) CreateRun(run *models.ReconciliationRun) error {
+	return r.db.Create(run).Error
+}
+
+func (r *reconciliationRepository) UpdateRun(run *models.ReconciliationRun) error {
+	return r.db.Save(run).Error
+}
+
+func (r *reconciliationRepository) CreateMismatch(mismatch *models.ReconciliationMismatch) error {
+	return r.db.Create(mismatch).Error
+}
+
+// FindRuns returns the latest runs, newest first, without their mismatches.
+func (r *reconciliationRepository) FindRuns(limit int) ([]models.ReconciliationRun, error) {
+	var runs []models.ReconciliationRun
+	if err := r.db.Order("started_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// FindRunByID returns a run with its mismatches.
+func (r *reconciliationRepository) FindRunByID(id string) (*models.ReconciliationRun, error) {
+	var run models.ReconciliationRun
+	if err := r.db.Where("id = ?", id).First(&run).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("run_id = ?", id).Order("currency, user_id").Find(&run.Mismatches).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
@@ -0,0 +1,84 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type scheduleRepository struct {
+	db *gorm.DB
+}
+
+func NewScheduleRepository(db *gorm.DB) ScheduleRepository {
+	return &scheduleRepository{db: db}
+}
+
+func (r *scheduleRepository) Create(schedule *models.Schedule) error {
+	return r.db.Create(schedule).Error
+}
+
+func (r *scheduleRepository) FindByID(id string) (*models.Schedule, error) {
+	var schedule models.Schedule
+	if err := r.db.Where("id = ?", id).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *scheduleRepository) FindByIDForUpdate(id string) (*models.Schedule, error) {
+	var schedule models.Schedule
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&schedule).Error; err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (r *scheduleRepository) FindByUserID(userID string) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC").Find(&schedules).Error; err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// FindDue returns up to limit active schedules whose next run is at or before now.
+func (r *scheduleRepository) FindDue(now time.Time, limit int) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	err := r.db.Where("status = ? AND next_run_at <= ?", models.ScheduleStatusActive, now).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&schedules).Error
+	return schedules, err
+}
+
+func (r *scheduleRepository) Update(schedule *models.Schedule) error {
+	return r.db.Save(schedule).Error
+}
+
+func (r *scheduleRepository) CreateExecution(execution *models.ScheduleExecution) error {
+	return r.db.Create(execution).Error
+}
+
+func (r *scheduleRepository) FindExecutionsByScheduleID(scheduleID string) ([]models.ScheduleExecution, error) {
+	var executions []models.ScheduleExecution
+	if err := r.db.Where("schedule_id = ?", scheduleID).Order("created_at DESC").Find(&executions).Error; err != nil {
+		return nil, err
+	}
+	return executions, nil
+}
+
+func (r *scheduleRepository) DB() *gorm.DB {
+	return r.db
+}
+
+func (r *scheduleRepository) WithTx(tx interface{}) ScheduleRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &scheduleRepository{db: txDB}
+}
@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// statementLineBatchSize is how many statement lines are inserted per
+// statement.
+const statementLineBatchSize = 500
+
+type statementRepository struct {
+	db *gorm.DB
+}
+
+func NewStatementRepository(db *gorm.DB) StatementRepository {
+	return &statementRepository{db: db}
+}
+
+// Create stores a statement and its lines, and reports whether it was new. A
+// statement that already exists for the same user, currency and period is
+// left untouched.
+func (r *statementRepository) Create(statement *models.Statement) (bool, error) {
+	created := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(statement)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		created = true
+		if len(statement.Lines) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(statement.Lines, statementLineBatchSize).Error
+	})
+	return created, err
+}
+
+// FindByPeriod returns a stored statement with its lines.
+func (r *statementRepository) FindByPeriod(userID, currency, period string) (*models.Statement, error) {
+	var statement models.Statement
+	if err := r.db.Where("user_id = ? AND currency = ? AND period = ?", userID, currency, period).First(&statement).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.Where("statement_id = ?", statement.ID).Order("position").Find(&statement.Lines).Error; err != nil {
+		return nil, err
+	}
+	return &statement, nil
+}
+
+// FindByUserID returns a user's stored statements, newest first, without
+// their lines.
+func (r *statementRepository) FindByUserID(userID string) ([]models.Statement, error) {
+	var statements []models.Statement
+	if err := r.db.Where("user_id = ?", userID).Order("period DESC, currency").Find(&statements).Error; err != nil {
+		return nil, err
+	}
+	return statements, nil
+}
+
+func (r *statementRepository) FindRun(period string) (*models.StatementRun, error) {
+	var run models.StatementRun
+	if err := r.db.Where("period = ?", period).First(&run).Error; err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (r *statementRepository) CreateRun(run *models.StatementRun) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(run).Error
+}
@@ -0,0 +1,200 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type transactionLogRepository struct {
+	db *gorm.DB
+}
+
+func NewTransactionLogRepository(db *gorm.DB) TransactionLogRepository {
+	return &transactionLogRepository{db: db}
+}
+
+// enqueueTransactionLog queues the version of a transaction the caller has
+// just written to be appended to the log. It must run in the database
+// transaction that wrote it, after the write, so that versions of the same
+// transaction are queued in the order its row lock lets them commit. Nothing
+// else is locked: the version reaches the log once it has committed and the
+// sequencer appends it.
+func enqueueTransactionLog(tx *gorm.DB, transaction *models.Transaction, operation string) error {
+	return tx.Create(&models.PendingTransactionLogEntry{
+		TransactionID: transaction.ID,
+		Operation:     operation,
+		Payload:       transaction.LogPayload(),
+		CreatedAt:     time.Now(),
+	}).Error
+}
+
+// AppendPending is the sequencer: it appends up to limit committed pending
+// entries to the log in the order they were queued, sets each transaction's
+// hashes from its new entry, and returns the entries appended. The head is
+// locked only while this runs, so wallet operations never wait on it, and
+// concurrent sequencers take turns.
+func (r *transactionLogRepository) AppendPending(limit int) ([]models.TransactionLogEntry, error) {
+	var entries []models.TransactionLogEntry
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var head models.TransactionLogHead
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", models.TransactionLogHeadID).First(&head).Error; err != nil {
+			return err
+		}
+
+		var pending []models.PendingTransactionLogEntry
+		if err := tx.Order("id").Limit(limit).Find(&pending).Error; err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+
+		now := time.Now()
+		ids := make([]int64, 0, len(pending))
+		for _, version := range pending {
+			entry := models.TransactionLogEntry{
+				Sequence:      head.Sequence + 1,
+				TransactionID: version.TransactionID,
+				Operation:     version.Operation,
+				Payload:       version.Payload,
+				PrevHash:      head.Hash,
+				CreatedAt:     now,
+			}
+			entry.Hash = models.TransactionLogHash(entry.PrevHash, entry.Sequence, entry.Operation, entry.Payload)
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+			if entry.Operation != models.TransactionLogOperationDelete {
+				err := tx.Model(&models.Transaction{}).Where("id = ?", entry.TransactionID).
+					UpdateColumns(map[string]interface{}{"hash": entry.Hash, "prev_hash": entry.PrevHash}).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			head.Sequence = entry.Sequence
+			head.Hash = entry.Hash
+			entries = append(entries, entry)
+			ids = append(ids, version.ID)
+		}
+
+		if err := tx.Delete(&models.PendingTransactionLogEntry{}, "id IN ?", ids).Error; err != nil {
+			return err
+		}
+		head.UpdatedAt = now
+		return tx.Save(&head).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// HasPendingEntries reports whether versions of a transaction are still
+// waiting to be appended to the log.
+func (r *transactionLogRepository) HasPendingEntries(transactionID string) (bool, error) {
+	var count int64
+	if err := r.db.Model(&models.PendingTransactionLogEntry{}).Where("transaction_id = ?", transactionID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (r *transactionLogRepository) Head() (*models.TransactionLogHead, error) {
+	var head models.TransactionLogHead
+	if err := r.db.Where("id = ?", models.TransactionLogHeadID).First(&head).Error; err != nil {
+		return nil, err
+	}
+	return &head, nil
+}
+
+// EachEntry calls fn with every entry of the log in sequence order, reading
+// them one at a time. Iteration stops at the first error fn returns.
+func (r *transactionLogRepository) EachEntry(fn func(entry *models.TransactionLogEntry) error) error {
+	rows, err := r.db.Model(&models.TransactionLogEntry{}).Order("sequence").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.TransactionLogEntry
+		if err := r.db.ScanRows(rows, &entry); err != nil {
+			return err
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FindLatestEntry returns the entry of a transaction's latest version.
+func (r *transactionLogRepository) FindLatestEntry(transactionID string) (*models.TransactionLogEntry, error) {
+	var entry models.TransactionLogEntry
+	if err := r.db.Where("transaction_id = ?", transactionID).Order("sequence DESC").First(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// FindFirstMissingTransaction returns the earliest latest-version entry of a
+// transaction that the log says still exists but that is no longer in the
+// transactions table, and whose deletion is not waiting to be appended.
+func (r *transactionLogRepository) FindFirstMissingTransaction() (*models.TransactionLogEntry, error) {
+	var entry models.TransactionLogEntry
+	err := r.db.Raw(`SELECT e.* FROM transaction_log_entries e
+		WHERE e.sequence = (SELECT MAX(l.sequence) FROM transaction_log_entries l WHERE l.transaction_id = e.transaction_id)
+		AND e.operation <> ?
+		AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.id = e.transaction_id)
+		AND NOT EXISTS (SELECT 1 FROM pending_transaction_log_entries p WHERE p.transaction_id = e.transaction_id)
+		ORDER BY e.sequence LIMIT 1`, models.TransactionLogOperationDelete).Scan(&entry).Error
+	if err != nil {
+		return nil, err
+	}
+	if entry.Sequence == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &entry, nil
+}
+
+// FindEntriesByUserID returns, in sequence order, the entries after sequence
+// after and up to until of the transactions the user sent or received.
+// Entries of transactions that no longer exist are left out.
+func (r *transactionLogRepository) FindEntriesByUserID(userID string, after, until int64, limit int) ([]models.TransactionLogEntry, error) {
+	var entries []models.TransactionLogEntry
+	err := r.db.Raw(`SELECT e.* FROM transaction_log_entries e
+		JOIN transactions t ON t.id = e.transaction_id
+		WHERE e.sequence > ? AND e.sequence <= ?
+		AND (t.from_user_id = ? OR t.to_user_id = ?)
+		ORDER BY e.sequence LIMIT ?`, after, until, userID, userID, limit).Scan(&entries).Error
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (r *transactionLogRepository) CreateCheckpoint(checkpoint *models.TransactionLogCheckpoint) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(checkpoint).Error
+}
+
+func (r *transactionLogRepository) LastCheckpoint() (*models.TransactionLogCheckpoint, error) {
+	var checkpoint models.TransactionLogCheckpoint
+	if err := r.db.Order("sequence DESC").First(&checkpoint).Error; err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// FindCheckpoints returns every checkpoint in sequence order.
+func (r *transactionLogRepository) FindCheckpoints() ([]models.TransactionLogCheckpoint, error) {
+	var checkpoints []models.TransactionLogCheckpoint
+	if err := r.db.Order("sequence").Find(&checkpoints).Error; err != nil {
+		return nil, err
+	}
+	return checkpoints, nil
+}
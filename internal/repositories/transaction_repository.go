@@ -1,9 +1,13 @@
 package repositories
 
 import (
+	"time"
+
 	"wallet/internal/models"
+	"wallet/internal/money"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type transactionRepository struct {
@@ -14,8 +18,45 @@ func NewTransactionRepository(db *gorm.DB) TransactionRepository {
 	return &transactionRepository{db: db}
 }
 
+// Create stores a new transaction and queues it to be appended to the
+// transaction log.
 func (r *transactionRepository) Create(transaction *models.Transaction) error {
-	return r.db.Create(transaction).Error
+	transaction.TruncateTimes()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(transaction).Error; err != nil {
+			return err
+		}
+		return enqueueTransactionLog(tx, transaction, models.TransactionLogOperationCreate)
+	})
+}
+
+func (r *transactionRepository) FindByID(id string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.Where("id = ?", id).First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *transactionRepository) FindByIDs(ids []string) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if len(ids) == 0 {
+		return transactions, nil
+	}
+	if err := r.db.Where("id IN ?", ids).Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// FindByIDForUpdate loads the transaction with a row lock, so that concurrent
+// refunds of the same transaction are serialized.
+func (r *transactionRepository) FindByIDForUpdate(id string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
 }
 
 func (r *transactionRepository) FindByUserID(userID string, page, pageSize int, transactionType, status string) ([]models.Transaction, error) {
@@ -37,12 +78,154 @@ func (r *transactionRepository) FindByUserID(userID string, page, pageSize int,
 	return transactions, nil
 }
 
+// EachByUserID calls fn with each of a user's transactions matching filter,
+// oldest first. Rows are read one at a time, so the full history is never held
+// in memory. Iteration stops at the first error fn returns.
+func (r *transactionRepository) EachByUserID(userID string, filter models.TransactionFilter, fn func(transaction *models.Transaction) error) error {
+	query := r.db.Model(&models.Transaction{}).Where("from_user_id = ? OR to_user_id = ?", userID, userID)
+
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency = ? OR to_currency = ?", filter.Currency, filter.Currency)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	rows, err := query.Order("created_at, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := r.db.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(&transaction); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Update saves a transaction and queues its new version to be appended to
+// the transaction log. Its hashes are left alone, as only the sequencer sets
+// them.
 func (r *transactionRepository) Update(transaction *models.Transaction) error {
-	return r.db.Save(transaction).Error
+	transaction.TruncateTimes()
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Hash", "PrevHash").Save(transaction).Error; err != nil {
+			return err
+		}
+		return enqueueTransactionLog(tx, transaction, models.TransactionLogOperationUpdate)
+	})
 }
 
+// Delete removes a transaction and queues its last version to be recorded as
+// deleted in the transaction log.
 func (r *transactionRepository) Delete(id string) error {
-	return r.db.Delete(&models.Transaction{}, "id = ?", id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var transaction models.Transaction
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&transaction).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Transaction{}, "id = ?", id).Error; err != nil {
+			return err
+		}
+		return enqueueTransactionLog(tx, &transaction, models.TransactionLogOperationDelete)
+	})
+}
+
+// Each calls fn with every transaction, oldest first, reading them one at a
+// time. Iteration stops at the first error fn returns.
+func (r *transactionRepository) Each(fn func(transaction *models.Transaction) error) error {
+	rows, err := r.db.Model(&models.Transaction{}).Order("created_at, id").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var transaction models.Transaction
+		if err := r.db.ScanRows(rows, &transaction); err != nil {
+			return err
+		}
+		if err := fn(&transaction); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// FindByBatchID returns the transfers of a batch in the order they were made.
+func (r *transactionRepository) FindByBatchID(batchID string) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	if err := r.db.Where("batch_id = ?", batchID).Order("created_at, id").Find(&transactions).Error; err != nil {
+		return nil, err
+	}
+	return transactions, nil
+}
+
+// SumOutgoingSince totals the transactions of a type a user has sent in a
+// currency since a point in time. Pending authorizations count, as they will
+// usually be captured; failed, voided and expired transactions do not.
+func (r *transactionRepository) SumOutgoingSince(userID, transactionType, currency string, since time.Time) (money.Amount, int, error) {
+	var result struct {
+		Total money.Amount
+		Count int
+	}
+	err := r.db.Model(&models.Transaction{}).
+		Select("COALESCE(SUM(amount), 0) AS total, COUNT(*) AS count").
+		Where("from_user_id = ? AND type = ? AND currency = ? AND created_at >= ?", userID, transactionType, currency, since).
+		Where("status NOT IN ?", []string{models.TransactionStatusFailed, models.TransactionStatusVoided, models.TransactionStatusExpired}).
+		Scan(&result).Error
+	return result.Total, result.Count, err
+}
+
+// SumSettled recomputes a user's balance in a currency from their settled
+// transactions: deposits and money received add to it, and everything else
+// the user sent takes from it. The amount received on a cross-currency
+// transfer is its ToAmount. It also returns how many transactions counted.
+func (r *transactionRepository) SumSettled(userID, currency string) (money.Amount, int, error) {
+	var result struct {
+		Total money.Amount
+		Count int
+	}
+	err := r.db.Model(&models.Transaction{}).
+		Select(`COALESCE(SUM(
+			CASE WHEN from_user_id = ? AND currency = ? THEN
+				CASE WHEN type = ? THEN amount ELSE -amount END
+			ELSE 0 END +
+			CASE WHEN to_user_id = ? AND COALESCE(NULLIF(to_currency, ''), currency) = ? THEN
+				CASE WHEN COALESCE(to_currency, '') <> '' THEN to_amount ELSE amount END
+			ELSE 0 END
+		), 0) AS total, COUNT(*) AS count`, userID, currency, models.TransactionTypeDeposit, userID, currency).
+		Where("(from_user_id = ? AND currency = ?) OR (to_user_id = ? AND COALESCE(NULLIF(to_currency, ''), currency) = ?)", userID, currency, userID, currency).
+		Where("status IN ?", models.SettledTransactionStatuses).
+		Scan(&result).Error
+	return result.Total, result.Count, err
+}
+
+func (r *transactionRepository) CreateBatch(batch *models.TransferBatch) error {
+	return r.db.Create(batch).Error
+}
+
+func (r *transactionRepository) FindBatchByID(id string) (*models.TransferBatch, error) {
+	var batch models.TransferBatch
+	if err := r.db.Where("id = ?", id).First(&batch).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
 }
 
 func (r *transactionRepository) WithTx(tx interface{}) TransactionRepository {
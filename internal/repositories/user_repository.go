@@ -1,10 +1,16 @@
 package repositories
 
 import (
+	"errors"
+
 	"wallet/internal/models"
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
+// uniqueViolation is the Postgres error code for a duplicate key.
+const uniqueViolation = "23505"
+
 type userRepository struct {
 	db *gorm.DB
 }
@@ -25,6 +31,22 @@ func (r *userRepository) FindByEmail(email string) (*models.User, error) {
 	return &user, nil
 }
 
+func (r *userRepository) FindByPhone(phone string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("phone = ?", phone).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) FindByHandle(handle string) (*models.User, error) {
+	var user models.User
+	if err := r.db.Where("handle = ?", handle).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
 func (r *userRepository) FindByID(id string) (*models.User, error) {
 	var user models.User
 	if err := r.db.Where("id = ?", id).First(&user).Error; err != nil {
@@ -33,8 +55,15 @@ func (r *userRepository) FindByID(id string) (*models.User, error) {
 	return &user, nil
 }
 
+// Update saves a user, returning gorm.ErrDuplicatedKey if their email, phone
+// number or handle was taken by another user in the meantime.
 func (r *userRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+	err := r.db.Save(user).Error
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return gorm.ErrDuplicatedKey
+	}
+	return err
 }
 
 func (r *userRepository) Delete(id string) error {
@@ -4,6 +4,7 @@ import (
 	"wallet/internal/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type walletRepository struct {
@@ -18,14 +19,77 @@ func (r *walletRepository) Create(wallet *models.Wallet) error {
 	return r.db.Create(wallet).Error
 }
 
-func (r *walletRepository) FindByUserID(userID string) (*models.Wallet, error) {
+// CreateIfMissing creates a wallet unless its user already has one in the
+// same currency, e.g. one created concurrently.
+func (r *walletRepository) CreateIfMissing(wallet *models.Wallet) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(wallet).Error
+}
+
+func (r *walletRepository) FindByID(id string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	if err := r.db.Where("id = ?", id).First(&wallet).Error; err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// FindByIDForUpdate loads a wallet by ID with a row lock; see
+// FindByUserIDForUpdate.
+func (r *walletRepository) FindByIDForUpdate(id string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", id).First(&wallet).Error; err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+func (r *walletRepository) FindByUserID(userID, currency string) (*models.Wallet, error) {
+	var wallet models.Wallet
+	if err := r.db.Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error; err != nil {
+		return nil, err
+	}
+	return &wallet, nil
+}
+
+// FindByUserIDForUpdate loads the wallet with a row lock (SELECT ... FOR UPDATE).
+// It must be called on a repository bound to a transaction via WithTx; the lock
+// is held until that transaction commits or rolls back.
+func (r *walletRepository) FindByUserIDForUpdate(userID, currency string) (*models.Wallet, error) {
 	var wallet models.Wallet
-	if err := r.db.Where("user_id = ?", userID).First(&wallet).Error; err != nil {
+	if err := r.db.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ? AND currency = ?", userID, currency).First(&wallet).Error; err != nil {
 		return nil, err
 	}
 	return &wallet, nil
 }
 
+func (r *walletRepository) ListByUserID(userID string) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	if err := r.db.Where("user_id = ?", userID).Order("currency").Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+// FindByCurrency pages through the wallets of a currency in ID order,
+// returning up to limit wallets after afterID.
+func (r *walletRepository) FindByCurrency(currency, afterID string, limit int) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	if err := r.db.Where("currency = ? AND id > ?", currency, afterID).Order("id").Limit(limit).Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
+// FindAll pages through every wallet in ID order, returning up to limit
+// wallets after afterID.
+func (r *walletRepository) FindAll(afterID string, limit int) ([]models.Wallet, error) {
+	var wallets []models.Wallet
+	if err := r.db.Where("id > ?", afterID).Order("id").Limit(limit).Find(&wallets).Error; err != nil {
+		return nil, err
+	}
+	return wallets, nil
+}
+
 func (r *walletRepository) Update(wallet *models.Wallet) error {
 	return r.db.Save(wallet).Error
 }
@@ -34,6 +98,19 @@ func (r *walletRepository) Delete(id string) error {
 	return r.db.Delete(&models.Wallet{}, "id = ?", id).Error
 }
 
+func (r *walletRepository) CreateStatusEvent(event *models.WalletStatusEvent) error {
+	return r.db.Create(event).Error
+}
+
+// FindStatusEvents returns a wallet's status changes, oldest first.
+func (r *walletRepository) FindStatusEvents(walletID string) ([]models.WalletStatusEvent, error) {
+	var events []models.WalletStatusEvent
+	if err := r.db.Where("wallet_id = ?", walletID).Order("created_at").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
 func (r *walletRepository) WithTx(tx interface{}) WalletRepository {
 	txDB, ok := tx.(*gorm.DB)
 	if !ok {
@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"time"
+
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) CreateEndpoint(endpoint *models.WebhookEndpoint) error {
+	return r.db.Create(endpoint).Error
+}
+
+func (r *webhookRepository) UpdateEndpoint(endpoint *models.WebhookEndpoint) error {
+	return r.db.Save(endpoint).Error
+}
+
+func (r *webhookRepository) FindEndpointByID(id string) (*models.WebhookEndpoint, error) {
+	var endpoint models.WebhookEndpoint
+	if err := r.db.Where("id = ?", id).First(&endpoint).Error; err != nil {
+		return nil, err
+	}
+	return &endpoint, nil
+}
+
+// FindEndpoints returns every endpoint, active or not, oldest first.
+func (r *webhookRepository) FindEndpoints() ([]models.WebhookEndpoint, error) {
+	var endpoints []models.WebhookEndpoint
+	if err := r.db.Order("created_at").Find(&endpoints).Error; err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
+
+// CreateDeliveries queues deliveries, skipping any already queued for the
+// same event and endpoint.
+func (r *webhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+// FindDueDeliveriesForUpdate locks pending deliveries whose next attempt is
+// due, oldest first. Deliveries locked by another dispatcher are skipped.
+func (r *webhookRepository) FindDueDeliveriesForUpdate(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryStatusPending, now).
+		Order("next_attempt_at").
+		Limit(limit).
+		Find(&deliveries).Error
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) FindDeliveryByID(id string) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := r.db.Where("id = ?", id).First(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// FindDeliveries returns the latest deliveries, newest first, optionally
+// only those with a status or to an endpoint.
+func (r *webhookRepository) FindDeliveries(status, endpointID string, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	query := r.db
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if endpointID != "" {
+		query = query.Where("endpoint_id = ?", endpointID)
+	}
+	if err := query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (r *webhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+// UpdateLeasedDelivery saves a delivery only if it is still held under the
+// lease leaseUntil, and reports whether it was. A delivery that was
+// redelivered or claimed by another dispatcher in the meantime is left alone.
+func (r *webhookRepository) UpdateLeasedDelivery(delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error) {
+	result := r.db.Model(delivery).Where("lease_until = ?", leaseUntil).Select("*").Updates(delivery)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *webhookRepository) DB() *gorm.DB {
+	return r.db
+}
+
+func (r *webhookRepository) WithTx(tx interface{}) WebhookRepository {
+	txDB, ok := tx.(*gorm.DB)
+	if !ok {
+		return r
+	}
+	return &webhookRepository{db: txDB}
+}
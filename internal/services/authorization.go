@@ -0,0 +1,291 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuthorizeTransfer reserves amount of the sender's balance for a later
+// capture by the recipient. It books a pending transfer and places a hold on
+// the sender's wallet for it; no money moves until the transfer is captured.
+func (s *walletService) AuthorizeTransfer(fromUserID, toUserID string, amount money.Amount, currency string) (*models.Hold, *APIError) {
+	currency, apiErr := validateAmount(amount, currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if fromUserID == toUserID {
+		return nil, NewBadRequestError("Cannot transfer to yourself")
+	}
+
+	var hold *models.Hold
+	apiErr = s.inTx(func(tx *gorm.DB) *APIError {
+		// Create repository instances with transaction
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
+		holdRepo := s.HoldRepo.WithTx(tx)
+
+		fromWallet, toWallet, apiErr := lockWalletPair(walletRepo, fromUserID, currency, toUserID, currency)
+		if apiErr != nil {
+			return apiErr
+		}
+		if apiErr := checkTransfer(fromWallet, toWallet); apiErr != nil {
+			return apiErr
+		}
+
+		if apiErr := s.checkLimits(tx, fromUserID, models.LimitOperationTransfer, currency, amount); apiErr != nil {
+			return apiErr
+		}
+
+		// The fee is charged on capture, but the sender must be able to
+		// cover it from the start
+		_, fee, apiErr := s.feesFor(fromUserID, models.FeeOperationTransfer, currency, amount)
+		if apiErr != nil {
+			return apiErr
+		}
+
+		available, apiErr := availableBalance(holdRepo, fromWallet)
+		if apiErr != nil {
+			return apiErr
+		}
+		if available < amount+fee {
+			return NewInsufficientBalanceError()
+		}
+
+		// Create pending transaction
+		transaction := &models.Transaction{
+			ID:         uuid.New().String(),
+			FromUserID: fromUserID,
+			ToUserID:   toUserID,
+			Amount:     amount,
+			Currency:   currency,
+			Type:       models.TransactionTypeTransfer,
+			Status:     models.TransactionStatusPending,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if err := transactionRepo.Create(transaction); err != nil {
+			return NewInternalServerError("Failed to create transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return apiErr
+		}
+
+		expiresAt := time.Now().Add(s.HoldTTL)
+		hold = &models.Hold{
+			ID:            uuid.New().String(),
+			UserID:        fromUserID,
+			WalletID:      fromWallet.ID,
+			TransactionID: transaction.ID,
+			Amount:        amount,
+			Currency:      currency,
+			Reason:        models.HoldReasonAuthorization,
+			Status:        models.HoldStatusActive,
+			ExpiresAt:     &expiresAt,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		if err := holdRepo.Create(hold); err != nil {
+			return NewInternalServerError("Failed to create hold")
+		}
+		return nil
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.changed(fromUserID, toUserID)
+	return hold, nil
+}
+
+// CaptureTransfer completes an authorized transfer for amount, or for the
+// full authorized amount when amount is zero. Only the recipient can capture.
+// Whatever part of the authorization is not captured is released. The
+// sender's fee is charged on the captured amount.
+func (s *walletService) CaptureTransfer(userID, transactionID string, amount money.Amount, idem Idempotency) (money.Amount, *APIError) {
+	if amount < 0 {
+		return 0, NewBadRequestError("Invalid amount")
+	}
+
+	response, apiErr := s.runIdempotent(userID, idem, func(tx *gorm.DB) (idempotentResponse, *APIError) {
+		// Create repository instances with transaction
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
+		holdRepo := s.HoldRepo.WithTx(tx)
+
+		transaction, hold, apiErr := lockAuthorization(transactionRepo, holdRepo, userID, transactionID)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if hold.Expired(time.Now()) {
+			return idempotentResponse{}, NewConflictError("Authorization has expired")
+		}
+
+		if amount == 0 {
+			amount = hold.Amount
+		}
+		if amount > hold.Amount {
+			return idempotentResponse{}, NewBadRequestError("Capture exceeds the authorized amount")
+		}
+		currency, _ := money.LookupCurrency(transaction.Currency)
+		if err := currency.Validate(amount); err != nil {
+			return idempotentResponse{}, NewBadRequestError(err.Error())
+		}
+
+		fromWallet, toWallet, apiErr := lockWalletPair(walletRepo, transaction.FromUserID, transaction.Currency, transaction.ToUserID, transaction.Currency)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		// The recipient captures, so it is their own wallet that must be
+		// able to receive
+		if apiErr := checkDebit(fromWallet, false); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := checkCredit(toWallet, true); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		// Release the hold before checking the balance, so the sender's
+		// reserved funds count towards the capture.
+		hold.Status = models.HoldStatusCaptured
+		hold.UpdatedAt = time.Now()
+		if err := holdRepo.Update(hold); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update hold")
+		}
+
+		fees, fee, apiErr := s.feesFor(transaction.FromUserID, models.FeeOperationTransfer, transaction.Currency, amount)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		available, apiErr := availableBalance(holdRepo, fromWallet)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if available < amount+fee {
+			return idempotentResponse{}, NewInsufficientBalanceError()
+		}
+
+		transaction.Amount = amount
+		transaction.Status = models.TransactionStatusSuccess
+		transaction.UpdatedAt = time.Now()
+		if err := transactionRepo.Update(transaction); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		// Update sender's wallet
+		fromWallet.Balance -= amount
+		fromWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(fromWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update sender's wallet")
+		}
+
+		// Update recipient's wallet
+		toWallet.Balance += amount
+		toWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(toWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update recipient's wallet")
+		}
+
+		if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+			{account: models.WalletLedgerAccount(fromWallet.ID), amount: -amount},
+			{account: models.WalletLedgerAccount(toWallet.ID), amount: amount},
+		}, fromWallet, toWallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := s.chargeFees(tx, fromWallet, []*models.Transaction{transaction}, fees); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		return idempotentResponse{
+			Balance:       toWallet.Balance,
+			TransactionID: transaction.ID,
+			UserIDs:       []string{transaction.FromUserID, transaction.ToUserID},
+		}, nil
+	})
+	if apiErr != nil {
+		return 0, apiErr
+	}
+
+	s.changed(response.UserIDs...)
+	return response.Balance, nil
+}
+
+// VoidTransfer cancels an authorized transfer and releases its hold. Only the
+// recipient can void; the sender's funds are otherwise released when the
+// hold expires.
+func (s *walletService) VoidTransfer(userID, transactionID string) *APIError {
+	var transaction *models.Transaction
+	apiErr := s.inTx(func(tx *gorm.DB) *APIError {
+		transactionRepo := s.TransactionRepo.WithTx(tx)
+		holdRepo := s.HoldRepo.WithTx(tx)
+
+		var hold *models.Hold
+		var apiErr *APIError
+		transaction, hold, apiErr = lockAuthorization(transactionRepo, holdRepo, userID, transactionID)
+		if apiErr != nil {
+			return apiErr
+		}
+
+		hold.Status = models.HoldStatusReleased
+		hold.UpdatedAt = time.Now()
+		if err := holdRepo.Update(hold); err != nil {
+			return NewInternalServerError("Failed to update hold")
+		}
+
+		transaction.Status = models.TransactionStatusVoided
+		transaction.UpdatedAt = time.Now()
+		if err := transactionRepo.Update(transaction); err != nil {
+			return NewInternalServerError("Failed to update transaction")
+		}
+		return s.publishTransaction(tx, transaction)
+	})
+	if apiErr != nil {
+		return apiErr
+	}
+
+	s.changed(transaction.FromUserID, transaction.ToUserID)
+	return nil
+}
+
+// lockAuthorization locks a pending transfer addressed to userID together
+// with its active hold.
+func lockAuthorization(transactionRepo repositories.TransactionRepository, holdRepo repositories.HoldRepository, userID, transactionID string) (*models.Transaction, *models.Hold, *APIError) {
+	transaction, err := transactionRepo.FindByIDForUpdate(transactionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, NewNotFoundError("Authorization not found")
+		}
+		return nil, nil, NewInternalServerError("Failed to get transaction")
+	}
+	if transaction.ToUserID != userID || transaction.Type != models.TransactionTypeTransfer {
+		return nil, nil, NewNotFoundError("Authorization not found")
+	}
+	switch transaction.Status {
+	case models.TransactionStatusPending:
+	case models.TransactionStatusSuccess:
+		return nil, nil, NewConflictError("Authorization has already been captured")
+	default:
+		return nil, nil, NewConflictError("Authorization is no longer pending")
+	}
+
+	hold, err := holdRepo.FindByTransactionIDForUpdate(transactionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, NewNotFoundError("Authorization not found")
+		}
+		return nil, nil, NewInternalServerError("Failed to get hold")
+	}
+	if hold.Status != models.HoldStatusActive {
+		return nil, nil, NewConflictError("Authorization is no longer pending")
+	}
+	return transaction, hold, nil
+}
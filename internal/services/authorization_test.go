@@ -0,0 +1,315 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_AuthorizeTransfer(t *testing.T) {
+	t.Run("books a pending transfer and holds the funds", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+		}
+		var transaction *models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			transaction = tx
+			return nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		hold, apiErr := env.service.AuthorizeTransfer("user123", "user456", money.FromMajor(40), "USD")
+
+		assert.Nil(t, apiErr)
+		if assert.NotNil(t, transaction) {
+			assert.Equal(t, models.TransactionStatusPending, transaction.Status)
+			assert.Equal(t, transaction.ID, hold.TransactionID)
+		}
+		assert.Equal(t, "wallet-user123", hold.WalletID)
+		assert.Equal(t, money.FromMajor(40), hold.Amount)
+		assert.Equal(t, models.HoldStatusActive, hold.Status)
+		assert.Equal(t, models.HoldReasonAuthorization, hold.Reason)
+		if assert.NotNil(t, hold.ExpiresAt) {
+			assert.WithinDuration(t, time.Now().Add(DefaultHoldTTL), *hold.ExpiresAt, time.Second)
+		}
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("existing holds reduce the available balance", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+		}
+		env.holdRepo.SumActiveByWalletIDFunc = func(walletID string, now time.Time) (money.Amount, error) {
+			return money.FromMajor(70), nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.AuthorizeTransfer("user123", "user456", money.FromMajor(40), "USD")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Insufficient balance", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_Withdraw_RespectsHolds(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+
+	env.sqlMock.ExpectBegin()
+	env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+		return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+	}
+	env.holdRepo.SumActiveByWalletIDFunc = func(walletID string, now time.Time) (money.Amount, error) {
+		return money.FromMajor(70), nil
+	}
+	env.sqlMock.ExpectRollback()
+
+	_, apiErr := env.service.Withdraw("user123", money.FromMajor(40), "USD", Idempotency{})
+
+	if assert.NotNil(t, apiErr) {
+		assert.Equal(t, "Insufficient balance", apiErr.Message)
+	}
+	assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+}
+
+func TestWalletService_CaptureTransfer(t *testing.T) {
+	newAuthorization := func(env *testEnv) (*models.Transaction, *models.Hold) {
+		transaction := &models.Transaction{
+			ID:         "tx1",
+			FromUserID: "user123",
+			ToUserID:   "user456",
+			Amount:     money.FromMajor(40),
+			Currency:   "USD",
+			Type:       models.TransactionTypeTransfer,
+			Status:     models.TransactionStatusPending,
+		}
+		expiresAt := time.Now().Add(time.Hour)
+		hold := &models.Hold{
+			ID:            "hold1",
+			WalletID:      "wallet-user123",
+			TransactionID: "tx1",
+			Amount:        money.FromMajor(40),
+			Currency:      "USD",
+			Reason:        models.HoldReasonAuthorization,
+			Status:        models.HoldStatusActive,
+			ExpiresAt:     &expiresAt,
+		}
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return transaction, nil
+		}
+		env.holdRepo.FindByTransactionIDForUpdateFunc = func(transactionID string) (*models.Hold, error) {
+			return hold, nil
+		}
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+		}
+		return transaction, hold
+	}
+
+	t.Run("partial capture moves the captured amount and releases the hold", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		transaction, hold := newAuthorization(env)
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.CaptureTransfer("user456", "tx1", money.FromMajor(25), Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(125), balance)
+		assert.Equal(t, models.TransactionStatusSuccess, transaction.Status)
+		assert.Equal(t, money.FromMajor(25), transaction.Amount)
+		assert.Equal(t, models.HoldStatusCaptured, hold.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("capture without an amount takes the full authorization", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		transaction, _ := newAuthorization(env)
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.CaptureTransfer("user456", "tx1", 0, Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(40), transaction.Amount)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("capture exceeding the authorization", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		newAuthorization(env)
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.CaptureTransfer("user456", "tx1", money.FromMajor(41), Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("expired authorization", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		_, hold := newAuthorization(env)
+		expiredAt := time.Now().Add(-time.Second)
+		hold.ExpiresAt = &expiredAt
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.CaptureTransfer("user456", "tx1", 0, Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Authorization has expired", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("sender cannot capture", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		newAuthorization(env)
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.CaptureTransfer("user123", "tx1", 0, Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusNotFound, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("void releases the hold", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		transaction, hold := newAuthorization(env)
+		env.sqlMock.ExpectCommit()
+
+		apiErr := env.service.VoidTransfer("user456", "tx1")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.TransactionStatusVoided, transaction.Status)
+		assert.Equal(t, models.HoldStatusReleased, hold.Status)
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("voided authorization cannot be captured", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		transaction, _ := newAuthorization(env)
+		transaction.Status = models.TransactionStatusVoided
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.CaptureTransfer("user456", "tx1", 0, Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusConflict, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("a retried capture replays the stored response", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		idem := Idempotency{Key: "capture-1", Fingerprint: "fp-1"}
+
+		env.sqlMock.ExpectBegin()
+		transaction, _ := newAuthorization(env)
+		var stored *models.IdempotencyKey
+		env.idempotencyKeyRepo.CreateIfNotExistsFunc = func(key *models.IdempotencyKey) (bool, error) {
+			if stored != nil {
+				return false, nil
+			}
+			return true, nil
+		}
+		env.idempotencyKeyRepo.UpdateFunc = func(key *models.IdempotencyKey) error {
+			stored = key
+			return nil
+		}
+		env.idempotencyKeyRepo.FindByUserIDAndKeyFunc = func(userID, key string) (*models.IdempotencyKey, error) {
+			return stored, nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.CaptureTransfer("user456", "tx1", money.FromMajor(25), idem)
+		if !assert.Nil(t, apiErr) {
+			return
+		}
+		assert.Equal(t, models.TransactionStatusSuccess, transaction.Status)
+
+		// The authorization is no longer pending, so only a replay succeeds
+		env.sqlMock.ExpectBegin()
+		var notified []string
+		env.cache.DeleteFunc = func(key string) {
+			notified = append(notified, key)
+		}
+		env.sqlMock.ExpectCommit()
+
+		replayed, apiErr := env.service.CaptureTransfer("user456", "tx1", money.FromMajor(25), idem)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, balance, replayed)
+		assert.Equal(t, []string{"user123", "user456"}, notified)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_ExpireHolds(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+
+	transaction := &models.Transaction{ID: "tx1", FromUserID: "user123", ToUserID: "user456", Status: models.TransactionStatusPending}
+	expiredAt := time.Now().Add(-time.Minute)
+	hold := &models.Hold{ID: "hold1", TransactionID: "tx1", Status: models.HoldStatusActive, ExpiresAt: &expiredAt}
+
+	env.holdRepo.FindExpiredFunc = func(now time.Time, limit int) ([]models.Hold, error) {
+		return []models.Hold{*hold}, nil
+	}
+	env.holdRepo.FindByIDForUpdateFunc = func(id string) (*models.Hold, error) {
+		return hold, nil
+	}
+	env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+		return transaction, nil
+	}
+	env.sqlMock.ExpectBegin()
+	env.sqlMock.ExpectCommit()
+
+	expired, apiErr := env.service.ExpireHolds()
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, 1, expired)
+	assert.Equal(t, models.HoldStatusExpired, hold.Status)
+	assert.Equal(t, models.TransactionStatusExpired, transaction.Status)
+	assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/repositories"
+
+	"gorm.io/gorm"
+)
+
+// balanceDateLayout is how days are written in balance histories: UTC
+// calendar dates.
+const balanceDateLayout = "2006-01-02"
+
+// maxDailyBalanceDays caps how many days one daily balance series can span.
+const maxDailyBalanceDays = 366
+
+// checkpointSettleTime is how long after a day ends it is checkpointed, so
+// that database transactions still in flight at midnight have committed.
+const checkpointSettleTime = time.Hour
+
+// checkLedgerHistory rejects a request for balances as of at if the ledger
+// only started later. The balances wallets already had then were booked as
+// opening balances at that time, so earlier balances are not known.
+func checkLedgerHistory(ledgerRepo repositories.LedgerRepository, at time.Time) *APIError {
+	start, err := ledgerRepo.LedgerStart()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return NewInternalServerError("Failed to get ledger start")
+	}
+	if !at.After(start) {
+		return NewBadRequestError("Balances are only known after " + start.UTC().Format(time.RFC3339) + ", when the ledger started")
+	}
+	return nil
+}
+
+// GetBalanceAsOf returns a wallet's ledger balance at a point in the past.
+// Holds are not kept historically, so only the ledger balance is known.
+func (s *walletService) GetBalanceAsOf(userID, currency string, at time.Time) (*models.HistoricalBalance, *APIError) {
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if at.After(time.Now()) {
+		return nil, NewBadRequestError("as_of cannot be in the future")
+	}
+	if apiErr := checkLedgerHistory(s.LedgerRepo, at); apiErr != nil {
+		return nil, apiErr
+	}
+
+	wallet, apiErr := s.findWallet(userID, currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	balance, err := s.LedgerRepo.BalanceAsOf(models.WalletLedgerAccount(wallet.ID), at)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get ledger balance")
+	}
+	return &models.HistoricalBalance{Currency: currency, AsOf: at.UTC(), Balance: balance}, nil
+}
+
+// GetDailyBalances returns a wallet's closing balance on each day from from
+// through to, both UTC dates. Today's closing balance is its balance so far.
+func (s *walletService) GetDailyBalances(userID, currency, from, to string) ([]models.DailyBalance, *APIError) {
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	fromDay, err := time.Parse(balanceDateLayout, from)
+	if err != nil {
+		return nil, NewBadRequestError("Invalid from date")
+	}
+	toDay, err := time.Parse(balanceDateLayout, to)
+	if err != nil {
+		return nil, NewBadRequestError("Invalid to date")
+	}
+	if toDay.Before(fromDay) {
+		return nil, NewBadRequestError("from must not be after to")
+	}
+	if toDay.After(time.Now().UTC()) {
+		return nil, NewBadRequestError("to cannot be in the future")
+	}
+	days := int(toDay.Sub(fromDay).Hours()/24) + 1
+	if days > maxDailyBalanceDays {
+		return nil, NewBadRequestError("Date range is too long")
+	}
+	// The first day's closing balance is the earliest one reported
+	if apiErr := checkLedgerHistory(s.LedgerRepo, fromDay.AddDate(0, 0, 1)); apiErr != nil {
+		return nil, apiErr
+	}
+
+	wallet, apiErr := s.findWallet(userID, currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	account := models.WalletLedgerAccount(wallet.ID)
+
+	balance, err := s.LedgerRepo.BalanceAsOf(account, fromDay)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get ledger balance")
+	}
+	totals, err := s.LedgerRepo.DailyTotals(account, fromDay, toDay.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get ledger postings")
+	}
+
+	balances := make([]models.DailyBalance, 0, days)
+	for day := fromDay; !day.After(toDay); day = day.AddDate(0, 0, 1) {
+		date := day.Format(balanceDateLayout)
+		balance += totals[date]
+		balances = append(balances, models.DailyBalance{Date: date, Balance: balance})
+	}
+	return balances, nil
+}
+
+// CheckpointBalances records the closing balance of every ledger account with
+// postings on each day since the last finished run, up to the last day that
+// has settled. The very first run starts with that day. It returns how many
+// checkpoints it wrote.
+func (s *walletService) CheckpointBalances() (int, *APIError) {
+	settled := time.Now().UTC().Add(-checkpointSettleTime)
+	through := time.Date(settled.Year(), settled.Month(), settled.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+
+	start := through
+	last, err := s.LedgerRepo.LastCheckpointRun()
+	if err == nil {
+		lastDay, err := time.Parse(balanceDateLayout, last.Date)
+		if err != nil {
+			return 0, NewInternalServerError("Invalid checkpoint run date")
+		}
+		start = lastDay.AddDate(0, 0, 1)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, NewInternalServerError("Failed to get last checkpoint run")
+	}
+
+	written := 0
+	for day := start; !day.After(through); day = day.AddDate(0, 0, 1) {
+		endOfDay := day.AddDate(0, 0, 1)
+		accounts, err := s.LedgerRepo.FindAccountsPostedBetween(day, endOfDay)
+		if err != nil {
+			return written, NewInternalServerError("Failed to get ledger accounts")
+		}
+
+		// Each checkpoint builds on the previous one, so days are done in
+		// order and a day is only recorded as finished once all its accounts
+		// have been checkpointed
+		for _, account := range accounts {
+			balance, err := s.LedgerRepo.BalanceAsOf(account, endOfDay)
+			if err != nil {
+				return written, NewInternalServerError("Failed to get ledger balance")
+			}
+			checkpoint := &models.BalanceCheckpoint{
+				AccountCode: account,
+				Date:        day.Format(balanceDateLayout),
+				Balance:     balance,
+				CreatedAt:   time.Now(),
+			}
+			if err := s.LedgerRepo.CreateCheckpoint(checkpoint); err != nil {
+				return written, NewInternalServerError("Failed to create balance checkpoint")
+			}
+			written++
+		}
+
+		run := &models.BalanceCheckpointRun{Date: day.Format(balanceDateLayout), CompletedAt: time.Now()}
+		if err := s.LedgerRepo.CreateCheckpointRun(run); err != nil {
+			return written, NewInternalServerError("Failed to record checkpoint run")
+		}
+	}
+	return written, nil
+}
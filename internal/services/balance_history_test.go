@@ -0,0 +1,160 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_GetBalanceAsOf(t *testing.T) {
+	t.Run("returns the ledger balance at the given time", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		at := time.Date(2025, time.April, 1, 0, 0, 0, 0, time.UTC)
+		env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency, Balance: money.FromMajor(500)}, nil
+		}
+		env.ledgerRepo.BalanceAsOfFunc = func(accountCode string, asOf time.Time) (money.Amount, error) {
+			assert.Equal(t, "wallets/wallet1", accountCode)
+			assert.Equal(t, at, asOf)
+			return money.FromMajor(120), nil
+		}
+
+		balance, apiErr := env.service.GetBalanceAsOf("user123", "usd", at)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, &models.HistoricalBalance{Currency: "USD", AsOf: at, Balance: money.FromMajor(120)}, balance)
+	})
+
+	t.Run("rejects times in the future", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		_, apiErr := env.service.GetBalanceAsOf("user123", "USD", time.Now().Add(time.Hour))
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "as_of cannot be in the future", apiErr.Message)
+		}
+	})
+
+	t.Run("rejects times before the ledger started", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.ledgerRepo.LedgerStartFunc = func() (time.Time, error) {
+			return time.Date(2025, time.June, 22, 10, 0, 0, 0, time.UTC), nil
+		}
+
+		_, apiErr := env.service.GetBalanceAsOf("user123", "USD", time.Date(2025, time.June, 1, 0, 0, 0, 0, time.UTC))
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 400, apiErr.Code)
+			assert.Equal(t, "Balances are only known after 2025-06-22T10:00:00Z, when the ledger started", apiErr.Message)
+		}
+	})
+}
+
+func TestWalletService_GetDailyBalances(t *testing.T) {
+	t.Run("adds each day's postings to the opening balance", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+		env.ledgerRepo.BalanceAsOfFunc = func(accountCode string, at time.Time) (money.Amount, error) {
+			assert.Equal(t, time.Date(2025, time.March, 30, 0, 0, 0, 0, time.UTC), at)
+			return money.FromMajor(100), nil
+		}
+		env.ledgerRepo.DailyTotalsFunc = func(accountCode string, from, to time.Time) (map[string]money.Amount, error) {
+			assert.Equal(t, time.Date(2025, time.April, 3, 0, 0, 0, 0, time.UTC), to)
+			return map[string]money.Amount{
+				"2025-03-30": money.FromMajor(20),
+				"2025-04-01": -money.FromMajor(50),
+			}, nil
+		}
+
+		balances, apiErr := env.service.GetDailyBalances("user123", "USD", "2025-03-30", "2025-04-02")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, []models.DailyBalance{
+			{Date: "2025-03-30", Balance: money.FromMajor(120)},
+			{Date: "2025-03-31", Balance: money.FromMajor(120)},
+			{Date: "2025-04-01", Balance: money.FromMajor(70)},
+			{Date: "2025-04-02", Balance: money.FromMajor(70)},
+		}, balances)
+	})
+
+	t.Run("validates the date range", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		tests := []struct {
+			from, to, message string
+		}{
+			{"2025-03-31", "2025-03-30", "from must not be after to"},
+			{"2025-03-31", "31/03/2025", "Invalid to date"},
+			{"2024-01-01", "2025-03-31", "Date range is too long"},
+			{"2025-03-31", time.Now().AddDate(0, 0, 2).Format("2006-01-02"), "to cannot be in the future"},
+			{"2025-02-28", "2025-03-31", "Balances are only known after 2025-03-01T10:00:00Z, when the ledger started"},
+		}
+		env.ledgerRepo.LedgerStartFunc = func() (time.Time, error) {
+			return time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC), nil
+		}
+		for _, test := range tests {
+			_, apiErr := env.service.GetDailyBalances("user123", "USD", test.from, test.to)
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, test.message, apiErr.Message)
+			}
+		}
+	})
+}
+
+func TestWalletService_CheckpointBalances(t *testing.T) {
+	t.Run("checkpoints every settled day since the last run", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		now := time.Now().UTC().Add(-checkpointSettleTime)
+		through := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -1)
+		env.ledgerRepo.LastCheckpointRunFunc = func() (*models.BalanceCheckpointRun, error) {
+			return &models.BalanceCheckpointRun{Date: through.AddDate(0, 0, -2).Format("2006-01-02")}, nil
+		}
+		env.ledgerRepo.FindAccountsPostedBetweenFunc = func(from, to time.Time) ([]string, error) {
+			if from.Equal(through) {
+				return nil, nil
+			}
+			return []string{"house/fees", "wallets/wallet1"}, nil
+		}
+		env.ledgerRepo.BalanceAsOfFunc = func(accountCode string, at time.Time) (money.Amount, error) {
+			return money.FromMajor(10), nil
+		}
+		var checkpoints []*models.BalanceCheckpoint
+		env.ledgerRepo.CreateCheckpointFunc = func(checkpoint *models.BalanceCheckpoint) error {
+			checkpoints = append(checkpoints, checkpoint)
+			return nil
+		}
+		var runs []string
+		env.ledgerRepo.CreateCheckpointRunFunc = func(run *models.BalanceCheckpointRun) error {
+			runs = append(runs, run.Date)
+			return nil
+		}
+
+		written, apiErr := env.service.CheckpointBalances()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 2, written)
+		day := through.AddDate(0, 0, -1).Format("2006-01-02")
+		if assert.Len(t, checkpoints, 2) {
+			assert.Equal(t, "house/fees", checkpoints[0].AccountCode)
+			assert.Equal(t, day, checkpoints[0].Date)
+			assert.Equal(t, money.FromMajor(10), checkpoints[0].Balance)
+		}
+		assert.Equal(t, []string{day, through.Format("2006-01-02")}, runs)
+	})
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxBatchTransfers caps the number of recipients in one batch transfer.
+const MaxBatchTransfers = 100
+
+// CheckBatchSize rejects a batch of count transfers that is empty or over
+// MaxBatchTransfers. Handlers call it before resolving each recipient, so an
+// oversized batch is turned away without a lookup per transfer.
+func CheckBatchSize(count int) *APIError {
+	if count == 0 {
+		return NewBadRequestError("At least one transfer is required")
+	}
+	if count > MaxBatchTransfers {
+		return NewBadRequestError(fmt.Sprintf("A batch can have at most %d transfers", MaxBatchTransfers))
+	}
+	return nil
+}
+
+// BatchTransferItem is one recipient of a batch transfer.
+type BatchTransferItem struct {
+	ToUserID string
+	Amount   money.Amount
+}
+
+// BatchTransfer pays several recipients from one wallet in a single database
+// transaction. The sender is checked and debited once for the total, and each
+// recipient gets its own transfer transaction grouped under a TransferBatch.
+// Each transfer is charged its own fee.
+// If any leg fails, for example because a recipient has no wallet in the
+// currency, nothing is paid.
+func (s *walletService) BatchTransfer(fromUserID, currency string, items []BatchTransferItem, idem Idempotency) (money.Amount, *models.TransferBatch, *APIError) {
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+	if apiErr := CheckBatchSize(len(items)); apiErr != nil {
+		return 0, nil, apiErr
+	}
+
+	var total money.Amount
+	recipients := make(map[string]bool, len(items))
+	for _, item := range items {
+		if _, apiErr := validateAmount(item.Amount, currency); apiErr != nil {
+			return 0, nil, apiErr
+		}
+		if item.ToUserID == "" {
+			return 0, nil, NewBadRequestError("Recipient is required")
+		}
+		if item.ToUserID == fromUserID {
+			return 0, nil, NewBadRequestError("Cannot transfer to yourself")
+		}
+		if recipients[item.ToUserID] {
+			return 0, nil, NewBadRequestError("Each recipient can appear only once in a batch")
+		}
+		recipients[item.ToUserID] = true
+		total += item.Amount
+	}
+
+	var batch *models.TransferBatch
+	response, apiErr := s.runIdempotent(fromUserID, idem, func(tx *gorm.DB) (idempotentResponse, *APIError) {
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
+
+		wallets, apiErr := lockBatchWallets(walletRepo, fromUserID, currency, items)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		fromWallet := wallets[fromUserID]
+		if apiErr := checkDebit(fromWallet, true); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		for _, item := range items {
+			if apiErr := checkCredit(wallets[item.ToUserID], false); apiErr != nil {
+				return idempotentResponse{}, apiErr
+			}
+		}
+
+		amounts := make([]money.Amount, len(items))
+		for i, item := range items {
+			amounts[i] = item.Amount
+		}
+		if apiErr := s.checkLimits(tx, fromUserID, models.LimitOperationTransfer, currency, amounts...); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		fees, fee, apiErr := s.feesFor(fromUserID, models.FeeOperationTransfer, currency, amounts...)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		available, apiErr := availableBalance(s.HoldRepo.WithTx(tx), fromWallet)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if available < total+fee {
+			return idempotentResponse{}, NewInsufficientBalanceError()
+		}
+
+		now := time.Now()
+		batch = &models.TransferBatch{
+			ID:          uuid.New().String(),
+			FromUserID:  fromUserID,
+			Currency:    currency,
+			TotalAmount: total,
+			Count:       len(items),
+			Status:      models.TransactionStatusSuccess,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := transactionRepo.CreateBatch(batch); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to create batch")
+		}
+
+		transactions := make([]*models.Transaction, len(items))
+		for i, item := range items {
+			toWallet := wallets[item.ToUserID]
+
+			transaction := &models.Transaction{
+				ID:         uuid.New().String(),
+				FromUserID: fromUserID,
+				ToUserID:   item.ToUserID,
+				Amount:     item.Amount,
+				Currency:   currency,
+				BatchID:    batch.ID,
+				Type:       models.TransactionTypeTransfer,
+				Status:     models.TransactionStatusSuccess,
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}
+			if err := transactionRepo.Create(transaction); err != nil {
+				return idempotentResponse{}, NewInternalServerError("Failed to create transaction")
+			}
+			if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+				return idempotentResponse{}, apiErr
+			}
+
+			fromWallet.Balance -= item.Amount
+			toWallet.Balance += item.Amount
+			toWallet.UpdatedAt = now
+			if err := walletRepo.Update(toWallet); err != nil {
+				return idempotentResponse{}, NewInternalServerError("Failed to update recipient's wallet")
+			}
+
+			if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+				{account: models.WalletLedgerAccount(fromWallet.ID), amount: -item.Amount},
+				{account: models.WalletLedgerAccount(toWallet.ID), amount: item.Amount},
+			}, toWallet); apiErr != nil {
+				return idempotentResponse{}, apiErr
+			}
+			batch.Transactions = append(batch.Transactions, *transaction)
+			transactions[i] = transaction
+		}
+
+		// The sender is debited once, and checked against the ledger once all
+		// legs are booked
+		fromWallet.UpdatedAt = now
+		if err := walletRepo.Update(fromWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update sender's wallet")
+		}
+		ledgerBalance, err := s.LedgerRepo.WithTx(tx).BalanceOf(models.WalletLedgerAccount(fromWallet.ID))
+		if err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to get ledger balance")
+		}
+		if ledgerBalance != fromWallet.Balance {
+			return idempotentResponse{}, NewInternalServerError("Wallet balance does not match ledger")
+		}
+		if apiErr := s.chargeFees(tx, fromWallet, transactions, fees); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		return idempotentResponse{Balance: fromWallet.Balance, BatchID: batch.ID}, nil
+	})
+	if apiErr != nil {
+		return 0, nil, apiErr
+	}
+
+	// A replayed request returns the batch the first one made
+	if batch == nil {
+		batch, apiErr = s.GetBatch(fromUserID, response.BatchID)
+		if apiErr != nil {
+			return 0, nil, apiErr
+		}
+	}
+
+	s.changed(fromUserID)
+	for _, item := range items {
+		s.changed(item.ToUserID)
+	}
+	return response.Balance, batch, nil
+}
+
+// GetBatch returns a batch transfer and its transactions to its sender.
+func (s *walletService) GetBatch(userID, batchID string) (*models.TransferBatch, *APIError) {
+	batch, err := s.TransactionRepo.FindBatchByID(batchID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Batch not found")
+		}
+		return nil, NewInternalServerError("Failed to get batch")
+	}
+	if batch.FromUserID != userID {
+		return nil, NewNotFoundError("Batch not found")
+	}
+
+	batch.Transactions, err = s.TransactionRepo.FindByBatchID(batch.ID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get batch transactions")
+	}
+	return batch, nil
+}
+
+// lockBatchWallets locks the sender's and every recipient's wallet in user ID
+// order, the same order lockWalletPair uses, so that batches and ordinary
+// transfers touching the same wallets cannot deadlock.
+func lockBatchWallets(walletRepo repositories.WalletRepository, fromUserID, currency string, items []BatchTransferItem) (map[string]*models.Wallet, *APIError) {
+	userIDs := make([]string, 0, len(items)+1)
+	userIDs = append(userIDs, fromUserID)
+	for _, item := range items {
+		userIDs = append(userIDs, item.ToUserID)
+	}
+	sort.Strings(userIDs)
+
+	wallets := make(map[string]*models.Wallet, len(userIDs))
+	for _, userID := range userIDs {
+		wallet, err := walletRepo.FindByUserIDForUpdate(userID, currency)
+		if err != nil {
+			sender := userID == fromUserID
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				if sender {
+					return nil, NewInternalServerError("Failed to get sender's wallet")
+				}
+				return nil, NewInternalServerError("Failed to get recipient's wallet")
+			}
+			if sender {
+				return nil, NewNotFoundError("No " + currency + " wallet")
+			}
+			return nil, NewBadRequestError("Recipient has no " + currency + " wallet")
+		}
+		wallets[userID] = wallet
+	}
+	return wallets, nil
+}
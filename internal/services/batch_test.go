@@ -0,0 +1,151 @@
+package services
+
+import (
+	"testing"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestWalletService_BatchTransfer(t *testing.T) {
+	items := []BatchTransferItem{
+		{ToUserID: "user789", Amount: money.FromMajor(30)},
+		{ToUserID: "user456", Amount: money.FromMajor(20)},
+	}
+
+	t.Run("debits the sender once and credits every recipient", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		var locked []string
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			locked = append(locked, userID)
+			return &models.Wallet{ID: "w-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+		}
+		var batch *models.TransferBatch
+		env.transactionRepo.CreateBatchFunc = func(b *models.TransferBatch) error {
+			batch = b
+			return nil
+		}
+		var transactions []*models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			transactions = append(transactions, tx)
+			return nil
+		}
+		var invalidated []string
+		env.cache.DeleteFunc = func(key string) {
+			invalidated = append(invalidated, key)
+		}
+
+		env.sqlMock.ExpectCommit()
+
+		balance, result, apiErr := env.service.BatchTransfer("user123", "usd", items, Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(50), balance)
+		assert.Equal(t, []string{"user123", "user456", "user789"}, locked)
+		assert.ElementsMatch(t, []string{"user123", "user456", "user789"}, invalidated)
+		if assert.NotNil(t, batch) {
+			assert.Equal(t, batch, result)
+			assert.Equal(t, money.FromMajor(50), batch.TotalAmount)
+			assert.Equal(t, 2, batch.Count)
+			assert.Len(t, batch.Transactions, 2)
+		}
+		if assert.Len(t, transactions, 2) {
+			for _, transaction := range transactions {
+				assert.Equal(t, batch.ID, transaction.BatchID)
+				assert.Equal(t, "USD", transaction.Currency)
+			}
+		}
+
+		balances := make(map[string]money.Amount)
+		updates := make(map[string]int)
+		for _, wallet := range env.walletRepo.Updated {
+			balances[wallet.UserID] = wallet.Balance
+			updates[wallet.UserID]++
+		}
+		assert.Equal(t, map[string]money.Amount{"user123": money.FromMajor(50), "user456": money.FromMajor(120), "user789": money.FromMajor(130)}, balances)
+		assert.Equal(t, 1, updates["user123"])
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("pays nobody when one recipient cannot be paid", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			if userID == "user789" {
+				return nil, gorm.ErrRecordNotFound
+			}
+			return &models.Wallet{ID: "w-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+		}
+		env.transactionRepo.CreateBatchFunc = func(b *models.TransferBatch) error {
+			t.Fatal("batch must not be created")
+			return nil
+		}
+
+		env.sqlMock.ExpectRollback()
+
+		_, _, apiErr := env.service.BatchTransfer("user123", "USD", items, Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 400, apiErr.Code)
+		}
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("insufficient balance for the total", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "w-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(40)}, nil
+		}
+
+		env.sqlMock.ExpectRollback()
+
+		_, _, apiErr := env.service.BatchTransfer("user123", "USD", items, Idempotency{})
+
+		assert.True(t, IsInsufficientBalance(apiErr))
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("rejects invalid batches", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		for _, batch := range [][]BatchTransferItem{
+			nil,
+			{{ToUserID: "user123", Amount: money.FromMajor(10)}},
+			{{ToUserID: "user456", Amount: money.FromMajor(10)}, {ToUserID: "user456", Amount: money.FromMajor(5)}},
+			{{ToUserID: "user456", Amount: 0}},
+		} {
+			_, _, apiErr := env.service.BatchTransfer("user123", "USD", batch, Idempotency{})
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, 400, apiErr.Code)
+			}
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestCheckBatchSize(t *testing.T) {
+	assert.Nil(t, CheckBatchSize(1))
+	assert.Nil(t, CheckBatchSize(MaxBatchTransfers))
+	for _, count := range []int{0, MaxBatchTransfers + 1} {
+		if apiErr := CheckBatchSize(count); assert.NotNil(t, apiErr) {
+			assert.Equal(t, 400, apiErr.Code)
+		}
+	}
+	assert.Equal(t, "A batch can have at most 100 transfers", CheckBatchSize(MaxBatchTransfers+1).Message)
+}
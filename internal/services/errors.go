@@ -3,10 +3,17 @@ package services
 import "net/http"
 
 type APIError struct {
-	Code    int    // HTTP status code
+	Code    int // HTTP status code
 	Message string
+	// Reason is a machine-readable code for errors a client can act on, and
+	// Details says what it applies to, e.g. the limit that was hit.
+	Reason  string
+	Details interface{}
 }
 
+// ErrorReasonLimitExceeded is the Reason of a request over a spending limit.
+const ErrorReasonLimitExceeded = "limit_exceeded"
+
 func (e *APIError) Error() string {
 	return e.Message
 }
@@ -29,3 +36,24 @@ func NewInternalServerError(msg string) *APIError {
 func NewNotFoundError(msg string) *APIError {
 	return NewAPIError(http.StatusNotFound, msg)
 }
+
+func NewUnprocessableEntityError(msg string) *APIError {
+	return NewAPIError(http.StatusUnprocessableEntity, msg)
+}
+
+func NewConflictError(msg string) *APIError {
+	return NewAPIError(http.StatusConflict, msg)
+}
+
+// insufficientBalanceMessage is returned whenever a wallet cannot cover a debit.
+const insufficientBalanceMessage = "Insufficient balance"
+
+func NewInsufficientBalanceError() *APIError {
+	return NewBadRequestError(insufficientBalanceMessage)
+}
+
+// IsInsufficientBalance reports whether err was caused by a wallet that could
+// not cover a debit.
+func IsInsufficientBalance(err *APIError) bool {
+	return err != nil && err.Code == http.StatusBadRequest && err.Message == insufficientBalanceMessage
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"wallet/internal/export"
+	"wallet/internal/models"
+)
+
+// ExportOptions selects the transactions of an export and its format. From and
+// To are UTC dates (YYYY-MM-DD) and both are included.
+type ExportOptions struct {
+	Format   string
+	Type     string
+	Status   string
+	Currency string
+	From     string
+	To       string
+}
+
+// ExportTransactions streams a user's transaction history matching options to
+// the writer returned by open, oldest first. Each transaction is written as
+// the movements it made in the user's wallets, signed from the user's side.
+// CSV lists every currency and status unless filtered; OFX and QIF describe a
+// single wallet, so they cover one currency (the default one unless given)
+// and only transactions that moved money. open is called once the request has
+// been validated, before anything is written; an error returned after that
+// means the export is incomplete.
+func (s *walletService) ExportTransactions(userID string, options ExportOptions, open func() io.Writer) *APIError {
+	format := strings.ToLower(options.Format)
+	if format == "" {
+		format = export.FormatCSV
+	}
+	if format != export.FormatCSV && format != export.FormatOFX && format != export.FormatQIF {
+		return NewBadRequestError("Unsupported format")
+	}
+
+	filter := models.TransactionFilter{Type: options.Type, Status: options.Status}
+	if options.Currency != "" || format != export.FormatCSV {
+		currency, apiErr := normalizeCurrency(options.Currency)
+		if apiErr != nil {
+			return apiErr
+		}
+		filter.Currency = currency
+	}
+	if options.From != "" {
+		from, err := time.Parse(balanceDateLayout, options.From)
+		if err != nil {
+			return NewBadRequestError("Invalid from date")
+		}
+		filter.From = &from
+	}
+	if options.To != "" {
+		to, err := time.Parse(balanceDateLayout, options.To)
+		if err != nil {
+			return NewBadRequestError("Invalid to date")
+		}
+		end := to.AddDate(0, 0, 1)
+		filter.To = &end
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return NewBadRequestError("from must not be after to")
+	}
+
+	account := export.Account{Currency: filter.Currency}
+	if format != export.FormatCSV {
+		var apiErr *APIError
+		if account, apiErr = s.exportAccount(userID, filter); apiErr != nil {
+			return apiErr
+		}
+	}
+
+	writer, err := export.NewWriter(format, open(), account)
+	if err != nil {
+		return NewInternalServerError("Failed to write export")
+	}
+	err = s.TransactionRepo.EachByUserID(userID, filter, func(transaction *models.Transaction) error {
+		if format != export.FormatCSV && !movedMoney(transaction) {
+			return nil
+		}
+		for _, entry := range exportEntries(transaction, userID) {
+			if filter.Currency != "" && entry.Currency != filter.Currency {
+				continue
+			}
+			if err := writer.Write(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return NewInternalServerError("Failed to export transactions")
+	}
+	if err := writer.Close(); err != nil {
+		return NewInternalServerError("Failed to write export")
+	}
+	return nil
+}
+
+// exportAccount describes the wallet an OFX or QIF export covers: the period
+// runs from the start of the filter, or the wallet's opening, to the end of
+// the filter or now, and the balance is the ledger balance at its end.
+func (s *walletService) exportAccount(userID string, filter models.TransactionFilter) (export.Account, *APIError) {
+	wallet, apiErr := s.findWallet(userID, filter.Currency)
+	if apiErr != nil {
+		return export.Account{}, apiErr
+	}
+
+	account := export.Account{ID: wallet.ID, Currency: wallet.Currency, Start: wallet.CreatedAt, End: time.Now()}
+	if filter.From != nil {
+		account.Start = *filter.From
+	}
+	if filter.To != nil && filter.To.Before(account.End) {
+		account.End = *filter.To
+	}
+	if apiErr := checkLedgerHistory(s.LedgerRepo, account.End); apiErr != nil {
+		return export.Account{}, apiErr
+	}
+
+	balance, err := s.LedgerRepo.BalanceAsOf(models.WalletLedgerAccount(wallet.ID), account.End)
+	if err != nil {
+		return export.Account{}, NewInternalServerError("Failed to get ledger balance")
+	}
+	account.Balance = balance
+	return account, nil
+}
+
+// movedMoney reports whether a transaction changed any balance. Pending,
+// failed, voided and expired transactions did not.
+func movedMoney(transaction *models.Transaction) bool {
+	return slices.Contains(models.SettledTransactionStatuses, transaction.Status)
+}
+
+// exportEntries returns the movements a transaction made in the user's
+// wallets: money paid is negative and money received positive. A conversion
+// between the user's own wallets has both.
+func exportEntries(transaction *models.Transaction, userID string) []export.Entry {
+	entry := export.Entry{
+		Date:          transaction.CreatedAt,
+		TransactionID: transaction.ID,
+		Type:          transaction.Type,
+		Status:        transaction.Status,
+	}
+
+	// Deposits are recorded as sent by the depositor, to no one
+	if transaction.Type == models.TransactionTypeDeposit {
+		entry.Amount = transaction.Amount
+		entry.Currency = transaction.Currency
+		return []export.Entry{entry}
+	}
+
+	var entries []export.Entry
+	if transaction.FromUserID == userID {
+		paid := entry
+		paid.Amount = -transaction.Amount
+		paid.Currency = transaction.Currency
+		paid.Counterparty = transaction.ToUserID
+		entries = append(entries, paid)
+	}
+	if transaction.ToUserID == userID {
+		received := entry
+		received.Amount, received.Currency = transaction.CreditAmount()
+		received.Counterparty = transaction.FromUserID
+		entries = append(entries, received)
+	}
+	return entries
+}
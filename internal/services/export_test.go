@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_ExportTransactions(t *testing.T) {
+	day := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.UTC)
+	transactions := []models.Transaction{
+		{ID: "tx1", FromUserID: "user123", Amount: money.FromMajor(100), Currency: "USD", Type: models.TransactionTypeDeposit, Status: models.TransactionStatusSuccess, CreatedAt: day},
+		{ID: "tx2", FromUserID: "user123", ToUserID: "user456", Amount: money.FromMajor(10), Currency: "USD", Type: models.TransactionTypeTransfer, Status: models.TransactionStatusSuccess, CreatedAt: day.Add(time.Hour)},
+		{ID: "tx3", FromUserID: "user456", ToUserID: "user123", Amount: money.FromMajor(5), Currency: "USD", ToAmount: money.FromMajor(4), ToCurrency: "EUR", Type: models.TransactionTypeTransfer, Status: models.TransactionStatusSuccess, CreatedAt: day.Add(2 * time.Hour)},
+		{ID: "tx4", FromUserID: "user123", ToUserID: "user456", Amount: money.FromMajor(7), Currency: "USD", Type: models.TransactionTypeTransfer, Status: models.TransactionStatusPending, CreatedAt: day.Add(3 * time.Hour)},
+	}
+	each := func(userID string, filter models.TransactionFilter, fn func(transaction *models.Transaction) error) error {
+		for i := range transactions {
+			if err := fn(&transactions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	t.Run("writes signed amounts from the user's side as CSV", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.transactionRepo.EachByUserIDFunc = func(userID string, filter models.TransactionFilter, fn func(transaction *models.Transaction) error) error {
+			assert.Equal(t, "user123", userID)
+			assert.Equal(t, models.TransactionFilter{}, filter)
+			return each(userID, filter, fn)
+		}
+
+		var buf bytes.Buffer
+		apiErr := env.service.ExportTransactions("user123", ExportOptions{}, func() io.Writer { return &buf })
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, "date,transaction_id,type,status,amount,currency,counterparty\n"+
+			"2025-03-03T00:00:00Z,tx1,deposit,success,100.00,USD,\n"+
+			"2025-03-03T01:00:00Z,tx2,transfer,success,-10.00,USD,user456\n"+
+			"2025-03-03T02:00:00Z,tx3,transfer,success,4.00,EUR,user456\n"+
+			"2025-03-03T03:00:00Z,tx4,transfer,pending,-7.00,USD,user456\n", buf.String())
+	})
+
+	t.Run("covers one wallet's money movements as QIF", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+		env.transactionRepo.EachByUserIDFunc = func(userID string, filter models.TransactionFilter, fn func(transaction *models.Transaction) error) error {
+			assert.Equal(t, "USD", filter.Currency)
+			if assert.NotNil(t, filter.From) && assert.NotNil(t, filter.To) {
+				assert.Equal(t, day, *filter.From)
+				assert.Equal(t, day.AddDate(0, 0, 1), *filter.To)
+			}
+			return each(userID, filter, fn)
+		}
+
+		var buf bytes.Buffer
+		apiErr := env.service.ExportTransactions("user123", ExportOptions{Format: "qif", From: "2025-03-03", To: "2025-03-03"}, func() io.Writer { return &buf })
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, "!Type:Bank\n"+
+			"D03/03/2025\nT100.00\nNtx1\nPdeposit\nMdeposit\n^\n"+
+			"D03/03/2025\nT-10.00\nNtx2\nPuser456\nMtransfer\n^\n", buf.String())
+	})
+
+	t.Run("validates the request before writing anything", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		tests := []struct {
+			options ExportOptions
+			message string
+		}{
+			{ExportOptions{Format: "xlsx"}, "Unsupported format"},
+			{ExportOptions{Currency: "XYZ"}, "Unsupported currency"},
+			{ExportOptions{From: "03/03/2025"}, "Invalid from date"},
+			{ExportOptions{From: "2025-03-04", To: "2025-03-03"}, "from must not be after to"},
+		}
+		for _, test := range tests {
+			apiErr := env.service.ExportTransactions("user123", test.options, func() io.Writer {
+				t.Fatal("an invalid export must not be started")
+				return nil
+			})
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, test.message, apiErr.Message)
+			}
+		}
+	})
+
+	t.Run("reports a failure while streaming", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.transactionRepo.EachByUserIDFunc = func(userID string, filter models.TransactionFilter, fn func(transaction *models.Transaction) error) error {
+			return errors.New("connection reset")
+		}
+
+		apiErr := env.service.ExportTransactions("user123", ExportOptions{}, func() io.Writer { return &bytes.Buffer{} })
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Failed to export transactions", apiErr.Message)
+		}
+	})
+}
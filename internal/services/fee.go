@@ -0,0 +1,258 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FeeConfig holds the fee rules by operation and then by currency. The first
+// rule matching a transaction sets its fee, so plan-specific and narrower
+// tiers must come before the rules they refine. A transaction no rule
+// matches is free.
+type FeeConfig map[string]map[string][]models.FeeRule
+
+// LoadFees reads fee rules from a JSON file such as:
+//
+//	{"withdraw": {"USD": [{"plan": "premium"}, {"flat": "0.25", "percent_bps": 100, "max_fee": "10.00"}]}}
+func LoadFees(path string) (FeeConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file FeeConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("fees: invalid fees file %s: %w", path, err)
+	}
+
+	config := make(FeeConfig, len(file))
+	for operation, byCurrency := range file {
+		if operation != models.FeeOperationWithdraw && operation != models.FeeOperationTransfer {
+			return nil, fmt.Errorf("fees: unknown operation %q", operation)
+		}
+		config[operation] = make(map[string][]models.FeeRule, len(byCurrency))
+		for code, rules := range byCurrency {
+			currency, err := money.LookupCurrency(code)
+			if err != nil {
+				return nil, fmt.Errorf("fees: unknown currency %q", code)
+			}
+			for i, rule := range rules {
+				if err := validateFeeRule(currency, rule); err != nil {
+					return nil, fmt.Errorf("fees: %s %s rule %d: %w", operation, currency.Code, i+1, err)
+				}
+			}
+			config[operation][currency.Code] = rules
+		}
+	}
+	return config, nil
+}
+
+func validateFeeRule(currency money.Currency, rule models.FeeRule) error {
+	for _, amount := range []money.Amount{rule.MinAmount, rule.MaxAmount, rule.Flat, rule.MinFee, rule.MaxFee} {
+		if amount < 0 {
+			return errors.New("amounts cannot be negative")
+		}
+		if err := currency.Validate(amount); err != nil {
+			return err
+		}
+	}
+	if rule.PercentBps < 0 || rule.PercentBps > 10000 {
+		return errors.New("percent_bps must be between 0 and 10000")
+	}
+	if rule.MaxAmount != 0 && rule.MinAmount > rule.MaxAmount {
+		return errors.New("min_amount is above max_amount")
+	}
+	if rule.MaxFee != 0 && rule.MinFee > rule.MaxFee {
+		return errors.New("min_fee is above max_fee")
+	}
+	return nil
+}
+
+// fee prices one transaction under the first rule that matches it. Fees are
+// rounded half up to the currency's minor unit.
+func (c FeeConfig) fee(operation, currency, plan string, amount money.Amount) money.Amount {
+	for _, rule := range c[operation][currency] {
+		if rule.Plan != "" && rule.Plan != plan {
+			continue
+		}
+		if amount < rule.MinAmount || (rule.MaxAmount != 0 && amount > rule.MaxAmount) {
+			continue
+		}
+
+		fee := rule.Flat + amount.Mul(big.NewRat(rule.PercentBps, 10000), money.RoundHalfUp)
+		if code, err := money.LookupCurrency(currency); err == nil {
+			fee = code.Round(fee, money.RoundHalfUp)
+		}
+		if rule.MinFee != 0 && fee < rule.MinFee {
+			fee = rule.MinFee
+		}
+		if rule.MaxFee != 0 && fee > rule.MaxFee {
+			fee = rule.MaxFee
+		}
+		return fee
+	}
+	return 0
+}
+
+// feesFor prices a user's withdrawals or transfers, one fee per amount, and
+// returns the fees with their total. The user's plan is only looked up when
+// there are rules to apply.
+func (s *walletService) feesFor(userID, operation, currency string, amounts ...money.Amount) ([]money.Amount, money.Amount, *APIError) {
+	fees := make([]money.Amount, len(amounts))
+	if len(s.Fees[operation][currency]) == 0 {
+		return fees, 0, nil
+	}
+
+	user, err := s.UserRepo.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, 0, NewNotFoundError("User not found")
+		}
+		return nil, 0, NewInternalServerError("Failed to get user")
+	}
+	plan := user.Plan
+	if plan == "" {
+		plan = models.UserPlanStandard
+	}
+
+	var total money.Amount
+	for i, amount := range amounts {
+		fees[i] = s.Fees.fee(operation, currency, plan, amount)
+		total += fees[i]
+	}
+	return fees, total, nil
+}
+
+// chargeFees books each non-zero fee as a fee transaction of its own, linked
+// to the transaction it was charged for, and moves the total from the payer's
+// wallet into the house fee wallet of its currency. The payer's wallet must be
+// locked, and must already agree with the ledger. The house fee wallet is
+// locked last, after every other wallet of the transaction, so that waiting
+// for it never deadlocks.
+func (s *walletService) chargeFees(tx *gorm.DB, wallet *models.Wallet, parents []*models.Transaction, fees []money.Amount) *APIError {
+	var total money.Amount
+	for _, fee := range fees {
+		total += fee
+	}
+	if total == 0 {
+		return nil
+	}
+
+	walletRepo := s.WalletRepo.WithTx(tx)
+	wallet.Balance -= total
+	wallet.UpdatedAt = time.Now()
+	if err := walletRepo.Update(wallet); err != nil {
+		return NewInternalServerError("Failed to update wallet")
+	}
+
+	house, apiErr := lockHouseFeeWallet(walletRepo, wallet.Currency)
+	if apiErr != nil {
+		return apiErr
+	}
+	house.Balance += total
+	house.UpdatedAt = time.Now()
+	if err := walletRepo.Update(house); err != nil {
+		return NewInternalServerError("Failed to update house fee wallet")
+	}
+
+	transactionRepo := s.TransactionRepo.WithTx(tx)
+	var charged money.Amount
+	for i, parent := range parents {
+		if fees[i] == 0 {
+			continue
+		}
+
+		transaction := &models.Transaction{
+			ID:                  uuid.New().String(),
+			FromUserID:          wallet.UserID,
+			ToUserID:            models.HouseFeesUserID,
+			Amount:              fees[i],
+			Currency:            wallet.Currency,
+			BatchID:             parent.BatchID,
+			ParentTransactionID: parent.ID,
+			Type:                models.TransactionTypeFee,
+			Status:              models.TransactionStatusSuccess,
+			CreatedAt:           time.Now(),
+			UpdatedAt:           time.Now(),
+		}
+		if err := transactionRepo.Create(transaction); err != nil {
+			return NewInternalServerError("Failed to create fee transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return apiErr
+		}
+
+		// The wallets only agree with the ledger again once the last fee is
+		// booked, so that is when they are checked
+		charged += fees[i]
+		var wallets []*models.Wallet
+		if charged == total {
+			wallets = append(wallets, wallet, house)
+		}
+		if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+			{account: models.WalletLedgerAccount(wallet.ID), amount: -fees[i]},
+			{account: models.WalletLedgerAccount(house.ID), amount: fees[i]},
+		}, wallets...); apiErr != nil {
+			return apiErr
+		}
+	}
+	return nil
+}
+
+// lockHouseFeeWallet locks the house fee wallet in a currency for update,
+// creating it the first time a fee is charged in that currency.
+func lockHouseFeeWallet(walletRepo repositories.WalletRepository, currency string) (*models.Wallet, *APIError) {
+	wallet, err := walletRepo.FindByUserIDForUpdate(models.HouseFeesUserID, currency)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := walletRepo.CreateIfMissing(&models.Wallet{
+			ID:        uuid.New().String(),
+			UserID:    models.HouseFeesUserID,
+			Currency:  currency,
+			Status:    models.WalletStatusActive,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}); err != nil {
+			return nil, NewInternalServerError("Failed to create house fee wallet")
+		}
+		wallet, err = walletRepo.FindByUserIDForUpdate(models.HouseFeesUserID, currency)
+	}
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get house fee wallet")
+	}
+	return wallet, nil
+}
+
+// PreviewFee shows what a withdrawal or transfer of amount would cost the
+// user, so they can confirm it knowing the fee.
+func (s *walletService) PreviewFee(userID, operation string, amount money.Amount, currency string) (*models.FeePreview, *APIError) {
+	if operation != models.FeeOperationWithdraw && operation != models.FeeOperationTransfer {
+		return nil, NewBadRequestError("Operation must be withdraw or transfer")
+	}
+	currency, apiErr := validateAmount(amount, currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	_, fee, apiErr := s.feesFor(userID, operation, currency, amount)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &models.FeePreview{
+		Operation: operation,
+		Amount:    amount,
+		Currency:  currency,
+		Fee:       fee,
+		Total:     amount + fee,
+	}, nil
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestLoadFees(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "fees.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"transfer": {"usd": [{"plan": "premium"}, {"percent_bps": 50, "max_fee": "10.00"}]}}`), 0o600))
+
+	fees, err := LoadFees(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.FeeRule{{Plan: "premium"}, {PercentBps: 50, MaxFee: money.FromMajor(10)}}, fees[models.FeeOperationTransfer]["USD"])
+
+	for name, body := range map[string]string{
+		"unknown operation": `{"deposit": {"USD": [{"flat": "1.00"}]}}`,
+		"inverted caps":     `{"withdraw": {"USD": [{"min_fee": "5.00", "max_fee": "1.00"}]}}`,
+		"sub-yen fee":       `{"withdraw": {"JPY": [{"flat": "0.50"}]}}`,
+	} {
+		invalid := filepath.Join(dir, "invalid.json")
+		assert.NoError(t, os.WriteFile(invalid, []byte(body), 0o600))
+
+		_, err := LoadFees(invalid)
+
+		assert.Error(t, err, name)
+	}
+}
+
+func TestFeeConfig_Fee(t *testing.T) {
+	fees := FeeConfig{
+		models.FeeOperationTransfer: {
+			"USD": {
+				{Plan: "premium"},
+				{MaxAmount: money.FromMajor(100)},
+				{MaxAmount: money.FromMajor(1000), Flat: money.MustParse("0.30"), PercentBps: 290},
+				{PercentBps: 50, MinFee: money.FromMajor(10), MaxFee: money.FromMajor(20)},
+			},
+			"JPY": {
+				{PercentBps: 125},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		currency string
+		plan     string
+		amount   money.Amount
+		want     money.Amount
+	}{
+		{"premium plan is free", "USD", "premium", money.FromMajor(5000), 0},
+		{"small transfers are free", "USD", models.UserPlanStandard, money.FromMajor(100), 0},
+		{"flat plus percentage", "USD", models.UserPlanStandard, money.MustParse("250.00"), money.MustParse("7.55")},
+		{"percentage rounds half up", "USD", models.UserPlanStandard, money.MustParse("105.00"), money.MustParse("3.35")},
+		{"minimum fee", "USD", models.UserPlanStandard, money.FromMajor(1001), money.FromMajor(10)},
+		{"maximum fee", "USD", models.UserPlanStandard, money.FromMajor(10000), money.FromMajor(20)},
+		{"rounds to whole yen", "JPY", models.UserPlanStandard, money.FromMajor(1000), money.FromMajor(13)},
+		{"no rules", "EUR", models.UserPlanStandard, money.FromMajor(1000), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, fees.fee(models.FeeOperationTransfer, tt.currency, tt.plan, tt.amount))
+		})
+	}
+}
+
+func TestWalletService_Fees(t *testing.T) {
+	newFeeEnv := func(t *testing.T, balance money.Amount) *testEnv {
+		env := newTestEnv(t)
+		env.service.(*walletService).Fees = FeeConfig{
+			models.FeeOperationTransfer: {"USD": {{Flat: money.FromMajor(1), PercentBps: 100}}},
+		}
+		env.userRepo.FindByIDFunc = func(id string) (*models.User, error) {
+			return &models.User{ID: id, Plan: models.UserPlanStandard}, nil
+		}
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "w-" + userID, UserID: userID, Currency: currency, Balance: balance}, nil
+		}
+		env.cache.DeleteFunc = func(key string) {}
+		return env
+	}
+
+	t.Run("transfer books its fee as a linked transaction", func(t *testing.T) {
+		env := newFeeEnv(t, money.FromMajor(1000))
+		defer env.db.Close()
+
+		var transactions []*models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			transactions = append(transactions, tx)
+			return nil
+		}
+		var postings []models.LedgerPosting
+		env.ledgerRepo.CreatePostingsFunc = func(p []models.LedgerPosting) error {
+			postings = append(postings, p...)
+			return nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(200), "USD", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(797), balance)
+		if assert.Len(t, transactions, 2) {
+			fee := transactions[1]
+			assert.Equal(t, models.TransactionTypeFee, fee.Type)
+			assert.Equal(t, "user123", fee.FromUserID)
+			assert.Equal(t, models.HouseFeesUserID, fee.ToUserID)
+			assert.Equal(t, money.FromMajor(3), fee.Amount)
+			assert.Equal(t, "USD", fee.Currency)
+			assert.Equal(t, transactions[0].ID, fee.ParentTransactionID)
+		}
+		if assert.Len(t, postings, 4) {
+			assert.Equal(t, models.WalletLedgerAccount("w-"+models.HouseFeesUserID), postings[3].AccountCode)
+			assert.Equal(t, money.FromMajor(3), postings[3].Amount)
+		}
+		// The house fee wallet is credited in the same transaction
+		updated := env.walletRepo.Updated
+		if assert.NotEmpty(t, updated) {
+			house := updated[len(updated)-1]
+			assert.Equal(t, models.HouseFeesUserID, house.UserID)
+			assert.Equal(t, money.FromMajor(1003), house.Balance)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("creates the house fee wallet of a currency on its first fee", func(t *testing.T) {
+		env := newFeeEnv(t, money.FromMajor(1000))
+		defer env.db.Close()
+
+		var house *models.Wallet
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			if userID != models.HouseFeesUserID {
+				return &models.Wallet{ID: "w-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(1000)}, nil
+			}
+			if house == nil {
+				return nil, gorm.ErrRecordNotFound
+			}
+			return house, nil
+		}
+		env.walletRepo.CreateIfMissingFunc = func(wallet *models.Wallet) error {
+			assert.Equal(t, models.HouseFeesUserID, wallet.UserID)
+			assert.Equal(t, "USD", wallet.Currency)
+			house = wallet
+			return nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(200), "USD", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		if assert.NotNil(t, house) {
+			assert.Equal(t, money.FromMajor(3), house.Balance)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("the balance must cover the fee too", func(t *testing.T) {
+		env := newFeeEnv(t, money.FromMajor(200))
+		defer env.db.Close()
+
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			t.Fatal("transaction must not be created")
+			return nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(200), "USD", Idempotency{})
+
+		assert.True(t, IsInsufficientBalance(apiErr))
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("each transfer of a batch is charged its own fee", func(t *testing.T) {
+		env := newFeeEnv(t, money.FromMajor(1000))
+		defer env.db.Close()
+
+		var fees []*models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			if tx.Type == models.TransactionTypeFee {
+				fees = append(fees, tx)
+			}
+			return nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		balance, batch, apiErr := env.service.BatchTransfer("user123", "USD", []BatchTransferItem{
+			{ToUserID: "user456", Amount: money.FromMajor(100)},
+			{ToUserID: "user789", Amount: money.FromMajor(300)},
+		}, Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(594), balance)
+		if assert.Len(t, fees, 2) {
+			assert.Equal(t, money.FromMajor(2), fees[0].Amount)
+			assert.Equal(t, batch.Transactions[0].ID, fees[0].ParentTransactionID)
+			assert.Equal(t, money.FromMajor(4), fees[1].Amount)
+			assert.Equal(t, batch.ID, fees[1].BatchID)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("preview shows the fee and total", func(t *testing.T) {
+		env := newFeeEnv(t, 0)
+		defer env.db.Close()
+
+		preview, apiErr := env.service.PreviewFee("user123", models.FeeOperationTransfer, money.FromMajor(50), "usd")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, &models.FeePreview{
+			Operation: models.FeeOperationTransfer,
+			Amount:    money.FromMajor(50),
+			Currency:  "USD",
+			Fee:       money.MustParse("1.50"),
+			Total:     money.MustParse("51.50"),
+		}, preview)
+
+		_, apiErr = env.service.PreviewFee("user123", "deposit", money.FromMajor(50), "USD")
+
+		assert.Equal(t, 400, apiErr.Code)
+	})
+}
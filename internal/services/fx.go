@@ -0,0 +1,231 @@
+package services
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"time"
+
+	"wallet/internal/fx"
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultFXSpreadBps = 50
+	DefaultFXQuoteTTL  = 30 * time.Second
+
+	// rateDecimals is the precision quoted rates are rounded to. The rounded
+	// rate is both stored and applied, so the recorded rate always reproduces
+	// the converted amount.
+	rateDecimals = 8
+)
+
+type fxService struct {
+	QuoteRepo    repositories.FXQuoteRepository
+	RateProvider fx.RateProvider
+	SpreadBps    int64
+	QuoteTTL     time.Duration
+}
+
+func NewFXService(
+	quoteRepo repositories.FXQuoteRepository,
+	rateProvider fx.RateProvider,
+	spreadBps int64,
+	quoteTTL time.Duration,
+) FXService {
+	if quoteTTL <= 0 {
+		quoteTTL = DefaultFXQuoteTTL
+	}
+	return &fxService{
+		QuoteRepo:    quoteRepo,
+		RateProvider: rateProvider,
+		SpreadBps:    spreadBps,
+		QuoteTTL:     quoteTTL,
+	}
+}
+
+// CreateQuote quotes converting amount of fromCurrency into toCurrency. The
+// customer rate is the mid rate less the spread, and the converted amount is
+// rounded down to the target currency's minor unit.
+func (s *fxService) CreateQuote(userID, fromCurrency, toCurrency string, amount money.Amount) (*models.FXQuote, *APIError) {
+	fromCurrency, apiErr := validateAmount(amount, fromCurrency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	toCurrency, apiErr = normalizeCurrency(toCurrency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if fromCurrency == toCurrency {
+		return nil, NewBadRequestError("Currencies must differ")
+	}
+
+	midRate, err := s.RateProvider.Rate(fromCurrency, toCurrency)
+	if err != nil {
+		if errors.Is(err, fx.ErrRateUnavailable) {
+			return nil, NewAPIError(http.StatusServiceUnavailable, "Exchange rate unavailable")
+		}
+		return nil, NewInternalServerError("Failed to get exchange rate")
+	}
+
+	rate := new(big.Rat).Mul(midRate, big.NewRat(10000-s.SpreadBps, 10000))
+	rate = roundRate(rate)
+
+	target, _ := money.LookupCurrency(toCurrency)
+	toAmount := target.Round(amount.Mul(rate, money.RoundDown), money.RoundDown)
+	if toAmount <= 0 {
+		return nil, NewBadRequestError("Amount is too small to convert")
+	}
+
+	now := time.Now()
+	quote := &models.FXQuote{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		FromCurrency: fromCurrency,
+		ToCurrency:   toCurrency,
+		FromAmount:   amount,
+		ToAmount:     toAmount,
+		MidRate:      roundRate(midRate).FloatString(rateDecimals),
+		SpreadBps:    s.SpreadBps,
+		Rate:         rate.FloatString(rateDecimals),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		ExpiresAt:    now.Add(s.QuoteTTL),
+	}
+	if err := s.QuoteRepo.Create(quote); err != nil {
+		return nil, NewInternalServerError("Failed to create quote")
+	}
+	return quote, nil
+}
+
+func roundRate(r *big.Rat) *big.Rat {
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(rateDecimals), nil)
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(scale))
+	return new(big.Rat).SetFrac(big.NewInt(money.Round(scaled, money.RoundHalfEven)), scale)
+}
+
+// TransferWithQuote executes a cross-currency transfer at a previously quoted
+// rate. The sender's wallet is debited the quoted amount in the source
+// currency and the recipient's wallet credited the quoted amount in the target
+// currency, with the house FX account taking the other side of each leg.
+func (s *walletService) TransferWithQuote(fromUserID, toUserID, quoteID string, idem Idempotency) (money.Amount, *APIError) {
+	response, apiErr := s.runIdempotent(fromUserID, idem, func(tx *gorm.DB) (idempotentResponse, *APIError) {
+		// Create repository instances with transaction
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
+		quoteRepo := s.FXQuoteRepo.WithTx(tx)
+
+		quote, err := quoteRepo.FindByIDForUpdate(quoteID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return idempotentResponse{}, NewNotFoundError("Quote not found")
+			}
+			return idempotentResponse{}, NewInternalServerError("Failed to get quote")
+		}
+		if quote.UserID != fromUserID {
+			return idempotentResponse{}, NewNotFoundError("Quote not found")
+		}
+		if quote.UsedAt != nil {
+			return idempotentResponse{}, NewConflictError("Quote has already been used")
+		}
+		if time.Now().After(quote.ExpiresAt) {
+			return idempotentResponse{}, NewBadRequestError("Quote has expired")
+		}
+
+		fromWallet, toWallet, apiErr := lockWalletPair(walletRepo, fromUserID, quote.FromCurrency, toUserID, quote.ToCurrency)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := checkTransfer(fromWallet, toWallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		if apiErr := s.checkLimits(tx, fromUserID, models.LimitOperationTransfer, quote.FromCurrency, quote.FromAmount); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		fees, fee, apiErr := s.feesFor(fromUserID, models.FeeOperationTransfer, quote.FromCurrency, quote.FromAmount)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		available, apiErr := availableBalance(s.HoldRepo.WithTx(tx), fromWallet)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if available < quote.FromAmount+fee {
+			return idempotentResponse{}, NewInsufficientBalanceError()
+		}
+
+		// Create transaction
+		transaction := &models.Transaction{
+			ID:           uuid.New().String(),
+			FromUserID:   fromUserID,
+			ToUserID:     toUserID,
+			Amount:       quote.FromAmount,
+			Currency:     quote.FromCurrency,
+			ToAmount:     quote.ToAmount,
+			ToCurrency:   quote.ToCurrency,
+			ExchangeRate: quote.Rate,
+			QuoteID:      quote.ID,
+			Type:         models.TransactionTypeTransfer,
+			Status:       models.TransactionStatusSuccess,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		}
+
+		if err := transactionRepo.Create(transaction); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to create transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		// Update sender's wallet
+		fromWallet.Balance -= quote.FromAmount
+		fromWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(fromWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update sender's wallet")
+		}
+
+		// Update recipient's wallet
+		toWallet.Balance += quote.ToAmount
+		toWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(toWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update recipient's wallet")
+		}
+
+		if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+			{account: models.WalletLedgerAccount(fromWallet.ID), amount: -quote.FromAmount, currency: quote.FromCurrency},
+			{account: models.LedgerAccountHouseFX, amount: quote.FromAmount, currency: quote.FromCurrency},
+			{account: models.LedgerAccountHouseFX, amount: -quote.ToAmount, currency: quote.ToCurrency},
+			{account: models.WalletLedgerAccount(toWallet.ID), amount: quote.ToAmount, currency: quote.ToCurrency},
+		}, fromWallet, toWallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := s.chargeFees(tx, fromWallet, []*models.Transaction{transaction}, fees); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		// Mark the quote as used
+		now := time.Now()
+		quote.UsedAt = &now
+		quote.TransactionID = transaction.ID
+		quote.UpdatedAt = now
+		if err := quoteRepo.Update(quote); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update quote")
+		}
+		return idempotentResponse{Balance: fromWallet.Balance, TransactionID: transaction.ID}, nil
+	})
+	if apiErr != nil {
+		return 0, apiErr
+	}
+
+	s.changed(fromUserID, toUserID)
+	return response.Balance, nil
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"wallet/internal/fx"
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubRateProvider serves fixed mid rates keyed by "FROM/TO"
+type stubRateProvider map[string]*big.Rat
+
+func (p stubRateProvider) Rate(from, to string) (*big.Rat, error) {
+	rate, ok := p[from+"/"+to]
+	if !ok {
+		return nil, fx.ErrRateUnavailable
+	}
+	return rate, nil
+}
+
+func TestFXService_CreateQuote(t *testing.T) {
+	rates := stubRateProvider{
+		"USD/EUR": big.NewRat(92, 100),
+		"USD/JPY": big.NewRat(1573, 10),
+	}
+
+	t.Run("applies the spread and rounds down", func(t *testing.T) {
+		var created *models.FXQuote
+		quoteRepo := &repositories.MockFXQuoteRepository{
+			CreateFunc: func(quote *models.FXQuote) error {
+				created = quote
+				return nil
+			},
+		}
+		service := NewFXService(quoteRepo, rates, 50, time.Minute)
+
+		quote, apiErr := service.CreateQuote("user123", "usd", "eur", money.FromMajor(100))
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, created, quote)
+		assert.Equal(t, "0.92000000", quote.MidRate)
+		assert.Equal(t, "0.91540000", quote.Rate)
+		assert.Equal(t, money.MustParse("91.54"), quote.ToAmount)
+		assert.Equal(t, "USD", quote.FromCurrency)
+		assert.Equal(t, "EUR", quote.ToCurrency)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), quote.ExpiresAt, time.Second)
+	})
+
+	t.Run("rounds to the target currency's minor unit", func(t *testing.T) {
+		service := NewFXService(&repositories.MockFXQuoteRepository{}, rates, 0, time.Minute)
+
+		quote, apiErr := service.CreateQuote("user123", "USD", "JPY", money.MustParse("10.01"))
+
+		assert.Nil(t, apiErr)
+		// 10.01 * 157.3 = 1574.573
+		assert.Equal(t, money.FromMajor(1574), quote.ToAmount)
+	})
+
+	t.Run("unknown rate", func(t *testing.T) {
+		service := NewFXService(&repositories.MockFXQuoteRepository{}, rates, 0, time.Minute)
+
+		_, apiErr := service.CreateQuote("user123", "EUR", "GBP", money.FromMajor(10))
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusServiceUnavailable, apiErr.Code)
+		}
+	})
+}
+
+func TestWalletService_TransferWithQuote(t *testing.T) {
+	newQuote := func() *models.FXQuote {
+		return &models.FXQuote{
+			ID:           "quote1",
+			UserID:       "user123",
+			FromCurrency: "EUR",
+			ToCurrency:   "USD",
+			FromAmount:   money.FromMajor(100),
+			ToAmount:     money.MustParse("108.15"),
+			Rate:         "1.08150000",
+			ExpiresAt:    time.Now().Add(time.Minute),
+		}
+	}
+
+	t.Run("debits and credits both legs at the quoted rate", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		quote := newQuote()
+
+		env.sqlMock.ExpectBegin()
+
+		env.fxQuoteRepo.FindByIDForUpdateFunc = func(id string) (*models.FXQuote, error) {
+			return quote, nil
+		}
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: userID + "-" + currency, UserID: userID, Currency: currency, Balance: money.FromMajor(500)}, nil
+		}
+
+		var transaction *models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			transaction = tx
+			return nil
+		}
+		var postings []models.LedgerPosting
+		env.ledgerRepo.CreatePostingsFunc = func(p []models.LedgerPosting) error {
+			postings = p
+			return nil
+		}
+
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.TransferWithQuote("user123", "user456", "quote1", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(400), balance)
+		if assert.NotNil(t, transaction) {
+			assert.Equal(t, money.FromMajor(100), transaction.Amount)
+			assert.Equal(t, "EUR", transaction.Currency)
+			assert.Equal(t, money.MustParse("108.15"), transaction.ToAmount)
+			assert.Equal(t, "USD", transaction.ToCurrency)
+			assert.Equal(t, "1.08150000", transaction.ExchangeRate)
+		}
+
+		assert.Len(t, env.walletRepo.Updated, 2)
+		for _, wallet := range env.walletRepo.Updated {
+			if wallet.UserID == "user456" {
+				assert.Equal(t, "USD", wallet.Currency)
+				assert.Equal(t, money.MustParse("608.15"), wallet.Balance)
+			}
+		}
+
+		totals := map[string]money.Amount{}
+		for _, posting := range postings {
+			totals[posting.Currency] += posting.Amount
+		}
+		assert.Equal(t, map[string]money.Amount{"EUR": 0, "USD": 0}, totals)
+
+		assert.NotNil(t, quote.UsedAt)
+		assert.Equal(t, transaction.ID, quote.TransactionID)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("quote can only be used once", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		quote := newQuote()
+		usedAt := time.Now()
+		quote.UsedAt = &usedAt
+
+		env.sqlMock.ExpectBegin()
+		env.fxQuoteRepo.FindByIDForUpdateFunc = func(id string) (*models.FXQuote, error) {
+			return quote, nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.TransferWithQuote("user123", "user456", "quote1", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusConflict, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("expired quote", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		quote := newQuote()
+		quote.ExpiresAt = time.Now().Add(-time.Second)
+
+		env.sqlMock.ExpectBegin()
+		env.fxQuoteRepo.FindByIDForUpdateFunc = func(id string) (*models.FXQuote, error) {
+			return quote, nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.TransferWithQuote("user123", "user456", "quote1", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Quote has expired", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("quote belongs to another user", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.fxQuoteRepo.FindByIDForUpdateFunc = func(id string) (*models.FXQuote, error) {
+			return newQuote(), nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.TransferWithQuote("user789", "user456", "quote1", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusNotFound, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,342 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultHoldTTL is how long a hold lasts when no TTL is configured.
+const DefaultHoldTTL = 7 * 24 * time.Hour
+
+// expireHoldsBatchSize caps how many holds one ExpireHolds call sweeps.
+const expireHoldsBatchSize = 100
+
+// availableBalance returns what a wallet can still spend: its balance less
+// its active holds. The caller must hold the wallet's row lock, since holds
+// are only placed under that lock.
+func availableBalance(holdRepo repositories.HoldRepository, wallet *models.Wallet) (money.Amount, *APIError) {
+	held, err := holdRepo.SumActiveByWalletID(wallet.ID, time.Now())
+	if err != nil {
+		return 0, NewInternalServerError("Failed to get held amount")
+	}
+	return wallet.Balance - held, nil
+}
+
+// PlaceHold reserves amount of a user's wallet for an internal process such as
+// a dispute or a pending payout. A positive ttl makes the hold expire; with
+// no ttl it lasts until it is released or converted.
+func (s *walletService) PlaceHold(userID, currency string, amount money.Amount, reason string, ttl time.Duration) (*models.Hold, *APIError) {
+	currency, apiErr := validateAmount(amount, currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if reason == "" || reason == models.HoldReasonAuthorization {
+		return nil, NewBadRequestError("Invalid hold reason")
+	}
+
+	var hold *models.Hold
+	apiErr = s.inTx(func(tx *gorm.DB) *APIError {
+		holdRepo := s.HoldRepo.WithTx(tx)
+
+		wallet, apiErr := lockWallet(s.WalletRepo.WithTx(tx), userID, currency)
+		if apiErr != nil {
+			return apiErr
+		}
+		// A frozen wallet can still be held against, e.g. for a dispute
+		if wallet.Status == models.WalletStatusClosed {
+			return NewWalletUnavailableError(wallet)
+		}
+
+		available, apiErr := availableBalance(holdRepo, wallet)
+		if apiErr != nil {
+			return apiErr
+		}
+		if available < amount {
+			return NewInsufficientBalanceError()
+		}
+
+		hold = &models.Hold{
+			ID:        uuid.New().String(),
+			UserID:    userID,
+			WalletID:  wallet.ID,
+			Amount:    amount,
+			Currency:  currency,
+			Reason:    reason,
+			Status:    models.HoldStatusActive,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if ttl > 0 {
+			expiresAt := time.Now().Add(ttl)
+			hold.ExpiresAt = &expiresAt
+		}
+		if err := holdRepo.Create(hold); err != nil {
+			return NewInternalServerError("Failed to create hold")
+		}
+		return s.publish(tx, models.EventHoldPlaced, hold)
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.changed(userID)
+	return hold, nil
+}
+
+// ReleaseHold gives the held funds back to the wallet's available balance.
+func (s *walletService) ReleaseHold(holdID string) *APIError {
+	var userID string
+	apiErr := s.inTx(func(tx *gorm.DB) *APIError {
+		holdRepo := s.HoldRepo.WithTx(tx)
+
+		hold, apiErr := lockHold(holdRepo, holdID)
+		if apiErr != nil {
+			return apiErr
+		}
+
+		hold.Status = models.HoldStatusReleased
+		hold.UpdatedAt = time.Now()
+		if err := holdRepo.Update(hold); err != nil {
+			return NewInternalServerError("Failed to update hold")
+		}
+		userID = hold.UserID
+		return s.publish(tx, models.EventHoldReleased, hold)
+	})
+	if apiErr != nil {
+		return apiErr
+	}
+
+	s.changed(userID)
+	return nil
+}
+
+// ConvertHold turns a hold into a transaction for the held amount: a transfer
+// to toUserID's wallet in the hold's currency, or a withdrawal when toUserID
+// is empty.
+func (s *walletService) ConvertHold(holdID, toUserID string) (*models.Transaction, *APIError) {
+	var transaction *models.Transaction
+	apiErr := s.inTx(func(tx *gorm.DB) *APIError {
+		// Create repository instances with transaction
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
+		holdRepo := s.HoldRepo.WithTx(tx)
+
+		hold, apiErr := lockHold(holdRepo, holdID)
+		if apiErr != nil {
+			return apiErr
+		}
+		if hold.UserID == toUserID {
+			return NewBadRequestError("Cannot transfer to yourself")
+		}
+
+		var fromWallet, toWallet *models.Wallet
+		if toUserID == "" {
+			fromWallet, apiErr = lockWallet(walletRepo, hold.UserID, hold.Currency)
+		} else {
+			fromWallet, toWallet, apiErr = lockWalletPair(walletRepo, hold.UserID, hold.Currency, toUserID, hold.Currency)
+		}
+		if apiErr != nil {
+			return apiErr
+		}
+		if apiErr := checkDebit(fromWallet, true); apiErr != nil {
+			return apiErr
+		}
+		if toWallet != nil {
+			if apiErr := checkCredit(toWallet, false); apiErr != nil {
+				return apiErr
+			}
+		}
+
+		// A converted hold is a withdrawal or transfer like any other, so it
+		// counts towards the user's limits and pays the same fee.
+		limitOperation, feeOperation := models.LimitOperationTransfer, models.FeeOperationTransfer
+		if toWallet == nil {
+			limitOperation, feeOperation = models.LimitOperationWithdraw, models.FeeOperationWithdraw
+		}
+		if apiErr := s.checkLimits(tx, hold.UserID, limitOperation, hold.Currency, hold.Amount); apiErr != nil {
+			return apiErr
+		}
+		fees, fee, apiErr := s.feesFor(hold.UserID, feeOperation, hold.Currency, hold.Amount)
+		if apiErr != nil {
+			return apiErr
+		}
+		// The held amount is already set aside, only the fee has to come out
+		// of what is still available.
+		available, apiErr := availableBalance(holdRepo, fromWallet)
+		if apiErr != nil {
+			return apiErr
+		}
+		if available < fee {
+			return NewInsufficientBalanceError()
+		}
+
+		transaction = &models.Transaction{
+			ID:         uuid.New().String(),
+			FromUserID: hold.UserID,
+			ToUserID:   toUserID,
+			Amount:     hold.Amount,
+			Currency:   hold.Currency,
+			Type:       models.TransactionTypeTransfer,
+			Status:     models.TransactionStatusSuccess,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+		if toWallet == nil {
+			transaction.Type = models.TransactionTypeWithdraw
+		}
+		if err := transactionRepo.Create(transaction); err != nil {
+			return NewInternalServerError("Failed to create transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return apiErr
+		}
+
+		hold.Status = models.HoldStatusCaptured
+		hold.TransactionID = transaction.ID
+		hold.UpdatedAt = time.Now()
+		if err := holdRepo.Update(hold); err != nil {
+			return NewInternalServerError("Failed to update hold")
+		}
+
+		// Update held wallet
+		fromWallet.Balance -= hold.Amount
+		fromWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(fromWallet); err != nil {
+			return NewInternalServerError("Failed to update wallet")
+		}
+
+		if toWallet == nil {
+			if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+				{account: models.WalletLedgerAccount(fromWallet.ID), amount: -hold.Amount},
+				{account: models.LedgerAccountWorldWithdrawals, amount: hold.Amount},
+			}, fromWallet); apiErr != nil {
+				return apiErr
+			}
+			return s.chargeFees(tx, fromWallet, []*models.Transaction{transaction}, fees)
+		}
+
+		// Update recipient's wallet
+		toWallet.Balance += hold.Amount
+		toWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(toWallet); err != nil {
+			return NewInternalServerError("Failed to update recipient's wallet")
+		}
+
+		if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+			{account: models.WalletLedgerAccount(fromWallet.ID), amount: -hold.Amount},
+			{account: models.WalletLedgerAccount(toWallet.ID), amount: hold.Amount},
+		}, fromWallet, toWallet); apiErr != nil {
+			return apiErr
+		}
+		return s.chargeFees(tx, fromWallet, []*models.Transaction{transaction}, fees)
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.changed(transaction.FromUserID, transaction.ToUserID)
+	return transaction, nil
+}
+
+// lockHold locks an active hold placed through PlaceHold. Authorization holds
+// are only settled through their transaction.
+func lockHold(holdRepo repositories.HoldRepository, holdID string) (*models.Hold, *APIError) {
+	hold, err := holdRepo.FindByIDForUpdate(holdID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Hold not found")
+		}
+		return nil, NewInternalServerError("Failed to get hold")
+	}
+	if hold.Reason == models.HoldReasonAuthorization {
+		return nil, NewConflictError("Hold belongs to an authorization")
+	}
+	if hold.Status != models.HoldStatusActive || hold.Expired(time.Now()) {
+		return nil, NewConflictError("Hold is no longer active")
+	}
+	return hold, nil
+}
+
+// ExpireHolds marks active holds past their expiry as expired, along with
+// the pending transactions they reserved funds for. Expired holds already
+// stop counting against the available balance; this makes the expiry visible
+// in their status. It returns the number of holds expired.
+func (s *walletService) ExpireHolds() (int, *APIError) {
+	now := time.Now()
+	holds, err := s.HoldRepo.FindExpired(now, expireHoldsBatchSize)
+	if err != nil {
+		return 0, NewInternalServerError("Failed to get expired holds")
+	}
+
+	expired := 0
+	for _, candidate := range holds {
+		var transaction *models.Transaction
+		var changed bool
+		apiErr := s.inTx(func(tx *gorm.DB) *APIError {
+			holdRepo := s.HoldRepo.WithTx(tx)
+			transactionRepo := s.TransactionRepo.WithTx(tx)
+
+			// Lock the transaction before the hold, in the same order as
+			// capture and void, so the two cannot deadlock.
+			transaction, changed = nil, false
+			if candidate.TransactionID != "" {
+				var err error
+				transaction, err = transactionRepo.FindByIDForUpdate(candidate.TransactionID)
+				if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					return NewInternalServerError("Failed to get transaction")
+				}
+			}
+
+			hold, err := holdRepo.FindByIDForUpdate(candidate.ID)
+			if err != nil {
+				return NewInternalServerError("Failed to get hold")
+			}
+			// Captured or released since it was listed
+			if hold.Status != models.HoldStatusActive {
+				return nil
+			}
+			changed = true
+
+			hold.Status = models.HoldStatusExpired
+			hold.UpdatedAt = time.Now()
+			if err := holdRepo.Update(hold); err != nil {
+				return NewInternalServerError("Failed to update hold")
+			}
+
+			// An authorization is announced through its transfer
+			if transaction == nil {
+				return s.publish(tx, models.EventHoldExpired, hold)
+			}
+			if transaction.Status == models.TransactionStatusPending {
+				transaction.Status = models.TransactionStatusExpired
+				transaction.UpdatedAt = time.Now()
+				if err := transactionRepo.Update(transaction); err != nil {
+					return NewInternalServerError("Failed to update transaction")
+				}
+				return s.publishTransaction(tx, transaction)
+			}
+			return nil
+		})
+		if apiErr != nil {
+			return expired, apiErr
+		}
+
+		if !changed {
+			continue
+		}
+		if transaction != nil {
+			s.changed(transaction.FromUserID, transaction.ToUserID)
+		} else {
+			s.changed(candidate.UserID)
+		}
+		expired++
+	}
+	return expired, nil
+}
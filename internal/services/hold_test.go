@@ -0,0 +1,262 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_PlaceHold(t *testing.T) {
+	t.Run("holds funds without moving them", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		hold, apiErr := env.service.PlaceHold("user123", "USD", money.FromMajor(60), "dispute", 0)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, "user123", hold.UserID)
+		assert.Equal(t, "wallet-user123", hold.WalletID)
+		assert.Equal(t, "dispute", hold.Reason)
+		assert.Nil(t, hold.ExpiresAt)
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("cannot hold more than is available", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+		}
+		env.holdRepo.SumActiveByWalletIDFunc = func(walletID string, now time.Time) (money.Amount, error) {
+			return money.FromMajor(50), nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.PlaceHold("user123", "USD", money.FromMajor(60), "payout", time.Hour)
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Insufficient balance", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_ReleaseHold(t *testing.T) {
+	t.Run("releases an active hold", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		hold := &models.Hold{ID: "hold1", UserID: "user123", Reason: "dispute", Status: models.HoldStatusActive}
+		env.sqlMock.ExpectBegin()
+		env.holdRepo.FindByIDForUpdateFunc = func(id string) (*models.Hold, error) {
+			return hold, nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		apiErr := env.service.ReleaseHold("hold1")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.HoldStatusReleased, hold.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("authorization holds are settled through their transaction", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.holdRepo.FindByIDForUpdateFunc = func(id string) (*models.Hold, error) {
+			return &models.Hold{ID: "hold1", TransactionID: "tx1", Reason: models.HoldReasonAuthorization, Status: models.HoldStatusActive}, nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		apiErr := env.service.ReleaseHold("hold1")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusConflict, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_ConvertHold(t *testing.T) {
+	newHold := func(env *testEnv) *models.Hold {
+		hold := &models.Hold{ID: "hold1", UserID: "user123", WalletID: "wallet-user123", Amount: money.FromMajor(30), Currency: "USD", Reason: "payout", Status: models.HoldStatusActive}
+		env.holdRepo.FindByIDForUpdateFunc = func(id string) (*models.Hold, error) {
+			return hold, nil
+		}
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+		}
+		return hold
+	}
+
+	t.Run("converts into a withdrawal", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		hold := newHold(env)
+		var postings []models.LedgerPosting
+		env.ledgerRepo.CreatePostingsFunc = func(p []models.LedgerPosting) error {
+			postings = p
+			return nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		transaction, apiErr := env.service.ConvertHold("hold1", "")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.TransactionTypeWithdraw, transaction.Type)
+		assert.Equal(t, money.FromMajor(30), transaction.Amount)
+		assert.Equal(t, models.HoldStatusCaptured, hold.Status)
+		assert.Equal(t, transaction.ID, hold.TransactionID)
+		if assert.Len(t, env.walletRepo.Updated, 1) {
+			assert.Equal(t, money.FromMajor(70), env.walletRepo.Updated[0].Balance)
+		}
+		assert.Len(t, postings, 2)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("converts into a transfer", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		newHold(env)
+		env.sqlMock.ExpectCommit()
+
+		transaction, apiErr := env.service.ConvertHold("hold1", "user456")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.TransactionTypeTransfer, transaction.Type)
+		assert.Equal(t, "user456", transaction.ToUserID)
+		assert.Len(t, env.walletRepo.Updated, 2)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("charges the withdrawal fee", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.service.(*walletService).Fees = FeeConfig{
+			models.FeeOperationWithdraw: {"USD": {{Flat: money.FromMajor(1)}}},
+		}
+		env.userRepo.FindByIDFunc = func(id string) (*models.User, error) {
+			return &models.User{ID: id, Plan: models.UserPlanStandard}, nil
+		}
+		env.sqlMock.ExpectBegin()
+		newHold(env)
+		env.holdRepo.SumActiveByWalletIDFunc = func(walletID string, now time.Time) (money.Amount, error) {
+			return money.FromMajor(30), nil
+		}
+		var transactions []*models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			transactions = append(transactions, tx)
+			return nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		transaction, apiErr := env.service.ConvertHold("hold1", "")
+
+		assert.Nil(t, apiErr)
+		if assert.Len(t, transactions, 2) {
+			fee := transactions[1]
+			assert.Equal(t, models.TransactionTypeFee, fee.Type)
+			assert.Equal(t, transaction.ID, fee.ParentTransactionID)
+			assert.Equal(t, models.HouseFeesUserID, fee.ToUserID)
+			assert.Equal(t, money.FromMajor(1), fee.Amount)
+		}
+		// The held amount is debited first, then the fee
+		if assert.Len(t, env.walletRepo.Updated, 3) {
+			assert.Equal(t, money.FromMajor(70), env.walletRepo.Updated[0].Balance)
+			assert.Equal(t, money.FromMajor(69), env.walletRepo.Updated[1].Balance)
+			assert.Equal(t, models.HouseFeesUserID, env.walletRepo.Updated[2].UserID)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("the fee must come out of the available balance", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.service.(*walletService).Fees = FeeConfig{
+			models.FeeOperationTransfer: {"USD": {{Flat: money.FromMajor(1)}}},
+		}
+		env.userRepo.FindByIDFunc = func(id string) (*models.User, error) {
+			return &models.User{ID: id, Plan: models.UserPlanStandard}, nil
+		}
+		env.sqlMock.ExpectBegin()
+		hold := newHold(env)
+		// The rest of the wallet is held by another hold
+		env.holdRepo.SumActiveByWalletIDFunc = func(walletID string, now time.Time) (money.Amount, error) {
+			return money.FromMajor(100), nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.ConvertHold("hold1", "user456")
+
+		assert.True(t, IsInsufficientBalance(apiErr))
+		assert.Equal(t, models.HoldStatusActive, hold.Status)
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("counts towards the transfer limits", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.service.(*walletService).Limits = LimitConfig{
+			models.LimitOperationTransfer: {"USD": {MaxAmount: money.FromMajor(20)}},
+		}
+		env.sqlMock.ExpectBegin()
+		hold := newHold(env)
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.ConvertHold("hold1", "user456")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusForbidden, apiErr.Code)
+			assert.Equal(t, ErrorReasonLimitExceeded, apiErr.Reason)
+		}
+		assert.Equal(t, models.HoldStatusActive, hold.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_GetBalance(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+
+	env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+		return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+	}
+	env.holdRepo.SumActiveByWalletIDFunc = func(walletID string, now time.Time) (money.Amount, error) {
+		return money.MustParse("35.50"), nil
+	}
+
+	balance, apiErr := env.service.GetBalance("user123", "")
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, &models.Balance{
+		Currency:      "USD",
+		Status:        models.WalletStatusActive,
+		LedgerBalance: money.FromMajor(100),
+		Held:          money.MustParse("35.50"),
+		Available:     money.MustParse("64.50"),
+	}, balance)
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultIdempotencyKeyTTL is how long a key is remembered when no window is configured.
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// Idempotency carries a client's Idempotency-Key and a fingerprint of the
+// request it was sent with. The zero value disables idempotency handling.
+type Idempotency struct {
+	Key         string
+	Fingerprint string
+}
+
+// idempotentResponse is the result of an idempotent operation, stored with
+// its key and replayed for a repeated key. TransactionID is never shown to
+// clients, but lets internal callers find the transaction a key produced.
+// BatchID does the same for batch transfers.
+type idempotentResponse struct {
+	Balance       money.Amount `json:"balance"`
+	TransactionID string       `json:"transaction_id,omitempty"`
+	BatchID       string       `json:"batch_id,omitempty"`
+	// UserIDs are the users whose balances the request changed, for
+	// operations that only learn them inside the transaction, so that a
+	// replay can notify them without reloading anything
+	UserIDs []string `json:"user_ids,omitempty"`
+}
+
+// runIdempotent runs op inside a database transaction. When a key is given,
+// the key is claimed in that same transaction and the result stored with it,
+// so that a retry of a committed request replays the stored result instead of
+// running op again. A failed op rolls back the key as well, leaving the client
+// free to retry.
+func (s *walletService) runIdempotent(userID string, idem Idempotency, op func(tx *gorm.DB) (idempotentResponse, *APIError)) (idempotentResponse, *APIError) {
+	var response idempotentResponse
+	if idem.Key == "" {
+		apiErr := s.inTx(func(tx *gorm.DB) *APIError {
+			var apiErr *APIError
+			response, apiErr = op(tx)
+			return apiErr
+		})
+		return response, apiErr
+	}
+
+	apiErr := s.inTx(func(tx *gorm.DB) *APIError {
+		keyRepo := s.IdempotencyKeyRepo.WithTx(tx)
+
+		now := time.Now()
+		if _, err := keyRepo.DeleteExpired(userID, idem.Key, now); err != nil {
+			return NewInternalServerError("Failed to check idempotency key")
+		}
+
+		record := &models.IdempotencyKey{
+			ID:          uuid.New().String(),
+			UserID:      userID,
+			Key:         idem.Key,
+			Fingerprint: idem.Fingerprint,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			ExpiresAt:   now.Add(s.IdempotencyKeyTTL),
+		}
+		created, err := keyRepo.CreateIfNotExists(record)
+		if err != nil {
+			return NewInternalServerError("Failed to store idempotency key")
+		}
+
+		if !created {
+			existing, err := keyRepo.FindByUserIDAndKey(userID, idem.Key)
+			if err != nil {
+				return NewInternalServerError("Failed to get idempotency key")
+			}
+			if existing.Fingerprint != idem.Fingerprint {
+				return NewUnprocessableEntityError("Idempotency key was already used with a different request")
+			}
+
+			if err := json.Unmarshal([]byte(existing.ResponseBody), &response); err != nil {
+				return NewInternalServerError("Failed to read stored response")
+			}
+			return nil
+		}
+
+		var apiErr *APIError
+		response, apiErr = op(tx)
+		if apiErr != nil {
+			return apiErr
+		}
+
+		body, err := json.Marshal(response)
+		if err != nil {
+			return NewInternalServerError("Failed to store response")
+		}
+		record.ResponseCode = http.StatusOK
+		record.ResponseBody = string(body)
+		record.UpdatedAt = time.Now()
+		if err := keyRepo.Update(record); err != nil {
+			return NewInternalServerError("Failed to store response")
+		}
+		return nil
+	})
+	return response, apiErr
+}
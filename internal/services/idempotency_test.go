@@ -0,0 +1,104 @@
+package services
+
+import (
+	"testing"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_Idempotency(t *testing.T) {
+	idem := Idempotency{Key: "retry-1", Fingerprint: "fp-1"}
+
+	t.Run("first request stores the response", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{UserID: userID, Balance: money.FromMajor(10)}, nil
+		}
+
+		var stored *models.IdempotencyKey
+		env.idempotencyKeyRepo.CreateIfNotExistsFunc = func(key *models.IdempotencyKey) (bool, error) {
+			assert.Equal(t, "user123", key.UserID)
+			assert.Equal(t, idem.Key, key.Key)
+			assert.Equal(t, idem.Fingerprint, key.Fingerprint)
+			assert.True(t, key.ExpiresAt.After(key.CreatedAt))
+			return true, nil
+		}
+		env.idempotencyKeyRepo.UpdateFunc = func(key *models.IdempotencyKey) error {
+			stored = key
+			return nil
+		}
+		var transaction *models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			transaction = tx
+			return nil
+		}
+
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.Deposit("user123", money.FromMajor(5), "USD", idem)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(15), balance)
+		if assert.NotNil(t, stored) && assert.NotNil(t, transaction) {
+			assert.Equal(t, 200, stored.ResponseCode)
+			assert.JSONEq(t, `{"balance":"15.00","transaction_id":"`+transaction.ID+`"}`, stored.ResponseBody)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("replay returns the stored response without moving money", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.idempotencyKeyRepo.CreateIfNotExistsFunc = func(key *models.IdempotencyKey) (bool, error) {
+			return false, nil
+		}
+		env.idempotencyKeyRepo.FindByUserIDAndKeyFunc = func(userID, key string) (*models.IdempotencyKey, error) {
+			return &models.IdempotencyKey{UserID: userID, Key: key, Fingerprint: idem.Fingerprint, ResponseCode: 200, ResponseBody: `{"balance":"90.00"}`}, nil
+		}
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			t.Fatal("wallet must not be touched on replay")
+			return nil, nil
+		}
+
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(10), "USD", idem)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(90), balance)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("different payload with the same key is rejected", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.idempotencyKeyRepo.CreateIfNotExistsFunc = func(key *models.IdempotencyKey) (bool, error) {
+			return false, nil
+		}
+		env.idempotencyKeyRepo.FindByUserIDAndKeyFunc = func(userID, key string) (*models.IdempotencyKey, error) {
+			return &models.IdempotencyKey{UserID: userID, Key: key, Fingerprint: "other", ResponseBody: `{"balance":"90.00"}`}, nil
+		}
+
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Withdraw("user123", money.FromMajor(10), "USD", idem)
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 422, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"wallet/internal/cache"
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/pubsub"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// interestDateLayout is how accrual days are written: UTC calendar dates.
+const interestDateLayout = "2006-01-02"
+
+// accrueBatchSize is how many wallets are loaded at a time while accruing.
+const accrueBatchSize = 500
+
+// InterestConfig holds the interest rate of each currency. Wallets in
+// currencies without an entry earn no interest.
+type InterestConfig map[string]models.InterestRate
+
+// LoadInterestRates reads interest rates from a JSON file such as:
+//
+//	{"USD": {"annual_rate": "0.02", "day_count": "actual/365"}}
+//
+// The day count defaults to actual/365.
+func LoadInterestRates(path string) (InterestConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file InterestConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("interest: invalid interest rates file %s: %w", path, err)
+	}
+
+	config := make(InterestConfig, len(file))
+	for currency, rate := range file {
+		code, apiErr := normalizeCurrency(currency)
+		if apiErr != nil {
+			return nil, fmt.Errorf("interest: unknown currency %q", currency)
+		}
+		if r, err := money.ParseRate(rate.AnnualRate); err != nil || r.Sign() < 0 {
+			return nil, fmt.Errorf("interest: invalid annual rate %q for %s", rate.AnnualRate, code)
+		}
+		switch rate.DayCount {
+		case "":
+			rate.DayCount = models.DayCountActual365
+		case models.DayCountActual365, models.DayCountActual360, models.DayCountActualActual:
+		default:
+			return nil, fmt.Errorf("interest: unknown day count %q for %s", rate.DayCount, code)
+		}
+		config[code] = rate
+	}
+	return config, nil
+}
+
+type interestService struct {
+	InterestRepo    repositories.InterestRepository
+	WalletRepo      repositories.WalletRepository
+	TransactionRepo repositories.TransactionRepository
+	LedgerRepo      repositories.LedgerRepository
+	OutboxRepo      repositories.OutboxRepository
+	Cache           cache.Cache
+	PubSub          pubsub.PubSub
+	Rates           InterestConfig
+}
+
+func NewInterestService(
+	interestRepo repositories.InterestRepository,
+	walletRepo repositories.WalletRepository,
+	transactionRepo repositories.TransactionRepository,
+	ledgerRepo repositories.LedgerRepository,
+	outboxRepo repositories.OutboxRepository,
+	cache cache.Cache,
+	pubSub pubsub.PubSub,
+	rates InterestConfig,
+) InterestService {
+	return &interestService{
+		InterestRepo:    interestRepo,
+		WalletRepo:      walletRepo,
+		TransactionRepo: transactionRepo,
+		LedgerRepo:      ledgerRepo,
+		OutboxRepo:      outboxRepo,
+		Cache:           cache,
+		PubSub:          pubSub,
+		Rates:           rates,
+	}
+}
+
+// AccrueInterest accrues interest for every day since the last finished run,
+// up to and including yesterday (UTC), and pays it out as each month ends.
+// The very first run starts with yesterday. It returns how many accruals it
+// made.
+func (s *interestService) AccrueInterest() (int, *APIError) {
+	now := time.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return s.accrueThrough(today.AddDate(0, 0, -1))
+}
+
+// accrueThrough runs the accrual job for each day from the one after the last
+// finished run through the given day. A day is only recorded as finished once
+// every wallet has accrued it, so a day cut short is run again; wallets that
+// already accrued it are skipped.
+func (s *interestService) accrueThrough(through time.Time) (int, *APIError) {
+	start := through
+	last, err := s.InterestRepo.LastRun()
+	if err == nil {
+		lastDay, err := time.Parse(interestDateLayout, last.Date)
+		if err != nil {
+			return 0, NewInternalServerError("Invalid interest run date")
+		}
+		start = lastDay.AddDate(0, 0, 1)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, NewInternalServerError("Failed to get last interest run")
+	}
+
+	accrued := 0
+	for day := start; !day.After(through); day = day.AddDate(0, 0, 1) {
+		count, apiErr := s.accrueDay(day)
+		accrued += count
+		if apiErr != nil {
+			return accrued, apiErr
+		}
+
+		// The last day of a month closes its interest period
+		if day.AddDate(0, 0, 1).Day() == 1 {
+			if apiErr := s.postInterest(day); apiErr != nil {
+				return accrued, apiErr
+			}
+		}
+
+		run := &models.InterestRun{Date: day.Format(interestDateLayout), CompletedAt: time.Now()}
+		if err := s.InterestRepo.CreateRun(run); err != nil {
+			return accrued, NewInternalServerError("Failed to record interest run")
+		}
+	}
+	return accrued, nil
+}
+
+// accrueDay accrues one day's interest on every wallet in a currency with a
+// rate, on the wallet's ledger balance at the end of that day. Zero and
+// negative balances earn nothing.
+func (s *interestService) accrueDay(day time.Time) (int, *APIError) {
+	date := day.Format(interestDateLayout)
+	endOfDay := day.AddDate(0, 0, 1)
+
+	accrued := 0
+	for currency, rate := range s.Rates {
+		dailyRate, apiErr := dailyInterestRate(rate, day)
+		if apiErr != nil {
+			return accrued, apiErr
+		}
+
+		afterID := ""
+		for {
+			wallets, err := s.WalletRepo.FindByCurrency(currency, afterID, accrueBatchSize)
+			if err != nil {
+				return accrued, NewInternalServerError("Failed to get wallets")
+			}
+
+			for _, wallet := range wallets {
+				// The house does not pay itself interest on the fees it earned
+				if wallet.UserID == models.HouseFeesUserID {
+					continue
+				}
+				balance, err := s.LedgerRepo.BalanceAsOf(models.WalletLedgerAccount(wallet.ID), endOfDay)
+				if err != nil {
+					return accrued, NewInternalServerError("Failed to get ledger balance")
+				}
+				if balance <= 0 {
+					continue
+				}
+
+				created, err := s.InterestRepo.CreateAccrual(&models.InterestAccrual{
+					ID:         uuid.New().String(),
+					WalletID:   wallet.ID,
+					UserID:     wallet.UserID,
+					Currency:   currency,
+					Date:       date,
+					Balance:    balance,
+					AnnualRate: rate.AnnualRate,
+					DayCount:   rate.DayCount,
+					Amount:     new(big.Rat).Mul(balance.Rat(), dailyRate).FloatString(12),
+					CreatedAt:  time.Now(),
+				})
+				if err != nil {
+					return accrued, NewInternalServerError("Failed to create interest accrual")
+				}
+				if created {
+					accrued++
+				}
+			}
+
+			if len(wallets) < accrueBatchSize {
+				break
+			}
+			afterID = wallets[len(wallets)-1].ID
+		}
+	}
+	return accrued, nil
+}
+
+// dailyInterestRate divides an annual rate by the days in the year under its
+// day-count convention.
+func dailyInterestRate(rate models.InterestRate, day time.Time) (*big.Rat, *APIError) {
+	annualRate, err := money.ParseRate(rate.AnnualRate)
+	if err != nil {
+		return nil, NewInternalServerError("Invalid interest rate")
+	}
+
+	days := int64(365)
+	switch rate.DayCount {
+	case models.DayCountActual360:
+		days = 360
+	case models.DayCountActualActual:
+		if year := day.Year(); year%4 == 0 && (year%100 != 0 || year%400 == 0) {
+			days = 366
+		}
+	}
+	return new(big.Rat).Quo(annualRate, big.NewRat(days, 1)), nil
+}
+
+// postInterest pays every wallet the interest it has accrued up to and
+// including through, as one interest transaction per wallet.
+func (s *interestService) postInterest(through time.Time) *APIError {
+	throughDate := through.Format(interestDateLayout)
+	wallets, err := s.InterestRepo.FindUnpostedWallets(throughDate)
+	if err != nil {
+		return NewInternalServerError("Failed to get interest accruals")
+	}
+
+	for _, wallet := range wallets {
+		if apiErr := s.postWalletInterest(wallet.UserID, wallet.Currency, wallet.WalletID, throughDate); apiErr != nil {
+			return apiErr
+		}
+	}
+	return nil
+}
+
+// postWalletInterest pays out one wallet's unposted accruals. They are read
+// again under the wallet's lock and marked with the interest transaction in
+// the same database transaction, so running a period twice never pays it
+// twice. Accruals that round to less than one minor unit stay unposted and
+// carry over into the next month.
+func (s *interestService) postWalletInterest(userID, currency, walletID, throughDate string) *APIError {
+	apiErr := runInTx(s.InterestRepo.DB(), func(tx *gorm.DB) *APIError {
+		walletRepo := s.WalletRepo.WithTx(tx)
+		interestRepo := s.InterestRepo.WithTx(tx)
+
+		wallet, apiErr := lockWallet(walletRepo, userID, currency)
+		if apiErr != nil {
+			return apiErr
+		}
+
+		accruals, err := interestRepo.FindUnpostedByWalletID(walletID, throughDate)
+		if err != nil {
+			return NewInternalServerError("Failed to get interest accruals")
+		}
+		ids := make([]string, len(accruals))
+		for i, accrual := range accruals {
+			ids[i] = accrual.ID
+		}
+
+		// A wallet closed mid-month has nowhere to pay its interest into, so
+		// its accruals are settled without a transaction
+		if wallet.Status == models.WalletStatusClosed {
+			if err := interestRepo.MarkPosted(ids, "", time.Now()); err != nil {
+				return NewInternalServerError("Failed to update interest accruals")
+			}
+			return nil
+		}
+
+		amount, apiErr := accruedInterest(currency, accruals)
+		if apiErr != nil {
+			return apiErr
+		}
+		if amount <= 0 {
+			return nil
+		}
+
+		transaction := &models.Transaction{
+			ID:        uuid.New().String(),
+			ToUserID:  userID,
+			Amount:    amount,
+			Currency:  currency,
+			Type:      models.TransactionTypeInterest,
+			Status:    models.TransactionStatusSuccess,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		}
+		if err := s.TransactionRepo.WithTx(tx).Create(transaction); err != nil {
+			return NewInternalServerError("Failed to create transaction")
+		}
+
+		wallet.Balance += amount
+		wallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(wallet); err != nil {
+			return NewInternalServerError("Failed to update wallet")
+		}
+
+		if apiErr := bookLedger(s.LedgerRepo.WithTx(tx), transaction, []ledgerEntry{
+			{account: models.LedgerAccountHouseInterest, amount: -amount},
+			{account: models.WalletLedgerAccount(wallet.ID), amount: amount},
+		}, wallet); apiErr != nil {
+			return apiErr
+		}
+		if apiErr := publishEvent(s.OutboxRepo.WithTx(tx), models.EventInterestPaid, transaction); apiErr != nil {
+			return apiErr
+		}
+
+		if err := interestRepo.MarkPosted(ids, transaction.ID, time.Now()); err != nil {
+			return NewInternalServerError("Failed to update interest accruals")
+		}
+		return nil
+	})
+	if apiErr != nil {
+		return apiErr
+	}
+
+	s.Cache.Delete(userID)
+	notifyChanged(s.PubSub, userID)
+	return nil
+}
+
+// accruedInterest adds up accruals exactly and rounds the total half to even
+// to the currency's minor unit.
+func accruedInterest(currency string, accruals []models.InterestAccrual) (money.Amount, *APIError) {
+	total := new(big.Rat)
+	for _, accrual := range accruals {
+		amount, ok := new(big.Rat).SetString(accrual.Amount)
+		if !ok {
+			return 0, NewInternalServerError("Invalid interest accrual")
+		}
+		total.Add(total, amount)
+	}
+
+	code, err := money.LookupCurrency(currency)
+	if err != nil {
+		return 0, NewInternalServerError("Invalid interest currency")
+	}
+	return code.FromRat(total, money.RoundHalfEven), nil
+}
+
+// GetInterest shows the rate each of a user's wallets earns and the interest
+// accrued so far that has yet to be paid.
+func (s *interestService) GetInterest(userID string) ([]models.InterestSummary, *APIError) {
+	wallets, err := s.WalletRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get wallets")
+	}
+
+	summaries := make([]models.InterestSummary, 0, len(wallets))
+	for _, wallet := range wallets {
+		rate, ok := s.Rates[wallet.Currency]
+		if !ok {
+			continue
+		}
+
+		accruals, err := s.InterestRepo.FindUnpostedByWalletID(wallet.ID, "9999-12-31")
+		if err != nil {
+			return nil, NewInternalServerError("Failed to get interest accruals")
+		}
+		accrued, apiErr := accruedInterest(wallet.Currency, accruals)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		summaries = append(summaries, models.InterestSummary{
+			Currency:   wallet.Currency,
+			AnnualRate: rate.AnnualRate,
+			DayCount:   rate.DayCount,
+			Accrued:    accrued,
+		})
+	}
+	return summaries, nil
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadInterestRates(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "interest.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"usd": {"annual_rate": "0.02"}, "EUR": {"annual_rate": "0.015", "day_count": "actual/360"}}`), 0o600))
+
+	rates, err := LoadInterestRates(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, InterestConfig{
+		"USD": {AnnualRate: "0.02", DayCount: models.DayCountActual365},
+		"EUR": {AnnualRate: "0.015", DayCount: models.DayCountActual360},
+	}, rates)
+
+	for name, body := range map[string]string{
+		"negative rate":     `{"USD": {"annual_rate": "-0.01"}}`,
+		"unknown day count": `{"USD": {"annual_rate": "0.02", "day_count": "30/360"}}`,
+		"unknown currency":  `{"XYZ": {"annual_rate": "0.02"}}`,
+	} {
+		invalid := filepath.Join(dir, "invalid.json")
+		assert.NoError(t, os.WriteFile(invalid, []byte(body), 0o600))
+
+		_, err := LoadInterestRates(invalid)
+
+		assert.Error(t, err, name)
+	}
+}
+
+func TestDailyInterestRate(t *testing.T) {
+	tests := []struct {
+		dayCount string
+		day      time.Time
+		want     string
+	}{
+		{models.DayCountActual365, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "0.000100000000"},
+		{models.DayCountActual360, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "0.000101388889"},
+		{models.DayCountActualActual, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), "0.000099726776"},
+		{models.DayCountActualActual, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), "0.000100000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dayCount, func(t *testing.T) {
+			rate, apiErr := dailyInterestRate(models.InterestRate{AnnualRate: "0.0365", DayCount: tt.dayCount}, tt.day)
+
+			assert.Nil(t, apiErr)
+			assert.Equal(t, tt.want, rate.FloatString(12))
+		})
+	}
+}
+
+func newTestInterestService(env *testEnv, interestRepo *repositories.MockInterestRepository) *interestService {
+	interestRepo.DBFunc = env.walletRepo.DB
+	return NewInterestService(interestRepo, env.walletRepo, env.transactionRepo, env.ledgerRepo, env.outboxRepo, env.cache, env.pubSub, InterestConfig{
+		"USD": {AnnualRate: "0.0365", DayCount: models.DayCountActual365},
+	}).(*interestService)
+}
+
+func TestInterestService_Accrue(t *testing.T) {
+	day := time.Date(2025, 7, 14, 0, 0, 0, 0, time.UTC)
+
+	t.Run("accrues on positive end-of-day balances", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByCurrencyFunc = func(currency, afterID string, limit int) ([]models.Wallet, error) {
+			assert.Equal(t, "USD", currency)
+			return []models.Wallet{
+				{ID: "wallet1", UserID: "user123", Currency: "USD"},
+				{ID: "wallet2", UserID: "user456", Currency: "USD"},
+				{ID: "wallet3", UserID: "user789", Currency: "USD"},
+				{ID: "fees", UserID: models.HouseFeesUserID, Currency: "USD"},
+			}, nil
+		}
+		balances := map[string]money.Amount{
+			models.WalletLedgerAccount("wallet1"): money.MustParse("1234.56"),
+			models.WalletLedgerAccount("wallet2"): 0,
+			models.WalletLedgerAccount("wallet3"): money.FromMajor(-5),
+			models.WalletLedgerAccount("fees"):    money.FromMajor(500),
+		}
+		env.ledgerRepo.BalanceAsOfFunc = func(accountCode string, at time.Time) (money.Amount, error) {
+			assert.Equal(t, day.AddDate(0, 0, 1), at)
+			return balances[accountCode], nil
+		}
+		var accruals []*models.InterestAccrual
+		var runs []string
+		service := newTestInterestService(env, &repositories.MockInterestRepository{
+			LastRunFunc: func() (*models.InterestRun, error) {
+				return &models.InterestRun{Date: "2025-07-13"}, nil
+			},
+			CreateAccrualFunc: func(accrual *models.InterestAccrual) (bool, error) {
+				accruals = append(accruals, accrual)
+				return true, nil
+			},
+			CreateRunFunc: func(run *models.InterestRun) error {
+				runs = append(runs, run.Date)
+				return nil
+			},
+		})
+
+		count, apiErr := service.accrueThrough(day)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 1, count)
+		if assert.Len(t, accruals, 1) {
+			assert.Equal(t, "wallet1", accruals[0].WalletID)
+			assert.Equal(t, "user123", accruals[0].UserID)
+			assert.Equal(t, "2025-07-14", accruals[0].Date)
+			assert.Equal(t, money.MustParse("1234.56"), accruals[0].Balance)
+			assert.Equal(t, "0.123456000000", accruals[0].Amount)
+		}
+		assert.Equal(t, []string{"2025-07-14"}, runs)
+	})
+
+	t.Run("catches up on missed days and skips days already accrued", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByCurrencyFunc = func(currency, afterID string, limit int) ([]models.Wallet, error) {
+			return []models.Wallet{{ID: "wallet1", UserID: "user123", Currency: "USD"}}, nil
+		}
+		env.ledgerRepo.BalanceAsOfFunc = func(accountCode string, at time.Time) (money.Amount, error) {
+			return money.FromMajor(100), nil
+		}
+		var runs []string
+		service := newTestInterestService(env, &repositories.MockInterestRepository{
+			LastRunFunc: func() (*models.InterestRun, error) {
+				return &models.InterestRun{Date: "2025-07-11"}, nil
+			},
+			CreateAccrualFunc: func(accrual *models.InterestAccrual) (bool, error) {
+				// The day the previous run was cut short on is already accrued
+				return accrual.Date != "2025-07-12", nil
+			},
+			CreateRunFunc: func(run *models.InterestRun) error {
+				runs = append(runs, run.Date)
+				return nil
+			},
+		})
+
+		count, apiErr := service.accrueThrough(day)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 2, count)
+		assert.Equal(t, []string{"2025-07-12", "2025-07-13", "2025-07-14"}, runs)
+	})
+}
+
+func TestInterestService_Post(t *testing.T) {
+	monthEnd := time.Date(2025, 7, 31, 0, 0, 0, 0, time.UTC)
+
+	newPostEnv := func(t *testing.T, amounts ...string) (*testEnv, *interestService, *[]string) {
+		env := newTestEnv(t)
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency, Balance: money.FromMajor(1000)}, nil
+		}
+		env.cache.DeleteFunc = func(key string) {}
+
+		accruals := make([]models.InterestAccrual, len(amounts))
+		for i, amount := range amounts {
+			accruals[i] = models.InterestAccrual{ID: "accrual" + string(rune('a'+i)), WalletID: "wallet1", Amount: amount}
+		}
+		var posted []string
+		service := newTestInterestService(env, &repositories.MockInterestRepository{
+			LastRunFunc: func() (*models.InterestRun, error) {
+				return &models.InterestRun{Date: "2025-07-30"}, nil
+			},
+			FindUnpostedWalletsFunc: func(throughDate string) ([]models.InterestAccrual, error) {
+				assert.Equal(t, "2025-07-31", throughDate)
+				return []models.InterestAccrual{{WalletID: "wallet1", UserID: "user123", Currency: "USD"}}, nil
+			},
+			FindUnpostedByWalletIDFunc: func(walletID, throughDate string) ([]models.InterestAccrual, error) {
+				return accruals, nil
+			},
+			MarkPostedFunc: func(ids []string, transactionID string, postedAt time.Time) error {
+				posted = append(posted, ids...)
+				return nil
+			},
+		})
+		return env, service, &posted
+	}
+
+	t.Run("pays the month's accruals at month end", func(t *testing.T) {
+		env, service, posted := newPostEnv(t, "0.054794520548", "0.054794520548", "0.054794520548")
+		defer env.db.Close()
+
+		var transaction *models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			transaction = tx
+			return nil
+		}
+		var postings []models.LedgerPosting
+		env.ledgerRepo.CreatePostingsFunc = func(p []models.LedgerPosting) error {
+			postings = p
+			return nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := service.accrueThrough(monthEnd)
+
+		assert.Nil(t, apiErr)
+		if assert.NotNil(t, transaction) {
+			assert.Equal(t, models.TransactionTypeInterest, transaction.Type)
+			assert.Equal(t, "user123", transaction.ToUserID)
+			assert.Equal(t, money.MustParse("0.16"), transaction.Amount)
+		}
+		if assert.Len(t, postings, 2) {
+			assert.Equal(t, models.LedgerAccountHouseInterest, postings[0].AccountCode)
+			assert.Equal(t, money.MustParse("-0.16"), postings[0].Amount)
+		}
+		assert.Equal(t, money.MustParse("1000.16"), env.walletRepo.Updated[0].Balance)
+		assert.Equal(t, []string{"accruala", "accrualb", "accrualc"}, *posted)
+		if assert.Len(t, env.outboxRepo.Events, 1) {
+			assert.Equal(t, models.EventInterestPaid, env.outboxRepo.Events[0].Type)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("less than a cent carries over to the next month", func(t *testing.T) {
+		env, service, posted := newPostEnv(t, "0.002", "0.002")
+		defer env.db.Close()
+
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			t.Fatal("transaction must not be created")
+			return nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := service.accrueThrough(monthEnd)
+
+		assert.Nil(t, apiErr)
+		assert.Empty(t, *posted)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestInterestService_GetInterest(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+
+	env.walletRepo.ListByUserIDFunc = func(userID string) ([]models.Wallet, error) {
+		return []models.Wallet{{ID: "wallet1", Currency: "USD"}, {ID: "wallet2", Currency: "GBP"}}, nil
+	}
+	service := newTestInterestService(env, &repositories.MockInterestRepository{
+		FindUnpostedByWalletIDFunc: func(walletID, throughDate string) ([]models.InterestAccrual, error) {
+			return []models.InterestAccrual{{Amount: "0.104"}, {Amount: "0.104"}}, nil
+		},
+	})
+
+	interest, apiErr := service.GetInterest("user123")
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, []models.InterestSummary{
+		{Currency: "USD", AnnualRate: "0.0365", DayCount: models.DayCountActual365, Accrued: money.MustParse("0.21")},
+	}, interest)
+}
@@ -1,13 +1,120 @@
 package services
 
 import (
+	"crypto/ed25519"
+	"io"
+	"time"
+
 	"wallet/internal/models"
+	"wallet/internal/money"
 )
 
 type WalletService interface {
-	Deposit(userID string, amount float64) (float64, *APIError)
-	Withdraw(userID string, amount float64) (float64, *APIError)
-	Transfer(fromUserID, toUserID string, amount float64) (float64, *APIError)
-	GetBalance(userID string) (float64, *APIError)
+	OpenWallet(userID, currency string) (*models.Wallet, *APIError)
+	Deposit(userID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError)
+	Withdraw(userID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError)
+	Transfer(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError)
+	TransferWithQuote(fromUserID, toUserID, quoteID string, idem Idempotency) (money.Amount, *APIError)
+	BatchTransfer(fromUserID, currency string, items []BatchTransferItem, idem Idempotency) (money.Amount, *models.TransferBatch, *APIError)
+	GetBatch(userID, batchID string) (*models.TransferBatch, *APIError)
+	ReverseTransaction(userID, transactionID string, idem Idempotency) (money.Amount, *APIError)
+	RefundTransaction(userID, transactionID string, amount money.Amount, idem Idempotency) (money.Amount, *APIError)
+	AuthorizeTransfer(fromUserID, toUserID string, amount money.Amount, currency string) (*models.Hold, *APIError)
+	CaptureTransfer(userID, transactionID string, amount money.Amount, idem Idempotency) (money.Amount, *APIError)
+	VoidTransfer(userID, transactionID string) *APIError
+	PlaceHold(userID, currency string, amount money.Amount, reason string, ttl time.Duration) (*models.Hold, *APIError)
+	ReleaseHold(holdID string) *APIError
+	ConvertHold(holdID, toUserID string) (*models.Transaction, *APIError)
+	ExpireHolds() (int, *APIError)
+	GetBalance(userID, currency string) (*models.Balance, *APIError)
+	GetBalances(userID string) ([]models.Balance, *APIError)
+	GetBalanceAsOf(userID, currency string, at time.Time) (*models.HistoricalBalance, *APIError)
+	GetDailyBalances(userID, currency, from, to string) ([]models.DailyBalance, *APIError)
+	CheckpointBalances() (int, *APIError)
+	GetLimits(userID, currency string) ([]models.LimitStatus, *APIError)
+	ListLimitOverrides(userID string) ([]models.LimitOverride, *APIError)
+	SetLimitOverride(userID, operation, currency string, limits models.Limits) (*models.LimitOverride, *APIError)
+	DeleteLimitOverride(userID, operation, currency string) *APIError
+	PreviewFee(userID, operation string, amount money.Amount, currency string) (*models.FeePreview, *APIError)
 	GetTransactionHistory(userID string, page, pageSize int, transactionType, status string) ([]models.Transaction, *APIError)
+	ExportTransactions(userID string, options ExportOptions, open func() io.Writer) *APIError
+	SetWalletStatus(actorID, walletID, status, reason string) (*models.Wallet, *APIError)
+	CloseWallet(actorID, walletID, sweepToUserID, reason string) (*models.Wallet, *APIError)
+	ListWallets(userID string) ([]models.Wallet, *APIError)
+	ListWalletStatusEvents(walletID string) ([]models.WalletStatusEvent, *APIError)
+}
+
+type StreamService interface {
+	Open(userID, lastEventID string) (*Stream, *APIError)
+	Run() error
+}
+
+type TransactionLogService interface {
+	AppendPending() (int, *APIError)
+	Checkpoint() (*models.TransactionLogCheckpoint, *APIError)
+	Verify(publicKey ed25519.PublicKey) (*models.TransactionLogVerification, *APIError)
+}
+
+type WebhookService interface {
+	CreateEndpoint(url string, eventTypes []string) (*models.WebhookEndpoint, *APIError)
+	ListEndpoints() ([]models.WebhookEndpoint, *APIError)
+	DisableEndpoint(endpointID string) *APIError
+	ListDeliveries(status, endpointID string) ([]models.WebhookDelivery, *APIError)
+	Redeliver(deliveryID string) (*models.WebhookDelivery, *APIError)
+	DispatchEvents() (int, *APIError)
+	DeliverDue() (int, *APIError)
+}
+
+type ReconciliationService interface {
+	Reconcile(autoFreeze bool) (*models.ReconciliationRun, *APIError)
+	ListRuns() ([]models.ReconciliationRun, *APIError)
+	GetRun(runID string) (*models.ReconciliationRun, *APIError)
+}
+
+type FXService interface {
+	CreateQuote(userID, fromCurrency, toCurrency string, amount money.Amount) (*models.FXQuote, *APIError)
+}
+
+type ScheduleService interface {
+	CreateSchedule(userID string, spec NewSchedule) (*models.Schedule, *APIError)
+	ListSchedules(userID string) ([]models.Schedule, *APIError)
+	CancelSchedule(userID, scheduleID string) *APIError
+	ListExecutions(userID, scheduleID string) ([]models.ScheduleExecution, *APIError)
+	RunDue() (int, *APIError)
+}
+
+type UserService interface {
+	ResolveRecipient(recipient Recipient) (*models.User, *APIError)
+	LookupRecipient(recipient Recipient) (string, *APIError)
+	UpdateProfile(userID string, handle, phone *string) (*models.User, *APIError)
+	VerifyPhone(userID, code string) (*models.User, *APIError)
+}
+
+type PaymentRequestService interface {
+	CreateRequest(requesterID, payerID string, amount money.Amount, currency, note string) (*models.PaymentRequest, *APIError)
+	ListIncoming(payerID, status string) ([]models.PaymentRequest, *APIError)
+	ListOutgoing(requesterID, status string) ([]models.PaymentRequest, *APIError)
+	AcceptRequest(payerID, requestID string) (*models.PaymentRequest, *APIError)
+	DeclineRequest(payerID, requestID string) *APIError
+	CancelRequest(requesterID, requestID string) *APIError
+	ExpireRequests() (int, *APIError)
+}
+
+type PayoutService interface {
+	CreateJob(userID, currency string, file io.Reader) (*models.PayoutJob, []PayoutRowError, *APIError)
+	GetJob(userID, jobID string) (*models.PayoutJob, *APIError)
+	ListJobs(userID string) ([]models.PayoutJob, *APIError)
+	ListItems(userID, jobID string) ([]models.PayoutItem, *APIError)
+	RunPending() (int, *APIError)
+}
+
+type InterestService interface {
+	AccrueInterest() (int, *APIError)
+	GetInterest(userID string) ([]models.InterestSummary, *APIError)
+}
+
+type StatementService interface {
+	GetStatement(userID, currency, period string) (*models.Statement, *APIError)
+	ListStatements(userID string) ([]models.Statement, *APIError)
+	GenerateMonthlyStatements() (int, *APIError)
 }
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ledgerEntry is one side of a ledger entry before it is booked. Credits are
+// positive and debits negative. An empty currency means the currency of the
+// transaction being booked.
+type ledgerEntry struct {
+	account  string
+	amount   money.Amount
+	currency string
+}
+
+// postLedger books balanced postings for a transaction within tx; see
+// bookLedger.
+func (s *walletService) postLedger(tx *gorm.DB, transaction *models.Transaction, entries []ledgerEntry, wallets ...*models.Wallet) *APIError {
+	return bookLedger(s.LedgerRepo.WithTx(tx), transaction, entries, wallets...)
+}
+
+// bookLedger books balanced postings for a transaction and then checks every
+// wallet it touched against the sum of that wallet's ledger account. Wallets
+// must already carry their new balance. A mismatch fails the whole database
+// transaction, so a wallet that has drifted from the ledger (e.g. through
+// manual SQL) cannot move money until it has been investigated.
+func bookLedger(ledgerRepo repositories.LedgerRepository, transaction *models.Transaction, entries []ledgerEntry, wallets ...*models.Wallet) *APIError {
+	totals := make(map[string]money.Amount)
+	for i := range entries {
+		if entries[i].currency == "" {
+			entries[i].currency = transaction.Currency
+		}
+		totals[entries[i].currency] += entries[i].amount
+	}
+	for _, total := range totals {
+		if total != 0 {
+			return NewInternalServerError("Unbalanced ledger entry")
+		}
+	}
+
+	walletAccounts := make(map[string]string, len(wallets))
+	for _, wallet := range wallets {
+		walletAccounts[models.WalletLedgerAccount(wallet.ID)] = wallet.ID
+	}
+
+	now := time.Now()
+	accounts := make([]models.LedgerAccount, 0, len(entries))
+	postings := make([]models.LedgerPosting, 0, len(entries))
+	for _, entry := range entries {
+		account := models.LedgerAccount{
+			Code:      entry.account,
+			Type:      models.LedgerAccountTypeSystem,
+			CreatedAt: now,
+		}
+		if walletID, ok := walletAccounts[entry.account]; ok {
+			account.Type = models.LedgerAccountTypeWallet
+			account.WalletID = walletID
+		}
+		accounts = append(accounts, account)
+
+		postings = append(postings, models.LedgerPosting{
+			ID:            uuid.New().String(),
+			TransactionID: transaction.ID,
+			AccountCode:   entry.account,
+			Amount:        entry.amount,
+			Currency:      entry.currency,
+			CreatedAt:     now,
+		})
+	}
+
+	if err := ledgerRepo.EnsureAccounts(accounts); err != nil {
+		return NewInternalServerError("Failed to create ledger accounts")
+	}
+	if err := ledgerRepo.CreatePostings(postings); err != nil {
+		return NewInternalServerError("Failed to create ledger postings")
+	}
+
+	for _, wallet := range wallets {
+		ledgerBalance, err := ledgerRepo.BalanceOf(models.WalletLedgerAccount(wallet.ID))
+		if err != nil {
+			return NewInternalServerError("Failed to get ledger balance")
+		}
+		if ledgerBalance != wallet.Balance {
+			return NewInternalServerError("Wallet balance does not match ledger")
+		}
+	}
+	return nil
+}
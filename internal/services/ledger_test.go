@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_Ledger(t *testing.T) {
+	t.Run("deposit books world/deposits against the wallet", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Balance: money.FromMajor(10)}, nil
+		}
+
+		var postings []models.LedgerPosting
+		env.ledgerRepo.CreatePostingsFunc = func(p []models.LedgerPosting) error {
+			postings = p
+			return nil
+		}
+
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.Deposit("user123", money.FromMajor(5), "USD", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		if assert.Len(t, postings, 2) {
+			assert.Equal(t, models.LedgerAccountWorldDeposits, postings[0].AccountCode)
+			assert.Equal(t, money.FromMajor(-5), postings[0].Amount)
+			assert.Equal(t, models.WalletLedgerAccount("wallet1"), postings[1].AccountCode)
+			assert.Equal(t, money.FromMajor(5), postings[1].Amount)
+			assert.Equal(t, postings[0].TransactionID, postings[1].TransactionID)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("transfer postings move money between wallet accounts", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Balance: money.FromMajor(100)}, nil
+		}
+
+		var postings []models.LedgerPosting
+		env.ledgerRepo.CreatePostingsFunc = func(p []models.LedgerPosting) error {
+			postings = p
+			return nil
+		}
+
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(30), "USD", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		var total money.Amount
+		for _, posting := range postings {
+			total += posting.Amount
+		}
+		assert.Len(t, postings, 2)
+		assert.Equal(t, money.Zero, total)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("drift between wallet and ledger aborts the transaction", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Balance: money.FromMajor(100)}, nil
+		}
+		env.ledgerRepo.BalanceOfFunc = func(accountCode string) (money.Amount, error) {
+			return money.FromMajor(40), nil
+		}
+
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Withdraw("user123", money.FromMajor(10), "USD", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Wallet balance does not match ledger", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LimitConfig holds the default limits by operation and then by currency.
+// Currencies without an entry have no limits.
+type LimitConfig map[string]map[string]models.Limits
+
+// limitWindows are the rolling windows limits are counted over.
+var limitWindows = []struct {
+	name     string
+	label    string
+	period   string
+	duration time.Duration
+	amount   func(models.Limits) money.Amount
+	count    func(models.Limits) int
+}{
+	{models.LimitWindowDaily, "Daily", "day", 24 * time.Hour, func(l models.Limits) money.Amount { return l.DailyAmount }, func(l models.Limits) int { return l.DailyCount }},
+	{models.LimitWindowWeekly, "Weekly", "week", 7 * 24 * time.Hour, func(l models.Limits) money.Amount { return l.WeeklyAmount }, func(l models.Limits) int { return l.WeeklyCount }},
+	{models.LimitWindowMonthly, "Monthly", "month", 30 * 24 * time.Hour, func(l models.Limits) money.Amount { return l.MonthlyAmount }, func(l models.Limits) int { return l.MonthlyCount }},
+}
+
+// LoadLimits reads default limits from a JSON file such as:
+//
+//	{"transfer": {"USD": {"max_amount": "5000.00", "daily_amount": "10000.00", "daily_count": 50}}}
+func LoadLimits(path string) (LimitConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file LimitConfig
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("limits: invalid limits file %s: %w", path, err)
+	}
+
+	config := make(LimitConfig, len(file))
+	for operation, byCurrency := range file {
+		if operation != models.LimitOperationWithdraw && operation != models.LimitOperationTransfer {
+			return nil, fmt.Errorf("limits: unknown operation %q", operation)
+		}
+		config[operation] = make(map[string]models.Limits, len(byCurrency))
+		for currency, limits := range byCurrency {
+			code, apiErr := normalizeCurrency(currency)
+			if apiErr != nil {
+				return nil, fmt.Errorf("limits: unknown currency %q", currency)
+			}
+			config[operation][code] = limits
+		}
+	}
+	return config, nil
+}
+
+// NewLimitExceededError is returned when a withdrawal or transfer would break
+// one of the user's limits. It carries the limit, so that clients need not
+// parse the message.
+func NewLimitExceededError(msg string, exceeded *models.LimitExceeded) *APIError {
+	return &APIError{
+		Code:    http.StatusForbidden,
+		Message: msg,
+		Reason:  ErrorReasonLimitExceeded,
+		Details: exceeded,
+	}
+}
+
+// limitExceeded returns the limit an error was caused by, or nil if it was
+// not caused by a limit.
+func limitExceeded(err *APIError) *models.LimitExceeded {
+	if err == nil || err.Reason != ErrorReasonLimitExceeded {
+		return nil
+	}
+	exceeded, _ := err.Details.(*models.LimitExceeded)
+	return exceeded
+}
+
+// limitsFor returns a user's limits for an operation and currency: their
+// override if they have one, otherwise the defaults.
+func (s *walletService) limitsFor(limitRepo repositories.LimitRepository, userID, operation, currency string) (models.Limits, *APIError) {
+	override, err := limitRepo.FindOverride(userID, operation, currency)
+	if err == nil {
+		return override.Limits, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Limits{}, NewInternalServerError("Failed to get limits")
+	}
+	return s.Limits[operation][currency], nil
+}
+
+// checkLimits rejects a withdrawal or transfer that would break the user's
+// limits. amounts holds one entry per transaction about to be made, so that a
+// batch counts once per recipient. It must run while the sender's wallet is
+// locked, so that concurrent requests cannot both squeeze under a cap.
+func (s *walletService) checkLimits(tx *gorm.DB, userID, operation, currency string, amounts ...money.Amount) *APIError {
+	limits, apiErr := s.limitsFor(s.LimitRepo.WithTx(tx), userID, operation, currency)
+	if apiErr != nil {
+		return apiErr
+	}
+	if limits == (models.Limits{}) {
+		return nil
+	}
+
+	var total money.Amount
+	for _, amount := range amounts {
+		if limits.MinAmount != 0 && amount < limits.MinAmount {
+			return NewLimitExceededError(fmt.Sprintf("Minimum %s is %s %s", operation, limits.MinAmount, currency),
+				&models.LimitExceeded{Operation: operation, Currency: currency, Limit: "min_amount", AmountLimit: &limits.MinAmount})
+		}
+		if limits.MaxAmount != 0 && amount > limits.MaxAmount {
+			return NewLimitExceededError(fmt.Sprintf("Maximum %s is %s %s", operation, limits.MaxAmount, currency),
+				&models.LimitExceeded{Operation: operation, Currency: currency, Limit: "max_amount", AmountLimit: &limits.MaxAmount})
+		}
+		total += amount
+	}
+
+	transactionRepo := s.TransactionRepo.WithTx(tx)
+	now := time.Now()
+	for _, window := range limitWindows {
+		amountLimit, countLimit := window.amount(limits), window.count(limits)
+		if amountLimit == 0 && countLimit == 0 {
+			continue
+		}
+
+		used, count, err := transactionRepo.SumOutgoingSince(userID, operation, currency, now.Add(-window.duration))
+		if err != nil {
+			return NewInternalServerError("Failed to get limit usage")
+		}
+		if amountLimit != 0 && used+total > amountLimit {
+			left := remaining(amountLimit, used)
+			return NewLimitExceededError(fmt.Sprintf("%s %s limit exceeded: %s %s remaining", window.label, operation, left, currency),
+				&models.LimitExceeded{Operation: operation, Currency: currency, Limit: window.name + "_amount", Window: window.name, AmountLimit: &amountLimit, AmountRemaining: &left})
+		}
+		if countLimit != 0 && count+len(amounts) > countLimit {
+			left := countLimit - count
+			if left < 0 {
+				left = 0
+			}
+			return NewLimitExceededError(fmt.Sprintf("%s %s limit exceeded: at most %d per %s", window.label, operation, countLimit, window.period),
+				&models.LimitExceeded{Operation: operation, Currency: currency, Limit: window.name + "_count", Window: window.name, CountLimit: &countLimit, CountRemaining: &left})
+		}
+	}
+	return nil
+}
+
+// GetLimits shows a user's withdrawal and transfer limits in a currency and
+// how much of each rolling window they have left.
+func (s *walletService) GetLimits(userID, currency string) ([]models.LimitStatus, *APIError) {
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	now := time.Now()
+	statuses := make([]models.LimitStatus, 0, 2)
+	for _, operation := range []string{models.LimitOperationWithdraw, models.LimitOperationTransfer} {
+		limits, apiErr := s.limitsFor(s.LimitRepo, userID, operation, currency)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		status := models.LimitStatus{
+			Operation: operation,
+			Currency:  currency,
+			MinAmount: limits.MinAmount,
+			MaxAmount: limits.MaxAmount,
+			Windows:   make([]models.LimitWindow, 0, len(limitWindows)),
+		}
+		for _, window := range limitWindows {
+			used, count, err := s.TransactionRepo.SumOutgoingSince(userID, operation, currency, now.Add(-window.duration))
+			if err != nil {
+				return nil, NewInternalServerError("Failed to get limit usage")
+			}
+
+			usage := models.LimitWindow{
+				Window:     window.name,
+				AmountUsed: used,
+				CountUsed:  count,
+			}
+			if amountLimit := window.amount(limits); amountLimit != 0 {
+				left := remaining(amountLimit, used)
+				usage.AmountLimit = &amountLimit
+				usage.AmountRemaining = &left
+			}
+			if countLimit := window.count(limits); countLimit != 0 {
+				left := countLimit - count
+				if left < 0 {
+					left = 0
+				}
+				usage.CountLimit = &countLimit
+				usage.CountRemaining = &left
+			}
+			status.Windows = append(status.Windows, usage)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// ListLimitOverrides returns the limits a user has in place of the defaults.
+func (s *walletService) ListLimitOverrides(userID string) ([]models.LimitOverride, *APIError) {
+	if apiErr := s.findUser(userID); apiErr != nil {
+		return nil, apiErr
+	}
+	overrides, err := s.LimitRepo.FindOverridesByUserID(userID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get limit overrides")
+	}
+	return overrides, nil
+}
+
+// SetLimitOverride gives a user their own limits for an operation and
+// currency, replacing the defaults as a whole. Any cap left at zero is
+// lifted, so empty limits exempt the user, e.g. an operations account that
+// uploads payout files.
+func (s *walletService) SetLimitOverride(userID, operation, currency string, limits models.Limits) (*models.LimitOverride, *APIError) {
+	currency, apiErr := validateLimitOverride(operation, currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	amounts := []money.Amount{limits.MinAmount, limits.MaxAmount, limits.DailyAmount, limits.WeeklyAmount, limits.MonthlyAmount}
+	counts := []int{limits.DailyCount, limits.WeeklyCount, limits.MonthlyCount}
+	if slices.ContainsFunc(amounts, func(a money.Amount) bool { return a < 0 }) || slices.ContainsFunc(counts, func(c int) bool { return c < 0 }) {
+		return nil, NewBadRequestError("Limits cannot be negative")
+	}
+	if limits.MaxAmount != 0 && limits.MinAmount > limits.MaxAmount {
+		return nil, NewBadRequestError("min_amount cannot be more than max_amount")
+	}
+	if apiErr := s.findUser(userID); apiErr != nil {
+		return nil, apiErr
+	}
+
+	now := time.Now()
+	override := &models.LimitOverride{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Operation: operation,
+		Currency:  currency,
+		Limits:    limits,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.LimitRepo.SaveOverride(override); err != nil {
+		return nil, NewInternalServerError("Failed to save limit override")
+	}
+	// An existing override keeps its ID and creation time
+	saved, err := s.LimitRepo.FindOverride(userID, operation, currency)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get limit override")
+	}
+	return saved, nil
+}
+
+// DeleteLimitOverride puts a user back on the default limits for an
+// operation and currency.
+func (s *walletService) DeleteLimitOverride(userID, operation, currency string) *APIError {
+	currency, apiErr := validateLimitOverride(operation, currency)
+	if apiErr != nil {
+		return apiErr
+	}
+	deleted, err := s.LimitRepo.DeleteOverride(userID, operation, currency)
+	if err != nil {
+		return NewInternalServerError("Failed to delete limit override")
+	}
+	if !deleted {
+		return NewNotFoundError("Limit override not found")
+	}
+	return nil
+}
+
+// validateLimitOverride checks the operation and currency of an override and
+// returns the currency code.
+func validateLimitOverride(operation, currency string) (string, *APIError) {
+	if operation != models.LimitOperationWithdraw && operation != models.LimitOperationTransfer {
+		return "", NewBadRequestError("Operation must be withdraw or transfer")
+	}
+	return normalizeCurrency(currency)
+}
+
+// findUser checks that a user exists.
+func (s *walletService) findUser(userID string) *APIError {
+	if _, err := s.UserRepo.FindByID(userID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return NewNotFoundError("User not found")
+		}
+		return NewInternalServerError("Failed to get user")
+	}
+	return nil
+}
+
+// remaining returns what is left of limit after used, never less than zero.
+func remaining(limit, used money.Amount) money.Amount {
+	if used >= limit {
+		return 0
+	}
+	return limit - used
+}
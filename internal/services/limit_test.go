@@ -0,0 +1,224 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadLimits(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "limits.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"withdraw": {"usd": {"max_amount": "500.00", "daily_count": 3}}}`), 0o600))
+
+	limits, err := LoadLimits(path)
+
+	assert.NoError(t, err)
+	assert.Equal(t, models.Limits{MaxAmount: money.FromMajor(500), DailyCount: 3}, limits[models.LimitOperationWithdraw]["USD"])
+
+	invalid := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, os.WriteFile(invalid, []byte(`{"deposit": {"USD": {"max_amount": "500.00"}}}`), 0o600))
+
+	_, err = LoadLimits(invalid)
+
+	assert.Error(t, err)
+}
+
+func TestWalletService_Limits(t *testing.T) {
+	newLimitEnv := func(t *testing.T, limits models.Limits) *testEnv {
+		env := newTestEnv(t)
+		env.service.(*walletService).Limits = LimitConfig{
+			models.LimitOperationTransfer: {"USD": limits},
+		}
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "w-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(1000)}, nil
+		}
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			t.Fatal("transaction must not be created")
+			return nil
+		}
+		return env
+	}
+
+	t.Run("per-transaction maximum", func(t *testing.T) {
+		env := newLimitEnv(t, models.Limits{MaxAmount: money.FromMajor(100)})
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(150), "USD", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 403, apiErr.Code)
+			assert.Equal(t, "Maximum transfer is 100.00 USD", apiErr.Message)
+			assert.Equal(t, ErrorReasonLimitExceeded, apiErr.Reason)
+			maxAmount := money.FromMajor(100)
+			assert.Equal(t, &models.LimitExceeded{Operation: "transfer", Currency: "USD", Limit: "max_amount", AmountLimit: &maxAmount}, apiErr.Details)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("rolling daily amount", func(t *testing.T) {
+		env := newLimitEnv(t, models.Limits{DailyAmount: money.FromMajor(500)})
+		defer env.db.Close()
+
+		env.transactionRepo.SumOutgoingSinceFunc = func(userID, transactionType, currency string, since time.Time) (money.Amount, int, error) {
+			assert.Equal(t, models.TransactionTypeTransfer, transactionType)
+			assert.WithinDuration(t, time.Now().Add(-24*time.Hour), since, time.Second)
+			return money.FromMajor(450), 3, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(60), "USD", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 403, apiErr.Code)
+			assert.Equal(t, "Daily transfer limit exceeded: 50.00 USD remaining", apiErr.Message)
+			dailyAmount, left := money.FromMajor(500), money.FromMajor(50)
+			assert.Equal(t, &models.LimitExceeded{Operation: "transfer", Currency: "USD", Limit: "daily_amount", Window: "daily", AmountLimit: &dailyAmount, AmountRemaining: &left}, apiErr.Details)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("a batch counts once per recipient", func(t *testing.T) {
+		env := newLimitEnv(t, models.Limits{WeeklyCount: 5})
+		defer env.db.Close()
+
+		env.transactionRepo.SumOutgoingSinceFunc = func(userID, transactionType, currency string, since time.Time) (money.Amount, int, error) {
+			return money.FromMajor(40), 4, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		_, _, apiErr := env.service.BatchTransfer("user123", "USD", []BatchTransferItem{
+			{ToUserID: "user456", Amount: money.FromMajor(10)},
+			{ToUserID: "user789", Amount: money.FromMajor(10)},
+		}, Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 403, apiErr.Code)
+			assert.Equal(t, "Weekly transfer limit exceeded: at most 5 per week", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("a user's override replaces the defaults", func(t *testing.T) {
+		env := newLimitEnv(t, models.Limits{MaxAmount: money.FromMajor(100)})
+		defer env.db.Close()
+
+		env.limitRepo.FindOverrideFunc = func(userID, operation, currency string) (*models.LimitOverride, error) {
+			return &models.LimitOverride{UserID: userID, Operation: operation, Currency: currency, Limits: models.Limits{MaxAmount: money.FromMajor(1000)}}, nil
+		}
+		env.transactionRepo.CreateFunc = nil
+		env.cache.DeleteFunc = func(key string) {}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(150), "USD", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(850), balance)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_GetLimits(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+
+	env.service.(*walletService).Limits = LimitConfig{
+		models.LimitOperationWithdraw: {"USD": {MaxAmount: money.FromMajor(500), DailyAmount: money.FromMajor(1000), DailyCount: 2}},
+	}
+	env.transactionRepo.SumOutgoingSinceFunc = func(userID, transactionType, currency string, since time.Time) (money.Amount, int, error) {
+		if transactionType == models.TransactionTypeWithdraw {
+			return money.FromMajor(1200), 3, nil
+		}
+		return money.FromMajor(75), 1, nil
+	}
+
+	limits, apiErr := env.service.GetLimits("user123", "")
+
+	assert.Nil(t, apiErr)
+	if assert.Len(t, limits, 2) {
+		withdraw := limits[0]
+		assert.Equal(t, models.LimitOperationWithdraw, withdraw.Operation)
+		assert.Equal(t, money.FromMajor(500), withdraw.MaxAmount)
+		daily := withdraw.Windows[0]
+		assert.Equal(t, models.LimitWindowDaily, daily.Window)
+		assert.Equal(t, money.Amount(0), *daily.AmountRemaining)
+		assert.Equal(t, 0, *daily.CountRemaining)
+		assert.Nil(t, withdraw.Windows[1].AmountLimit)
+
+		transfer := limits[1]
+		assert.Equal(t, models.LimitOperationTransfer, transfer.Operation)
+		assert.Equal(t, money.FromMajor(75), transfer.Windows[2].AmountUsed)
+		assert.Nil(t, transfer.Windows[2].AmountRemaining)
+	}
+}
+
+func TestWalletService_LimitOverrides(t *testing.T) {
+	t.Run("sets a user's override", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.userRepo.FindByIDFunc = func(id string) (*models.User, error) {
+			return &models.User{ID: id}, nil
+		}
+		var saved *models.LimitOverride
+		env.limitRepo.SaveOverrideFunc = func(override *models.LimitOverride) error {
+			saved = override
+			return nil
+		}
+		env.limitRepo.FindOverrideFunc = func(userID, operation, currency string) (*models.LimitOverride, error) {
+			return saved, nil
+		}
+
+		override, apiErr := env.service.SetLimitOverride("ops", models.LimitOperationTransfer, "usd", models.Limits{})
+
+		assert.Nil(t, apiErr)
+		if assert.NotNil(t, override) {
+			assert.Equal(t, "ops", override.UserID)
+			assert.Equal(t, "USD", override.Currency)
+			assert.Equal(t, models.Limits{}, override.Limits)
+		}
+	})
+
+	t.Run("rejects invalid limits", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		for _, tc := range []struct {
+			operation string
+			limits    models.Limits
+		}{
+			{"deposit", models.Limits{}},
+			{models.LimitOperationWithdraw, models.Limits{DailyCount: -1}},
+			{models.LimitOperationWithdraw, models.Limits{MinAmount: money.FromMajor(10), MaxAmount: money.FromMajor(5)}},
+		} {
+			_, apiErr := env.service.SetLimitOverride("user123", tc.operation, "USD", tc.limits)
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, 400, apiErr.Code)
+			}
+		}
+	})
+
+	t.Run("deleting a missing override is not found", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		apiErr := env.service.DeleteLimitOverride("user123", models.LimitOperationTransfer, "USD")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 404, apiErr.Code)
+		}
+	})
+}
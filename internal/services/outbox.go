@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// publishEvent writes a domain event to the outbox. Called with a repository
+// bound to the database transaction making the change, the event is only
+// recorded if the change commits.
+func publishEvent(outboxRepo repositories.OutboxRepository, eventType string, data interface{}) *APIError {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return NewInternalServerError("Failed to encode event")
+	}
+	event := &models.OutboxEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		Payload:   string(payload),
+		CreatedAt: time.Now(),
+	}
+	if err := outboxRepo.Create(event); err != nil {
+		return NewInternalServerError("Failed to record event")
+	}
+	return nil
+}
+
+// publish writes a domain event within tx.
+func (s *walletService) publish(tx *gorm.DB, eventType string, data interface{}) *APIError {
+	return publishEvent(s.OutboxRepo.WithTx(tx), eventType, data)
+}
+
+// publishTransaction writes the event for a transaction that has just been
+// created or changed status within tx.
+func (s *walletService) publishTransaction(tx *gorm.DB, transaction *models.Transaction) *APIError {
+	eventType := transactionEventType(transaction)
+	if eventType == "" {
+		return nil
+	}
+	return s.publish(tx, eventType, transaction)
+}
+
+// transactionEventType returns the event a transaction in its current state
+// is announced with, or "" if it has none.
+func transactionEventType(transaction *models.Transaction) string {
+	switch transaction.Status {
+	case models.TransactionStatusPending:
+		return models.EventTransferAuthorized
+	case models.TransactionStatusVoided:
+		return models.EventTransferVoided
+	case models.TransactionStatusExpired:
+		return models.EventTransferExpired
+	case models.TransactionStatusSuccess:
+	default:
+		return ""
+	}
+
+	switch transaction.Type {
+	case models.TransactionTypeDeposit:
+		return models.EventDepositCompleted
+	case models.TransactionTypeWithdraw:
+		return models.EventWithdrawalCompleted
+	case models.TransactionTypeTransfer:
+		return models.EventTransferCompleted
+	case models.TransactionTypeRefund:
+		return models.EventRefundCompleted
+	case models.TransactionTypeReversal:
+		return models.EventReversalCompleted
+	case models.TransactionTypeFee:
+		return models.EventFeeCharged
+	case models.TransactionTypeSweep:
+		return models.EventSweepCompleted
+	case models.TransactionTypeInterest:
+		return models.EventInterestPaid
+	}
+	return ""
+}
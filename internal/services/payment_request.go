@@ -0,0 +1,239 @@
+package services
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultPaymentRequestTTL is how long a payment request waits for the payer
+// before it expires.
+const DefaultPaymentRequestTTL = 7 * 24 * time.Hour
+
+// maxPaymentRequestNoteLength caps the note on a payment request, in characters.
+const maxPaymentRequestNoteLength = 140
+
+type paymentRequestService struct {
+	PaymentRequestRepo repositories.PaymentRequestRepository
+	IdempotencyKeyRepo repositories.IdempotencyKeyRepository
+	WalletService      WalletService
+	TTL                time.Duration
+}
+
+func NewPaymentRequestService(
+	paymentRequestRepo repositories.PaymentRequestRepository,
+	idempotencyKeyRepo repositories.IdempotencyKeyRepository,
+	walletService WalletService,
+	ttl time.Duration,
+) PaymentRequestService {
+	if ttl <= 0 {
+		ttl = DefaultPaymentRequestTTL
+	}
+	return &paymentRequestService{
+		PaymentRequestRepo: paymentRequestRepo,
+		IdempotencyKeyRepo: idempotencyKeyRepo,
+		WalletService:      walletService,
+		TTL:                ttl,
+	}
+}
+
+// CreateRequest asks payerID to pay amount in currency to requesterID.
+func (s *paymentRequestService) CreateRequest(requesterID, payerID string, amount money.Amount, currency, note string) (*models.PaymentRequest, *APIError) {
+	currency, apiErr := validateAmount(amount, currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if payerID == "" {
+		return nil, NewBadRequestError("Payer is required")
+	}
+	if payerID == requesterID {
+		return nil, NewBadRequestError("Cannot request money from yourself")
+	}
+	if utf8.RuneCountInString(note) > maxPaymentRequestNoteLength {
+		return nil, NewBadRequestError("Note must be at most 140 characters")
+	}
+
+	now := time.Now()
+	request := &models.PaymentRequest{
+		ID:          uuid.New().String(),
+		RequesterID: requesterID,
+		PayerID:     payerID,
+		Amount:      amount,
+		Currency:    currency,
+		Note:        note,
+		Status:      models.PaymentRequestStatusPending,
+		ExpiresAt:   now.Add(s.TTL),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.PaymentRequestRepo.Create(request); err != nil {
+		return nil, NewInternalServerError("Failed to create payment request")
+	}
+	return request, nil
+}
+
+// ListIncoming returns the requests addressed to a payer, optionally only
+// those with the given status.
+func (s *paymentRequestService) ListIncoming(payerID, status string) ([]models.PaymentRequest, *APIError) {
+	requests, err := s.PaymentRequestRepo.FindByPayerID(payerID, status)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get payment requests")
+	}
+	return requests, nil
+}
+
+// ListOutgoing returns the requests a user has sent, optionally only those
+// with the given status.
+func (s *paymentRequestService) ListOutgoing(requesterID, status string) ([]models.PaymentRequest, *APIError) {
+	requests, err := s.PaymentRequestRepo.FindByRequesterID(requesterID, status)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get payment requests")
+	}
+	return requests, nil
+}
+
+// AcceptRequest pays a pending request through Transfer.
+//
+// The request is first claimed by moving it to accepted, so that it can no
+// longer be declined or cancelled while the transfer runs. The transfer uses
+// an Idempotency-Key derived from the request, so accepting a request whose
+// transfer is still unrecorded (a crash, or two concurrent accepts) replays
+// the stored transfer instead of paying twice. If the transfer fails the
+// request goes back to pending.
+func (s *paymentRequestService) AcceptRequest(payerID, requestID string) (*models.PaymentRequest, *APIError) {
+	var request *models.PaymentRequest
+	apiErr := s.update(requestID, func(current *models.PaymentRequest) *APIError {
+		if current.PayerID != payerID {
+			return NewNotFoundError("Payment request not found")
+		}
+		request = current
+		if current.Status == models.PaymentRequestStatusAccepted {
+			return nil
+		}
+		if apiErr := checkPending(current); apiErr != nil {
+			return apiErr
+		}
+		current.Status = models.PaymentRequestStatusAccepted
+		return nil
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if request.TransactionID != "" {
+		return request, nil
+	}
+
+	key := "payment-request:" + request.ID
+	_, transferErr := s.WalletService.Transfer(request.PayerID, request.RequesterID, request.Amount, request.Currency, Idempotency{Key: key, Fingerprint: key})
+
+	var transactionID string
+	if transferErr == nil {
+		transactionID, apiErr = transactionIDForKey(s.IdempotencyKeyRepo, request.PayerID, key)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+	}
+
+	apiErr = s.update(requestID, func(current *models.PaymentRequest) *APIError {
+		request = current
+		// Another accept has already recorded the transfer
+		if current.Status != models.PaymentRequestStatusAccepted || current.TransactionID != "" {
+			return nil
+		}
+		if transferErr != nil {
+			current.Status = models.PaymentRequestStatusPending
+		} else {
+			current.TransactionID = transactionID
+		}
+		return nil
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if transferErr != nil {
+		return nil, transferErr
+	}
+	return request, nil
+}
+
+// DeclineRequest turns down a pending request.
+func (s *paymentRequestService) DeclineRequest(payerID, requestID string) *APIError {
+	return s.update(requestID, func(request *models.PaymentRequest) *APIError {
+		if request.PayerID != payerID {
+			return NewNotFoundError("Payment request not found")
+		}
+		if apiErr := checkPending(request); apiErr != nil {
+			return apiErr
+		}
+		request.Status = models.PaymentRequestStatusDeclined
+		return nil
+	})
+}
+
+// CancelRequest withdraws a pending request the requester sent.
+func (s *paymentRequestService) CancelRequest(requesterID, requestID string) *APIError {
+	return s.update(requestID, func(request *models.PaymentRequest) *APIError {
+		if request.RequesterID != requesterID {
+			return NewNotFoundError("Payment request not found")
+		}
+		if apiErr := checkPending(request); apiErr != nil {
+			return apiErr
+		}
+		request.Status = models.PaymentRequestStatusCancelled
+		return nil
+	})
+}
+
+// ExpireRequests marks pending requests whose expiry has passed as expired
+// and returns how many there were.
+func (s *paymentRequestService) ExpireRequests() (int, *APIError) {
+	expired, err := s.PaymentRequestRepo.ExpirePending(time.Now())
+	if err != nil {
+		return 0, NewInternalServerError("Failed to expire payment requests")
+	}
+	return int(expired), nil
+}
+
+// update locks a request, applies fn to it and saves it. fn checks that the
+// caller is a party to the request.
+func (s *paymentRequestService) update(requestID string, fn func(request *models.PaymentRequest) *APIError) *APIError {
+	return runInTx(s.PaymentRequestRepo.DB(), func(tx *gorm.DB) *APIError {
+		paymentRequestRepo := s.PaymentRequestRepo.WithTx(tx)
+
+		request, err := paymentRequestRepo.FindByIDForUpdate(requestID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return NewNotFoundError("Payment request not found")
+			}
+			return NewInternalServerError("Failed to get payment request")
+		}
+		if apiErr := fn(request); apiErr != nil {
+			return apiErr
+		}
+
+		request.UpdatedAt = time.Now()
+		if err := paymentRequestRepo.Update(request); err != nil {
+			return NewInternalServerError("Failed to update payment request")
+		}
+		return nil
+	})
+}
+
+// checkPending rejects requests that can no longer be acted on, including
+// pending ones past their expiry that the worker has not reached yet.
+func checkPending(request *models.PaymentRequest) *APIError {
+	if request.Status != models.PaymentRequestStatusPending {
+		return NewConflictError("Payment request is already " + request.Status)
+	}
+	if !request.ExpiresAt.After(time.Now()) {
+		return NewConflictError("Payment request has expired")
+	}
+	return nil
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaymentRequestService_CreateRequest(t *testing.T) {
+	service := NewPaymentRequestService(&repositories.MockPaymentRequestRepository{}, &repositories.MockIdempotencyKeyRepository{}, &stubWalletService{}, time.Hour)
+
+	t.Run("creates a pending request", func(t *testing.T) {
+		request, apiErr := service.CreateRequest("user123", "user456", money.FromMajor(25), "", "Dinner")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, "USD", request.Currency)
+		assert.Equal(t, models.PaymentRequestStatusPending, request.Status)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), request.ExpiresAt, time.Second)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		longNote := string(make([]rune, maxPaymentRequestNoteLength+1))
+
+		for _, payerID := range []string{"", "user123"} {
+			_, apiErr := service.CreateRequest("user123", payerID, money.FromMajor(25), "USD", "")
+			assert.NotNil(t, apiErr)
+		}
+		_, apiErr := service.CreateRequest("user123", "user456", 0, "USD", "")
+		assert.NotNil(t, apiErr)
+		_, apiErr = service.CreateRequest("user123", "user456", money.FromMajor(25), "USD", longNote)
+		assert.NotNil(t, apiErr)
+	})
+}
+
+func TestPaymentRequestService_AcceptRequest(t *testing.T) {
+	type acceptEnv struct {
+		*testEnv
+		wallet  *stubWalletService
+		service PaymentRequestService
+		request *models.PaymentRequest
+	}
+	newAcceptEnv := func(t *testing.T) *acceptEnv {
+		env := &acceptEnv{testEnv: newTestEnv(t)}
+		env.request = &models.PaymentRequest{
+			ID:          "request1",
+			RequesterID: "user456",
+			PayerID:     "user123",
+			Amount:      money.FromMajor(25),
+			Currency:    "USD",
+			Status:      models.PaymentRequestStatusPending,
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}
+		paymentRequestRepo := &repositories.MockPaymentRequestRepository{
+			DBFunc: env.walletRepo.DB,
+			FindByIDForUpdateFunc: func(id string) (*models.PaymentRequest, error) {
+				request := *env.request
+				return &request, nil
+			},
+			UpdateFunc: func(request *models.PaymentRequest) error {
+				env.request = request
+				return nil
+			},
+		}
+		env.idempotencyKeyRepo.FindByUserIDAndKeyFunc = func(userID, key string) (*models.IdempotencyKey, error) {
+			return &models.IdempotencyKey{UserID: userID, Key: key, ResponseBody: `{"balance":"75.00","transaction_id":"tx1"}`}, nil
+		}
+		env.wallet = &stubWalletService{}
+		env.service = NewPaymentRequestService(paymentRequestRepo, env.idempotencyKeyRepo, env.wallet, time.Hour)
+		return env
+	}
+
+	t.Run("pays the requester and links the transfer", func(t *testing.T) {
+		env := newAcceptEnv(t)
+		defer env.db.Close()
+
+		env.wallet.TransferFunc = func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+			assert.Equal(t, "user123", fromUserID)
+			assert.Equal(t, "user456", toUserID)
+			assert.Equal(t, money.FromMajor(25), amount)
+			assert.Equal(t, "payment-request:request1", idem.Key)
+			assert.Equal(t, models.PaymentRequestStatusAccepted, env.request.Status)
+			return money.FromMajor(75), nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		request, apiErr := env.service.AcceptRequest("user123", "request1")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.PaymentRequestStatusAccepted, request.Status)
+		assert.Equal(t, "tx1", request.TransactionID)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("failed transfer puts the request back to pending", func(t *testing.T) {
+		env := newAcceptEnv(t)
+		defer env.db.Close()
+
+		env.wallet.TransferFunc = func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+			return 0, NewInsufficientBalanceError()
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.AcceptRequest("user123", "request1")
+
+		assert.True(t, IsInsufficientBalance(apiErr))
+		assert.Equal(t, models.PaymentRequestStatusPending, env.request.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("an accepted request is not paid twice", func(t *testing.T) {
+		env := newAcceptEnv(t)
+		defer env.db.Close()
+
+		env.request.Status = models.PaymentRequestStatusAccepted
+		env.request.TransactionID = "tx1"
+		env.wallet.TransferFunc = func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+			t.Fatal("transfer must not run again")
+			return 0, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		request, apiErr := env.service.AcceptRequest("user123", "request1")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, "tx1", request.TransactionID)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("only the payer can accept", func(t *testing.T) {
+		env := newAcceptEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.AcceptRequest("user456", "request1")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 404, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("expired and declined requests cannot be accepted", func(t *testing.T) {
+		env := newAcceptEnv(t)
+		defer env.db.Close()
+
+		env.request.ExpiresAt = time.Now().Add(-time.Minute)
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.AcceptRequest("user123", "request1")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+
+		env.request.Status = models.PaymentRequestStatusDeclined
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr = env.service.AcceptRequest("user123", "request1")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+// paymentRequestEnv serves a single pending request from user456 to user123
+// and records whether it was saved.
+type paymentRequestEnv struct {
+	*testEnv
+	service PaymentRequestService
+	request *models.PaymentRequest
+	saved   bool
+}
+
+func newPaymentRequestEnv(t *testing.T) *paymentRequestEnv {
+	env := &paymentRequestEnv{testEnv: newTestEnv(t)}
+	env.request = &models.PaymentRequest{
+		ID:          "request1",
+		RequesterID: "user456",
+		PayerID:     "user123",
+		Amount:      money.FromMajor(25),
+		Currency:    "USD",
+		Status:      models.PaymentRequestStatusPending,
+		ExpiresAt:   time.Now().Add(time.Hour),
+	}
+	paymentRequestRepo := &repositories.MockPaymentRequestRepository{
+		DBFunc: env.walletRepo.DB,
+		FindByIDForUpdateFunc: func(id string) (*models.PaymentRequest, error) {
+			request := *env.request
+			return &request, nil
+		},
+		UpdateFunc: func(request *models.PaymentRequest) error {
+			env.request = request
+			env.saved = true
+			return nil
+		},
+	}
+	env.service = NewPaymentRequestService(paymentRequestRepo, env.idempotencyKeyRepo, &stubWalletService{}, time.Hour)
+	return env
+}
+
+func TestPaymentRequestService_DeclineRequest(t *testing.T) {
+	t.Run("the payer declines a pending request", func(t *testing.T) {
+		env := newPaymentRequestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		apiErr := env.service.DeclineRequest("user123", "request1")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.PaymentRequestStatusDeclined, env.request.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("only the payer can decline", func(t *testing.T) {
+		env := newPaymentRequestEnv(t)
+		defer env.db.Close()
+
+		for _, userID := range []string{"user456", "user789"} {
+			env.sqlMock.ExpectBegin()
+			env.sqlMock.ExpectRollback()
+
+			apiErr := env.service.DeclineRequest(userID, "request1")
+
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, 404, apiErr.Code)
+			}
+		}
+		assert.False(t, env.saved)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("requests that are no longer pending cannot be declined", func(t *testing.T) {
+		for _, status := range []string{models.PaymentRequestStatusAccepted, models.PaymentRequestStatusCancelled, models.PaymentRequestStatusExpired} {
+			env := newPaymentRequestEnv(t)
+
+			env.request.Status = status
+			env.sqlMock.ExpectBegin()
+			env.sqlMock.ExpectRollback()
+
+			apiErr := env.service.DeclineRequest("user123", "request1")
+
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, 409, apiErr.Code)
+				assert.Equal(t, "Payment request is already "+status, apiErr.Message)
+			}
+			assert.False(t, env.saved)
+			assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+			env.db.Close()
+		}
+	})
+
+	t.Run("a pending request past its expiry cannot be declined", func(t *testing.T) {
+		env := newPaymentRequestEnv(t)
+		defer env.db.Close()
+
+		env.request.ExpiresAt = time.Now().Add(-time.Minute)
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		apiErr := env.service.DeclineRequest("user123", "request1")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+		assert.False(t, env.saved)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestPaymentRequestService_CancelRequest(t *testing.T) {
+	t.Run("the requester cancels a pending request", func(t *testing.T) {
+		env := newPaymentRequestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		apiErr := env.service.CancelRequest("user456", "request1")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.PaymentRequestStatusCancelled, env.request.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("only the requester can cancel", func(t *testing.T) {
+		env := newPaymentRequestEnv(t)
+		defer env.db.Close()
+
+		for _, userID := range []string{"user123", "user789"} {
+			env.sqlMock.ExpectBegin()
+			env.sqlMock.ExpectRollback()
+
+			apiErr := env.service.CancelRequest(userID, "request1")
+
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, 404, apiErr.Code)
+			}
+		}
+		assert.False(t, env.saved)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("requests that are no longer pending cannot be cancelled", func(t *testing.T) {
+		for _, status := range []string{models.PaymentRequestStatusAccepted, models.PaymentRequestStatusDeclined, models.PaymentRequestStatusExpired} {
+			env := newPaymentRequestEnv(t)
+
+			env.request.Status = status
+			env.sqlMock.ExpectBegin()
+			env.sqlMock.ExpectRollback()
+
+			apiErr := env.service.CancelRequest("user456", "request1")
+
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, 409, apiErr.Code)
+				assert.Equal(t, "Payment request is already "+status, apiErr.Message)
+			}
+			assert.False(t, env.saved)
+			assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+			env.db.Close()
+		}
+	})
+
+	t.Run("a pending request past its expiry cannot be cancelled", func(t *testing.T) {
+		env := newPaymentRequestEnv(t)
+		defer env.db.Close()
+
+		env.request.ExpiresAt = time.Now().Add(-time.Minute)
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		apiErr := env.service.CancelRequest("user456", "request1")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+		assert.False(t, env.saved)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestPaymentRequestService_ExpireRequests(t *testing.T) {
+	t.Run("expires what is due now", func(t *testing.T) {
+		before := time.Now()
+		service := NewPaymentRequestService(&repositories.MockPaymentRequestRepository{
+			ExpirePendingFunc: func(now time.Time) (int64, error) {
+				assert.WithinDuration(t, before, now, time.Second)
+				return 2, nil
+			},
+		}, &repositories.MockIdempotencyKeyRepository{}, &stubWalletService{}, time.Hour)
+
+		expired, apiErr := service.ExpireRequests()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 2, expired)
+	})
+
+	t.Run("only touches pending requests past their expiry", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		service := NewPaymentRequestService(repositories.NewPaymentRequestRepository(env.walletRepo.DB()), env.idempotencyKeyRepo, &stubWalletService{}, time.Hour)
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectExec(regexp.QuoteMeta(`UPDATE "payment_requests" SET "status"=$1,"updated_at"=$2 WHERE status = $3 AND expires_at <= $4`)).
+			WithArgs(models.PaymentRequestStatusExpired, sqlmock.AnyArg(), models.PaymentRequestStatusPending, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 3))
+		env.sqlMock.ExpectCommit()
+
+		expired, apiErr := service.ExpireRequests()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 3, expired)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("reports a failed update", func(t *testing.T) {
+		service := NewPaymentRequestService(&repositories.MockPaymentRequestRepository{
+			ExpirePendingFunc: func(now time.Time) (int64, error) {
+				return 0, errors.New("connection reset")
+			},
+		}, &repositories.MockIdempotencyKeyRepository{}, &stubWalletService{}, time.Hour)
+
+		_, apiErr := service.ExpireRequests()
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 500, apiErr.Code)
+		}
+	})
+}
+
+// TestPaymentRequestService_ExpireRequestsPostgres checks against a real
+// database that only pending requests past their expiry are expired.
+func TestPaymentRequestService_ExpireRequestsPostgres(t *testing.T) {
+	db := setupPostgres(t)
+	service := NewPaymentRequestService(repositories.NewPaymentRequestRepository(db), repositories.NewIdempotencyKeyRepository(db), &stubWalletService{}, time.Hour)
+
+	now := time.Now()
+	newRequest := func(status string, expiresAt time.Time) *models.PaymentRequest {
+		request := &models.PaymentRequest{
+			ID:          uuid.New().String(),
+			RequesterID: uuid.New().String(),
+			PayerID:     uuid.New().String(),
+			Amount:      money.FromMajor(25),
+			Currency:    "USD",
+			Status:      status,
+			ExpiresAt:   expiresAt,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		require.NoError(t, db.Create(request).Error)
+		return request
+	}
+	due := newRequest(models.PaymentRequestStatusPending, now.Add(-time.Minute))
+	notDue := newRequest(models.PaymentRequestStatusPending, now.Add(time.Hour))
+	accepted := newRequest(models.PaymentRequestStatusAccepted, now.Add(-time.Minute))
+	declined := newRequest(models.PaymentRequestStatusDeclined, now.Add(-time.Minute))
+
+	expired, apiErr := service.ExpireRequests()
+
+	require.Nil(t, apiErr)
+	assert.GreaterOrEqual(t, expired, 1)
+	for request, status := range map[*models.PaymentRequest]string{
+		due:      models.PaymentRequestStatusExpired,
+		notDue:   models.PaymentRequestStatusPending,
+		accepted: models.PaymentRequestStatusAccepted,
+		declined: models.PaymentRequestStatusDeclined,
+	} {
+		var stored models.PaymentRequest
+		require.NoError(t, db.First(&stored, "id = ?", request.ID).Error)
+		assert.Equal(t, status, stored.Status)
+	}
+}
@@ -0,0 +1,471 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxPayoutRows caps the number of rows in one payout file.
+	maxPayoutRows = 10000
+	// maxPayoutReferenceLength caps a row's reference, in characters.
+	maxPayoutReferenceLength = 140
+	// runPayoutJobsBatchSize caps how many jobs one RunPending call works on.
+	runPayoutJobsBatchSize = 10
+	// runPayoutsBatchSize caps how many rows one RunPending call pays per job.
+	runPayoutsBatchSize = 500
+	// maxPayoutAttempts is how many server errors a row may hit before its
+	// job fails.
+	maxPayoutAttempts = 8
+	// payoutRetryBase and payoutRetryMax bound the backoff after a server
+	// error, which doubles with each attempt.
+	payoutRetryBase = time.Minute
+	payoutRetryMax  = time.Hour
+	// payoutLimitRetryDelay is how long a job waits for a used-up rolling
+	// window of the uploader's limits to free up.
+	payoutLimitRetryDelay = 15 * time.Minute
+)
+
+// payoutColumns are the columns a payout file must have, in any order.
+var payoutColumns = []string{"recipient", "amount", "reference"}
+
+// PayoutRowError reports why a line of a payout file was rejected.
+type PayoutRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+type payoutService struct {
+	PayoutRepo         repositories.PayoutRepository
+	IdempotencyKeyRepo repositories.IdempotencyKeyRepository
+	WalletService      WalletService
+	UserService        UserService
+}
+
+func NewPayoutService(
+	payoutRepo repositories.PayoutRepository,
+	idempotencyKeyRepo repositories.IdempotencyKeyRepository,
+	walletService WalletService,
+	userService UserService,
+) PayoutService {
+	return &payoutService{
+		PayoutRepo:         payoutRepo,
+		IdempotencyKeyRepo: idempotencyKeyRepo,
+		WalletService:      walletService,
+		UserService:        userService,
+	}
+}
+
+// CreateJob validates every row of a payout file and, if all of them are
+// valid, stores them as a pending job for RunPending to pay. Otherwise no job
+// is created and every invalid line is reported, so the file can be fixed and
+// uploaded again in one go.
+//
+// The file needs a header row naming the recipient, amount and reference
+// columns. Recipients are written as an email, a phone number, an @handle or
+// a user ID. References are optional but must be unique within the file, so
+// that a row pasted twice is caught rather than paid twice.
+func (s *payoutService) CreateJob(userID, currency string, file io.Reader) (*models.PayoutJob, []PayoutRowError, *APIError) {
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+
+	reader := csv.NewReader(file)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, NewBadRequestError("Payout file must start with a header row")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range payoutColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, NewBadRequestError("Payout file is missing the " + name + " column")
+		}
+	}
+	reader.FieldsPerRecord = len(header)
+
+	now := time.Now()
+	job := &models.PayoutJob{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Currency:  currency,
+		Status:    models.PayoutJobStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	var items []models.PayoutItem
+	var rowErrors []PayoutRowError
+	references := make(map[string]int)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if len(items)+len(rowErrors) >= maxPayoutRows {
+			return nil, nil, NewBadRequestError(fmt.Sprintf("Payout file can have at most %d rows", maxPayoutRows))
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, NewBadRequestError("Failed to read payout file")
+			}
+			rowErrors = append(rowErrors, PayoutRowError{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		item, rowErr, apiErr := s.parseRow(userID, currency, record, columns)
+		if apiErr != nil {
+			return nil, nil, apiErr
+		}
+		if rowErr == "" && item.Reference != "" {
+			if first, ok := references[item.Reference]; ok {
+				rowErr = fmt.Sprintf("Reference is already used on line %d", first)
+			} else {
+				references[item.Reference] = line
+			}
+		}
+		if rowErr != "" {
+			rowErrors = append(rowErrors, PayoutRowError{Line: line, Error: rowErr})
+			continue
+		}
+
+		item.ID = uuid.New().String()
+		item.JobID = job.ID
+		item.Line = line
+		item.Status = models.PayoutItemStatusPending
+		item.CreatedAt = now
+		item.UpdatedAt = now
+		items = append(items, item)
+		job.TotalAmount += item.Amount
+	}
+
+	if len(rowErrors) > 0 {
+		return nil, rowErrors, NewUnprocessableEntityError("Payout file has invalid rows")
+	}
+	if len(items) == 0 {
+		return nil, nil, NewBadRequestError("Payout file has no rows")
+	}
+	job.TotalRows = len(items)
+
+	apiErr = runInTx(s.PayoutRepo.DB(), func(tx *gorm.DB) *APIError {
+		payoutRepo := s.PayoutRepo.WithTx(tx)
+		if err := payoutRepo.CreateJob(job); err != nil {
+			return NewInternalServerError("Failed to create payout job")
+		}
+		if err := payoutRepo.CreateItems(items); err != nil {
+			return NewInternalServerError("Failed to create payout items")
+		}
+		return nil
+	})
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	return job, nil, nil
+}
+
+// parseRow validates one row of a payout file. It returns the reason the row
+// is invalid, or an empty string, and fails only if the row cannot be checked
+// at all.
+func (s *payoutService) parseRow(userID, currency string, record []string, columns map[string]int) (models.PayoutItem, string, *APIError) {
+	item := models.PayoutItem{
+		Recipient: strings.TrimSpace(record[columns["recipient"]]),
+		Reference: strings.TrimSpace(record[columns["reference"]]),
+	}
+
+	if item.Recipient == "" {
+		return item, "Recipient is required", nil
+	}
+	if len([]rune(item.Reference)) > maxPayoutReferenceLength {
+		return item, fmt.Sprintf("Reference must be at most %d characters", maxPayoutReferenceLength), nil
+	}
+
+	amount, err := money.Parse(strings.TrimSpace(record[columns["amount"]]))
+	if err != nil {
+		return item, "Invalid amount", nil
+	}
+	if _, apiErr := validateAmount(amount, currency); apiErr != nil {
+		return item, apiErr.Message, nil
+	}
+	item.Amount = amount
+
+	recipient, apiErr := s.UserService.ResolveRecipient(ParseRecipient(item.Recipient))
+	if apiErr != nil {
+		if apiErr.Code >= 500 {
+			return item, "", apiErr
+		}
+		return item, apiErr.Message, nil
+	}
+	if recipient.ID == userID {
+		return item, "Cannot transfer to yourself", nil
+	}
+	item.ToUserID = recipient.ID
+	return item, "", nil
+}
+
+func (s *payoutService) GetJob(userID, jobID string) (*models.PayoutJob, *APIError) {
+	job, err := s.PayoutRepo.FindJobByID(jobID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Payout job not found")
+		}
+		return nil, NewInternalServerError("Failed to get payout job")
+	}
+	if job.UserID != userID {
+		return nil, NewNotFoundError("Payout job not found")
+	}
+	return job, nil
+}
+
+func (s *payoutService) ListJobs(userID string) ([]models.PayoutJob, *APIError) {
+	jobs, err := s.PayoutRepo.FindJobsByUserID(userID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get payout jobs")
+	}
+	return jobs, nil
+}
+
+// ListItems returns every row of a job with its outcome so far, in file order.
+func (s *payoutService) ListItems(userID, jobID string) ([]models.PayoutItem, *APIError) {
+	if _, apiErr := s.GetJob(userID, jobID); apiErr != nil {
+		return nil, apiErr
+	}
+	items, err := s.PayoutRepo.FindItemsByJobID(jobID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get payout items")
+	}
+	return items, nil
+}
+
+// RunPending pays the outstanding rows of unfinished jobs that are not
+// backing off, in the order they became due, and returns the number of rows
+// dealt with.
+func (s *payoutService) RunPending() (int, *APIError) {
+	jobs, err := s.PayoutRepo.FindDueJobs(time.Now(), runPayoutJobsBatchSize)
+	if err != nil {
+		return 0, NewInternalServerError("Failed to get payout jobs")
+	}
+
+	processed := 0
+	for i := range jobs {
+		n, apiErr := s.runJob(&jobs[i])
+		processed += n
+		if apiErr != nil {
+			return processed, apiErr
+		}
+	}
+	return processed, nil
+}
+
+// runJob pays up to runPayoutsBatchSize outstanding rows of a job, completing
+// the job once none are left. A row that hits a server error or a rolling
+// limit stays pending and the job backs off there, see retryLater.
+func (s *payoutService) runJob(job *models.PayoutJob) (int, *APIError) {
+	items, err := s.PayoutRepo.FindPendingItems(job.ID, runPayoutsBatchSize)
+	if err != nil {
+		return 0, NewInternalServerError("Failed to get payout items")
+	}
+	if len(items) == 0 {
+		return 0, s.finishJob(job.ID)
+	}
+
+	for i := range items {
+		item := &items[i]
+		retryErr, apiErr := s.payItem(job, item)
+		if apiErr != nil {
+			return i, apiErr
+		}
+		if retryErr != nil {
+			failed, apiErr := s.retryLater(job.ID, item, retryErr)
+			if failed {
+				return i + 1, apiErr
+			}
+			return i, apiErr
+		}
+	}
+	return len(items), nil
+}
+
+// payItem pays one row through Transfer and records the outcome. The transfer
+// uses an Idempotency-Key derived from the row, so a row paid just before a
+// crash is replayed rather than paid again. When the row should be retried
+// later, nothing is recorded and the transfer's error is returned instead:
+// after a server error, or when the uploader has used up a rolling window of
+// their transfer limits, which frees up as it moves on.
+func (s *payoutService) payItem(job *models.PayoutJob, item *models.PayoutItem) (*APIError, *APIError) {
+	key := "payout:" + item.ID
+	_, transferErr := s.WalletService.Transfer(job.UserID, item.ToUserID, item.Amount, job.Currency, Idempotency{Key: key, Fingerprint: key})
+	if transferErr != nil && transferErr.Code >= 500 {
+		return transferErr, nil
+	}
+	if exceeded := limitExceeded(transferErr); exceeded != nil && exceeded.Window != "" {
+		return transferErr, nil
+	}
+
+	if transferErr == nil {
+		transactionID, apiErr := transactionIDForKey(s.IdempotencyKeyRepo, job.UserID, key)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		item.Status = models.PayoutItemStatusSuccess
+		item.TransactionID = transactionID
+	} else {
+		item.Status = models.PayoutItemStatusFailed
+		item.Error = transferErr.Message
+	}
+
+	return nil, runInTx(s.PayoutRepo.DB(), func(tx *gorm.DB) *APIError {
+		payoutRepo := s.PayoutRepo.WithTx(tx)
+
+		current, err := payoutRepo.FindJobByIDForUpdate(job.ID)
+		if err != nil {
+			return NewInternalServerError("Failed to get payout job")
+		}
+		now := time.Now()
+		recorded, apiErr := recordPayoutItem(payoutRepo, current, item, now)
+		if apiErr != nil || !recorded {
+			return apiErr
+		}
+
+		// The job made progress, so it no longer backs off
+		current.Status = models.PayoutJobStatusRunning
+		current.Attempts = 0
+		current.NextAttemptAt = nil
+		current.Error = ""
+		if current.ProcessedRows == current.TotalRows {
+			current.Status = models.PayoutJobStatusCompleted
+			current.CompletedAt = &now
+		}
+		current.UpdatedAt = now
+		if err := payoutRepo.UpdateJob(current); err != nil {
+			return NewInternalServerError("Failed to update payout job")
+		}
+		return nil
+	})
+}
+
+// retryLater makes a job back off after its next row could not be paid yet,
+// so that it does not hold up other jobs. A used-up rolling limit is waited
+// out for as long as it takes. Server errors are retried with exponential
+// backoff, and after maxPayoutAttempts of them the row is marked failed and
+// the job fails, leaving its remaining rows unpaid. It reports whether the
+// job failed.
+func (s *payoutService) retryLater(jobID string, item *models.PayoutItem, transferErr *APIError) (bool, *APIError) {
+	failed := false
+	apiErr := runInTx(s.PayoutRepo.DB(), func(tx *gorm.DB) *APIError {
+		payoutRepo := s.PayoutRepo.WithTx(tx)
+
+		job, err := payoutRepo.FindJobByIDForUpdate(jobID)
+		if err != nil {
+			return NewInternalServerError("Failed to get payout job")
+		}
+		if job.Status != models.PayoutJobStatusPending && job.Status != models.PayoutJobStatusRunning {
+			return nil
+		}
+
+		now := time.Now()
+		var nextAttemptAt time.Time
+		if limitExceeded(transferErr) != nil {
+			nextAttemptAt = now.Add(payoutLimitRetryDelay)
+		} else {
+			job.Attempts++
+			nextAttemptAt = now.Add(payoutRetryDelay(job.Attempts))
+		}
+		job.Error = transferErr.Message
+		job.NextAttemptAt = &nextAttemptAt
+
+		if job.Attempts >= maxPayoutAttempts {
+			item.Status = models.PayoutItemStatusFailed
+			item.Error = transferErr.Message
+			if _, apiErr := recordPayoutItem(payoutRepo, job, item, now); apiErr != nil {
+				return apiErr
+			}
+			job.Status = models.PayoutJobStatusFailed
+			job.NextAttemptAt = nil
+			job.Error = fmt.Sprintf("Gave up on line %d after %d attempts: %s", item.Line, job.Attempts, transferErr.Message)
+			failed = true
+		}
+		job.UpdatedAt = now
+		if err := payoutRepo.UpdateJob(job); err != nil {
+			return NewInternalServerError("Failed to update payout job")
+		}
+		return nil
+	})
+	return failed && apiErr == nil, apiErr
+}
+
+// payoutRetryDelay returns how long a job waits after the given number of
+// server errors on the same row.
+func payoutRetryDelay(attempts int) time.Duration {
+	delay := payoutRetryBase
+	for i := 1; i < attempts && delay < payoutRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, payoutRetryMax)
+}
+
+// recordPayoutItem saves the outcome of a row and counts it on its locked
+// job. It reports false, and changes nothing, when another worker has already
+// recorded the row.
+func recordPayoutItem(payoutRepo repositories.PayoutRepository, job *models.PayoutJob, item *models.PayoutItem, now time.Time) (bool, *APIError) {
+	recorded, err := payoutRepo.FindItemByID(item.ID)
+	if err != nil {
+		return false, NewInternalServerError("Failed to get payout item")
+	}
+	if recorded.Status != models.PayoutItemStatusPending {
+		return false, nil
+	}
+
+	item.UpdatedAt = now
+	if err := payoutRepo.UpdateItem(item); err != nil {
+		return false, NewInternalServerError("Failed to update payout item")
+	}
+	job.ProcessedRows++
+	if item.Status == models.PayoutItemStatusSuccess {
+		job.SucceededRows++
+	} else {
+		job.FailedRows++
+	}
+	return true, nil
+}
+
+// finishJob completes a job with no outstanding rows.
+func (s *payoutService) finishJob(jobID string) *APIError {
+	return runInTx(s.PayoutRepo.DB(), func(tx *gorm.DB) *APIError {
+		payoutRepo := s.PayoutRepo.WithTx(tx)
+
+		job, err := payoutRepo.FindJobByIDForUpdate(jobID)
+		if err != nil {
+			return NewInternalServerError("Failed to get payout job")
+		}
+		if job.Status == models.PayoutJobStatusCompleted {
+			return nil
+		}
+
+		now := time.Now()
+		job.Status = models.PayoutJobStatusCompleted
+		job.CompletedAt = &now
+		job.UpdatedAt = now
+		if err := payoutRepo.UpdateJob(job); err != nil {
+			return NewInternalServerError("Failed to update payout job")
+		}
+		return nil
+	})
+}
@@ -0,0 +1,374 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+	smsmock "wallet/internal/sms/mock"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPayoutUserService() UserService {
+	users := map[string]*models.User{
+		"user123": {ID: "user123", Email: "ops@example.com"},
+		"user456": {ID: "user456", Email: "jane@example.com"},
+		"user789": {ID: "user789", Email: "john@example.com"},
+	}
+	return NewUserService(&repositories.MockUserRepository{
+		FindByIDFunc: func(id string) (*models.User, error) {
+			if user, ok := users[id]; ok {
+				return user, nil
+			}
+			return (&repositories.MockUserRepository{}).FindByID(id)
+		},
+		FindByEmailFunc: func(email string) (*models.User, error) {
+			for _, user := range users {
+				if user.Email == email {
+					return user, nil
+				}
+			}
+			return (&repositories.MockUserRepository{}).FindByEmail(email)
+		},
+	}, &smsmock.MockSender{})
+}
+
+func TestPayoutService_CreateJob(t *testing.T) {
+	t.Run("stores every row of a valid file", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		var job *models.PayoutJob
+		var items []models.PayoutItem
+		payoutRepo := &repositories.MockPayoutRepository{
+			DBFunc: env.walletRepo.DB,
+			CreateJobFunc: func(j *models.PayoutJob) error {
+				job = j
+				return nil
+			},
+			CreateItemsFunc: func(i []models.PayoutItem) error {
+				items = i
+				return nil
+			},
+		}
+		service := NewPayoutService(payoutRepo, env.idempotencyKeyRepo, &stubWalletService{}, newPayoutUserService())
+
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		file := "Recipient,Amount,Reference\njane@example.com,10.50,INV-1\nuser789, 4.50 ,\n"
+		created, rowErrors, apiErr := service.CreateJob("user123", "usd", strings.NewReader(file))
+
+		assert.Nil(t, apiErr)
+		assert.Empty(t, rowErrors)
+		assert.Equal(t, job, created)
+		assert.Equal(t, "USD", created.Currency)
+		assert.Equal(t, 2, created.TotalRows)
+		assert.Equal(t, money.FromMajor(15), created.TotalAmount)
+		assert.Equal(t, models.PayoutJobStatusPending, created.Status)
+		if assert.Len(t, items, 2) {
+			assert.Equal(t, 2, items[0].Line)
+			assert.Equal(t, "user456", items[0].ToUserID)
+			assert.Equal(t, "INV-1", items[0].Reference)
+			assert.Equal(t, 3, items[1].Line)
+			assert.Equal(t, "user789", items[1].ToUserID)
+			assert.Equal(t, money.MustParse("4.50"), items[1].Amount)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("reports every invalid line and creates nothing", func(t *testing.T) {
+		payoutRepo := &repositories.MockPayoutRepository{
+			CreateJobFunc: func(job *models.PayoutJob) error {
+				t.Fatal("job must not be created")
+				return nil
+			},
+		}
+		service := NewPayoutService(payoutRepo, &repositories.MockIdempotencyKeyRepository{}, &stubWalletService{}, newPayoutUserService())
+
+		file := strings.Join([]string{
+			"recipient,amount,reference",
+			"jane@example.com,10.00,INV-1",
+			"nobody@example.com,10.00,INV-2",
+			"john@example.com,ten,INV-3",
+			"ops@example.com,10.00,INV-4",
+			"john@example.com,10.00,INV-1",
+			"john@example.com,10.00",
+			"john@example.com,10.001,INV-6",
+		}, "\n")
+		_, rowErrors, apiErr := service.CreateJob("user123", "USD", strings.NewReader(file))
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 422, apiErr.Code)
+		}
+		assert.Equal(t, []PayoutRowError{
+			{Line: 3, Error: "Recipient not found"},
+			{Line: 4, Error: "Invalid amount"},
+			{Line: 5, Error: "Cannot transfer to yourself"},
+			{Line: 6, Error: "Reference is already used on line 2"},
+			{Line: 7, Error: "wrong number of fields"},
+			{Line: 8, Error: "Invalid amount"},
+		}, rowErrors)
+	})
+
+	t.Run("requires the header columns", func(t *testing.T) {
+		service := NewPayoutService(&repositories.MockPayoutRepository{}, &repositories.MockIdempotencyKeyRepository{}, &stubWalletService{}, newPayoutUserService())
+
+		_, _, apiErr := service.CreateJob("user123", "USD", strings.NewReader("email,amount\njane@example.com,10.00\n"))
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 400, apiErr.Code)
+		}
+	})
+}
+
+// payoutRunEnv serves one payout job from user123 and its rows, in line
+// order.
+type payoutRunEnv struct {
+	*testEnv
+	job   *models.PayoutJob
+	items map[string]*models.PayoutItem
+	repo  *repositories.MockPayoutRepository
+}
+
+func newPayoutRunEnv(t *testing.T, items ...*models.PayoutItem) *payoutRunEnv {
+	env := &payoutRunEnv{
+		testEnv: newTestEnv(t),
+		job:     &models.PayoutJob{ID: "job1", UserID: "user123", Currency: "USD", TotalRows: len(items), Status: models.PayoutJobStatusPending},
+		items:   map[string]*models.PayoutItem{},
+	}
+	for _, item := range items {
+		env.items[item.ID] = item
+	}
+	env.repo = &repositories.MockPayoutRepository{
+		DBFunc: env.walletRepo.DB,
+		FindDueJobsFunc: func(now time.Time, limit int) ([]models.PayoutJob, error) {
+			job := env.job
+			if job.Status != models.PayoutJobStatusPending && job.Status != models.PayoutJobStatusRunning {
+				return nil, nil
+			}
+			if job.NextAttemptAt != nil && job.NextAttemptAt.After(now) {
+				return nil, nil
+			}
+			return []models.PayoutJob{*job}, nil
+		},
+		FindPendingItemsFunc: func(jobID string, limit int) ([]models.PayoutItem, error) {
+			var pending []models.PayoutItem
+			for _, item := range items {
+				if current := env.items[item.ID]; current.Status == models.PayoutItemStatusPending {
+					pending = append(pending, *current)
+				}
+			}
+			return pending, nil
+		},
+		FindJobByIDForUpdateFunc: func(id string) (*models.PayoutJob, error) {
+			current := *env.job
+			return &current, nil
+		},
+		FindItemByIDFunc: func(id string) (*models.PayoutItem, error) {
+			current := *env.items[id]
+			return &current, nil
+		},
+		UpdateJobFunc: func(job *models.PayoutJob) error {
+			env.job = job
+			return nil
+		},
+		UpdateItemFunc: func(item *models.PayoutItem) error {
+			env.items[item.ID] = item
+			return nil
+		},
+	}
+	env.idempotencyKeyRepo.FindByUserIDAndKeyFunc = func(userID, key string) (*models.IdempotencyKey, error) {
+		return &models.IdempotencyKey{UserID: userID, Key: key, ResponseBody: `{"balance":"0.00","transaction_id":"tx-` + key + `"}`}, nil
+	}
+	return env
+}
+
+// backoffPassed moves the job's next attempt into the past.
+func (env *payoutRunEnv) backoffPassed() {
+	past := time.Now().Add(-time.Second)
+	env.job.NextAttemptAt = &past
+}
+
+func TestPayoutService_RunPending(t *testing.T) {
+	t.Run("pays rows and backs off on rows that cannot be paid yet", func(t *testing.T) {
+		env := newPayoutRunEnv(t,
+			&models.PayoutItem{ID: "item1", JobID: "job1", Line: 2, ToUserID: "user456", Amount: money.FromMajor(10), Status: models.PayoutItemStatusPending},
+			&models.PayoutItem{ID: "item2", JobID: "job1", Line: 3, ToUserID: "user789", Amount: money.FromMajor(500), Status: models.PayoutItemStatusPending},
+			&models.PayoutItem{ID: "item3", JobID: "job1", Line: 4, ToUserID: "user999", Amount: money.FromMajor(5), Status: models.PayoutItemStatusPending},
+		)
+		defer env.db.Close()
+
+		transfers := 0
+		unavailable, overDailyLimit := true, false
+		wallet := &stubWalletService{
+			TransferFunc: func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+				transfers++
+				switch toUserID {
+				case "user789":
+					return 0, NewInsufficientBalanceError()
+				case "user999":
+					if unavailable {
+						return 0, NewInternalServerError("Failed to update recipient's wallet")
+					}
+					if overDailyLimit {
+						return 0, NewLimitExceededError("Daily transfer limit exceeded: at most 100 per day", &models.LimitExceeded{Operation: models.LimitOperationTransfer, Currency: currency, Limit: "daily_count", Window: models.LimitWindowDaily})
+					}
+				}
+				return 0, nil
+			},
+		}
+		service := NewPayoutService(env.repo, env.idempotencyKeyRepo, wallet, newPayoutUserService())
+
+		// The server error leaves the last row pending and the job backs off
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		processed, apiErr := service.RunPending()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 2, processed)
+		assert.Equal(t, models.PayoutItemStatusSuccess, env.items["item1"].Status)
+		assert.Equal(t, "tx-payout:item1", env.items["item1"].TransactionID)
+		assert.Equal(t, models.PayoutItemStatusFailed, env.items["item2"].Status)
+		assert.Equal(t, "Insufficient balance", env.items["item2"].Error)
+		assert.Equal(t, models.PayoutItemStatusPending, env.items["item3"].Status)
+		assert.Equal(t, models.PayoutJobStatusRunning, env.job.Status)
+		assert.Equal(t, 2, env.job.ProcessedRows)
+		assert.Equal(t, 1, env.job.Attempts)
+		assert.Equal(t, "Failed to update recipient's wallet", env.job.Error)
+		if assert.NotNil(t, env.job.NextAttemptAt) {
+			assert.WithinDuration(t, time.Now().Add(payoutRetryBase), *env.job.NextAttemptAt, time.Second)
+		}
+
+		// Until the backoff has passed the job is skipped
+		processed, apiErr = service.RunPending()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 0, processed)
+		assert.Equal(t, 3, transfers)
+
+		// A used-up rolling limit leaves the row pending too, without using
+		// up an attempt
+		env.backoffPassed()
+		unavailable, overDailyLimit = false, true
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		processed, apiErr = service.RunPending()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 0, processed)
+		assert.Equal(t, models.PayoutItemStatusPending, env.items["item3"].Status)
+		assert.Equal(t, 1, env.job.Attempts)
+		if assert.NotNil(t, env.job.NextAttemptAt) {
+			assert.WithinDuration(t, time.Now().Add(payoutLimitRetryDelay), *env.job.NextAttemptAt, time.Second)
+		}
+
+		env.backoffPassed()
+		overDailyLimit = false
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		processed, apiErr = service.RunPending()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 1, processed)
+		assert.Equal(t, models.PayoutJobStatusCompleted, env.job.Status)
+		assert.Equal(t, 2, env.job.SucceededRows)
+		assert.Equal(t, 1, env.job.FailedRows)
+		assert.NotNil(t, env.job.CompletedAt)
+		assert.Zero(t, env.job.Attempts)
+		assert.Nil(t, env.job.NextAttemptAt)
+		assert.Empty(t, env.job.Error)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("fails the job once a row has used up its attempts", func(t *testing.T) {
+		env := newPayoutRunEnv(t,
+			&models.PayoutItem{ID: "item1", JobID: "job1", Line: 2, ToUserID: "user456", Amount: money.FromMajor(10), Status: models.PayoutItemStatusPending},
+			&models.PayoutItem{ID: "item2", JobID: "job1", Line: 3, ToUserID: "user789", Amount: money.FromMajor(5), Status: models.PayoutItemStatusPending},
+		)
+		defer env.db.Close()
+
+		wallet := &stubWalletService{
+			TransferFunc: func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+				if toUserID != "user456" {
+					t.Fatal("rows after the failing one must not be paid")
+				}
+				return 0, NewInternalServerError("Failed to update recipient's wallet")
+			},
+		}
+		service := NewPayoutService(env.repo, env.idempotencyKeyRepo, wallet, newPayoutUserService())
+
+		for attempt := 1; attempt < maxPayoutAttempts; attempt++ {
+			env.sqlMock.ExpectBegin()
+			env.sqlMock.ExpectCommit()
+
+			processed, apiErr := service.RunPending()
+
+			assert.Nil(t, apiErr)
+			assert.Equal(t, 0, processed)
+			assert.Equal(t, attempt, env.job.Attempts)
+			assert.Equal(t, models.PayoutItemStatusPending, env.items["item1"].Status)
+			env.backoffPassed()
+		}
+
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		processed, apiErr := service.RunPending()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 1, processed)
+		assert.Equal(t, models.PayoutJobStatusFailed, env.job.Status)
+		assert.Equal(t, "Gave up on line 2 after 8 attempts: Failed to update recipient's wallet", env.job.Error)
+		assert.Nil(t, env.job.NextAttemptAt)
+		assert.Equal(t, 1, env.job.ProcessedRows)
+		assert.Equal(t, 1, env.job.FailedRows)
+		assert.Equal(t, models.PayoutItemStatusFailed, env.items["item1"].Status)
+		assert.Equal(t, "Failed to update recipient's wallet", env.items["item1"].Error)
+		assert.Equal(t, models.PayoutItemStatusPending, env.items["item2"].Status)
+
+		// A failed job is not picked up again
+		processed, apiErr = service.RunPending()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 0, processed)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("picks jobs that are due, in the order they became due", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		service := NewPayoutService(repositories.NewPayoutRepository(env.walletRepo.DB()), env.idempotencyKeyRepo, &stubWalletService{}, newPayoutUserService())
+		env.sqlMock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "payout_jobs" WHERE status IN ($1,$2) AND (next_attempt_at IS NULL OR next_attempt_at <= $3) ORDER BY COALESCE(next_attempt_at, created_at), created_at LIMIT $4`)).
+			WithArgs(models.PayoutJobStatusPending, models.PayoutJobStatusRunning, sqlmock.AnyArg(), runPayoutJobsBatchSize).
+			WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+		processed, apiErr := service.RunPending()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 0, processed)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestPayoutRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Minute, payoutRetryDelay(1))
+	assert.Equal(t, 2*time.Minute, payoutRetryDelay(2))
+	assert.Equal(t, 32*time.Minute, payoutRetryDelay(6))
+	assert.Equal(t, time.Hour, payoutRetryDelay(7))
+	assert.Equal(t, time.Hour, payoutRetryDelay(20))
+}
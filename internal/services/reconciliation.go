@@ -0,0 +1,191 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultReconciliationInterval is how often balances are reconciled unless
+// configured otherwise.
+const DefaultReconciliationInterval = 24 * time.Hour
+
+// reconciliationBatchSize is how many wallets are loaded at a time while
+// reconciling.
+const reconciliationBatchSize = 500
+
+// reconciliationRunsLimit is how many recent runs are listed.
+const reconciliationRunsLimit = 50
+
+// ReconciliationActorID is recorded as the actor of wallets frozen by
+// reconciliation.
+const ReconciliationActorID = "reconciliation"
+
+type reconciliationService struct {
+	ReconciliationRepo repositories.ReconciliationRepository
+	WalletRepo         repositories.WalletRepository
+	TransactionRepo    repositories.TransactionRepository
+	LedgerRepo         repositories.LedgerRepository
+	WalletService      WalletService
+}
+
+func NewReconciliationService(
+	reconciliationRepo repositories.ReconciliationRepository,
+	walletRepo repositories.WalletRepository,
+	transactionRepo repositories.TransactionRepository,
+	ledgerRepo repositories.LedgerRepository,
+	walletService WalletService,
+) ReconciliationService {
+	return &reconciliationService{
+		ReconciliationRepo: reconciliationRepo,
+		WalletRepo:         walletRepo,
+		TransactionRepo:    transactionRepo,
+		LedgerRepo:         ledgerRepo,
+		WalletService:      walletService,
+	}
+}
+
+// Reconcile recomputes every wallet's balance from its settled transactions
+// and from its ledger account, and records each wallet whose stored balance
+// disagrees with either. With autoFreeze, drifted wallets that are active are
+// frozen for debits, so that no money leaves them until support has looked
+// into it. The run is recorded even when it fails partway.
+func (s *reconciliationService) Reconcile(autoFreeze bool) (*models.ReconciliationRun, *APIError) {
+	run := &models.ReconciliationRun{
+		ID:         uuid.New().String(),
+		Status:     models.ReconciliationStatusRunning,
+		AutoFreeze: autoFreeze,
+		StartedAt:  time.Now(),
+	}
+	if err := s.ReconciliationRepo.CreateRun(run); err != nil {
+		return nil, NewInternalServerError("Failed to create reconciliation run")
+	}
+
+	apiErr := s.reconcileAll(run)
+
+	now := time.Now()
+	run.CompletedAt = &now
+	run.Status = models.ReconciliationStatusCompleted
+	if apiErr != nil {
+		run.Status = models.ReconciliationStatusFailed
+		run.Error = apiErr.Message
+	}
+	if err := s.ReconciliationRepo.UpdateRun(run); err != nil && apiErr == nil {
+		apiErr = NewInternalServerError("Failed to update reconciliation run")
+	}
+	return run, apiErr
+}
+
+// ListRuns returns the most recent reconciliation runs without their
+// mismatches.
+func (s *reconciliationService) ListRuns() ([]models.ReconciliationRun, *APIError) {
+	runs, err := s.ReconciliationRepo.FindRuns(reconciliationRunsLimit)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get reconciliation runs")
+	}
+	return runs, nil
+}
+
+// GetRun returns a reconciliation run with the mismatches it found.
+func (s *reconciliationService) GetRun(runID string) (*models.ReconciliationRun, *APIError) {
+	run, err := s.ReconciliationRepo.FindRunByID(runID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Reconciliation run not found")
+		}
+		return nil, NewInternalServerError("Failed to get reconciliation run")
+	}
+	return run, nil
+}
+
+func (s *reconciliationService) reconcileAll(run *models.ReconciliationRun) *APIError {
+	afterID := ""
+	for {
+		wallets, err := s.WalletRepo.FindAll(afterID, reconciliationBatchSize)
+		if err != nil {
+			return NewInternalServerError("Failed to get wallets")
+		}
+
+		for i := range wallets {
+			mismatch, apiErr := s.check(wallets[i].ID)
+			if apiErr != nil {
+				return apiErr
+			}
+			run.WalletsChecked++
+			if mismatch == nil {
+				continue
+			}
+
+			run.Mismatched++
+			mismatch.RunID = run.ID
+			if run.AutoFreeze && mismatch.WalletStatus == models.WalletStatusActive {
+				// A wallet that cannot be frozen, e.g. because it was closed
+				// in the meantime, is still reported
+				_, apiErr := s.WalletService.SetWalletStatus(ReconciliationActorID, mismatch.WalletID, models.WalletStatusFrozenDebits, "Balance does not reconcile (run "+run.ID+")")
+				if apiErr == nil {
+					mismatch.Frozen = true
+					run.Frozen++
+				}
+			}
+			if err := s.ReconciliationRepo.CreateMismatch(mismatch); err != nil {
+				return NewInternalServerError("Failed to record reconciliation mismatch")
+			}
+		}
+
+		if len(wallets) < reconciliationBatchSize {
+			return nil
+		}
+		afterID = wallets[len(wallets)-1].ID
+	}
+}
+
+// check recomputes one wallet's balance and returns the mismatch, or nil if
+// it reconciles. The wallet is locked while its balance, transactions and
+// ledger are read, so that a payment in flight cannot show up in some of them
+// and not the others.
+func (s *reconciliationService) check(walletID string) (*models.ReconciliationMismatch, *APIError) {
+	var mismatch *models.ReconciliationMismatch
+	apiErr := runInTx(s.WalletRepo.DB(), func(tx *gorm.DB) *APIError {
+		wallet, apiErr := lockWalletByID(s.WalletRepo.WithTx(tx), walletID)
+		if apiErr != nil {
+			return apiErr
+		}
+
+		transactionBalance, count, err := s.TransactionRepo.WithTx(tx).SumSettled(wallet.UserID, wallet.Currency)
+		if err != nil {
+			return NewInternalServerError("Failed to sum transactions")
+		}
+		ledgerBalance, err := s.LedgerRepo.WithTx(tx).BalanceOf(models.WalletLedgerAccount(wallet.ID))
+		if err != nil {
+			return NewInternalServerError("Failed to get ledger balance")
+		}
+		if wallet.Balance == transactionBalance && wallet.Balance == ledgerBalance {
+			return nil
+		}
+
+		status := wallet.Status
+		if status == "" {
+			status = models.WalletStatusActive
+		}
+		mismatch = &models.ReconciliationMismatch{
+			ID:                 uuid.New().String(),
+			WalletID:           wallet.ID,
+			UserID:             wallet.UserID,
+			Currency:           wallet.Currency,
+			WalletStatus:       status,
+			Balance:            wallet.Balance,
+			TransactionBalance: transactionBalance,
+			LedgerBalance:      ledgerBalance,
+			Difference:         wallet.Balance - transactionBalance,
+			TransactionCount:   count,
+			CreatedAt:          time.Now(),
+		}
+		return nil
+	})
+	return mismatch, apiErr
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"testing"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestReconciliationService(env *testEnv, reconciliationRepo *repositories.MockReconciliationRepository) ReconciliationService {
+	return NewReconciliationService(reconciliationRepo, env.walletRepo, env.transactionRepo, env.ledgerRepo, env.service)
+}
+
+func TestReconciliationService_Reconcile(t *testing.T) {
+	wallets := map[string]*models.Wallet{
+		"wallet1": {ID: "wallet1", UserID: "user1", Currency: "USD", Balance: money.FromMajor(100), Status: models.WalletStatusActive},
+		"wallet2": {ID: "wallet2", UserID: "user2", Currency: "USD", Balance: money.FromMajor(80), Status: models.WalletStatusActive},
+		"wallet3": {ID: "wallet3", UserID: "user3", Currency: "EUR", Balance: money.FromMajor(5), Status: models.WalletStatusFrozenAll},
+	}
+	setup := func(t *testing.T) (*testEnv, *repositories.MockReconciliationRepository) {
+		env := newTestEnv(t)
+		reconciliationRepo := &repositories.MockReconciliationRepository{}
+
+		env.walletRepo.FindAllFunc = func(afterID string, limit int) ([]models.Wallet, error) {
+			return []models.Wallet{*wallets["wallet1"], *wallets["wallet2"], *wallets["wallet3"]}, nil
+		}
+		env.walletRepo.FindByIDForUpdateFunc = func(id string) (*models.Wallet, error) {
+			wallet := *wallets[id]
+			return &wallet, nil
+		}
+		// wallet2 was credited 10.00 outside any transaction; wallet3's
+		// ledger is missing a posting
+		env.transactionRepo.SumSettledFunc = func(userID, currency string) (money.Amount, int, error) {
+			switch userID {
+			case "user2":
+				return money.FromMajor(70), 4, nil
+			case "user3":
+				return money.FromMajor(5), 1, nil
+			}
+			return money.FromMajor(100), 2, nil
+		}
+		env.ledgerRepo.BalanceOfFunc = func(accountCode string) (money.Amount, error) {
+			switch accountCode {
+			case "wallets/wallet2":
+				return money.FromMajor(80), nil
+			case "wallets/wallet3":
+				return 0, nil
+			}
+			return money.FromMajor(100), nil
+		}
+		for range wallets {
+			env.sqlMock.ExpectBegin()
+			env.sqlMock.ExpectCommit()
+		}
+		return env, reconciliationRepo
+	}
+
+	t.Run("records every wallet that disagrees with its transactions or ledger", func(t *testing.T) {
+		env, reconciliationRepo := setup(t)
+		defer env.db.Close()
+		service := newTestReconciliationService(env, reconciliationRepo)
+
+		var mismatches []*models.ReconciliationMismatch
+		reconciliationRepo.CreateMismatchFunc = func(mismatch *models.ReconciliationMismatch) error {
+			mismatches = append(mismatches, mismatch)
+			return nil
+		}
+		var updated *models.ReconciliationRun
+		reconciliationRepo.UpdateRunFunc = func(run *models.ReconciliationRun) error {
+			updated = run
+			return nil
+		}
+
+		run, apiErr := service.Reconcile(false)
+
+		assert.Nil(t, apiErr)
+		assert.Same(t, updated, run)
+		assert.Equal(t, models.ReconciliationStatusCompleted, run.Status)
+		assert.Equal(t, 3, run.WalletsChecked)
+		assert.Equal(t, 2, run.Mismatched)
+		assert.Equal(t, 0, run.Frozen)
+		if assert.Len(t, mismatches, 2) {
+			assert.Equal(t, run.ID, mismatches[0].RunID)
+			assert.Equal(t, "wallet2", mismatches[0].WalletID)
+			assert.Equal(t, money.FromMajor(70), mismatches[0].TransactionBalance)
+			assert.Equal(t, money.FromMajor(10), mismatches[0].Difference)
+			assert.Equal(t, 4, mismatches[0].TransactionCount)
+			assert.Equal(t, "wallet3", mismatches[1].WalletID)
+			assert.Equal(t, money.Amount(0), mismatches[1].Difference)
+			assert.Equal(t, money.Amount(0), mismatches[1].LedgerBalance)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("freezes drifted active wallets when asked", func(t *testing.T) {
+		env, reconciliationRepo := setup(t)
+		defer env.db.Close()
+		service := newTestReconciliationService(env, reconciliationRepo)
+
+		// SetWalletStatus runs in a transaction of its own
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+		var events []*models.WalletStatusEvent
+		env.walletRepo.CreateStatusEventFunc = func(event *models.WalletStatusEvent) error {
+			events = append(events, event)
+			return nil
+		}
+		var mismatches []*models.ReconciliationMismatch
+		reconciliationRepo.CreateMismatchFunc = func(mismatch *models.ReconciliationMismatch) error {
+			mismatches = append(mismatches, mismatch)
+			return nil
+		}
+
+		run, apiErr := service.Reconcile(true)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 1, run.Frozen)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, "wallet2", events[0].WalletID)
+			assert.Equal(t, models.WalletStatusFrozenDebits, events[0].ToStatus)
+			assert.Equal(t, ReconciliationActorID, events[0].ActorID)
+		}
+		if assert.Len(t, mismatches, 2) {
+			assert.True(t, mismatches[0].Frozen)
+			// Already frozen for all movements, so left as it is
+			assert.False(t, mismatches[1].Frozen)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReverseTransaction returns everything that is still refundable on a
+// transfer the user received back to its sender.
+func (s *walletService) ReverseTransaction(userID, transactionID string, idem Idempotency) (money.Amount, *APIError) {
+	return s.refund(userID, transactionID, 0, true, idem)
+}
+
+// RefundTransaction returns part of a transfer the user received back to its
+// sender. Refunds can be repeated until the original amount is used up.
+func (s *walletService) RefundTransaction(userID, transactionID string, amount money.Amount, idem Idempotency) (money.Amount, *APIError) {
+	if amount <= 0 {
+		return 0, NewBadRequestError("Invalid amount")
+	}
+	return s.refund(userID, transactionID, amount, false, idem)
+}
+
+// refund books a compensating transaction from the recipient of a transfer
+// back to its sender. Only the recipient can refund, since it is their money
+// that moves. The original transaction is locked for the duration, so that
+// concurrent refunds can never return more than was originally sent.
+func (s *walletService) refund(userID, transactionID string, amount money.Amount, full bool, idem Idempotency) (money.Amount, *APIError) {
+	response, apiErr := s.runIdempotent(userID, idem, func(tx *gorm.DB) (idempotentResponse, *APIError) {
+		// Create repository instances with transaction
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
+
+		original, err := transactionRepo.FindByIDForUpdate(transactionID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return idempotentResponse{}, NewNotFoundError("Transaction not found")
+			}
+			return idempotentResponse{}, NewInternalServerError("Failed to get transaction")
+		}
+		if original.ToUserID != userID {
+			return idempotentResponse{}, NewNotFoundError("Transaction not found")
+		}
+		if original.Type != models.TransactionTypeTransfer {
+			return idempotentResponse{}, NewBadRequestError("Only transfers can be refunded")
+		}
+		switch original.Status {
+		case models.TransactionStatusSuccess, models.TransactionStatusPartiallyRefunded:
+		case models.TransactionStatusReversed:
+			return idempotentResponse{}, NewConflictError("Transaction has already been fully refunded")
+		default:
+			return idempotentResponse{}, NewBadRequestError("Transaction cannot be refunded")
+		}
+
+		remaining := original.Amount - original.RefundedAmount
+		if full {
+			amount = remaining
+		}
+		if amount > remaining {
+			return idempotentResponse{}, NewBadRequestError("Refund exceeds the refundable amount")
+		}
+		currency, err := money.LookupCurrency(original.Currency)
+		if err != nil {
+			return idempotentResponse{}, NewInternalServerError("Unsupported currency")
+		}
+		if err := currency.Validate(amount); err != nil {
+			return idempotentResponse{}, NewBadRequestError(err.Error())
+		}
+
+		// A cross-currency transfer is reversed at its original amounts, so
+		// the recipient returns exactly what they received. Partial refunds
+		// would need a new rate and are not supported.
+		debitAmount, debitCurrency := amount, original.Currency
+		if original.ToCurrency != "" {
+			if !full || original.RefundedAmount != 0 {
+				return idempotentResponse{}, NewBadRequestError("Cross-currency transfers can only be reversed in full")
+			}
+			debitAmount, debitCurrency = original.ToAmount, original.ToCurrency
+		}
+
+		payerWallet, payeeWallet, apiErr := lockWalletPair(walletRepo, original.ToUserID, debitCurrency, original.FromUserID, original.Currency)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := checkTransfer(payerWallet, payeeWallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		available, apiErr := availableBalance(s.HoldRepo.WithTx(tx), payerWallet)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if available < debitAmount {
+			return idempotentResponse{}, NewInsufficientBalanceError()
+		}
+
+		transactionType := models.TransactionTypeRefund
+		if full {
+			transactionType = models.TransactionTypeReversal
+		}
+
+		// Create compensating transaction
+		transaction := &models.Transaction{
+			ID:                    uuid.New().String(),
+			FromUserID:            original.ToUserID,
+			ToUserID:              original.FromUserID,
+			Amount:                debitAmount,
+			Currency:              debitCurrency,
+			OriginalTransactionID: original.ID,
+			Type:                  transactionType,
+			Status:                models.TransactionStatusSuccess,
+			CreatedAt:             time.Now(),
+			UpdatedAt:             time.Now(),
+		}
+		if original.ToCurrency != "" {
+			transaction.ToAmount = amount
+			transaction.ToCurrency = original.Currency
+		}
+
+		if err := transactionRepo.Create(transaction); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to create transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		// Update refunding wallet
+		payerWallet.Balance -= debitAmount
+		payerWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(payerWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update wallet")
+		}
+
+		// Update refunded wallet
+		payeeWallet.Balance += amount
+		payeeWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(payeeWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update recipient's wallet")
+		}
+
+		entries := []ledgerEntry{
+			{account: models.WalletLedgerAccount(payerWallet.ID), amount: -debitAmount},
+			{account: models.WalletLedgerAccount(payeeWallet.ID), amount: amount},
+		}
+		if original.ToCurrency != "" {
+			entries = []ledgerEntry{
+				{account: models.WalletLedgerAccount(payerWallet.ID), amount: -debitAmount, currency: debitCurrency},
+				{account: models.LedgerAccountHouseFX, amount: debitAmount, currency: debitCurrency},
+				{account: models.LedgerAccountHouseFX, amount: -amount, currency: original.Currency},
+				{account: models.WalletLedgerAccount(payeeWallet.ID), amount: amount, currency: original.Currency},
+			}
+		}
+		if apiErr := s.postLedger(tx, transaction, entries, payerWallet, payeeWallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+
+		// Mark the original as refunded
+		original.RefundedAmount += amount
+		original.Status = models.TransactionStatusPartiallyRefunded
+		if original.RefundedAmount == original.Amount {
+			original.Status = models.TransactionStatusReversed
+		}
+		original.UpdatedAt = time.Now()
+		if err := transactionRepo.Update(original); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update transaction")
+		}
+		return idempotentResponse{
+			Balance:       payerWallet.Balance,
+			TransactionID: transaction.ID,
+			UserIDs:       []string{original.ToUserID, original.FromUserID},
+		}, nil
+	})
+	if apiErr != nil {
+		return 0, apiErr
+	}
+
+	s.changed(response.UserIDs...)
+	return response.Balance, nil
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestWalletService_RefundTransaction(t *testing.T) {
+	newTransfer := func() *models.Transaction {
+		return &models.Transaction{
+			ID:         "tx1",
+			FromUserID: "user123",
+			ToUserID:   "user456",
+			Amount:     money.FromMajor(100),
+			Currency:   "USD",
+			Type:       models.TransactionTypeTransfer,
+			Status:     models.TransactionStatusSuccess,
+		}
+	}
+	walletsWith := func(env *testEnv, balance money.Amount) {
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: userID + "-" + currency, UserID: userID, Currency: currency, Balance: balance}, nil
+		}
+	}
+
+	t.Run("partial refund returns money to the sender", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		original := newTransfer()
+
+		env.sqlMock.ExpectBegin()
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return original, nil
+		}
+		walletsWith(env, money.FromMajor(500))
+		var refund *models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			refund = tx
+			return nil
+		}
+		env.transactionRepo.UpdateFunc = func(tx *models.Transaction) error {
+			return nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.RefundTransaction("user456", "tx1", money.FromMajor(30), Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(470), balance)
+		if assert.NotNil(t, refund) {
+			assert.Equal(t, models.TransactionTypeRefund, refund.Type)
+			assert.Equal(t, "tx1", refund.OriginalTransactionID)
+			assert.Equal(t, "user456", refund.FromUserID)
+			assert.Equal(t, "user123", refund.ToUserID)
+			assert.Equal(t, money.FromMajor(30), refund.Amount)
+		}
+		assert.Equal(t, money.FromMajor(30), original.RefundedAmount)
+		assert.Equal(t, models.TransactionStatusPartiallyRefunded, original.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("refunding the remainder marks the transfer reversed", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		original := newTransfer()
+		original.RefundedAmount = money.FromMajor(30)
+		original.Status = models.TransactionStatusPartiallyRefunded
+
+		env.sqlMock.ExpectBegin()
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return original, nil
+		}
+		walletsWith(env, money.FromMajor(500))
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.RefundTransaction("user456", "tx1", money.FromMajor(70), Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(100), original.RefundedAmount)
+		assert.Equal(t, models.TransactionStatusReversed, original.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("refund exceeding the remaining amount", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		original := newTransfer()
+		original.RefundedAmount = money.FromMajor(80)
+		original.Status = models.TransactionStatusPartiallyRefunded
+
+		env.sqlMock.ExpectBegin()
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return original, nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.RefundTransaction("user456", "tx1", money.FromMajor(30), Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+			assert.Equal(t, "Refund exceeds the refundable amount", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("only the recipient can refund", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return newTransfer(), nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.RefundTransaction("user123", "tx1", money.FromMajor(30), Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusNotFound, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("transaction not found", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.RefundTransaction("user456", "missing", money.FromMajor(30), Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusNotFound, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("recipient has spent the money", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return newTransfer(), nil
+		}
+		walletsWith(env, money.FromMajor(10))
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.RefundTransaction("user456", "tx1", money.FromMajor(30), Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Insufficient balance", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_ReverseTransaction(t *testing.T) {
+	t.Run("reverses both legs of a cross-currency transfer", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		original := &models.Transaction{
+			ID:           "tx1",
+			FromUserID:   "user123",
+			ToUserID:     "user456",
+			Amount:       money.FromMajor(100),
+			Currency:     "EUR",
+			ToAmount:     money.MustParse("108.15"),
+			ToCurrency:   "USD",
+			ExchangeRate: "1.08150000",
+			Type:         models.TransactionTypeTransfer,
+			Status:       models.TransactionStatusSuccess,
+		}
+
+		env.sqlMock.ExpectBegin()
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return original, nil
+		}
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: userID + "-" + currency, UserID: userID, Currency: currency, Balance: money.FromMajor(500)}, nil
+		}
+		var reversal *models.Transaction
+		env.transactionRepo.CreateFunc = func(tx *models.Transaction) error {
+			reversal = tx
+			return nil
+		}
+		var postings []models.LedgerPosting
+		env.ledgerRepo.CreatePostingsFunc = func(p []models.LedgerPosting) error {
+			postings = p
+			return nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.ReverseTransaction("user456", "tx1", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.MustParse("391.85"), balance)
+		if assert.NotNil(t, reversal) {
+			assert.Equal(t, models.TransactionTypeReversal, reversal.Type)
+			assert.Equal(t, "USD", reversal.Currency)
+			assert.Equal(t, money.MustParse("108.15"), reversal.Amount)
+			assert.Equal(t, "EUR", reversal.ToCurrency)
+			assert.Equal(t, money.FromMajor(100), reversal.ToAmount)
+		}
+
+		totals := map[string]money.Amount{}
+		for _, posting := range postings {
+			totals[posting.Currency] += posting.Amount
+		}
+		assert.Equal(t, map[string]money.Amount{"EUR": 0, "USD": 0}, totals)
+		assert.Equal(t, models.TransactionStatusReversed, original.Status)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("already reversed", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			return &models.Transaction{
+				ID:             "tx1",
+				FromUserID:     "user123",
+				ToUserID:       "user456",
+				Amount:         money.FromMajor(100),
+				RefundedAmount: money.FromMajor(100),
+				Currency:       "USD",
+				Type:           models.TransactionTypeTransfer,
+				Status:         models.TransactionStatusReversed,
+			}, nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.ReverseTransaction("user456", "tx1", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusConflict, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_RefundReplay(t *testing.T) {
+	idem := Idempotency{Key: "refund-1", Fingerprint: "fp-1"}
+
+	replay := func(t *testing.T, body string, refund func(env *testEnv) (money.Amount, *APIError)) []string {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.idempotencyKeyRepo.CreateIfNotExistsFunc = func(key *models.IdempotencyKey) (bool, error) {
+			return false, nil
+		}
+		env.idempotencyKeyRepo.FindByUserIDAndKeyFunc = func(userID, key string) (*models.IdempotencyKey, error) {
+			return &models.IdempotencyKey{UserID: userID, Key: key, Fingerprint: idem.Fingerprint, ResponseCode: 200, ResponseBody: body}, nil
+		}
+		env.transactionRepo.FindByIDForUpdateFunc = func(id string) (*models.Transaction, error) {
+			t.Fatal("the transaction must not be touched on replay")
+			return nil, nil
+		}
+		var notified []string
+		env.cache.DeleteFunc = func(key string) {
+			notified = append(notified, key)
+		}
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := refund(env)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(70), balance)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+		return notified
+	}
+
+	t.Run("a replayed refund returns the stored response", func(t *testing.T) {
+		notified := replay(t, `{"balance":"70.00","transaction_id":"tx2","user_ids":["user456","user123"]}`, func(env *testEnv) (money.Amount, *APIError) {
+			return env.service.RefundTransaction("user456", "tx1", money.FromMajor(30), idem)
+		})
+
+		assert.Equal(t, []string{"user456", "user123"}, notified)
+	})
+
+	t.Run("a replayed reversal returns the stored response", func(t *testing.T) {
+		notified := replay(t, `{"balance":"70.00","transaction_id":"tx2","user_ids":["user456","user123"]}`, func(env *testEnv) (money.Amount, *APIError) {
+			return env.service.ReverseTransaction("user456", "tx1", idem)
+		})
+
+		assert.Equal(t, []string{"user456", "user123"}, notified)
+	})
+
+	t.Run("a response stored before user IDs were recorded replays too", func(t *testing.T) {
+		notified := replay(t, `{"balance":"70.00","transaction_id":"tx2"}`, func(env *testEnv) (money.Amount, *APIError) {
+			return env.service.ReverseTransaction("user456", "tx1", idem)
+		})
+
+		assert.Empty(t, notified)
+	})
+}
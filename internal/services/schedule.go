@@ -0,0 +1,341 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultScheduleRetryInterval is how long a failed occurrence waits
+	// before it is attempted again.
+	DefaultScheduleRetryInterval = time.Hour
+	// DefaultScheduleMaxAttempts is how many times an occurrence is
+	// attempted before it is skipped.
+	DefaultScheduleMaxAttempts = 3
+)
+
+// runDueBatchSize caps how many schedules one RunDue call executes.
+const runDueBatchSize = 100
+
+// NewSchedule describes a schedule to create. EndAt and MaxOccurrences are
+// optional limits on recurring schedules; OnInsufficientFunds defaults to
+// retrying.
+type NewSchedule struct {
+	ToUserID            string
+	Amount              money.Amount
+	Currency            string
+	Frequency           string
+	StartAt             time.Time
+	EndAt               *time.Time
+	MaxOccurrences      int
+	OnInsufficientFunds string
+}
+
+type scheduleService struct {
+	ScheduleRepo       repositories.ScheduleRepository
+	IdempotencyKeyRepo repositories.IdempotencyKeyRepository
+	WalletService      WalletService
+	RetryInterval      time.Duration
+	MaxAttempts        int
+}
+
+func NewScheduleService(
+	scheduleRepo repositories.ScheduleRepository,
+	idempotencyKeyRepo repositories.IdempotencyKeyRepository,
+	walletService WalletService,
+	retryInterval time.Duration,
+	maxAttempts int,
+) ScheduleService {
+	if retryInterval <= 0 {
+		retryInterval = DefaultScheduleRetryInterval
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultScheduleMaxAttempts
+	}
+	return &scheduleService{
+		ScheduleRepo:       scheduleRepo,
+		IdempotencyKeyRepo: idempotencyKeyRepo,
+		WalletService:      walletService,
+		RetryInterval:      retryInterval,
+		MaxAttempts:        maxAttempts,
+	}
+}
+
+func (s *scheduleService) CreateSchedule(userID string, spec NewSchedule) (*models.Schedule, *APIError) {
+	currency, apiErr := validateAmount(spec.Amount, spec.Currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if spec.ToUserID == "" {
+		return nil, NewBadRequestError("Recipient is required")
+	}
+	if spec.ToUserID == userID {
+		return nil, NewBadRequestError("Cannot transfer to yourself")
+	}
+
+	switch spec.Frequency {
+	case models.ScheduleFrequencyOnce, models.ScheduleFrequencyDaily, models.ScheduleFrequencyWeekly, models.ScheduleFrequencyMonthly:
+	default:
+		return nil, NewBadRequestError("Invalid frequency")
+	}
+
+	onInsufficientFunds := spec.OnInsufficientFunds
+	switch onInsufficientFunds {
+	case "":
+		onInsufficientFunds = models.ScheduleOnInsufficientFundsRetry
+	case models.ScheduleOnInsufficientFundsRetry, models.ScheduleOnInsufficientFundsSkip:
+	default:
+		return nil, NewBadRequestError("Invalid insufficient funds policy")
+	}
+
+	if spec.StartAt.Before(time.Now()) {
+		return nil, NewBadRequestError("Start time must be in the future")
+	}
+	if spec.EndAt != nil && spec.EndAt.Before(spec.StartAt) {
+		return nil, NewBadRequestError("End time must be after the start time")
+	}
+	if spec.MaxOccurrences < 0 {
+		return nil, NewBadRequestError("Invalid maximum number of occurrences")
+	}
+
+	startAt := spec.StartAt.UTC()
+	schedule := &models.Schedule{
+		ID:                  uuid.New().String(),
+		UserID:              userID,
+		ToUserID:            spec.ToUserID,
+		Amount:              spec.Amount,
+		Currency:            currency,
+		Frequency:           spec.Frequency,
+		StartAt:             startAt,
+		EndAt:               spec.EndAt,
+		MaxOccurrences:      spec.MaxOccurrences,
+		OnInsufficientFunds: onInsufficientFunds,
+		NextRunAt:           &startAt,
+		Status:              models.ScheduleStatusActive,
+		CreatedAt:           time.Now(),
+		UpdatedAt:           time.Now(),
+	}
+	if err := s.ScheduleRepo.Create(schedule); err != nil {
+		return nil, NewInternalServerError("Failed to create schedule")
+	}
+	return schedule, nil
+}
+
+func (s *scheduleService) ListSchedules(userID string) ([]models.Schedule, *APIError) {
+	schedules, err := s.ScheduleRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get schedules")
+	}
+	return schedules, nil
+}
+
+func (s *scheduleService) CancelSchedule(userID, scheduleID string) *APIError {
+	return runInTx(s.ScheduleRepo.DB(), func(tx *gorm.DB) *APIError {
+		scheduleRepo := s.ScheduleRepo.WithTx(tx)
+
+		schedule, err := scheduleRepo.FindByIDForUpdate(scheduleID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return NewNotFoundError("Schedule not found")
+			}
+			return NewInternalServerError("Failed to get schedule")
+		}
+		if schedule.UserID != userID {
+			return NewNotFoundError("Schedule not found")
+		}
+		if schedule.Status != models.ScheduleStatusActive {
+			return NewConflictError("Schedule is no longer active")
+		}
+
+		schedule.Status = models.ScheduleStatusCancelled
+		schedule.NextRunAt = nil
+		schedule.UpdatedAt = time.Now()
+		if err := scheduleRepo.Update(schedule); err != nil {
+			return NewInternalServerError("Failed to update schedule")
+		}
+		return nil
+	})
+}
+
+func (s *scheduleService) ListExecutions(userID, scheduleID string) ([]models.ScheduleExecution, *APIError) {
+	schedule, err := s.ScheduleRepo.FindByID(scheduleID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Schedule not found")
+		}
+		return nil, NewInternalServerError("Failed to get schedule")
+	}
+	if schedule.UserID != userID {
+		return nil, NewNotFoundError("Schedule not found")
+	}
+
+	executions, err := s.ScheduleRepo.FindExecutionsByScheduleID(scheduleID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get schedule executions")
+	}
+	return executions, nil
+}
+
+// RunDue attempts the current occurrence of every schedule that is due and
+// returns the number of schedules attempted.
+func (s *scheduleService) RunDue() (int, *APIError) {
+	schedules, err := s.ScheduleRepo.FindDue(time.Now(), runDueBatchSize)
+	if err != nil {
+		return 0, NewInternalServerError("Failed to get due schedules")
+	}
+
+	for i := range schedules {
+		if apiErr := s.runOccurrence(&schedules[i]); apiErr != nil {
+			return i, apiErr
+		}
+	}
+	return len(schedules), nil
+}
+
+// runOccurrence pays a schedule's current occurrence through Transfer. The
+// transfer uses an Idempotency-Key derived from the occurrence, so if the
+// schedule cannot be advanced afterwards (a crash, or a second worker) the
+// next attempt replays the stored transfer instead of paying twice.
+func (s *scheduleService) runOccurrence(schedule *models.Schedule) *APIError {
+	occurrence := schedule.Occurrences
+	key := fmt.Sprintf("schedule:%s:%d", schedule.ID, occurrence)
+	_, transferErr := s.WalletService.Transfer(schedule.UserID, schedule.ToUserID, schedule.Amount, schedule.Currency, Idempotency{Key: key, Fingerprint: key})
+
+	execution := &models.ScheduleExecution{
+		ID:           uuid.New().String(),
+		ScheduleID:   schedule.ID,
+		Occurrence:   occurrence,
+		ScheduledFor: occurrenceAt(schedule.StartAt, schedule.Frequency, occurrence),
+		Attempt:      schedule.Attempts + 1,
+		CreatedAt:    time.Now(),
+	}
+	if transferErr == nil {
+		transactionID, apiErr := transactionIDForKey(s.IdempotencyKeyRepo, schedule.UserID, key)
+		if apiErr != nil {
+			return apiErr
+		}
+		execution.Status = models.ScheduleExecutionStatusSuccess
+		execution.TransactionID = transactionID
+	} else {
+		execution.Error = transferErr.Message
+	}
+
+	return runInTx(s.ScheduleRepo.DB(), func(tx *gorm.DB) *APIError {
+		scheduleRepo := s.ScheduleRepo.WithTx(tx)
+
+		current, err := scheduleRepo.FindByIDForUpdate(schedule.ID)
+		if err != nil {
+			return NewInternalServerError("Failed to get schedule")
+		}
+		// Another worker has already recorded this attempt
+		if current.Occurrences != occurrence || current.Attempts != schedule.Attempts {
+			return nil
+		}
+
+		switch {
+		case transferErr == nil:
+			s.advance(current)
+		case s.shouldRetry(current, transferErr):
+			execution.Status = models.ScheduleExecutionStatusFailed
+			current.Attempts++
+			retryAt := time.Now().Add(s.RetryInterval)
+			current.NextRunAt = &retryAt
+		default:
+			execution.Status = models.ScheduleExecutionStatusSkipped
+			s.advance(current)
+		}
+
+		if err := scheduleRepo.CreateExecution(execution); err != nil {
+			return NewInternalServerError("Failed to record schedule execution")
+		}
+
+		// A schedule cancelled while the transfer ran stays cancelled
+		if current.Status == models.ScheduleStatusCancelled {
+			return nil
+		}
+		current.UpdatedAt = time.Now()
+		if err := scheduleRepo.Update(current); err != nil {
+			return NewInternalServerError("Failed to update schedule")
+		}
+		return nil
+	})
+}
+
+// shouldRetry decides whether a failed occurrence is attempted again. Server
+// errors are always retried; insufficient funds only under the retry policy.
+// Anything else will not go away by waiting, so the occurrence is skipped.
+func (s *scheduleService) shouldRetry(schedule *models.Schedule, apiErr *APIError) bool {
+	if schedule.Attempts+1 >= s.MaxAttempts {
+		return false
+	}
+	if apiErr.Code >= 500 {
+		return true
+	}
+	return IsInsufficientBalance(apiErr) && schedule.OnInsufficientFunds == models.ScheduleOnInsufficientFundsRetry
+}
+
+// advance moves a schedule on to its next occurrence, completing it when
+// there is none.
+func (s *scheduleService) advance(schedule *models.Schedule) {
+	schedule.Occurrences++
+	schedule.Attempts = 0
+
+	if schedule.Frequency == models.ScheduleFrequencyOnce ||
+		(schedule.MaxOccurrences > 0 && schedule.Occurrences >= schedule.MaxOccurrences) {
+		schedule.Status = models.ScheduleStatusCompleted
+		schedule.NextRunAt = nil
+		return
+	}
+
+	next := occurrenceAt(schedule.StartAt, schedule.Frequency, schedule.Occurrences)
+	if schedule.EndAt != nil && next.After(*schedule.EndAt) {
+		schedule.Status = models.ScheduleStatusCompleted
+		schedule.NextRunAt = nil
+		return
+	}
+	schedule.NextRunAt = &next
+}
+
+// transactionIDForKey returns the transaction a stored Idempotency-Key produced.
+func transactionIDForKey(idempotencyKeyRepo repositories.IdempotencyKeyRepository, userID, key string) (string, *APIError) {
+	stored, err := idempotencyKeyRepo.FindByUserIDAndKey(userID, key)
+	if err != nil {
+		return "", NewInternalServerError("Failed to get idempotency key")
+	}
+	var response idempotentResponse
+	if err := json.Unmarshal([]byte(stored.ResponseBody), &response); err != nil {
+		return "", NewInternalServerError("Failed to read stored response")
+	}
+	return response.TransactionID, nil
+}
+
+// occurrenceAt returns the time of the nth occurrence (counting from zero) of
+// a schedule starting at start. Occurrences are computed from the start rather
+// than from each other so they never drift, and are computed in UTC. Monthly
+// schedules starting late in the month run on the last day of shorter months.
+func occurrenceAt(start time.Time, frequency string, n int) time.Time {
+	start = start.UTC()
+	switch frequency {
+	case models.ScheduleFrequencyDaily:
+		return start.AddDate(0, 0, n)
+	case models.ScheduleFrequencyWeekly:
+		return start.AddDate(0, 0, 7*n)
+	case models.ScheduleFrequencyMonthly:
+		month := time.Date(start.Year(), start.Month()+time.Month(n), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+		day := start.Day()
+		if last := month.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		return month.AddDate(0, 0, day-1)
+	}
+	return start
+}
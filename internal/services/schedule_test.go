@@ -0,0 +1,226 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stubWalletService records transfers made through it and answers them with TransferFunc
+type stubWalletService struct {
+	WalletService
+	TransferFunc func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError)
+}
+
+func (s *stubWalletService) Transfer(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+	return s.TransferFunc(fromUserID, toUserID, amount, currency, idem)
+}
+
+func TestOccurrenceAt(t *testing.T) {
+	start := time.Date(2025, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, start, occurrenceAt(start, models.ScheduleFrequencyMonthly, 0))
+	assert.Equal(t, time.Date(2025, time.February, 28, 9, 0, 0, 0, time.UTC), occurrenceAt(start, models.ScheduleFrequencyMonthly, 1))
+	assert.Equal(t, time.Date(2025, time.March, 31, 9, 0, 0, 0, time.UTC), occurrenceAt(start, models.ScheduleFrequencyMonthly, 2))
+	assert.Equal(t, time.Date(2026, time.February, 28, 9, 0, 0, 0, time.UTC), occurrenceAt(start, models.ScheduleFrequencyMonthly, 13))
+	assert.Equal(t, time.Date(2025, time.February, 14, 9, 0, 0, 0, time.UTC), occurrenceAt(start, models.ScheduleFrequencyWeekly, 2))
+	assert.Equal(t, time.Date(2025, time.February, 1, 9, 0, 0, 0, time.UTC), occurrenceAt(start, models.ScheduleFrequencyDaily, 1))
+}
+
+func TestScheduleService_CreateSchedule(t *testing.T) {
+	service := NewScheduleService(&repositories.MockScheduleRepository{}, &repositories.MockIdempotencyKeyRepository{}, &stubWalletService{}, 0, 0)
+
+	t.Run("starts at the first occurrence", func(t *testing.T) {
+		startAt := time.Now().Add(time.Hour)
+
+		schedule, apiErr := service.CreateSchedule("user123", NewSchedule{
+			ToUserID:  "user456",
+			Amount:    money.FromMajor(1200),
+			Frequency: models.ScheduleFrequencyMonthly,
+			StartAt:   startAt,
+		})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, "USD", schedule.Currency)
+		assert.Equal(t, models.ScheduleStatusActive, schedule.Status)
+		assert.Equal(t, models.ScheduleOnInsufficientFundsRetry, schedule.OnInsufficientFunds)
+		if assert.NotNil(t, schedule.NextRunAt) {
+			assert.True(t, startAt.Equal(*schedule.NextRunAt))
+		}
+	})
+
+	t.Run("rejects invalid schedules", func(t *testing.T) {
+		valid := NewSchedule{ToUserID: "user456", Amount: money.FromMajor(10), Frequency: models.ScheduleFrequencyDaily, StartAt: time.Now().Add(time.Hour)}
+
+		past := valid
+		past.StartAt = time.Now().Add(-time.Hour)
+		frequency := valid
+		frequency.Frequency = "yearly"
+		self := valid
+		self.ToUserID = "user123"
+		policy := valid
+		policy.OnInsufficientFunds = "ignore"
+
+		for _, spec := range []NewSchedule{past, frequency, self, policy} {
+			_, apiErr := service.CreateSchedule("user123", spec)
+			assert.NotNil(t, apiErr)
+		}
+	})
+}
+
+func TestScheduleService_RunDue(t *testing.T) {
+	type runEnv struct {
+		*testEnv
+		scheduleRepo *repositories.MockScheduleRepository
+		wallet       *stubWalletService
+		service      ScheduleService
+		schedule     *models.Schedule
+		executions   []models.ScheduleExecution
+	}
+	newRunEnv := func(t *testing.T, schedule *models.Schedule) *runEnv {
+		env := &runEnv{testEnv: newTestEnv(t), schedule: schedule}
+		env.scheduleRepo = &repositories.MockScheduleRepository{
+			DBFunc: env.walletRepo.DB,
+			FindDueFunc: func(now time.Time, limit int) ([]models.Schedule, error) {
+				return []models.Schedule{*schedule}, nil
+			},
+			FindByIDForUpdateFunc: func(id string) (*models.Schedule, error) {
+				return schedule, nil
+			},
+			CreateExecutionFunc: func(execution *models.ScheduleExecution) error {
+				env.executions = append(env.executions, *execution)
+				return nil
+			},
+		}
+		env.idempotencyKeyRepo.FindByUserIDAndKeyFunc = func(userID, key string) (*models.IdempotencyKey, error) {
+			return &models.IdempotencyKey{UserID: userID, Key: key, ResponseBody: `{"balance":"0.00","transaction_id":"tx-` + key + `"}`}, nil
+		}
+		env.wallet = &stubWalletService{}
+		env.service = NewScheduleService(env.scheduleRepo, env.idempotencyKeyRepo, env.wallet, time.Hour, 2)
+		return env
+	}
+	newSchedule := func() *models.Schedule {
+		startAt := time.Date(2025, time.January, 1, 9, 0, 0, 0, time.UTC)
+		return &models.Schedule{
+			ID:                  "schedule1",
+			UserID:              "user123",
+			ToUserID:            "user456",
+			Amount:              money.FromMajor(1200),
+			Currency:            "USD",
+			Frequency:           models.ScheduleFrequencyMonthly,
+			StartAt:             startAt,
+			OnInsufficientFunds: models.ScheduleOnInsufficientFundsRetry,
+			NextRunAt:           &startAt,
+			Status:              models.ScheduleStatusActive,
+		}
+	}
+
+	t.Run("pays the occurrence and moves to the next", func(t *testing.T) {
+		env := newRunEnv(t, newSchedule())
+		defer env.db.Close()
+
+		var idem Idempotency
+		env.wallet.TransferFunc = func(fromUserID, toUserID string, amount money.Amount, currency string, i Idempotency) (money.Amount, *APIError) {
+			idem = i
+			assert.Equal(t, "user123", fromUserID)
+			assert.Equal(t, "user456", toUserID)
+			assert.Equal(t, money.FromMajor(1200), amount)
+			return 0, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		count, apiErr := env.service.RunDue()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, "schedule:schedule1:0", idem.Key)
+		if assert.Len(t, env.executions, 1) {
+			assert.Equal(t, models.ScheduleExecutionStatusSuccess, env.executions[0].Status)
+			assert.Equal(t, "tx-schedule:schedule1:0", env.executions[0].TransactionID)
+		}
+		assert.Equal(t, 1, env.schedule.Occurrences)
+		assert.Equal(t, time.Date(2025, time.February, 1, 9, 0, 0, 0, time.UTC), *env.schedule.NextRunAt)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("retries on insufficient funds, then skips", func(t *testing.T) {
+		env := newRunEnv(t, newSchedule())
+		defer env.db.Close()
+
+		env.wallet.TransferFunc = func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+			return 0, NewInsufficientBalanceError()
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.RunDue()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.ScheduleExecutionStatusFailed, env.executions[0].Status)
+		assert.Equal(t, 0, env.schedule.Occurrences)
+		assert.Equal(t, 1, env.schedule.Attempts)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), *env.schedule.NextRunAt, time.Second)
+
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr = env.service.RunDue()
+
+		assert.Nil(t, apiErr)
+		if assert.Len(t, env.executions, 2) {
+			assert.Equal(t, models.ScheduleExecutionStatusSkipped, env.executions[1].Status)
+			assert.Equal(t, 2, env.executions[1].Attempt)
+		}
+		assert.Equal(t, 1, env.schedule.Occurrences)
+		assert.Equal(t, 0, env.schedule.Attempts)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("skip policy skips at once", func(t *testing.T) {
+		schedule := newSchedule()
+		schedule.OnInsufficientFunds = models.ScheduleOnInsufficientFundsSkip
+		env := newRunEnv(t, schedule)
+		defer env.db.Close()
+
+		env.wallet.TransferFunc = func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+			return 0, NewInsufficientBalanceError()
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.RunDue()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.ScheduleExecutionStatusSkipped, env.executions[0].Status)
+		assert.Equal(t, 1, env.schedule.Occurrences)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("completes after the last occurrence", func(t *testing.T) {
+		schedule := newSchedule()
+		schedule.MaxOccurrences = 2
+		schedule.Occurrences = 1
+		env := newRunEnv(t, schedule)
+		defer env.db.Close()
+
+		env.wallet.TransferFunc = func(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+			assert.Equal(t, "schedule:schedule1:1", idem.Key)
+			return 0, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.RunDue()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.ScheduleStatusCompleted, env.schedule.Status)
+		assert.Nil(t, env.schedule.NextRunAt)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// statementPeriodLayout is how statement periods are written: UTC months.
+const statementPeriodLayout = "2006-01"
+
+// statementBatchSize is how many wallets are loaded at a time while
+// generating month-end statements.
+const statementBatchSize = 500
+
+type statementService struct {
+	StatementRepo   repositories.StatementRepository
+	WalletRepo      repositories.WalletRepository
+	TransactionRepo repositories.TransactionRepository
+	LedgerRepo      repositories.LedgerRepository
+}
+
+func NewStatementService(
+	statementRepo repositories.StatementRepository,
+	walletRepo repositories.WalletRepository,
+	transactionRepo repositories.TransactionRepository,
+	ledgerRepo repositories.LedgerRepository,
+) StatementService {
+	return &statementService{
+		StatementRepo:   statementRepo,
+		WalletRepo:      walletRepo,
+		TransactionRepo: transactionRepo,
+		LedgerRepo:      ledgerRepo,
+	}
+}
+
+// GetStatement returns a user's statement for a month, given as YYYY-MM. A
+// month that has ended is generated once and stored; the current month's
+// statement is provisional and generated afresh each time.
+func (s *statementService) GetStatement(userID, currency, period string) (*models.Statement, *APIError) {
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	start, err := time.Parse(statementPeriodLayout, period)
+	if err != nil {
+		return nil, NewBadRequestError("Invalid period")
+	}
+	end := start.AddDate(0, 1, 0)
+	if start.After(time.Now()) {
+		return nil, NewBadRequestError("Period has not started")
+	}
+	// The opening balance must be known
+	if apiErr := checkLedgerHistory(s.LedgerRepo, start); apiErr != nil {
+		return nil, apiErr
+	}
+
+	statement, apiErr := s.findStatement(userID, currency, period)
+	if apiErr != nil || statement != nil {
+		return statement, apiErr
+	}
+
+	wallet, err := s.WalletRepo.FindByUserID(userID, currency)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("No " + currency + " wallet")
+		}
+		return nil, NewInternalServerError("Failed to get wallet")
+	}
+	if !wallet.CreatedAt.Before(end) {
+		return nil, NewNotFoundError("No statement for " + period)
+	}
+
+	statement, apiErr = s.generate(wallet, start, end)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !statementSettled(end) {
+		statement.Provisional = true
+		return statement, nil
+	}
+
+	created, err := s.StatementRepo.Create(statement)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to store statement")
+	}
+	if !created {
+		// Generated concurrently; the stored statement is the one of record
+		return s.findStatement(userID, currency, period)
+	}
+	return statement, nil
+}
+
+// ListStatements returns the user's stored statements without their lines.
+func (s *statementService) ListStatements(userID string) ([]models.Statement, *APIError) {
+	statements, err := s.StatementRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get statements")
+	}
+	return statements, nil
+}
+
+// GenerateMonthlyStatements stores the statement of every wallet for the
+// month that last ended, once it has settled. A month is only generated once;
+// wallets opened after it or closed before it get no statement. It returns
+// how many statements it stored.
+func (s *statementService) GenerateMonthlyStatements() (int, *APIError) {
+	settled := time.Now().UTC().Add(-checkpointSettleTime)
+	end := time.Date(settled.Year(), settled.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start := end.AddDate(0, -1, 0)
+	period := start.Format(statementPeriodLayout)
+
+	if _, err := s.StatementRepo.FindRun(period); err == nil {
+		return 0, nil
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, NewInternalServerError("Failed to get statement run")
+	}
+
+	run := &models.StatementRun{Period: period, CompletedAt: time.Now()}
+	// A month that began before the ledger has no known opening balance, so
+	// it is recorded as done without statements
+	if apiErr := checkLedgerHistory(s.LedgerRepo, start); apiErr != nil {
+		if apiErr.Code != http.StatusBadRequest {
+			return 0, apiErr
+		}
+		if err := s.StatementRepo.CreateRun(run); err != nil {
+			return 0, NewInternalServerError("Failed to record statement run")
+		}
+		return 0, nil
+	}
+
+	generated := 0
+	afterID := ""
+	for {
+		wallets, err := s.WalletRepo.FindAll(afterID, statementBatchSize)
+		if err != nil {
+			return generated, NewInternalServerError("Failed to get wallets")
+		}
+
+		for i := range wallets {
+			wallet := &wallets[i]
+			if !wallet.CreatedAt.Before(end) || (wallet.ClosedAt != nil && wallet.ClosedAt.Before(start)) {
+				continue
+			}
+
+			statement, apiErr := s.generate(wallet, start, end)
+			if apiErr != nil {
+				return generated, apiErr
+			}
+			created, err := s.StatementRepo.Create(statement)
+			if err != nil {
+				return generated, NewInternalServerError("Failed to store statement")
+			}
+			if created {
+				generated++
+			}
+		}
+
+		if len(wallets) < statementBatchSize {
+			break
+		}
+		afterID = wallets[len(wallets)-1].ID
+	}
+
+	run.CompletedAt = time.Now()
+	if err := s.StatementRepo.CreateRun(run); err != nil {
+		return generated, NewInternalServerError("Failed to record statement run")
+	}
+	return generated, nil
+}
+
+// findStatement returns a stored statement with its totals, or nil if there
+// is none.
+func (s *statementService) findStatement(userID, currency, period string) (*models.Statement, *APIError) {
+	statement, err := s.StatementRepo.FindByPeriod(userID, currency, period)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, NewInternalServerError("Failed to get statement")
+	}
+	statement.Totals = statementTotals(statement.Lines)
+	return statement, nil
+}
+
+// generate builds a wallet's statement from its ledger postings between start
+// and end. Once the period has settled, the closing balance is checked
+// against the ledger.
+func (s *statementService) generate(wallet *models.Wallet, start, end time.Time) (*models.Statement, *APIError) {
+	account := models.WalletLedgerAccount(wallet.ID)
+
+	opening, err := s.LedgerRepo.BalanceAsOf(account, start)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get ledger balance")
+	}
+	postings, err := s.LedgerRepo.FindPostingsByAccount(account, start, end)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get ledger postings")
+	}
+
+	ids := make([]string, 0, len(postings))
+	seen := make(map[string]bool, len(postings))
+	for _, posting := range postings {
+		if posting.TransactionID != "" && !seen[posting.TransactionID] {
+			seen[posting.TransactionID] = true
+			ids = append(ids, posting.TransactionID)
+		}
+	}
+	transactions, err := s.TransactionRepo.FindByIDs(ids)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get transactions")
+	}
+	byID := make(map[string]*models.Transaction, len(transactions))
+	for i := range transactions {
+		byID[transactions[i].ID] = &transactions[i]
+	}
+
+	statement := &models.Statement{
+		ID:             uuid.New().String(),
+		UserID:         wallet.UserID,
+		WalletID:       wallet.ID,
+		Currency:       wallet.Currency,
+		Period:         start.Format(statementPeriodLayout),
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		OpeningBalance: opening,
+		Lines:          make([]models.StatementLine, 0, len(postings)),
+		CreatedAt:      time.Now(),
+	}
+
+	balance := opening
+	for i, posting := range postings {
+		balance += posting.Amount
+		line := models.StatementLine{
+			ID:            uuid.New().String(),
+			StatementID:   statement.ID,
+			Position:      i + 1,
+			Date:          posting.CreatedAt,
+			TransactionID: posting.TransactionID,
+			Type:          models.StatementLineTypeAdjustment,
+			Amount:        posting.Amount,
+			Balance:       balance,
+		}
+		if transaction, ok := byID[posting.TransactionID]; ok {
+			line.Type = transaction.Type
+			line.Counterparty = statementCounterparty(transaction, wallet.UserID)
+		}
+		statement.Lines = append(statement.Lines, line)
+	}
+	statement.ClosingBalance = balance
+	statement.Totals = statementTotals(statement.Lines)
+
+	if statementSettled(end) {
+		closing, err := s.LedgerRepo.BalanceAsOf(account, end)
+		if err != nil {
+			return nil, NewInternalServerError("Failed to get ledger balance")
+		}
+		if closing != statement.ClosingBalance {
+			return nil, NewInternalServerError("Statement does not match ledger")
+		}
+	}
+	return statement, nil
+}
+
+// statementSettled reports whether a period ending at end is over and has
+// had time for in-flight transactions to commit.
+func statementSettled(end time.Time) bool {
+	return !end.Add(checkpointSettleTime).After(time.Now())
+}
+
+// statementCounterparty returns the other user of a transaction, if any.
+func statementCounterparty(transaction *models.Transaction, userID string) string {
+	if transaction.FromUserID != userID {
+		return transaction.FromUserID
+	}
+	if transaction.ToUserID != userID {
+		return transaction.ToUserID
+	}
+	return ""
+}
+
+// statementTotals adds up a statement's lines by transaction type.
+func statementTotals(lines []models.StatementLine) []models.StatementTotal {
+	byType := make(map[string]*models.StatementTotal)
+	for _, line := range lines {
+		total, ok := byType[line.Type]
+		if !ok {
+			total = &models.StatementTotal{Type: line.Type}
+			byType[line.Type] = total
+		}
+		total.Count++
+		if line.Amount > 0 {
+			total.Credits += line.Amount
+		} else {
+			total.Debits -= line.Amount
+		}
+	}
+
+	totals := make([]models.StatementTotal, 0, len(byType))
+	for _, total := range byType {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Type < totals[j].Type })
+	return totals
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStatementService(env *testEnv, statementRepo *repositories.MockStatementRepository) StatementService {
+	return NewStatementService(statementRepo, env.walletRepo, env.transactionRepo, env.ledgerRepo)
+}
+
+func TestStatementService_GetStatement(t *testing.T) {
+	start := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	t.Run("lists every movement with a running balance and stores the statement", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		statementRepo := &repositories.MockStatementRepository{}
+		service := newTestStatementService(env, statementRepo)
+
+		env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+		env.ledgerRepo.BalanceAsOfFunc = func(accountCode string, at time.Time) (money.Amount, error) {
+			if at.Equal(start) {
+				return money.FromMajor(100), nil
+			}
+			assert.Equal(t, end, at)
+			return money.FromMajor(139), nil
+		}
+		env.ledgerRepo.FindPostingsByAccountFunc = func(accountCode string, from, to time.Time) ([]models.LedgerPosting, error) {
+			assert.Equal(t, "wallets/wallet1", accountCode)
+			return []models.LedgerPosting{
+				{TransactionID: "tx1", Amount: money.FromMajor(50), CreatedAt: start.Add(time.Hour)},
+				{TransactionID: "tx2", Amount: -money.FromMajor(10), CreatedAt: start.Add(2 * time.Hour)},
+				{TransactionID: "tx3", Amount: -money.FromMajor(1), CreatedAt: start.Add(2 * time.Hour)},
+			}, nil
+		}
+		env.transactionRepo.FindByIDsFunc = func(ids []string) ([]models.Transaction, error) {
+			assert.Equal(t, []string{"tx1", "tx2", "tx3"}, ids)
+			return []models.Transaction{
+				{ID: "tx1", FromUserID: "user123", Type: models.TransactionTypeDeposit},
+				{ID: "tx2", FromUserID: "user123", ToUserID: "user456", Type: models.TransactionTypeTransfer},
+				{ID: "tx3", FromUserID: "user123", Type: models.TransactionTypeFee},
+			}, nil
+		}
+		var stored *models.Statement
+		statementRepo.CreateFunc = func(statement *models.Statement) (bool, error) {
+			stored = statement
+			return true, nil
+		}
+
+		statement, apiErr := service.GetStatement("user123", "USD", "2025-03")
+
+		assert.Nil(t, apiErr)
+		assert.Same(t, stored, statement)
+		assert.False(t, statement.Provisional)
+		assert.Equal(t, money.FromMajor(100), statement.OpeningBalance)
+		assert.Equal(t, money.FromMajor(139), statement.ClosingBalance)
+		if assert.Len(t, statement.Lines, 3) {
+			assert.Equal(t, money.FromMajor(150), statement.Lines[0].Balance)
+			assert.Equal(t, "user456", statement.Lines[1].Counterparty)
+			assert.Equal(t, money.FromMajor(140), statement.Lines[1].Balance)
+			assert.Equal(t, money.FromMajor(139), statement.Lines[2].Balance)
+			assert.Equal(t, 3, statement.Lines[2].Position)
+		}
+		assert.Equal(t, []models.StatementTotal{
+			{Type: models.TransactionTypeDeposit, Count: 1, Credits: money.FromMajor(50)},
+			{Type: models.TransactionTypeFee, Count: 1, Debits: money.FromMajor(1)},
+			{Type: models.TransactionTypeTransfer, Count: 1, Debits: money.FromMajor(10)},
+		}, statement.Totals)
+	})
+
+	t.Run("returns the stored statement of an ended month", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		statementRepo := &repositories.MockStatementRepository{}
+		service := newTestStatementService(env, statementRepo)
+
+		statementRepo.FindByPeriodFunc = func(userID, currency, period string) (*models.Statement, error) {
+			return &models.Statement{Period: period, Lines: []models.StatementLine{
+				{Type: models.TransactionTypeDeposit, Amount: money.FromMajor(5)},
+			}}, nil
+		}
+		env.ledgerRepo.FindPostingsByAccountFunc = func(accountCode string, from, to time.Time) ([]models.LedgerPosting, error) {
+			t.Fatal("a stored statement must not be generated again")
+			return nil, nil
+		}
+
+		statement, apiErr := service.GetStatement("user123", "USD", "2025-03")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, []models.StatementTotal{{Type: models.TransactionTypeDeposit, Count: 1, Credits: money.FromMajor(5)}}, statement.Totals)
+	})
+
+	t.Run("the current month is provisional and not stored", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		statementRepo := &repositories.MockStatementRepository{}
+		service := newTestStatementService(env, statementRepo)
+
+		env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+		statementRepo.CreateFunc = func(statement *models.Statement) (bool, error) {
+			t.Fatal("a provisional statement must not be stored")
+			return false, nil
+		}
+
+		statement, apiErr := service.GetStatement("user123", "USD", time.Now().UTC().Format("2006-01"))
+
+		assert.Nil(t, apiErr)
+		assert.True(t, statement.Provisional)
+	})
+
+	t.Run("rejects a closing balance that disagrees with the ledger", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		service := newTestStatementService(env, &repositories.MockStatementRepository{})
+
+		env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+		env.ledgerRepo.BalanceAsOfFunc = func(accountCode string, at time.Time) (money.Amount, error) {
+			if at.Equal(start) {
+				return 0, nil
+			}
+			return money.FromMajor(1), nil
+		}
+
+		_, apiErr := service.GetStatement("user123", "USD", "2025-03")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusInternalServerError, apiErr.Code)
+		}
+	})
+
+	t.Run("rejects invalid and future periods", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		service := newTestStatementService(env, &repositories.MockStatementRepository{})
+
+		_, apiErr := service.GetStatement("user123", "USD", "March 2025")
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Invalid period", apiErr.Message)
+		}
+
+		_, apiErr = service.GetStatement("user123", "USD", time.Now().AddDate(0, 2, 0).Format("2006-01"))
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Period has not started", apiErr.Message)
+		}
+
+		env.ledgerRepo.LedgerStartFunc = func() (time.Time, error) {
+			return time.Date(2025, time.March, 15, 0, 0, 0, 0, time.UTC), nil
+		}
+		_, apiErr = service.GetStatement("user123", "USD", "2025-03")
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+		}
+	})
+}
+
+func TestStatementService_GenerateMonthlyStatements(t *testing.T) {
+	t.Run("generates the last month once for wallets open during it", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		statementRepo := &repositories.MockStatementRepository{}
+		service := newTestStatementService(env, statementRepo)
+
+		settled := time.Now().UTC().Add(-checkpointSettleTime)
+		end := time.Date(settled.Year(), settled.Month(), 1, 0, 0, 0, 0, time.UTC)
+		closedBefore := end.AddDate(0, -2, 0)
+		env.walletRepo.FindAllFunc = func(afterID string, limit int) ([]models.Wallet, error) {
+			return []models.Wallet{
+				{ID: "wallet1", UserID: "user1", Currency: "USD", CreatedAt: end.AddDate(0, -3, 0)},
+				{ID: "wallet2", UserID: "user2", Currency: "USD", CreatedAt: end.Add(time.Minute)},
+				{ID: "wallet3", UserID: "user3", Currency: "USD", CreatedAt: end.AddDate(0, -3, 0), ClosedAt: &closedBefore},
+			}, nil
+		}
+		var stored []string
+		statementRepo.CreateFunc = func(statement *models.Statement) (bool, error) {
+			stored = append(stored, statement.WalletID)
+			return true, nil
+		}
+		var run *models.StatementRun
+		statementRepo.CreateRunFunc = func(r *models.StatementRun) error {
+			run = r
+			return nil
+		}
+
+		generated, apiErr := service.GenerateMonthlyStatements()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 1, generated)
+		assert.Equal(t, []string{"wallet1"}, stored)
+		if assert.NotNil(t, run) {
+			assert.Equal(t, end.AddDate(0, -1, 0).Format("2006-01"), run.Period)
+		}
+
+		statementRepo.FindRunFunc = func(period string) (*models.StatementRun, error) {
+			return run, nil
+		}
+		generated, apiErr = service.GenerateMonthlyStatements()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 0, generated)
+		assert.Len(t, stored, 1)
+	})
+}
+
+func TestStatementService_GenerateMonthlyStatements_BeforeLedger(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+	statementRepo := &repositories.MockStatementRepository{}
+	service := newTestStatementService(env, statementRepo)
+
+	env.ledgerRepo.LedgerStartFunc = func() (time.Time, error) {
+		return time.Now().AddDate(0, 0, -1), nil
+	}
+	env.walletRepo.FindAllFunc = func(afterID string, limit int) ([]models.Wallet, error) {
+		t.Fatal("a month that began before the ledger must not be generated")
+		return nil, nil
+	}
+	var run *models.StatementRun
+	statementRepo.CreateRunFunc = func(r *models.StatementRun) error {
+		run = r
+		return nil
+	}
+
+	generated, apiErr := service.GenerateMonthlyStatements()
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, 0, generated)
+	assert.NotNil(t, run)
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/pubsub"
+	"wallet/internal/repositories"
+
+	"gorm.io/gorm"
+)
+
+// StreamChannel is the pub/sub channel on which the ID of a user is
+// published once a change to their balance or transactions has committed.
+const StreamChannel = "wallet_user_changes"
+
+// StreamHeartbeatInterval is how often an idle stream sends a comment to
+// keep the connection open. Each heartbeat also checks for changes, so a
+// lost notification delays an event rather than losing it.
+const StreamHeartbeatInterval = 15 * time.Second
+
+// streamBatchSize is how many transaction log entries are read at a time.
+const streamBatchSize = 100
+
+type streamService struct {
+	WalletService      WalletService
+	TransactionRepo    repositories.TransactionRepository
+	TransactionLogRepo repositories.TransactionLogRepository
+	PubSub             pubsub.PubSub
+
+	mu      sync.Mutex
+	streams map[string]map[*Stream]struct{}
+}
+
+func NewStreamService(
+	walletService WalletService,
+	transactionRepo repositories.TransactionRepository,
+	transactionLogRepo repositories.TransactionLogRepository,
+	pubSub pubsub.PubSub,
+) StreamService {
+	return &streamService{
+		WalletService:      walletService,
+		TransactionRepo:    transactionRepo,
+		TransactionLogRepo: transactionLogRepo,
+		PubSub:             pubSub,
+		streams:            make(map[string]map[*Stream]struct{}),
+	}
+}
+
+// notifyChanged tells the open streams of each user that their balance or
+// transactions changed. It is called once the change has committed; a
+// failure to publish is only a delay, as streams also check on every
+// heartbeat.
+func notifyChanged(pubSub pubsub.PubSub, userIDs ...string) {
+	for _, userID := range userIDs {
+		if userID != "" {
+			pubSub.Publish(StreamChannel, userID)
+		}
+	}
+}
+
+// changed invalidates the cached history of users whose balance or
+// transactions changed and notifies their streams.
+func (s *walletService) changed(userIDs ...string) {
+	for _, userID := range userIDs {
+		if userID != "" {
+			s.Cache.Delete(userID)
+		}
+	}
+	notifyChanged(s.PubSub, userIDs...)
+}
+
+// Run wakes up the open streams of every user whose change is published on
+// StreamChannel, by this or any other instance of the service. It returns
+// when the subscription fails or ends.
+func (s *streamService) Run() error {
+	subscription, err := s.PubSub.Subscribe(StreamChannel)
+	if err != nil {
+		return err
+	}
+	defer subscription.Close()
+
+	for userID := range subscription.Messages() {
+		s.mu.Lock()
+		for stream := range s.streams[userID] {
+			stream.wake()
+		}
+		s.mu.Unlock()
+	}
+	return errors.New("stream subscription ended")
+}
+
+// Open opens a stream of the user's balance changes and transactions. With
+// lastEventID, the ID of the last event the client received, the stream
+// first replays the transactions the client missed; without it, the stream
+// starts from now. Either way the first poll includes the current balances.
+func (s *streamService) Open(userID, lastEventID string) (*Stream, *APIError) {
+	stream := &Stream{
+		service: s,
+		userID:  userID,
+		wakeup:  make(chan struct{}, 1),
+	}
+
+	// Register before reading the head, so that a change committed in
+	// between wakes the stream rather than going unnoticed
+	s.mu.Lock()
+	if s.streams[userID] == nil {
+		s.streams[userID] = make(map[*Stream]struct{})
+	}
+	s.streams[userID][stream] = struct{}{}
+	s.mu.Unlock()
+
+	head, apiErr := s.head()
+	if apiErr != nil {
+		stream.Close()
+		return nil, apiErr
+	}
+	stream.cursor = head
+	if lastEventID != "" {
+		sequence, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || sequence < 0 {
+			stream.Close()
+			return nil, NewBadRequestError("Invalid Last-Event-ID")
+		}
+		stream.cursor = min(sequence, head)
+	}
+	return stream, nil
+}
+
+// head returns the sequence of the latest transaction log entry. Every entry
+// up to it has committed.
+func (s *streamService) head() (int64, *APIError) {
+	head, err := s.TransactionLogRepo.Head()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, NewInternalServerError("Failed to get transaction log head")
+	}
+	return head.Sequence, nil
+}
+
+// Stream is one client's stream of a user's changes. Its position is a
+// transaction log sequence: transactions are read in the order their changes
+// committed, so a stream never skips one that commits after it has moved on.
+type Stream struct {
+	service  *streamService
+	userID   string
+	cursor   int64
+	balances []models.Balance
+	wakeup   chan struct{}
+}
+
+// Wake signals that the user's balance or transactions may have changed
+// since the last poll.
+func (st *Stream) Wake() <-chan struct{} {
+	return st.wakeup
+}
+
+func (st *Stream) wake() {
+	select {
+	case st.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Close stops the stream from being woken up.
+func (st *Stream) Close() {
+	s := st.service
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.streams[st.userID], st)
+	if len(s.streams[st.userID]) == 0 {
+		delete(s.streams, st.userID)
+	}
+}
+
+// Poll returns the events since the last poll: a transaction event for each
+// change to one of the user's transactions, then a balance event if any of
+// the user's balances differ from the ones last sent. At most one batch of
+// transactions is returned at a time, so that a client far behind is sent
+// its replay as it is read rather than all at once; until the stream has
+// caught up, it wakes itself to be polled again and leaves out the balances.
+func (st *Stream) Poll() ([]models.StreamEvent, *APIError) {
+	s := st.service
+	head, apiErr := s.head()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	var events []models.StreamEvent
+	if st.cursor < head {
+		entries, err := s.TransactionLogRepo.FindEntriesByUserID(st.userID, st.cursor, head, streamBatchSize)
+		if err != nil {
+			return nil, NewInternalServerError("Failed to get transaction log entries")
+		}
+		transactions, apiErr := st.transactionsOf(entries)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+
+		for _, entry := range entries {
+			// A transaction removed since its entry was read is skipped
+			if transaction, ok := transactions[entry.TransactionID]; ok {
+				events = append(events, models.StreamEvent{
+					ID:   strconv.FormatInt(entry.Sequence, 10),
+					Type: models.StreamEventTransaction,
+					Data: transaction,
+				})
+			}
+			st.cursor = entry.Sequence
+		}
+		if len(entries) < streamBatchSize {
+			st.cursor = head
+		}
+		if st.cursor < head {
+			st.wake()
+			return events, nil
+		}
+	}
+
+	balances, apiErr := s.WalletService.GetBalances(st.userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if st.balances == nil || !slices.Equal(balances, st.balances) {
+		st.balances = balances
+		events = append(events, models.StreamEvent{
+			Type: models.StreamEventBalance,
+			Data: balances,
+		})
+	}
+	return events, nil
+}
+
+func (st *Stream) transactionsOf(entries []models.TransactionLogEntry) (map[string]*models.Transaction, *APIError) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !slices.Contains(ids, entry.TransactionID) {
+			ids = append(ids, entry.TransactionID)
+		}
+	}
+	found, err := st.service.TransactionRepo.FindByIDs(ids)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get transactions")
+	}
+	transactions := make(map[string]*models.Transaction, len(found))
+	for i := range found {
+		transactions[found[i].ID] = &found[i]
+	}
+	return transactions, nil
+}
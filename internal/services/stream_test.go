@@ -0,0 +1,223 @@
+package services
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStreamService(env *testEnv, logRepo *repositories.MockTransactionLogRepository) *streamService {
+	return NewStreamService(env.service, env.transactionRepo, logRepo, env.pubSub).(*streamService)
+}
+
+func TestStreamService_Poll(t *testing.T) {
+	t.Run("replays the transactions after the last event, then the balances", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		logRepo := &repositories.MockTransactionLogRepository{}
+		service := newTestStreamService(env, logRepo)
+
+		head := int64(8)
+		logRepo.HeadFunc = func() (*models.TransactionLogHead, error) {
+			return &models.TransactionLogHead{ID: models.TransactionLogHeadID, Sequence: head}, nil
+		}
+		var reads [][2]int64
+		logRepo.FindEntriesByUserIDFunc = func(userID string, after, until int64, limit int) ([]models.TransactionLogEntry, error) {
+			assert.Equal(t, "user123", userID)
+			reads = append(reads, [2]int64{after, until})
+			if after >= 6 {
+				return nil, nil
+			}
+			return []models.TransactionLogEntry{
+				{Sequence: 6, TransactionID: "tx1"},
+				{Sequence: 8, TransactionID: "tx2"},
+			}, nil
+		}
+		env.transactionRepo.FindByIDsFunc = func(ids []string) ([]models.Transaction, error) {
+			assert.Equal(t, []string{"tx1", "tx2"}, ids)
+			return []models.Transaction{{ID: "tx1"}, {ID: "tx2"}}, nil
+		}
+		balance := money.FromMajor(10)
+		env.walletRepo.ListByUserIDFunc = func(userID string) ([]models.Wallet, error) {
+			return []models.Wallet{{ID: "wallet1", UserID: userID, Currency: "USD", Balance: balance}}, nil
+		}
+
+		stream, apiErr := service.Open("user123", "5")
+		if !assert.Nil(t, apiErr) {
+			return
+		}
+		defer stream.Close()
+
+		events, apiErr := stream.Poll()
+
+		assert.Nil(t, apiErr)
+		if assert.Len(t, events, 3) {
+			assert.Equal(t, models.StreamEvent{ID: "6", Type: models.StreamEventTransaction, Data: &models.Transaction{ID: "tx1"}}, events[0])
+			assert.Equal(t, "8", events[1].ID)
+			assert.Equal(t, models.StreamEventBalance, events[2].Type)
+			assert.Empty(t, events[2].ID)
+		}
+
+		// Nothing changed
+		events, apiErr = stream.Poll()
+		assert.Nil(t, apiErr)
+		assert.Empty(t, events)
+		assert.Equal(t, [][2]int64{{5, 8}}, reads)
+
+		// Only the balance changed, e.g. by a hold
+		balance = money.FromMajor(4)
+		events, apiErr = stream.Poll()
+		assert.Nil(t, apiErr)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, []models.Balance{{Currency: "USD", Status: models.WalletStatusActive, LedgerBalance: balance, Available: balance}}, events[0].Data)
+		}
+
+		// Other users' entries are skipped over
+		head = 12
+		events, apiErr = stream.Poll()
+		assert.Nil(t, apiErr)
+		assert.Empty(t, events)
+		assert.Equal(t, [2]int64{8, 12}, reads[len(reads)-1])
+	})
+
+	t.Run("replays a long history one batch at a time", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		logRepo := &repositories.MockTransactionLogRepository{}
+		service := newTestStreamService(env, logRepo)
+
+		logRepo.HeadFunc = func() (*models.TransactionLogHead, error) {
+			return &models.TransactionLogHead{ID: models.TransactionLogHeadID, Sequence: 1000}, nil
+		}
+		logRepo.FindEntriesByUserIDFunc = func(userID string, after, until int64, limit int) ([]models.TransactionLogEntry, error) {
+			var entries []models.TransactionLogEntry
+			for sequence := after + 1; sequence <= until && len(entries) < limit && sequence <= 150; sequence++ {
+				entries = append(entries, models.TransactionLogEntry{Sequence: sequence, TransactionID: strconv.FormatInt(sequence, 10)})
+			}
+			return entries, nil
+		}
+		env.transactionRepo.FindByIDsFunc = func(ids []string) ([]models.Transaction, error) {
+			transactions := make([]models.Transaction, len(ids))
+			for i, id := range ids {
+				transactions[i] = models.Transaction{ID: id}
+			}
+			return transactions, nil
+		}
+
+		stream, apiErr := service.Open("user123", "0")
+		if !assert.Nil(t, apiErr) {
+			return
+		}
+		defer stream.Close()
+
+		events, apiErr := stream.Poll()
+		assert.Nil(t, apiErr)
+		if assert.Len(t, events, streamBatchSize) {
+			assert.Equal(t, "100", events[streamBatchSize-1].ID)
+		}
+		select {
+		case <-stream.Wake():
+		default:
+			t.Error("a stream behind the head must wake itself to send the rest")
+		}
+
+		events, apiErr = stream.Poll()
+		assert.Nil(t, apiErr)
+		if assert.Len(t, events, 51) {
+			assert.Equal(t, "150", events[49].ID)
+			assert.Equal(t, models.StreamEventBalance, events[50].Type)
+		}
+		select {
+		case <-stream.Wake():
+			t.Error("a stream that caught up must not wake itself")
+		default:
+		}
+	})
+
+	t.Run("starts from now without a last event ID", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		logRepo := &repositories.MockTransactionLogRepository{}
+		service := newTestStreamService(env, logRepo)
+
+		logRepo.HeadFunc = func() (*models.TransactionLogHead, error) {
+			return &models.TransactionLogHead{ID: models.TransactionLogHeadID, Sequence: 42}, nil
+		}
+		logRepo.FindEntriesByUserIDFunc = func(userID string, after, until int64, limit int) ([]models.TransactionLogEntry, error) {
+			t.Fatal("a new stream must not replay past transactions")
+			return nil, nil
+		}
+
+		stream, apiErr := service.Open("user123", "")
+		if !assert.Nil(t, apiErr) {
+			return
+		}
+		defer stream.Close()
+
+		events, apiErr := stream.Poll()
+
+		assert.Nil(t, apiErr)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, models.StreamEventBalance, events[0].Type)
+		}
+	})
+
+	t.Run("rejects an invalid last event ID", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+		service := newTestStreamService(env, &repositories.MockTransactionLogRepository{})
+
+		_, apiErr := service.Open("user123", "abc")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+		}
+		assert.Empty(t, service.streams)
+	})
+}
+
+func TestStreamService_Run(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+	service := newTestStreamService(env, &repositories.MockTransactionLogRepository{})
+	go service.Run()
+
+	stream, apiErr := service.Open("user123", "")
+	if !assert.Nil(t, apiErr) {
+		return
+	}
+	defer stream.Close()
+	other, _ := service.Open("user456", "")
+	defer other.Close()
+
+	env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+		return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+	}
+	// Run subscribes in the background, so deposit until it has
+	assert.Eventually(t, func() bool {
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+		if _, apiErr := env.service.Deposit("user123", money.FromMajor(1), "USD", Idempotency{}); apiErr != nil {
+			return false
+		}
+		select {
+		case <-stream.Wake():
+			return true
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+
+	select {
+	case <-other.Wake():
+		t.Error("another user's stream must not be woken up")
+	default:
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/pubsub"
+	"wallet/internal/repositories"
+
+	"gorm.io/gorm"
+)
+
+// appendPendingBatchSize is how many pending versions the sequencer appends
+// to the log in one database transaction.
+const appendPendingBatchSize = 500
+
+// errTransactionLogBroken stops walking the log at the first broken link.
+var errTransactionLogBroken = errors.New("transaction log broken")
+
+type transactionLogService struct {
+	TransactionLogRepo repositories.TransactionLogRepository
+	TransactionRepo    repositories.TransactionRepository
+	PubSub             pubsub.PubSub
+	SigningKey         ed25519.PrivateKey
+}
+
+// NewTransactionLogService returns the service that sequences, checkpoints
+// and verifies the transaction log. pubSub wakes up the streams of users
+// whose transactions were appended, and signingKey signs checkpoints; both
+// may be nil where only verification is needed.
+func NewTransactionLogService(
+	transactionLogRepo repositories.TransactionLogRepository,
+	transactionRepo repositories.TransactionRepository,
+	pubSub pubsub.PubSub,
+	signingKey ed25519.PrivateKey,
+) TransactionLogService {
+	return &transactionLogService{
+		TransactionLogRepo: transactionLogRepo,
+		TransactionRepo:    transactionRepo,
+		PubSub:             pubSub,
+		SigningKey:         signingKey,
+	}
+}
+
+// ParseTransactionLogKey reads an Ed25519 signing key given as its base64
+// encoded 32-byte seed.
+func ParseTransactionLogKey(encoded string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, errors.New("transaction log signing key must be a 32-byte seed")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// AppendPending appends every committed version of a transaction that is
+// waiting for the sequencer to the log, and returns how many were appended.
+// Streams read transactions from the log, so the users involved are notified
+// once their transactions are in it.
+func (s *transactionLogService) AppendPending() (int, *APIError) {
+	appended := 0
+	for {
+		entries, err := s.TransactionLogRepo.AppendPending(appendPendingBatchSize)
+		if err != nil {
+			return appended, NewInternalServerError("Failed to append to transaction log")
+		}
+		appended += len(entries)
+
+		if s.PubSub != nil && len(entries) > 0 {
+			ids := make([]string, len(entries))
+			for i, entry := range entries {
+				ids[i] = entry.TransactionID
+			}
+			// Deleted transactions have nobody left to notify
+			transactions, err := s.TransactionRepo.FindByIDs(ids)
+			if err != nil {
+				return appended, NewInternalServerError("Failed to get transactions")
+			}
+			for _, transaction := range transactions {
+				notifyChanged(s.PubSub, transaction.FromUserID, transaction.ToUserID)
+			}
+		}
+
+		if len(entries) < appendPendingBatchSize {
+			return appended, nil
+		}
+	}
+}
+
+// Checkpoint signs the current head of the transaction log and stores the
+// signature. It returns nil if nothing was appended since the last
+// checkpoint.
+func (s *transactionLogService) Checkpoint() (*models.TransactionLogCheckpoint, *APIError) {
+	if s.SigningKey == nil {
+		return nil, NewInternalServerError("No transaction log signing key")
+	}
+
+	head, err := s.TransactionLogRepo.Head()
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get transaction log head")
+	}
+	if head.Sequence == 0 {
+		return nil, nil
+	}
+	last, err := s.TransactionLogRepo.LastCheckpoint()
+	if err == nil && last.Sequence >= head.Sequence {
+		return nil, nil
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, NewInternalServerError("Failed to get last transaction log checkpoint")
+	}
+
+	checkpoint := &models.TransactionLogCheckpoint{
+		Sequence:  head.Sequence,
+		Hash:      head.Hash,
+		CreatedAt: time.Now(),
+	}
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.SigningKey, checkpoint.SignedMessage()))
+	if err := s.TransactionLogRepo.CreateCheckpoint(checkpoint); err != nil {
+		return nil, NewInternalServerError("Failed to create transaction log checkpoint")
+	}
+	return checkpoint, nil
+}
+
+// Verify walks the whole transaction log and reports the first link that
+// cannot be trusted:
+//   - an entry out of sequence, not chained to the one before it, or whose
+//     content does not match its hash
+//   - a checkpoint that is not signed with publicKey or does not match the
+//     entry it was taken at
+//   - a transaction that differs from its latest logged version, or was
+//     removed without its removal being logged
+//
+// Transactions with versions still waiting for the sequencer are not
+// compared with the log. Signatures are only checked when publicKey is given.
+func (s *transactionLogService) Verify(publicKey ed25519.PublicKey) (*models.TransactionLogVerification, *APIError) {
+	result := &models.TransactionLogVerification{SignaturesVerified: publicKey != nil}
+
+	checkpoints, err := s.TransactionLogRepo.FindCheckpoints()
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get transaction log checkpoints")
+	}
+	result.Checkpoints = len(checkpoints)
+	bySequence := make(map[int64]*models.TransactionLogCheckpoint, len(checkpoints))
+	for i := range checkpoints {
+		checkpoint := &checkpoints[i]
+		if publicKey != nil && !verifyCheckpoint(publicKey, checkpoint) {
+			result.Broken = &models.TransactionLogBreak{Sequence: checkpoint.Sequence, Reason: "checkpoint signature is invalid"}
+			return result, nil
+		}
+		bySequence[checkpoint.Sequence] = checkpoint
+	}
+
+	// Walk the chain itself
+	var prevHash string
+	err = s.TransactionLogRepo.EachEntry(func(entry *models.TransactionLogEntry) error {
+		broken := &models.TransactionLogBreak{Sequence: result.Entries + 1, TransactionID: entry.TransactionID}
+		switch {
+		case entry.Sequence != result.Entries+1:
+			broken.Reason = "entry is missing"
+		case entry.PrevHash != prevHash:
+			broken.Reason = "entry is not chained to the one before it"
+		case entry.Hash != models.TransactionLogHash(entry.PrevHash, entry.Sequence, entry.Operation, entry.Payload):
+			broken.Reason = "entry does not match its hash"
+		case bySequence[entry.Sequence] != nil && bySequence[entry.Sequence].Hash != entry.Hash:
+			broken.Reason = "entry does not match its signed checkpoint"
+		default:
+			result.Entries++
+			prevHash = entry.Hash
+			return nil
+		}
+		result.Broken = broken
+		return errTransactionLogBroken
+	})
+	if err != nil && !errors.Is(err, errTransactionLogBroken) {
+		return nil, NewInternalServerError("Failed to read transaction log")
+	}
+	if result.Broken != nil {
+		return result, nil
+	}
+
+	head, err := s.TransactionLogRepo.Head()
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get transaction log head")
+	}
+	result.HeadSequence = head.Sequence
+	result.HeadHash = head.Hash
+	if head.Sequence != result.Entries || head.Hash != prevHash {
+		result.Broken = &models.TransactionLogBreak{Sequence: result.Entries + 1, Reason: "entries after this point are missing"}
+		return result, nil
+	}
+	if len(checkpoints) > 0 && checkpoints[len(checkpoints)-1].Sequence > result.Entries {
+		result.Broken = &models.TransactionLogBreak{Sequence: result.Entries + 1, Reason: "entries covered by a signed checkpoint are missing"}
+		return result, nil
+	}
+
+	// Then check each transaction against its latest logged version, keeping
+	// the earliest problem
+	err = s.TransactionRepo.Each(func(transaction *models.Transaction) error {
+		result.Transactions++
+		entry, err := s.TransactionLogRepo.FindLatestEntry(transaction.ID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		broken := &models.TransactionLogBreak{Sequence: result.Entries + 1, TransactionID: transaction.ID}
+		switch {
+		case entry == nil:
+			broken.Reason = "transaction is not in the log"
+		case entry.Operation == models.TransactionLogOperationDelete:
+			broken.Reason = "deleted transaction is back"
+		case transaction.Hash != entry.Hash || transaction.PrevHash != entry.PrevHash:
+			broken.Reason = "transaction hash does not match its latest log entry"
+		case transaction.LogPayload() != entry.Payload:
+			broken.Reason = "transaction was changed without being logged"
+		default:
+			return nil
+		}
+		if entry != nil {
+			broken.Sequence = entry.Sequence
+		}
+
+		// Its latest version may not have been appended yet
+		pending, err := s.TransactionLogRepo.HasPendingEntries(transaction.ID)
+		if err != nil {
+			return err
+		}
+		if !pending {
+			result.Broken = earliestBreak(result.Broken, broken)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, NewInternalServerError("Failed to read transactions")
+	}
+
+	missing, err := s.TransactionLogRepo.FindFirstMissingTransaction()
+	if err == nil {
+		result.Broken = earliestBreak(result.Broken, &models.TransactionLogBreak{Sequence: missing.Sequence, TransactionID: missing.TransactionID, Reason: "transaction was removed without being logged"})
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, NewInternalServerError("Failed to read transaction log")
+	}
+	return result, nil
+}
+
+func verifyCheckpoint(publicKey ed25519.PublicKey, checkpoint *models.TransactionLogCheckpoint) bool {
+	signature, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(publicKey, checkpoint.SignedMessage(), signature)
+}
+
+// earliestBreak returns whichever break comes first in the log.
+func earliestBreak(current, found *models.TransactionLogBreak) *models.TransactionLogBreak {
+	if current == nil || found.Sequence < current.Sequence {
+		return found
+	}
+	return current
+}
@@ -0,0 +1,301 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/pubsub"
+	"wallet/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+// testTransactionLog is an in-memory transaction log built the way the
+// repository appends to it.
+type testTransactionLog struct {
+	entries      []models.TransactionLogEntry
+	transactions []models.Transaction
+	checkpoints  []models.TransactionLogCheckpoint
+}
+
+func (l *testTransactionLog) append(transaction models.Transaction, operation string) {
+	prevHash := ""
+	if len(l.entries) > 0 {
+		prevHash = l.entries[len(l.entries)-1].Hash
+	}
+	entry := models.TransactionLogEntry{
+		Sequence:      int64(len(l.entries) + 1),
+		TransactionID: transaction.ID,
+		Operation:     operation,
+		Payload:       transaction.LogPayload(),
+		PrevHash:      prevHash,
+	}
+	entry.Hash = models.TransactionLogHash(entry.PrevHash, entry.Sequence, entry.Operation, entry.Payload)
+	l.entries = append(l.entries, entry)
+
+	transaction.Hash = entry.Hash
+	transaction.PrevHash = entry.PrevHash
+	for i := range l.transactions {
+		if l.transactions[i].ID == transaction.ID {
+			l.transactions[i] = transaction
+			return
+		}
+	}
+	l.transactions = append(l.transactions, transaction)
+}
+
+func (l *testTransactionLog) repositories() (*repositories.MockTransactionLogRepository, *repositories.MockTransactionRepository) {
+	logRepo := &repositories.MockTransactionLogRepository{
+		HeadFunc: func() (*models.TransactionLogHead, error) {
+			head := &models.TransactionLogHead{ID: models.TransactionLogHeadID}
+			if len(l.entries) > 0 {
+				head.Sequence = l.entries[len(l.entries)-1].Sequence
+				head.Hash = l.entries[len(l.entries)-1].Hash
+			}
+			return head, nil
+		},
+		EachEntryFunc: func(fn func(entry *models.TransactionLogEntry) error) error {
+			for i := range l.entries {
+				if err := fn(&l.entries[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		FindLatestEntryFunc: func(transactionID string) (*models.TransactionLogEntry, error) {
+			for i := len(l.entries) - 1; i >= 0; i-- {
+				if l.entries[i].TransactionID == transactionID {
+					return &l.entries[i], nil
+				}
+			}
+			return nil, gorm.ErrRecordNotFound
+		},
+		FindCheckpointsFunc: func() ([]models.TransactionLogCheckpoint, error) {
+			return l.checkpoints, nil
+		},
+	}
+	transactionRepo := &repositories.MockTransactionRepository{
+		EachFunc: func(fn func(transaction *models.Transaction) error) error {
+			for i := range l.transactions {
+				if err := fn(&l.transactions[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+	return logRepo, transactionRepo
+}
+
+func newTestTransactionLog(key ed25519.PrivateKey) *testTransactionLog {
+	now := time.Date(2025, time.March, 3, 10, 0, 0, 0, time.UTC)
+	l := &testTransactionLog{}
+	l.append(models.Transaction{ID: "tx1", FromUserID: "user1", Amount: money.FromMajor(100), Currency: "USD", Type: models.TransactionTypeDeposit, Status: models.TransactionStatusSuccess, CreatedAt: now, UpdatedAt: now}, models.TransactionLogOperationCreate)
+	l.append(models.Transaction{ID: "tx2", FromUserID: "user1", ToUserID: "user2", Amount: money.FromMajor(10), Currency: "USD", Type: models.TransactionTypeTransfer, Status: models.TransactionStatusPending, CreatedAt: now, UpdatedAt: now}, models.TransactionLogOperationCreate)
+
+	checkpoint := models.TransactionLogCheckpoint{Sequence: 2, Hash: l.entries[1].Hash}
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, checkpoint.SignedMessage()))
+	l.checkpoints = append(l.checkpoints, checkpoint)
+
+	captured := l.transactions[1]
+	captured.Status = models.TransactionStatusSuccess
+	captured.UpdatedAt = now.Add(time.Minute)
+	l.append(captured, models.TransactionLogOperationUpdate)
+	return l
+}
+
+func TestTransactionLogService_Verify(t *testing.T) {
+	publicKey, key, _ := ed25519.GenerateKey(nil)
+
+	t.Run("accepts an intact log", func(t *testing.T) {
+		l := newTestTransactionLog(key)
+		logRepo, transactionRepo := l.repositories()
+		service := NewTransactionLogService(logRepo, transactionRepo, nil, nil)
+
+		result, apiErr := service.Verify(publicKey)
+
+		assert.Nil(t, apiErr)
+		assert.Nil(t, result.Broken)
+		assert.Equal(t, int64(3), result.Entries)
+		assert.Equal(t, int64(2), result.Transactions)
+		assert.Equal(t, l.entries[2].Hash, result.HeadHash)
+		assert.True(t, result.SignaturesVerified)
+	})
+
+	t.Run("pinpoints an edited entry", func(t *testing.T) {
+		l := newTestTransactionLog(key)
+		l.entries[1].Payload = l.transactions[1].LogPayload()
+		logRepo, transactionRepo := l.repositories()
+		service := NewTransactionLogService(logRepo, transactionRepo, nil, nil)
+
+		result, apiErr := service.Verify(publicKey)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, &models.TransactionLogBreak{Sequence: 2, TransactionID: "tx2", Reason: "entry does not match its hash"}, result.Broken)
+	})
+
+	t.Run("catches a log rewritten after a signed checkpoint", func(t *testing.T) {
+		l := newTestTransactionLog(key)
+		forged := &testTransactionLog{}
+		rewritten := l.transactions[0]
+		rewritten.Amount = money.FromMajor(1000)
+		forged.append(rewritten, models.TransactionLogOperationCreate)
+		for _, entry := range l.entries[1:] {
+			var transaction models.Transaction
+			for _, tx := range l.transactions {
+				if tx.ID == entry.TransactionID {
+					transaction = tx
+				}
+			}
+			forged.append(transaction, entry.Operation)
+		}
+		forged.checkpoints = l.checkpoints
+		logRepo, transactionRepo := forged.repositories()
+		service := NewTransactionLogService(logRepo, transactionRepo, nil, nil)
+
+		result, apiErr := service.Verify(publicKey)
+
+		assert.Nil(t, apiErr)
+		if assert.NotNil(t, result.Broken) {
+			assert.Equal(t, int64(2), result.Broken.Sequence)
+			assert.Equal(t, "entry does not match its signed checkpoint", result.Broken.Reason)
+		}
+	})
+
+	t.Run("catches a transaction changed behind the log's back", func(t *testing.T) {
+		l := newTestTransactionLog(key)
+		l.transactions[0].Amount = money.FromMajor(1000)
+		logRepo, transactionRepo := l.repositories()
+		service := NewTransactionLogService(logRepo, transactionRepo, nil, nil)
+
+		result, apiErr := service.Verify(publicKey)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, &models.TransactionLogBreak{Sequence: 1, TransactionID: "tx1", Reason: "transaction was changed without being logged"}, result.Broken)
+	})
+
+	t.Run("skips a transaction waiting for the sequencer", func(t *testing.T) {
+		l := newTestTransactionLog(key)
+		l.transactions = append(l.transactions, models.Transaction{ID: "tx3", FromUserID: "user1", Amount: money.FromMajor(5), Currency: "USD", Type: models.TransactionTypeWithdraw, Status: models.TransactionStatusSuccess})
+		l.transactions[1].Status = models.TransactionStatusReversed
+		logRepo, transactionRepo := l.repositories()
+		logRepo.HasPendingEntriesFunc = func(transactionID string) (bool, error) {
+			return transactionID != "tx1", nil
+		}
+		service := NewTransactionLogService(logRepo, transactionRepo, nil, nil)
+
+		result, apiErr := service.Verify(publicKey)
+
+		assert.Nil(t, apiErr)
+		assert.Nil(t, result.Broken)
+		assert.Equal(t, int64(3), result.Transactions)
+	})
+
+	t.Run("rejects a checkpoint signed with another key", func(t *testing.T) {
+		l := newTestTransactionLog(key)
+		otherPublicKey, _, _ := ed25519.GenerateKey(nil)
+		logRepo, transactionRepo := l.repositories()
+		service := NewTransactionLogService(logRepo, transactionRepo, nil, nil)
+
+		result, apiErr := service.Verify(otherPublicKey)
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, &models.TransactionLogBreak{Sequence: 2, Reason: "checkpoint signature is invalid"}, result.Broken)
+	})
+}
+
+func TestTransactionLogService_Checkpoint(t *testing.T) {
+	publicKey, key, _ := ed25519.GenerateKey(nil)
+
+	t.Run("signs the head once", func(t *testing.T) {
+		logRepo := &repositories.MockTransactionLogRepository{}
+		logRepo.HeadFunc = func() (*models.TransactionLogHead, error) {
+			return &models.TransactionLogHead{Sequence: 7, Hash: "abc"}, nil
+		}
+		var stored *models.TransactionLogCheckpoint
+		logRepo.CreateCheckpointFunc = func(checkpoint *models.TransactionLogCheckpoint) error {
+			stored = checkpoint
+			return nil
+		}
+		service := NewTransactionLogService(logRepo, &repositories.MockTransactionRepository{}, nil, key)
+
+		checkpoint, apiErr := service.Checkpoint()
+
+		assert.Nil(t, apiErr)
+		assert.Same(t, stored, checkpoint)
+		assert.Equal(t, int64(7), checkpoint.Sequence)
+		assert.True(t, verifyCheckpoint(publicKey, checkpoint))
+
+		logRepo.LastCheckpointFunc = func() (*models.TransactionLogCheckpoint, error) {
+			return stored, nil
+		}
+		checkpoint, apiErr = service.Checkpoint()
+
+		assert.Nil(t, apiErr)
+		assert.Nil(t, checkpoint)
+	})
+}
+
+func TestTransactionLogService_AppendPending(t *testing.T) {
+	logRepo := &repositories.MockTransactionLogRepository{}
+	batches := [][]models.TransactionLogEntry{
+		make([]models.TransactionLogEntry, appendPendingBatchSize),
+		{{Sequence: 501, TransactionID: "tx2"}, {Sequence: 502, TransactionID: "tx3", Operation: models.TransactionLogOperationDelete}},
+	}
+	for i := range batches[0] {
+		batches[0][i] = models.TransactionLogEntry{Sequence: int64(i + 1), TransactionID: "tx1"}
+	}
+	logRepo.AppendPendingFunc = func(limit int) ([]models.TransactionLogEntry, error) {
+		assert.Equal(t, appendPendingBatchSize, limit)
+		if len(batches) == 0 {
+			t.Fatal("a short batch means nothing is left to append")
+		}
+		batch := batches[0]
+		batches = batches[1:]
+		return batch, nil
+	}
+	transactionRepo := &repositories.MockTransactionRepository{}
+	transactionRepo.FindByIDsFunc = func(ids []string) ([]models.Transaction, error) {
+		var transactions []models.Transaction
+		if ids[0] == "tx1" {
+			transactions = append(transactions, models.Transaction{ID: "tx1", FromUserID: "user1"})
+		} else {
+			assert.Equal(t, []string{"tx2", "tx3"}, ids)
+			transactions = append(transactions, models.Transaction{ID: "tx2", FromUserID: "user1", ToUserID: "user2"})
+		}
+		return transactions, nil
+	}
+	pubSub := pubsub.NewInMemoryPubSub()
+	subscription, err := pubSub.Subscribe(StreamChannel)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer subscription.Close()
+	service := NewTransactionLogService(logRepo, transactionRepo, pubSub, nil)
+
+	appended, apiErr := service.AppendPending()
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, appendPendingBatchSize+2, appended)
+	var notified []string
+	for len(subscription.Messages()) > 0 {
+		notified = append(notified, <-subscription.Messages())
+	}
+	assert.Equal(t, []string{"user1", "user1", "user2"}, notified)
+}
+
+func TestParseTransactionLogKey(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	key, err := ParseTransactionLogKey(base64.StdEncoding.EncodeToString(seed))
+
+	assert.NoError(t, err)
+	assert.Equal(t, ed25519.NewKeyFromSeed(seed), key)
+
+	_, err = ParseTransactionLogKey(base64.StdEncoding.EncodeToString(seed[:16]))
+	assert.Error(t, err)
+}
@@ -0,0 +1,316 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/repositories"
+	"wallet/internal/sms"
+
+	"gorm.io/gorm"
+)
+
+var (
+	handlePattern = regexp.MustCompile(`^[a-z0-9_]{3,30}$`)
+	phonePattern  = regexp.MustCompile(`^\+[1-9][0-9]{6,14}$`)
+)
+
+// A phone verification code is valid for phoneCodeTTL and can be guessed at
+// most phoneCodeMaxAttempts times.
+const (
+	phoneCodeTTL         = 10 * time.Minute
+	phoneCodeMaxAttempts = 5
+)
+
+// Recipient addresses the recipient of a transfer by exactly one of their
+// user ID, email, phone number or handle.
+type Recipient struct {
+	UserID string
+	Email  string
+	Phone  string
+	Handle string
+}
+
+// ParseRecipient reads a recipient written as a single string: an @handle, an
+// email, a phone number starting with "+", or otherwise a user ID.
+func ParseRecipient(s string) Recipient {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(s, "@"):
+		return Recipient{Handle: s}
+	case strings.Contains(s, "@"):
+		return Recipient{Email: s}
+	case strings.HasPrefix(s, "+"):
+		return Recipient{Phone: s}
+	}
+	return Recipient{UserID: s}
+}
+
+type userService struct {
+	UserRepo  repositories.UserRepository
+	SMSSender sms.Sender
+}
+
+func NewUserService(userRepo repositories.UserRepository, smsSender sms.Sender) UserService {
+	return &userService{
+		UserRepo:  userRepo,
+		SMSSender: smsSender,
+	}
+}
+
+// ResolveRecipient finds the user a transfer is addressed to. A phone number
+// only finds a user once they have verified it.
+func (s *userService) ResolveRecipient(recipient Recipient) (*models.User, *APIError) {
+	var find func() (*models.User, error)
+	given := 0
+	if recipient.UserID != "" {
+		given++
+		find = func() (*models.User, error) { return s.UserRepo.FindByID(recipient.UserID) }
+	}
+	if recipient.Email != "" {
+		given++
+		find = func() (*models.User, error) { return s.UserRepo.FindByEmail(strings.TrimSpace(recipient.Email)) }
+	}
+	if recipient.Phone != "" {
+		given++
+		phone, apiErr := normalizePhone(recipient.Phone)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		find = func() (*models.User, error) { return s.UserRepo.FindByPhone(phone) }
+	}
+	if recipient.Handle != "" {
+		given++
+		handle, apiErr := normalizeHandle(recipient.Handle)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		find = func() (*models.User, error) { return s.UserRepo.FindByHandle(handle) }
+	}
+	if given != 1 {
+		return nil, NewBadRequestError("Exactly one of user ID, email, phone or handle is required")
+	}
+
+	user, err := find()
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Recipient not found")
+		}
+		return nil, NewInternalServerError("Failed to get recipient")
+	}
+	return user, nil
+}
+
+// LookupRecipient returns the masked display name of a recipient, so that a
+// sender can confirm who they are about to pay without learning their name.
+func (s *userService) LookupRecipient(recipient Recipient) (string, *APIError) {
+	user, apiErr := s.ResolveRecipient(recipient)
+	if apiErr != nil {
+		return "", apiErr
+	}
+	return maskName(user.Name), nil
+}
+
+// UpdateProfile sets a user's handle and phone number. A nil value leaves the
+// field unchanged and an empty one clears it. A new phone number is not set
+// straight away: a code is texted to it, and it only replaces the user's
+// phone number once VerifyPhone confirms the code.
+func (s *userService) UpdateProfile(userID string, handle, phone *string) (*models.User, *APIError) {
+	user, apiErr := s.findUser(userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	if handle != nil {
+		user.Handle = nil
+		if *handle != "" {
+			normalized, apiErr := normalizeHandle(*handle)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			if other, err := s.UserRepo.FindByHandle(normalized); err == nil && other.ID != userID {
+				return nil, NewConflictError("Handle is already taken")
+			} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, NewInternalServerError("Failed to check handle")
+			}
+			user.Handle = &normalized
+		}
+	}
+
+	var code string
+	if phone != nil {
+		clearPendingPhone(user)
+		if *phone == "" {
+			user.Phone = nil
+		} else {
+			normalized, apiErr := normalizePhone(*phone)
+			if apiErr != nil {
+				return nil, apiErr
+			}
+			if user.Phone == nil || *user.Phone != normalized {
+				if apiErr := s.checkPhoneAvailable(normalized, userID); apiErr != nil {
+					return nil, apiErr
+				}
+				if code, apiErr = newPhoneCode(); apiErr != nil {
+					return nil, apiErr
+				}
+				expiresAt := time.Now().Add(phoneCodeTTL)
+				user.PendingPhone = &normalized
+				user.PhoneCodeHash = hashPhoneCode(code)
+				user.PhoneCodeExpiresAt = &expiresAt
+			}
+		}
+	}
+
+	if apiErr := s.updateUser(user); apiErr != nil {
+		return nil, apiErr
+	}
+	if code != "" {
+		message := fmt.Sprintf("Your wallet verification code is %s", code)
+		if err := s.SMSSender.Send(*user.PendingPhone, message); err != nil {
+			return nil, NewInternalServerError("Failed to send verification code")
+		}
+	}
+	return user, nil
+}
+
+// VerifyPhone checks the code texted to a user's pending phone number and,
+// if it matches, makes the number theirs.
+func (s *userService) VerifyPhone(userID, code string) (*models.User, *APIError) {
+	user, apiErr := s.findUser(userID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	switch {
+	case user.PendingPhone == nil:
+		return nil, NewBadRequestError("No phone number is waiting to be verified")
+	case user.PhoneCodeExpiresAt == nil || time.Now().After(*user.PhoneCodeExpiresAt):
+		return nil, NewBadRequestError("Verification code has expired, set the phone number again for a new one")
+	case user.PhoneCodeAttempts >= phoneCodeMaxAttempts:
+		return nil, NewBadRequestError("Too many attempts, set the phone number again for a new code")
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashPhoneCode(strings.TrimSpace(code))), []byte(user.PhoneCodeHash)) != 1 {
+		user.PhoneCodeAttempts++
+		if apiErr := s.updateUser(user); apiErr != nil {
+			return nil, apiErr
+		}
+		return nil, NewBadRequestError("Invalid verification code")
+	}
+
+	// Another user may have verified the number since the code was sent
+	if apiErr := s.checkPhoneAvailable(*user.PendingPhone, userID); apiErr != nil {
+		return nil, apiErr
+	}
+	user.Phone = user.PendingPhone
+	clearPendingPhone(user)
+	if apiErr := s.updateUser(user); apiErr != nil {
+		return nil, apiErr
+	}
+	return user, nil
+}
+
+func (s *userService) findUser(userID string) (*models.User, *APIError) {
+	user, err := s.UserRepo.FindByID(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("User not found")
+		}
+		return nil, NewInternalServerError("Failed to get user")
+	}
+	return user, nil
+}
+
+// checkPhoneAvailable fails with a conflict if phone is verified by a user
+// other than userID.
+func (s *userService) checkPhoneAvailable(phone, userID string) *APIError {
+	if other, err := s.UserRepo.FindByPhone(phone); err == nil && other.ID != userID {
+		return NewConflictError("Phone number is already in use")
+	} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return NewInternalServerError("Failed to check phone number")
+	}
+	return nil
+}
+
+// updateUser saves a user. A handle or phone number claimed by another user
+// between checking and saving is a conflict, caught by their unique indexes.
+func (s *userService) updateUser(user *models.User) *APIError {
+	user.UpdatedAt = time.Now()
+	if err := s.UserRepo.Update(user); err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return NewConflictError("Handle or phone number is already in use")
+		}
+		return NewInternalServerError("Failed to update user")
+	}
+	return nil
+}
+
+func clearPendingPhone(user *models.User) {
+	user.PendingPhone = nil
+	user.PhoneCodeHash = ""
+	user.PhoneCodeExpiresAt = nil
+	user.PhoneCodeAttempts = 0
+}
+
+// newPhoneCode returns a random six-digit verification code.
+func newPhoneCode() (string, *APIError) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", NewInternalServerError("Failed to generate verification code")
+	}
+	return fmt.Sprintf("%06d", n), nil
+}
+
+func hashPhoneCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeHandle lowercases a handle and strips a leading "@".
+func normalizeHandle(handle string) (string, *APIError) {
+	handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+	if !handlePattern.MatchString(handle) {
+		return "", NewBadRequestError("Handle must be 3 to 30 letters, digits or underscores")
+	}
+	return handle, nil
+}
+
+// normalizePhone strips the punctuation people type into phone numbers and
+// requires what is left to be in E.164 form, such as +14155550123.
+func normalizePhone(phone string) (string, *APIError) {
+	phone = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '.', '(', ')':
+			return -1
+		}
+		return r
+	}, phone)
+	if !phonePattern.MatchString(phone) {
+		return "", NewBadRequestError("Phone number must be in international format, e.g. +14155550123")
+	}
+	return phone, nil
+}
+
+// maskName keeps the initial of each name and hides the rest, turning
+// "Jane Doe" into "J*** D.".
+func maskName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		runes := []rune(word)
+		if i == 0 {
+			words[i] = string(runes[0]) + strings.Repeat("*", len(runes)-1)
+		} else {
+			words[i] = string(runes[0]) + "."
+		}
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/repositories"
+	smsmock "wallet/internal/sms/mock"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestUserService_ResolveRecipient(t *testing.T) {
+	jane := &models.User{ID: "user456", Name: "Jane Doe", Email: "jane@example.com"}
+
+	t.Run("resolves each kind of identifier", func(t *testing.T) {
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				assert.Equal(t, "user456", id)
+				return jane, nil
+			},
+			FindByEmailFunc: func(email string) (*models.User, error) {
+				assert.Equal(t, "jane@example.com", email)
+				return jane, nil
+			},
+			FindByPhoneFunc: func(phone string) (*models.User, error) {
+				assert.Equal(t, "+14155550123", phone)
+				return jane, nil
+			},
+			FindByHandleFunc: func(handle string) (*models.User, error) {
+				assert.Equal(t, "jane_doe", handle)
+				return jane, nil
+			},
+		}
+		service := NewUserService(userRepo, &smsmock.MockSender{})
+
+		for _, recipient := range []Recipient{
+			{UserID: "user456"},
+			{Email: " jane@example.com "},
+			{Phone: "+1 (415) 555-0123"},
+			{Handle: "@Jane_Doe"},
+		} {
+			user, apiErr := service.ResolveRecipient(recipient)
+
+			assert.Nil(t, apiErr)
+			assert.Equal(t, jane, user)
+		}
+	})
+
+	t.Run("requires exactly one identifier", func(t *testing.T) {
+		service := NewUserService(&repositories.MockUserRepository{}, &smsmock.MockSender{})
+
+		for _, recipient := range []Recipient{
+			{},
+			{UserID: "user456", Email: "jane@example.com"},
+		} {
+			_, apiErr := service.ResolveRecipient(recipient)
+
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, 400, apiErr.Code)
+			}
+		}
+	})
+
+	t.Run("unknown recipient", func(t *testing.T) {
+		service := NewUserService(&repositories.MockUserRepository{}, &smsmock.MockSender{})
+
+		_, apiErr := service.ResolveRecipient(Recipient{Email: "nobody@example.com"})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 404, apiErr.Code)
+		}
+	})
+
+	t.Run("malformed phone number", func(t *testing.T) {
+		service := NewUserService(&repositories.MockUserRepository{}, &smsmock.MockSender{})
+
+		_, apiErr := service.ResolveRecipient(Recipient{Phone: "555-0123"})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 400, apiErr.Code)
+		}
+	})
+}
+
+func TestUserService_LookupRecipient(t *testing.T) {
+	userRepo := &repositories.MockUserRepository{
+		FindByHandleFunc: func(handle string) (*models.User, error) {
+			return &models.User{ID: "user456", Name: "Jane Mary Doe"}, nil
+		},
+	}
+	service := NewUserService(userRepo, &smsmock.MockSender{})
+
+	displayName, apiErr := service.LookupRecipient(Recipient{Handle: "jane"})
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, "J*** M. D.", displayName)
+}
+
+func TestUserService_UpdateProfile(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+
+	t.Run("sets normalized handle and texts a code to the new phone", func(t *testing.T) {
+		var updated *models.User
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				return &models.User{ID: id}, nil
+			},
+			UpdateFunc: func(user *models.User) error {
+				updated = user
+				return nil
+			},
+		}
+		var sentTo, message string
+		sender := &smsmock.MockSender{
+			SendFunc: func(phone, text string) error {
+				sentTo, message = phone, text
+				return nil
+			},
+		}
+		service := NewUserService(userRepo, sender)
+
+		user, apiErr := service.UpdateProfile("user123", strPtr("@John"), strPtr("+44 20 7946 0958"))
+
+		assert.Nil(t, apiErr)
+		if assert.NotNil(t, updated) {
+			assert.Equal(t, user, updated)
+			assert.Equal(t, "john", *user.Handle)
+			// The number does not address transfers until it is verified
+			assert.Nil(t, user.Phone)
+			assert.Equal(t, "+442079460958", *user.PendingPhone)
+		}
+		assert.Equal(t, "+442079460958", sentTo)
+		code := message[strings.LastIndex(message, " ")+1:]
+		assert.Len(t, code, 6)
+		assert.Equal(t, hashPhoneCode(code), user.PhoneCodeHash)
+	})
+
+	t.Run("clears a field with an empty value and keeps an omitted one", func(t *testing.T) {
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				return &models.User{ID: id, Handle: strPtr("john"), Phone: strPtr("+442079460958")}, nil
+			},
+		}
+		service := NewUserService(userRepo, &smsmock.MockSender{})
+
+		user, apiErr := service.UpdateProfile("user123", strPtr(""), nil)
+
+		assert.Nil(t, apiErr)
+		assert.Nil(t, user.Handle)
+		assert.Equal(t, "+442079460958", *user.Phone)
+	})
+
+	t.Run("handle taken by another user", func(t *testing.T) {
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				return &models.User{ID: id}, nil
+			},
+			FindByHandleFunc: func(handle string) (*models.User, error) {
+				return &models.User{ID: "user456"}, nil
+			},
+			UpdateFunc: func(user *models.User) error {
+				t.Fatal("user must not be updated")
+				return nil
+			},
+		}
+		service := NewUserService(userRepo, &smsmock.MockSender{})
+
+		_, apiErr := service.UpdateProfile("user123", strPtr("jane"), nil)
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+	})
+
+	t.Run("handle taken by another user between checking and saving", func(t *testing.T) {
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				return &models.User{ID: id}, nil
+			},
+			UpdateFunc: func(user *models.User) error {
+				return gorm.ErrDuplicatedKey
+			},
+		}
+		service := NewUserService(userRepo, &smsmock.MockSender{})
+
+		_, apiErr := service.UpdateProfile("user123", strPtr("jane"), nil)
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+	})
+
+	t.Run("phone verified by another user", func(t *testing.T) {
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				return &models.User{ID: id}, nil
+			},
+			FindByPhoneFunc: func(phone string) (*models.User, error) {
+				return &models.User{ID: "user456"}, nil
+			},
+		}
+		sender := &smsmock.MockSender{
+			SendFunc: func(phone, message string) error {
+				t.Fatal("no code must be sent")
+				return nil
+			},
+		}
+		service := NewUserService(userRepo, sender)
+
+		_, apiErr := service.UpdateProfile("user123", nil, strPtr("+14155550123"))
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+	})
+}
+
+func TestUserService_VerifyPhone(t *testing.T) {
+	pending := func() *models.User {
+		phone := "+14155550123"
+		expiresAt := time.Now().Add(time.Minute)
+		return &models.User{ID: "user123", PendingPhone: &phone, PhoneCodeHash: hashPhoneCode("123456"), PhoneCodeExpiresAt: &expiresAt}
+	}
+	setup := func(user *models.User) (UserService, **models.User) {
+		var updated *models.User
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				return user, nil
+			},
+			UpdateFunc: func(user *models.User) error {
+				updated = user
+				return nil
+			},
+		}
+		return NewUserService(userRepo, &smsmock.MockSender{}), &updated
+	}
+
+	t.Run("sets the phone number once the code matches", func(t *testing.T) {
+		service, updated := setup(pending())
+
+		user, apiErr := service.VerifyPhone("user123", " 123456 ")
+
+		assert.Nil(t, apiErr)
+		if assert.NotNil(t, *updated) {
+			assert.Equal(t, "+14155550123", *user.Phone)
+			assert.Nil(t, user.PendingPhone)
+			assert.Empty(t, user.PhoneCodeHash)
+		}
+	})
+
+	t.Run("counts a wrong code and refuses once out of attempts", func(t *testing.T) {
+		user := pending()
+		service, updated := setup(user)
+
+		for i := 0; i < phoneCodeMaxAttempts; i++ {
+			_, apiErr := service.VerifyPhone("user123", "000000")
+			if assert.NotNil(t, apiErr) {
+				assert.Equal(t, "Invalid verification code", apiErr.Message)
+			}
+		}
+		assert.Equal(t, phoneCodeMaxAttempts, (*updated).PhoneCodeAttempts)
+
+		_, apiErr := service.VerifyPhone("user123", "123456")
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 400, apiErr.Code)
+		}
+		assert.Nil(t, user.Phone)
+	})
+
+	t.Run("refuses an expired code", func(t *testing.T) {
+		user := pending()
+		expiredAt := time.Now().Add(-time.Second)
+		user.PhoneCodeExpiresAt = &expiredAt
+		service, _ := setup(user)
+
+		_, apiErr := service.VerifyPhone("user123", "123456")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 400, apiErr.Code)
+		}
+		assert.Nil(t, user.Phone)
+	})
+
+	t.Run("number verified by another user in the meantime", func(t *testing.T) {
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				return pending(), nil
+			},
+			UpdateFunc: func(user *models.User) error {
+				return gorm.ErrDuplicatedKey
+			},
+		}
+		service := NewUserService(userRepo, &smsmock.MockSender{})
+
+		_, apiErr := service.VerifyPhone("user123", "123456")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 409, apiErr.Code)
+		}
+	})
+
+	t.Run("nothing to verify", func(t *testing.T) {
+		service, _ := setup(&models.User{ID: "user123"})
+
+		_, apiErr := service.VerifyPhone("user123", "123456")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 400, apiErr.Code)
+		}
+	})
+
+	t.Run("failure to get the user", func(t *testing.T) {
+		userRepo := &repositories.MockUserRepository{
+			FindByIDFunc: func(id string) (*models.User, error) {
+				return nil, errors.New("connection refused")
+			},
+		}
+		service := NewUserService(userRepo, &smsmock.MockSender{})
+
+		_, apiErr := service.VerifyPhone("user123", "123456")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, 500, apiErr.Code)
+		}
+	})
+}
@@ -1,246 +1,394 @@
 package services
 
 import (
+	"errors"
 	"time"
 
 	"wallet/internal/cache"
 	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/pubsub"
 	"wallet/internal/repositories"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type walletService struct {
-	WalletRepo      repositories.WalletRepository
-	TransactionRepo repositories.TransactionRepository
-	Cache           cache.Cache
+	WalletRepo         repositories.WalletRepository
+	TransactionRepo    repositories.TransactionRepository
+	LedgerRepo         repositories.LedgerRepository
+	IdempotencyKeyRepo repositories.IdempotencyKeyRepository
+	FXQuoteRepo        repositories.FXQuoteRepository
+	HoldRepo           repositories.HoldRepository
+	LimitRepo          repositories.LimitRepository
+	UserRepo           repositories.UserRepository
+	OutboxRepo         repositories.OutboxRepository
+	Cache              cache.Cache
+	PubSub             pubsub.PubSub
+	IdempotencyKeyTTL  time.Duration
+	HoldTTL            time.Duration
+	Limits             LimitConfig
+	Fees               FeeConfig
 }
 
 func NewWalletService(
 	walletRepo repositories.WalletRepository,
 	transactionRepo repositories.TransactionRepository,
+	ledgerRepo repositories.LedgerRepository,
+	idempotencyKeyRepo repositories.IdempotencyKeyRepository,
+	fxQuoteRepo repositories.FXQuoteRepository,
+	holdRepo repositories.HoldRepository,
+	limitRepo repositories.LimitRepository,
+	userRepo repositories.UserRepository,
+	outboxRepo repositories.OutboxRepository,
 	cache cache.Cache,
+	pubSub pubsub.PubSub,
+	idempotencyKeyTTL time.Duration,
+	holdTTL time.Duration,
+	limits LimitConfig,
+	fees FeeConfig,
 ) WalletService {
+	if idempotencyKeyTTL <= 0 {
+		idempotencyKeyTTL = DefaultIdempotencyKeyTTL
+	}
+	if holdTTL <= 0 {
+		holdTTL = DefaultHoldTTL
+	}
 	return &walletService{
-		WalletRepo:      walletRepo,
-		TransactionRepo: transactionRepo,
-		Cache:           cache,
+		WalletRepo:         walletRepo,
+		TransactionRepo:    transactionRepo,
+		LedgerRepo:         ledgerRepo,
+		IdempotencyKeyRepo: idempotencyKeyRepo,
+		FXQuoteRepo:        fxQuoteRepo,
+		HoldRepo:           holdRepo,
+		LimitRepo:          limitRepo,
+		UserRepo:           userRepo,
+		OutboxRepo:         outboxRepo,
+		Cache:              cache,
+		PubSub:             pubSub,
+		IdempotencyKeyTTL:  idempotencyKeyTTL,
+		HoldTTL:            holdTTL,
+		Limits:             limits,
+		Fees:               fees,
 	}
 }
 
-func (s *walletService) Deposit(userID string, amount float64) (float64, *APIError) {
-	if amount <= 0 {
-		return 0, NewBadRequestError("Invalid amount")
+func (s *walletService) OpenWallet(userID, currency string) (*models.Wallet, *APIError) {
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	wallet, err := s.WalletRepo.FindByUserID(userID)
-	if err != nil {
-		return 0, NewInternalServerError("Failed to get wallet")
+	if existing, err := s.WalletRepo.FindByUserID(userID, currency); err == nil {
+		if existing.Status == models.WalletStatusClosed {
+			return nil, NewConflictError(currency + " wallet is closed")
+		}
+		return nil, NewConflictError("Wallet already exists for " + currency)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, NewInternalServerError("Failed to get wallet")
 	}
 
-	// Start a database transaction for the create and update operations
-	tx := s.WalletRepo.DB().Begin()
-	if tx.Error != nil {
-		return 0, NewInternalServerError("Failed to start transaction")
+	wallet := &models.Wallet{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Currency:  currency,
+		Balance:   0,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := s.WalletRepo.Create(wallet); err != nil {
+		return nil, NewInternalServerError("Failed to create wallet")
 	}
+	return wallet, nil
+}
 
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+func (s *walletService) Deposit(userID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+	currency, apiErr := validateAmount(amount, currency)
+	if apiErr != nil {
+		return 0, apiErr
+	}
 
-	// Create repository instances with transaction
-	walletRepo := s.WalletRepo.WithTx(tx)
-	transactionRepo := s.TransactionRepo.WithTx(tx)
+	response, apiErr := s.runIdempotent(userID, idem, func(tx *gorm.DB) (idempotentResponse, *APIError) {
+		// Create repository instances with transaction
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
 
-	// Create transaction
-	transaction := &models.Transaction{
-		ID:         uuid.New().String(),
-		FromUserID: userID,
-		ToUserID:   "",
-		Amount:     amount,
-		Type:       models.TransactionTypeDeposit,
-		Status:     models.TransactionStatusSuccess,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-	}
+		wallet, apiErr := lockWallet(walletRepo, userID, currency)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := checkCredit(wallet, true); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
 
-	if err := transactionRepo.Create(transaction); err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to create transaction")
-	}
+		// Create transaction
+		transaction := &models.Transaction{
+			ID:         uuid.New().String(),
+			FromUserID: userID,
+			ToUserID:   "",
+			Amount:     amount,
+			Currency:   currency,
+			Type:       models.TransactionTypeDeposit,
+			Status:     models.TransactionStatusSuccess,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
 
-	// Update wallet balance
-	wallet.Balance += amount
-	wallet.UpdatedAt = time.Now()
-	if err := walletRepo.Update(wallet); err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to update wallet")
-	}
+		if err := transactionRepo.Create(transaction); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to create transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to commit transaction")
+		// Update wallet balance
+		wallet.Balance += amount
+		wallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(wallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update wallet")
+		}
+
+		if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+			{account: models.LedgerAccountWorldDeposits, amount: -amount},
+			{account: models.WalletLedgerAccount(wallet.ID), amount: amount},
+		}, wallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		return idempotentResponse{Balance: wallet.Balance, TransactionID: transaction.ID}, nil
+	})
+	if apiErr != nil {
+		return 0, apiErr
 	}
 
-	s.Cache.Delete(userID)
-	return wallet.Balance, nil
+	s.changed(userID)
+	return response.Balance, nil
 }
 
-func (s *walletService) Withdraw(userID string, amount float64) (float64, *APIError) {
-	if amount <= 0 {
-		return 0, NewBadRequestError("Invalid amount")
+func (s *walletService) Withdraw(userID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+	currency, apiErr := validateAmount(amount, currency)
+	if apiErr != nil {
+		return 0, apiErr
 	}
 
-	wallet, err := s.WalletRepo.FindByUserID(userID)
-	if err != nil {
-		return 0, NewInternalServerError("Failed to get wallet")
-	}
+	response, apiErr := s.runIdempotent(userID, idem, func(tx *gorm.DB) (idempotentResponse, *APIError) {
+		// Create repository instances with transaction
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
 
-	if wallet.Balance < amount {
-		return 0, NewBadRequestError("Insufficient balance")
-	}
+		// The balance check only holds while the row is locked, otherwise a
+		// concurrent withdrawal could spend the same funds.
+		wallet, apiErr := lockWallet(walletRepo, userID, currency)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := checkDebit(wallet, true); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
 
-	// Start a database transaction for the create and update operations
-	tx := s.WalletRepo.DB().Begin()
-	if tx.Error != nil {
-		return 0, NewInternalServerError("Failed to start transaction")
-	}
+		if apiErr := s.checkLimits(tx, userID, models.LimitOperationWithdraw, currency, amount); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
 
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+		fees, fee, apiErr := s.feesFor(userID, models.FeeOperationWithdraw, currency, amount)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
 		}
-	}()
 
-	// Create repository instances with transaction
-	walletRepo := s.WalletRepo.WithTx(tx)
-	transactionRepo := s.TransactionRepo.WithTx(tx)
+		available, apiErr := availableBalance(s.HoldRepo.WithTx(tx), wallet)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if available < amount+fee {
+			return idempotentResponse{}, NewInsufficientBalanceError()
+		}
 
-	// Create transaction
-	transaction := &models.Transaction{
-		ID:         uuid.New().String(),
-		FromUserID: userID,
-		ToUserID:   "",
-		Amount:     amount,
-		Type:       models.TransactionTypeWithdraw,
-		Status:     models.TransactionStatusSuccess,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-	}
+		// Create transaction
+		transaction := &models.Transaction{
+			ID:         uuid.New().String(),
+			FromUserID: userID,
+			ToUserID:   "",
+			Amount:     amount,
+			Currency:   currency,
+			Type:       models.TransactionTypeWithdraw,
+			Status:     models.TransactionStatusSuccess,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
 
-	if err := transactionRepo.Create(transaction); err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to create transaction")
-	}
+		if err := transactionRepo.Create(transaction); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to create transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
 
-	// Update wallet balance
-	wallet.Balance -= amount
-	wallet.UpdatedAt = time.Now()
-	if err := walletRepo.Update(wallet); err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to update wallet")
-	}
+		// Update wallet balance
+		wallet.Balance -= amount
+		wallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(wallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update wallet")
+		}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to commit transaction")
+		if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+			{account: models.WalletLedgerAccount(wallet.ID), amount: -amount},
+			{account: models.LedgerAccountWorldWithdrawals, amount: amount},
+		}, wallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := s.chargeFees(tx, wallet, []*models.Transaction{transaction}, fees); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		return idempotentResponse{Balance: wallet.Balance, TransactionID: transaction.ID}, nil
+	})
+	if apiErr != nil {
+		return 0, apiErr
 	}
 
-	s.Cache.Delete(userID)
-	return wallet.Balance, nil
+	s.changed(userID)
+	return response.Balance, nil
 }
 
-func (s *walletService) Transfer(fromUserID, toUserID string, amount float64) (float64, *APIError) {
-	if amount <= 0 {
-		return 0, NewBadRequestError("Invalid amount")
+func (s *walletService) Transfer(fromUserID, toUserID string, amount money.Amount, currency string, idem Idempotency) (money.Amount, *APIError) {
+	currency, apiErr := validateAmount(amount, currency)
+	if apiErr != nil {
+		return 0, apiErr
 	}
-
-	// Get sender's wallet
-	fromWallet, err := s.WalletRepo.FindByUserID(fromUserID)
-	if err != nil {
-		return 0, NewInternalServerError("Failed to get sender's wallet")
+	if fromUserID == toUserID {
+		return 0, NewBadRequestError("Cannot transfer to yourself")
 	}
 
-	// Get recipient's wallet
-	toWallet, err := s.WalletRepo.FindByUserID(toUserID)
-	if err != nil {
-		return 0, NewInternalServerError("Failed to get recipient's wallet")
-	}
+	response, apiErr := s.runIdempotent(fromUserID, idem, func(tx *gorm.DB) (idempotentResponse, *APIError) {
+		// Create repository instances with transaction
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
 
-	if fromWallet.Balance < amount {
-		return 0, NewBadRequestError("Insufficient balance")
-	}
+		fromWallet, toWallet, apiErr := lockWalletPair(walletRepo, fromUserID, currency, toUserID, currency)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := checkTransfer(fromWallet, toWallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
 
-	// Start a database transaction
-	tx := s.WalletRepo.DB().Begin()
-	if tx.Error != nil {
-		return 0, NewInternalServerError("Failed to start transaction")
-	}
+		if apiErr := s.checkLimits(tx, fromUserID, models.LimitOperationTransfer, currency, amount); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
 
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+		fees, fee, apiErr := s.feesFor(fromUserID, models.FeeOperationTransfer, currency, amount)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
 		}
-	}()
 
-	// Create repository instances with transaction
-	walletRepo := s.WalletRepo.WithTx(tx)
-	transactionRepo := s.TransactionRepo.WithTx(tx)
+		available, apiErr := availableBalance(s.HoldRepo.WithTx(tx), fromWallet)
+		if apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if available < amount+fee {
+			return idempotentResponse{}, NewInsufficientBalanceError()
+		}
 
-	// Create transaction
-	transaction := &models.Transaction{
-		ID:         uuid.New().String(),
-		FromUserID: fromUserID,
-		ToUserID:   toUserID,
-		Amount:     amount,
-		Type:       models.TransactionTypeTransfer,
-		Status:     models.TransactionStatusSuccess,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
-	}
+		// Create transaction
+		transaction := &models.Transaction{
+			ID:         uuid.New().String(),
+			FromUserID: fromUserID,
+			ToUserID:   toUserID,
+			Amount:     amount,
+			Currency:   currency,
+			Type:       models.TransactionTypeTransfer,
+			Status:     models.TransactionStatusSuccess,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
 
-	if err := transactionRepo.Create(transaction); err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to create transaction")
-	}
+		if err := transactionRepo.Create(transaction); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to create transaction")
+		}
+		if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
 
-	// Update sender's wallet
-	fromWallet.Balance -= amount
-	fromWallet.UpdatedAt = time.Now()
-	if err := walletRepo.Update(fromWallet); err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to update sender's wallet")
-	}
+		// Update sender's wallet
+		fromWallet.Balance -= amount
+		fromWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(fromWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update sender's wallet")
+		}
 
-	// Update recipient's wallet
-	toWallet.Balance += amount
-	toWallet.UpdatedAt = time.Now()
-	if err := walletRepo.Update(toWallet); err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to update recipient's wallet")
+		// Update recipient's wallet
+		toWallet.Balance += amount
+		toWallet.UpdatedAt = time.Now()
+		if err := walletRepo.Update(toWallet); err != nil {
+			return idempotentResponse{}, NewInternalServerError("Failed to update recipient's wallet")
+		}
+
+		if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+			{account: models.WalletLedgerAccount(fromWallet.ID), amount: -amount},
+			{account: models.WalletLedgerAccount(toWallet.ID), amount: amount},
+		}, fromWallet, toWallet); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		if apiErr := s.chargeFees(tx, fromWallet, []*models.Transaction{transaction}, fees); apiErr != nil {
+			return idempotentResponse{}, apiErr
+		}
+		return idempotentResponse{Balance: fromWallet.Balance, TransactionID: transaction.ID}, nil
+	})
+	if apiErr != nil {
+		return 0, apiErr
 	}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		tx.Rollback()
-		return 0, NewInternalServerError("Failed to commit transaction")
+	s.changed(fromUserID, toUserID)
+	return response.Balance, nil
+}
+
+func (s *walletService) GetBalance(userID, currency string) (*models.Balance, *APIError) {
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
-	s.Cache.Delete(fromUserID)
-	s.Cache.Delete(toUserID)
-	return fromWallet.Balance, nil
+	wallet, apiErr := s.findWallet(userID, currency)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return s.balanceOf(wallet)
 }
 
-func (s *walletService) GetBalance(userID string) (float64, *APIError) {
-	wallet, err := s.WalletRepo.FindByUserID(userID)
+func (s *walletService) GetBalances(userID string) ([]models.Balance, *APIError) {
+	wallets, err := s.WalletRepo.ListByUserID(userID)
 	if err != nil {
-		return 0, NewInternalServerError("Failed to get wallet")
+		return nil, NewInternalServerError("Failed to get wallets")
 	}
-	return wallet.Balance, nil
+
+	balances := make([]models.Balance, 0, len(wallets))
+	for i := range wallets {
+		balance, apiErr := s.balanceOf(&wallets[i])
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		balances = append(balances, *balance)
+	}
+	return balances, nil
+}
+
+// balanceOf splits a wallet's balance into held and available funds. It reads
+// without locks, so it is a snapshot for display rather than a spending check.
+func (s *walletService) balanceOf(wallet *models.Wallet) (*models.Balance, *APIError) {
+	available, apiErr := availableBalance(s.HoldRepo, wallet)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	status := wallet.Status
+	if status == "" {
+		status = models.WalletStatusActive
+	}
+	return &models.Balance{
+		Currency:      wallet.Currency,
+		Status:        status,
+		LedgerBalance: wallet.Balance,
+		Held:          wallet.Balance - available,
+		Available:     available,
+	}, nil
 }
 
 func (s *walletService) GetTransactionHistory(userID string, page, pageSize int, transactionType, status string) ([]models.Transaction, *APIError) {
@@ -270,3 +418,136 @@ func (s *walletService) GetTransactionHistory(userID string, page, pageSize int,
 
 	return transactions, nil
 }
+
+// inTx runs fn inside a database transaction. The transaction is committed
+// when fn returns nil and rolled back otherwise.
+func (s *walletService) inTx(fn func(tx *gorm.DB) *APIError) *APIError {
+	return runInTx(s.WalletRepo.DB(), fn)
+}
+
+// runInTx runs fn inside a transaction on db, committing when fn returns nil
+// and rolling back otherwise.
+func runInTx(db *gorm.DB, fn func(tx *gorm.DB) *APIError) *APIError {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return NewInternalServerError("Failed to start transaction")
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if apiErr := fn(tx); apiErr != nil {
+		tx.Rollback()
+		return apiErr
+	}
+
+	// Commit the transaction
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		return NewInternalServerError("Failed to commit transaction")
+	}
+	return nil
+}
+
+// normalizeCurrency resolves an optional ISO-4217 code to its canonical form,
+// falling back to the default currency when none is given.
+func normalizeCurrency(currency string) (string, *APIError) {
+	if currency == "" {
+		return money.DefaultCurrency, nil
+	}
+	c, err := money.LookupCurrency(currency)
+	if err != nil {
+		return "", NewBadRequestError("Unsupported currency")
+	}
+	return c.Code, nil
+}
+
+// validateAmount checks that amount is positive and representable in the
+// currency's minor unit, and returns the canonical currency code.
+func validateAmount(amount money.Amount, currency string) (string, *APIError) {
+	if amount <= 0 {
+		return "", NewBadRequestError("Invalid amount")
+	}
+	currency, apiErr := normalizeCurrency(currency)
+	if apiErr != nil {
+		return "", apiErr
+	}
+	c, _ := money.LookupCurrency(currency)
+	if err := c.Validate(amount); err != nil {
+		return "", NewBadRequestError(err.Error())
+	}
+	return currency, nil
+}
+
+// findWallet returns a user's wallet in a currency without locking it.
+func (s *walletService) findWallet(userID, currency string) (*models.Wallet, *APIError) {
+	wallet, err := s.WalletRepo.FindByUserID(userID, currency)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("No " + currency + " wallet")
+		}
+		return nil, NewInternalServerError("Failed to get wallet")
+	}
+	return wallet, nil
+}
+
+// lockWallet locks a user's wallet in the given currency for update.
+func lockWallet(walletRepo repositories.WalletRepository, userID, currency string) (*models.Wallet, *APIError) {
+	wallet, err := walletRepo.FindByUserIDForUpdate(userID, currency)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("No " + currency + " wallet")
+		}
+		return nil, NewInternalServerError("Failed to get wallet")
+	}
+	return wallet, nil
+}
+
+// lockWalletPair locks the sender's and recipient's wallets for update. Locks
+// are always taken in (user ID, currency) order so that two opposing
+// transfers between the same pair of wallets cannot deadlock.
+func lockWalletPair(walletRepo repositories.WalletRepository, fromUserID, fromCurrency, toUserID, toCurrency string) (*models.Wallet, *models.Wallet, *APIError) {
+	lock := func(userID, currency string, sender bool) (*models.Wallet, *APIError) {
+		wallet, err := walletRepo.FindByUserIDForUpdate(userID, currency)
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				if sender {
+					return nil, NewInternalServerError("Failed to get sender's wallet")
+				}
+				return nil, NewInternalServerError("Failed to get recipient's wallet")
+			}
+			if sender {
+				return nil, NewNotFoundError("No " + currency + " wallet")
+			}
+			return nil, NewBadRequestError("Recipient has no " + currency + " wallet")
+		}
+		return wallet, nil
+	}
+
+	senderFirst := fromUserID < toUserID || (fromUserID == toUserID && fromCurrency < toCurrency)
+	if senderFirst {
+		fromWallet, apiErr := lock(fromUserID, fromCurrency, true)
+		if apiErr != nil {
+			return nil, nil, apiErr
+		}
+		toWallet, apiErr := lock(toUserID, toCurrency, false)
+		if apiErr != nil {
+			return nil, nil, apiErr
+		}
+		return fromWallet, toWallet, nil
+	}
+
+	toWallet, apiErr := lock(toUserID, toCurrency, false)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	fromWallet, apiErr := lock(fromUserID, fromCurrency, true)
+	if apiErr != nil {
+		return nil, nil, apiErr
+	}
+	return fromWallet, toWallet, nil
+}
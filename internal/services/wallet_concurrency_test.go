@@ -0,0 +1,209 @@
+package services
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	cachemock "wallet/internal/cache/mock"
+	"wallet/internal/migrations"
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/pubsub"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// setupPostgres connects to the database named by TEST_DATABASE_DSN. Row locks
+// can only be exercised against a real Postgres, so the test is skipped when
+// the variable is not set.
+func setupPostgres(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, migrations.NewMigrator(db).Migrate())
+	return db
+}
+
+// createTestWallet creates a wallet together with the opening balance postings
+// that keep it in agreement with the ledger.
+func createTestWallet(t *testing.T, db *gorm.DB, balance money.Amount) string {
+	userID := uuid.New().String()
+	wallet := &models.Wallet{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Currency:  money.DefaultCurrency,
+		Balance:   balance,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	require.NoError(t, db.Create(wallet).Error)
+
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	require.NoError(t, ledgerRepo.EnsureAccounts([]models.LedgerAccount{
+		{Code: models.WalletLedgerAccount(wallet.ID), Type: models.LedgerAccountTypeWallet, WalletID: wallet.ID, CreatedAt: time.Now()},
+		{Code: models.LedgerAccountOpeningBalances, Type: models.LedgerAccountTypeSystem, CreatedAt: time.Now()},
+	}))
+	require.NoError(t, ledgerRepo.CreatePostings([]models.LedgerPosting{
+		{ID: uuid.New().String(), AccountCode: models.WalletLedgerAccount(wallet.ID), Amount: balance, Currency: money.DefaultCurrency, CreatedAt: time.Now()},
+		{ID: uuid.New().String(), AccountCode: models.LedgerAccountOpeningBalances, Amount: -balance, Currency: money.DefaultCurrency, CreatedAt: time.Now()},
+	}))
+	return userID
+}
+
+func TestWalletService_Concurrent(t *testing.T) {
+	db := setupPostgres(t)
+	walletRepo := repositories.NewWalletRepository(db)
+	transactionRepo := repositories.NewTransactionRepository(db)
+	walletService := NewWalletService(walletRepo, transactionRepo, repositories.NewLedgerRepository(db), repositories.NewIdempotencyKeyRepository(db), repositories.NewFXQuoteRepository(db), repositories.NewHoldRepository(db), repositories.NewLimitRepository(db), repositories.NewUserRepository(db), repositories.NewOutboxRepository(db), &cachemock.MockCache{}, pubsub.NewInMemoryPubSub(), 0, 0, nil, nil)
+
+	t.Run("concurrent withdrawals never overdraw", func(t *testing.T) {
+		userID := createTestWallet(t, db, money.FromMajor(100))
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		succeeded := 0
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, apiErr := walletService.Withdraw(userID, money.FromMajor(10), "USD", Idempotency{}); apiErr == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		wallet, err := walletRepo.FindByUserID(userID, money.DefaultCurrency)
+		require.NoError(t, err)
+		assert.Equal(t, 10, succeeded)
+		assert.Equal(t, money.Zero, wallet.Balance)
+	})
+
+	t.Run("concurrent deposits are not lost", func(t *testing.T) {
+		userID := createTestWallet(t, db, money.Zero)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 100; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, apiErr := walletService.Deposit(userID, money.MustParse("0.10"), "USD", Idempotency{})
+				assert.Nil(t, apiErr)
+			}()
+		}
+		wg.Wait()
+
+		wallet, err := walletRepo.FindByUserID(userID, money.DefaultCurrency)
+		require.NoError(t, err)
+		assert.Equal(t, money.FromMajor(10), wallet.Balance)
+	})
+
+	t.Run("opposing transfers do not deadlock", func(t *testing.T) {
+		alice := createTestWallet(t, db, money.FromMajor(100))
+		bob := createTestWallet(t, db, money.FromMajor(100))
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, apiErr := walletService.Transfer(alice, bob, money.FromMajor(1), "USD", Idempotency{})
+				assert.Nil(t, apiErr)
+			}()
+			go func() {
+				defer wg.Done()
+				_, apiErr := walletService.Transfer(bob, alice, money.FromMajor(1), "USD", Idempotency{})
+				assert.Nil(t, apiErr)
+			}()
+		}
+		wg.Wait()
+
+		aliceWallet, err := walletRepo.FindByUserID(alice, money.DefaultCurrency)
+		require.NoError(t, err)
+		bobWallet, err := walletRepo.FindByUserID(bob, money.DefaultCurrency)
+		require.NoError(t, err)
+		assert.Equal(t, money.FromMajor(100), aliceWallet.Balance)
+		assert.Equal(t, money.FromMajor(100), bobWallet.Balance)
+	})
+
+	t.Run("writes do not wait on the transaction log head", func(t *testing.T) {
+		userID := createTestWallet(t, db, money.Zero)
+
+		// Hold the head the way a slow sequencer would
+		sequencer := db.Begin()
+		defer sequencer.Rollback()
+		require.NoError(t, sequencer.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", models.TransactionLogHeadID).First(&models.TransactionLogHead{}).Error)
+
+		done := make(chan *APIError, 1)
+		go func() {
+			_, apiErr := walletService.Deposit(userID, money.FromMajor(1), "USD", Idempotency{})
+			done <- apiErr
+		}()
+		select {
+		case apiErr := <-done:
+			assert.Nil(t, apiErr)
+		case <-time.After(5 * time.Second):
+			t.Fatal("deposit waited for the transaction log head")
+		}
+	})
+
+	t.Run("the sequencer chains concurrent writes", func(t *testing.T) {
+		logService := NewTransactionLogService(repositories.NewTransactionLogRepository(db), transactionRepo, nil, nil)
+		alice := createTestWallet(t, db, money.FromMajor(100))
+		bob := createTestWallet(t, db, money.FromMajor(100))
+
+		stop := make(chan struct{})
+		sequenced := make(chan *APIError)
+		go func() {
+			for {
+				select {
+				case <-stop:
+					_, apiErr := logService.AppendPending()
+					sequenced <- apiErr
+					return
+				default:
+					logService.AppendPending()
+				}
+			}
+		}()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, apiErr := walletService.Transfer(alice, bob, money.FromMajor(1), "USD", Idempotency{})
+				assert.Nil(t, apiErr)
+			}()
+			go func() {
+				defer wg.Done()
+				_, apiErr := walletService.Deposit(bob, money.FromMajor(1), "USD", Idempotency{})
+				assert.Nil(t, apiErr)
+			}()
+		}
+		wg.Wait()
+		close(stop)
+		require.Nil(t, <-sequenced)
+
+		result, apiErr := logService.Verify(nil)
+		require.Nil(t, apiErr)
+		assert.Nil(t, result.Broken)
+	})
+}
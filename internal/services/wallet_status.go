@@ -0,0 +1,270 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// NewWalletUnavailableError is returned when the user's own wallet is frozen
+// or closed.
+func NewWalletUnavailableError(wallet *models.Wallet) *APIError {
+	if wallet.Status == models.WalletStatusClosed {
+		return NewAPIError(http.StatusForbidden, wallet.Currency+" wallet is closed")
+	}
+	return NewAPIError(http.StatusForbidden, wallet.Currency+" wallet is frozen")
+}
+
+// checkDebit rejects taking money out of a wallet that is frozen or closed.
+// own is false when the wallet belongs to the other party of a payment, whose
+// wallet status is not disclosed.
+func checkDebit(wallet *models.Wallet, own bool) *APIError {
+	if wallet.CanDebit() {
+		return nil
+	}
+	if own {
+		return NewWalletUnavailableError(wallet)
+	}
+	return NewBadRequestError("Sender's wallet cannot send funds")
+}
+
+// checkCredit rejects paying into a wallet that is frozen for all movements
+// or closed; see checkDebit.
+func checkCredit(wallet *models.Wallet, own bool) *APIError {
+	if wallet.CanCredit() {
+		return nil
+	}
+	if own {
+		return NewWalletUnavailableError(wallet)
+	}
+	return NewBadRequestError("Recipient's wallet cannot receive funds")
+}
+
+// checkTransfer checks that the sender can pay and the recipient can be paid.
+func checkTransfer(fromWallet, toWallet *models.Wallet) *APIError {
+	if apiErr := checkDebit(fromWallet, true); apiErr != nil {
+		return apiErr
+	}
+	return checkCredit(toWallet, false)
+}
+
+// SetWalletStatus freezes or unfreezes a wallet on behalf of support, and
+// records who did it and why. Closing goes through CloseWallet, and a closed
+// wallet cannot be reopened.
+func (s *walletService) SetWalletStatus(actorID, walletID, status, reason string) (*models.Wallet, *APIError) {
+	switch status {
+	case models.WalletStatusActive, models.WalletStatusFrozenDebits, models.WalletStatusFrozenAll:
+	case models.WalletStatusClosed:
+		return nil, NewBadRequestError("Wallets are closed through the close endpoint")
+	default:
+		return nil, NewBadRequestError("Invalid wallet status")
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, NewBadRequestError("A reason is required")
+	}
+
+	var wallet *models.Wallet
+	apiErr := s.inTx(func(tx *gorm.DB) *APIError {
+		walletRepo := s.WalletRepo.WithTx(tx)
+
+		var apiErr *APIError
+		wallet, apiErr = lockWalletByID(walletRepo, walletID)
+		if apiErr != nil {
+			return apiErr
+		}
+		if wallet.Status == models.WalletStatusClosed {
+			return NewConflictError("Wallet is closed")
+		}
+		if wallet.Status == status {
+			return nil
+		}
+		return s.changeStatus(tx, wallet, actorID, status, reason)
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.changed(wallet.UserID)
+	return wallet, nil
+}
+
+// CloseWallet closes a wallet for good. Its balance must be zero, unless
+// sweepToUserID names a user whose wallet in the same currency takes the
+// remainder. A wallet with active holds cannot be closed until they are
+// settled.
+func (s *walletService) CloseWallet(actorID, walletID, sweepToUserID, reason string) (*models.Wallet, *APIError) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, NewBadRequestError("A reason is required")
+	}
+
+	// The owner and currency never change, so they can be read before the
+	// wallet is locked together with the sweep target in the usual order
+	current, err := s.WalletRepo.FindByID(walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Wallet not found")
+		}
+		return nil, NewInternalServerError("Failed to get wallet")
+	}
+	if sweepToUserID == current.UserID {
+		return nil, NewBadRequestError("Cannot sweep a wallet into itself")
+	}
+
+	var wallet *models.Wallet
+	var sweepTo *models.Wallet
+	apiErr := s.inTx(func(tx *gorm.DB) *APIError {
+		walletRepo := s.WalletRepo.WithTx(tx)
+		transactionRepo := s.TransactionRepo.WithTx(tx)
+
+		var apiErr *APIError
+		if sweepToUserID == "" {
+			wallet, apiErr = lockWallet(walletRepo, current.UserID, current.Currency)
+		} else {
+			wallet, sweepTo, apiErr = lockWalletPair(walletRepo, current.UserID, current.Currency, sweepToUserID, current.Currency)
+		}
+		if apiErr != nil {
+			return apiErr
+		}
+		if wallet.Status == models.WalletStatusClosed {
+			return NewConflictError("Wallet is already closed")
+		}
+
+		held, err := s.HoldRepo.WithTx(tx).SumActiveByWalletID(wallet.ID, time.Now())
+		if err != nil {
+			return NewInternalServerError("Failed to get held amount")
+		}
+		if held > 0 {
+			return NewConflictError("Wallet has active holds")
+		}
+		if wallet.Balance < 0 {
+			return NewConflictError("Wallet balance is negative")
+		}
+
+		if wallet.Balance > 0 {
+			if sweepToUserID == "" {
+				return NewConflictError("Wallet balance must be zero, or swept to another wallet")
+			}
+			if apiErr := checkCredit(sweepTo, false); apiErr != nil {
+				return apiErr
+			}
+
+			amount := wallet.Balance
+			transaction := &models.Transaction{
+				ID:         uuid.New().String(),
+				FromUserID: wallet.UserID,
+				ToUserID:   sweepToUserID,
+				Amount:     amount,
+				Currency:   wallet.Currency,
+				Type:       models.TransactionTypeSweep,
+				Status:     models.TransactionStatusSuccess,
+				CreatedAt:  time.Now(),
+				UpdatedAt:  time.Now(),
+			}
+			if err := transactionRepo.Create(transaction); err != nil {
+				return NewInternalServerError("Failed to create transaction")
+			}
+			if apiErr := s.publishTransaction(tx, transaction); apiErr != nil {
+				return apiErr
+			}
+
+			wallet.Balance = 0
+			wallet.UpdatedAt = time.Now()
+			if err := walletRepo.Update(wallet); err != nil {
+				return NewInternalServerError("Failed to update wallet")
+			}
+			sweepTo.Balance += amount
+			sweepTo.UpdatedAt = time.Now()
+			if err := walletRepo.Update(sweepTo); err != nil {
+				return NewInternalServerError("Failed to update recipient's wallet")
+			}
+
+			if apiErr := s.postLedger(tx, transaction, []ledgerEntry{
+				{account: models.WalletLedgerAccount(wallet.ID), amount: -amount},
+				{account: models.WalletLedgerAccount(sweepTo.ID), amount: amount},
+			}, wallet, sweepTo); apiErr != nil {
+				return apiErr
+			}
+		}
+
+		now := time.Now()
+		wallet.ClosedAt = &now
+		return s.changeStatus(tx, wallet, actorID, models.WalletStatusClosed, reason)
+	})
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
+	s.changed(wallet.UserID)
+	if sweepTo != nil {
+		s.changed(sweepTo.UserID)
+	}
+	return wallet, nil
+}
+
+// changeStatus saves a wallet's new status and records the change.
+func (s *walletService) changeStatus(tx *gorm.DB, wallet *models.Wallet, actorID, status, reason string) *APIError {
+	walletRepo := s.WalletRepo.WithTx(tx)
+
+	event := &models.WalletStatusEvent{
+		ID:         uuid.New().String(),
+		WalletID:   wallet.ID,
+		FromStatus: wallet.Status,
+		ToStatus:   status,
+		Reason:     reason,
+		ActorID:    actorID,
+		CreatedAt:  time.Now(),
+	}
+	if event.FromStatus == "" {
+		event.FromStatus = models.WalletStatusActive
+	}
+
+	wallet.Status = status
+	wallet.StatusReason = reason
+	wallet.UpdatedAt = time.Now()
+	if err := walletRepo.Update(wallet); err != nil {
+		return NewInternalServerError("Failed to update wallet")
+	}
+	if err := walletRepo.CreateStatusEvent(event); err != nil {
+		return NewInternalServerError("Failed to record wallet status change")
+	}
+	return s.publish(tx, models.EventWalletStatusChanged, event)
+}
+
+// ListWallets returns all of a user's wallets, whatever their status.
+func (s *walletService) ListWallets(userID string) ([]models.Wallet, *APIError) {
+	wallets, err := s.WalletRepo.ListByUserID(userID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get wallets")
+	}
+	return wallets, nil
+}
+
+// ListWalletStatusEvents returns the history of a wallet's status changes.
+func (s *walletService) ListWalletStatusEvents(walletID string) ([]models.WalletStatusEvent, *APIError) {
+	events, err := s.WalletRepo.FindStatusEvents(walletID)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get wallet status changes")
+	}
+	return events, nil
+}
+
+// lockWalletByID locks a wallet by its ID for update.
+func lockWalletByID(walletRepo repositories.WalletRepository, walletID string) (*models.Wallet, *APIError) {
+	wallet, err := walletRepo.FindByIDForUpdate(walletID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Wallet not found")
+		}
+		return nil, NewInternalServerError("Failed to get wallet")
+	}
+	return wallet, nil
+}
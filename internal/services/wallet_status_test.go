@@ -0,0 +1,271 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalletService_StatusEnforcement(t *testing.T) {
+	wallets := func(statuses map[string]string) func(userID, currency string) (*models.Wallet, error) {
+		return func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(100), Status: statuses[userID]}, nil
+		}
+	}
+
+	t.Run("a wallet frozen for debits can still receive deposits", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = wallets(map[string]string{"user123": models.WalletStatusFrozenDebits})
+		env.sqlMock.ExpectCommit()
+
+		balance, apiErr := env.service.Deposit("user123", money.FromMajor(10), "USD", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, money.FromMajor(110), balance)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("a wallet frozen for debits cannot withdraw", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = wallets(map[string]string{"user123": models.WalletStatusFrozenDebits})
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Withdraw("user123", money.FromMajor(10), "USD", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusForbidden, apiErr.Code)
+			assert.Equal(t, "USD wallet is frozen", apiErr.Message)
+		}
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("a wallet frozen for everything cannot receive deposits", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = wallets(map[string]string{"user123": models.WalletStatusFrozenAll})
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Deposit("user123", money.FromMajor(10), "USD", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "USD wallet is frozen", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("transfers to a frozen recipient are rejected without saying why", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = wallets(map[string]string{"user456": models.WalletStatusFrozenAll})
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(10), "USD", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+			assert.Equal(t, "Recipient's wallet cannot receive funds", apiErr.Message)
+		}
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("batches are rejected when any recipient is closed", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = wallets(map[string]string{"user789": models.WalletStatusClosed})
+		env.sqlMock.ExpectRollback()
+
+		_, _, apiErr := env.service.BatchTransfer("user123", "USD", []BatchTransferItem{
+			{ToUserID: "user456", Amount: money.FromMajor(10)},
+			{ToUserID: "user789", Amount: money.FromMajor(10)},
+		}, Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Recipient's wallet cannot receive funds", apiErr.Message)
+		}
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("a closed wallet cannot be opened again", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByUserIDFunc = wallets(map[string]string{"user123": models.WalletStatusClosed})
+
+		_, apiErr := env.service.OpenWallet("user123", "USD")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusConflict, apiErr.Code)
+			assert.Equal(t, "USD wallet is closed", apiErr.Message)
+		}
+	})
+}
+
+func TestWalletService_SetWalletStatus(t *testing.T) {
+	t.Run("freezes a wallet and records the change", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByIDForUpdateFunc = func(id string) (*models.Wallet, error) {
+			return &models.Wallet{ID: id, UserID: "user123", Currency: "USD"}, nil
+		}
+		var events []*models.WalletStatusEvent
+		env.walletRepo.CreateStatusEventFunc = func(event *models.WalletStatusEvent) error {
+			events = append(events, event)
+			return nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		wallet, apiErr := env.service.SetWalletStatus("admin1", "wallet1", models.WalletStatusFrozenAll, " fraud review ")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.WalletStatusFrozenAll, wallet.Status)
+		assert.Equal(t, "fraud review", wallet.StatusReason)
+		if assert.Len(t, events, 1) {
+			assert.Equal(t, "wallet1", events[0].WalletID)
+			assert.Equal(t, models.WalletStatusActive, events[0].FromStatus)
+			assert.Equal(t, models.WalletStatusFrozenAll, events[0].ToStatus)
+			assert.Equal(t, "admin1", events[0].ActorID)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("requires a reason", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		_, apiErr := env.service.SetWalletStatus("admin1", "wallet1", models.WalletStatusFrozenAll, " ")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "A reason is required", apiErr.Message)
+		}
+	})
+
+	t.Run("cannot close or reopen through a status change", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		_, apiErr := env.service.SetWalletStatus("admin1", "wallet1", models.WalletStatusClosed, "done")
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+		}
+
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByIDForUpdateFunc = func(id string) (*models.Wallet, error) {
+			return &models.Wallet{ID: id, UserID: "user123", Currency: "USD", Status: models.WalletStatusClosed}, nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr = env.service.SetWalletStatus("admin1", "wallet1", models.WalletStatusActive, "reopen")
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusConflict, apiErr.Code)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWalletService_CloseWallet(t *testing.T) {
+	t.Run("closes an empty wallet", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByIDFunc = func(id string) (*models.Wallet, error) {
+			return &models.Wallet{ID: id, UserID: "user123", Currency: "USD"}, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		wallet, apiErr := env.service.CloseWallet("admin1", "wallet1", "", "customer request")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.WalletStatusClosed, wallet.Status)
+		assert.NotNil(t, wallet.ClosedAt)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("sweeps the remaining balance", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByIDFunc = func(id string) (*models.Wallet, error) {
+			return &models.Wallet{ID: id, UserID: "user456", Currency: "USD"}, nil
+		}
+		env.sqlMock.ExpectBegin()
+		var locked []string
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			locked = append(locked, userID)
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Currency: currency, Balance: money.FromMajor(25)}, nil
+		}
+		var transactions []*models.Transaction
+		env.transactionRepo.CreateFunc = func(transaction *models.Transaction) error {
+			transactions = append(transactions, transaction)
+			return nil
+		}
+		env.sqlMock.ExpectCommit()
+
+		wallet, apiErr := env.service.CloseWallet("admin1", "wallet-user456", "user123", "customer request")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.WalletStatusClosed, wallet.Status)
+		assert.Equal(t, money.Amount(0), wallet.Balance)
+		assert.Equal(t, []string{"user123", "user456"}, locked)
+		if assert.Len(t, transactions, 1) {
+			assert.Equal(t, models.TransactionTypeSweep, transactions[0].Type)
+			assert.Equal(t, "user456", transactions[0].FromUserID)
+			assert.Equal(t, "user123", transactions[0].ToUserID)
+			assert.Equal(t, money.FromMajor(25), transactions[0].Amount)
+		}
+		var sweptTo models.Wallet
+		for _, updated := range env.walletRepo.Updated {
+			if updated.ID == "wallet-user123" {
+				sweptTo = updated
+			}
+		}
+		assert.Equal(t, money.FromMajor(50), sweptTo.Balance)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("a balance needs somewhere to go", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByIDFunc = func(id string) (*models.Wallet, error) {
+			return &models.Wallet{ID: id, UserID: "user123", Currency: "USD"}, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency, Balance: money.FromMajor(1)}, nil
+		}
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.CloseWallet("admin1", "wallet1", "", "customer request")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusConflict, apiErr.Code)
+		}
+		assert.Empty(t, env.walletRepo.Updated)
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
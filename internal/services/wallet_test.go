@@ -2,10 +2,13 @@ package services
 
 import (
 	"database/sql"
+	"net/http"
 	"testing"
 
 	cachemock "wallet/internal/cache/mock"
 	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/pubsub"
 	"wallet/internal/repositories"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -14,8 +17,26 @@ import (
 	"gorm.io/gorm"
 )
 
-// setupTests initializes a mock DB and repositories for testing
-func setupTests(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *repositories.MockWalletRepository, *repositories.MockTransactionRepository, *cachemock.MockCache, WalletService) {
+// testEnv bundles the stub database and mocked dependencies of a walletService under test
+type testEnv struct {
+	db                 *sql.DB
+	sqlMock            sqlmock.Sqlmock
+	walletRepo         *repositories.MockWalletRepository
+	transactionRepo    *repositories.MockTransactionRepository
+	ledgerRepo         *repositories.MockLedgerRepository
+	idempotencyKeyRepo *repositories.MockIdempotencyKeyRepository
+	fxQuoteRepo        *repositories.MockFXQuoteRepository
+	limitRepo          *repositories.MockLimitRepository
+	userRepo           *repositories.MockUserRepository
+	holdRepo           *repositories.MockHoldRepository
+	outboxRepo         *repositories.MockOutboxRepository
+	cache              *cachemock.MockCache
+	pubSub             *pubsub.InMemoryPubSub
+	service            WalletService
+}
+
+// newTestEnv initializes a mock DB and repositories for testing
+func newTestEnv(t *testing.T) *testEnv {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
@@ -30,7 +51,25 @@ func setupTests(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *repositories.MockWalle
 
 	mockWalletRepo := &repositories.MockWalletRepository{}
 	mockTransactionRepo := &repositories.MockTransactionRepository{}
+	mockLedgerRepo := &repositories.MockLedgerRepository{}
+	mockIdempotencyKeyRepo := &repositories.MockIdempotencyKeyRepository{}
+	mockFXQuoteRepo := &repositories.MockFXQuoteRepository{}
+	mockHoldRepo := &repositories.MockHoldRepository{}
+	mockLimitRepo := &repositories.MockLimitRepository{}
+	mockUserRepo := &repositories.MockUserRepository{}
+	mockOutboxRepo := &repositories.MockOutboxRepository{}
 	mockCache := &cachemock.MockCache{}
+	pubSub := pubsub.NewInMemoryPubSub()
+
+	// By default the ledger agrees with whatever balance a wallet was last updated to
+	mockLedgerRepo.BalanceOfFunc = func(accountCode string) (money.Amount, error) {
+		for i := len(mockWalletRepo.Updated) - 1; i >= 0; i-- {
+			if models.WalletLedgerAccount(mockWalletRepo.Updated[i].ID) == accountCode {
+				return mockWalletRepo.Updated[i].Balance, nil
+			}
+		}
+		return 0, nil
+	}
 
 	// Mock the DB transaction methods
 	mockWalletRepo.DBFunc = func() *gorm.DB {
@@ -43,9 +82,30 @@ func setupTests(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *repositories.MockWalle
 		return mockTransactionRepo // Return the same mock
 	}
 
-	walletService := NewWalletService(mockWalletRepo, mockTransactionRepo, mockCache)
+	walletService := NewWalletService(mockWalletRepo, mockTransactionRepo, mockLedgerRepo, mockIdempotencyKeyRepo, mockFXQuoteRepo, mockHoldRepo, mockLimitRepo, mockUserRepo, mockOutboxRepo, mockCache, pubSub, 0, 0, nil, nil)
+
+	return &testEnv{
+		db:                 db,
+		sqlMock:            mock,
+		walletRepo:         mockWalletRepo,
+		transactionRepo:    mockTransactionRepo,
+		ledgerRepo:         mockLedgerRepo,
+		idempotencyKeyRepo: mockIdempotencyKeyRepo,
+		fxQuoteRepo:        mockFXQuoteRepo,
+		limitRepo:          mockLimitRepo,
+		userRepo:           mockUserRepo,
+		outboxRepo:         mockOutboxRepo,
+		holdRepo:           mockHoldRepo,
+		cache:              mockCache,
+		pubSub:             pubSub,
+		service:            walletService,
+	}
+}
 
-	return db, mock, mockWalletRepo, mockTransactionRepo, mockCache, walletService
+// setupTests initializes a mock DB and repositories for testing
+func setupTests(t *testing.T) (*sql.DB, sqlmock.Sqlmock, *repositories.MockWalletRepository, *repositories.MockTransactionRepository, *cachemock.MockCache, WalletService) {
+	env := newTestEnv(t)
+	return env.db, env.sqlMock, env.walletRepo, env.transactionRepo, env.cache, env.service
 }
 
 func TestWalletService_Deposit(t *testing.T) {
@@ -54,10 +114,10 @@ func TestWalletService_Deposit(t *testing.T) {
 		defer db.Close()
 
 		userID := "user123"
-		amount := 100.0
-		initialBalance := 50.0
+		amount := money.FromMajor(100)
+		initialBalance := money.FromMajor(50)
 
-		mockWalletRepo.FindByUserIDFunc = func(uid string) (*models.Wallet, error) {
+		mockWalletRepo.FindByUserIDForUpdateFunc = func(uid, currency string) (*models.Wallet, error) {
 			assert.Equal(t, userID, uid)
 			return &models.Wallet{ID: "wallet1", UserID: userID, Balance: initialBalance}, nil
 		}
@@ -83,7 +143,7 @@ func TestWalletService_Deposit(t *testing.T) {
 			assert.Equal(t, userID, key)
 		}
 
-		newBalance, err := walletService.Deposit(userID, amount)
+		newBalance, err := walletService.Deposit(userID, amount, "USD", Idempotency{})
 
 		assert.Nil(t, err)
 		assert.Equal(t, initialBalance+amount, newBalance)
@@ -97,10 +157,10 @@ func TestWalletService_Withdraw(t *testing.T) {
 		defer db.Close()
 
 		userID := "user123"
-		amount := 50.0
-		initialBalance := 100.0
+		amount := money.FromMajor(50)
+		initialBalance := money.FromMajor(100)
 
-		mockWalletRepo.FindByUserIDFunc = func(uid string) (*models.Wallet, error) {
+		mockWalletRepo.FindByUserIDForUpdateFunc = func(uid, currency string) (*models.Wallet, error) {
 			assert.Equal(t, userID, uid)
 			return &models.Wallet{ID: "wallet1", UserID: userID, Balance: initialBalance}, nil
 		}
@@ -126,7 +186,7 @@ func TestWalletService_Withdraw(t *testing.T) {
 			assert.Equal(t, userID, key)
 		}
 
-		newBalance, err := walletService.Withdraw(userID, amount)
+		newBalance, err := walletService.Withdraw(userID, amount, "USD", Idempotency{})
 
 		assert.Nil(t, err)
 		assert.Equal(t, initialBalance-amount, newBalance)
@@ -134,21 +194,26 @@ func TestWalletService_Withdraw(t *testing.T) {
 	})
 
 	t.Run("insufficient balance", func(t *testing.T) {
-		db, _, mockWalletRepo, _, _, walletService := setupTests(t)
+		db, mock, mockWalletRepo, _, _, walletService := setupTests(t)
 		defer db.Close()
 
 		userID := "user123"
-		amount := 150.0
-		initialBalance := 100.0
+		amount := money.FromMajor(150)
+		initialBalance := money.FromMajor(100)
 
-		mockWalletRepo.FindByUserIDFunc = func(uid string) (*models.Wallet, error) {
+		mock.ExpectBegin()
+
+		mockWalletRepo.FindByUserIDForUpdateFunc = func(uid, currency string) (*models.Wallet, error) {
 			return &models.Wallet{ID: "wallet1", UserID: userID, Balance: initialBalance}, nil
 		}
 
-		_, apiErr := walletService.Withdraw(userID, amount)
+		mock.ExpectRollback()
+
+		_, apiErr := walletService.Withdraw(userID, amount, "USD", Idempotency{})
 
 		assert.Error(t, apiErr)
 		assert.Equal(t, "Insufficient balance", apiErr.Message)
+		assert.NoError(t, mock.ExpectationsWereMet())
 	})
 }
 
@@ -159,11 +224,11 @@ func TestWalletService_Transfer(t *testing.T) {
 
 		fromUserID := "user123"
 		toUserID := "user456"
-		amount := 50.0
-		fromInitialBalance := 100.0
-		toInitialBalance := 20.0
+		amount := money.FromMajor(50)
+		fromInitialBalance := money.FromMajor(100)
+		toInitialBalance := money.FromMajor(20)
 
-		mockWalletRepo.FindByUserIDFunc = func(userID string) (*models.Wallet, error) {
+		mockWalletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
 			if userID == fromUserID {
 				return &models.Wallet{ID: "wallet1", UserID: fromUserID, Balance: fromInitialBalance}, nil
 			}
@@ -202,7 +267,7 @@ func TestWalletService_Transfer(t *testing.T) {
 			assert.Contains(t, []string{fromUserID, toUserID}, key)
 		}
 
-		newBalance, err := walletService.Transfer(fromUserID, toUserID, amount)
+		newBalance, err := walletService.Transfer(fromUserID, toUserID, amount, "USD", Idempotency{})
 
 		assert.Nil(t, err)
 		assert.Equal(t, fromInitialBalance-amount, newBalance)
@@ -212,15 +277,17 @@ func TestWalletService_Transfer(t *testing.T) {
 	})
 
 	t.Run("insufficient balance", func(t *testing.T) {
-		db, _, mockWalletRepo, _, _, walletService := setupTests(t)
+		db, mock, mockWalletRepo, _, _, walletService := setupTests(t)
 		defer db.Close()
 
 		fromUserID := "user123"
 		toUserID := "user456"
-		amount := 150.0
-		fromInitialBalance := 100.0
+		amount := money.FromMajor(150)
+		fromInitialBalance := money.FromMajor(100)
+
+		mock.ExpectBegin()
 
-		mockWalletRepo.FindByUserIDFunc = func(userID string) (*models.Wallet, error) {
+		mockWalletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
 			if userID == fromUserID {
 				return &models.Wallet{ID: "wallet1", UserID: fromUserID, Balance: fromInitialBalance}, nil
 			}
@@ -230,9 +297,131 @@ func TestWalletService_Transfer(t *testing.T) {
 			return nil, nil
 		}
 
-		_, apiErr := walletService.Transfer(fromUserID, toUserID, amount)
+		mock.ExpectRollback()
+
+		_, apiErr := walletService.Transfer(fromUserID, toUserID, amount, "USD", Idempotency{})
 
 		assert.Error(t, apiErr)
 		assert.Equal(t, "Insufficient balance", apiErr.Message)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("locks wallets in user ID order", func(t *testing.T) {
+		db, mock, mockWalletRepo, _, _, walletService := setupTests(t)
+		defer db.Close()
+
+		fromUserID := "user456"
+		toUserID := "user123"
+
+		mock.ExpectBegin()
+
+		var lockOrder []string
+		mockWalletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			lockOrder = append(lockOrder, userID)
+			return &models.Wallet{ID: "wallet-" + userID, UserID: userID, Balance: money.FromMajor(100)}, nil
+		}
+
+		mock.ExpectCommit()
+
+		_, apiErr := walletService.Transfer(fromUserID, toUserID, money.FromMajor(10), "USD", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, []string{"user123", "user456"}, lockOrder)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejects transfer to self", func(t *testing.T) {
+		db, _, _, _, _, walletService := setupTests(t)
+		defer db.Close()
+
+		_, apiErr := walletService.Transfer("user123", "user123", money.FromMajor(10), "USD", Idempotency{})
+
+		assert.Error(t, apiErr)
+		assert.Equal(t, "Cannot transfer to yourself", apiErr.Message)
+	})
+}
+
+func TestWalletService_Currencies(t *testing.T) {
+	t.Run("transfer is rejected when recipient has no wallet in the currency", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.sqlMock.ExpectBegin()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			assert.Equal(t, "EUR", currency)
+			if userID == "user123" {
+				return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency, Balance: money.FromMajor(100)}, nil
+			}
+			return nil, gorm.ErrRecordNotFound
+		}
+
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Transfer("user123", "user456", money.FromMajor(10), "eur", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+			assert.Equal(t, "Recipient has no EUR wallet", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("amount must fit the currency's minor unit", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		_, apiErr := env.service.Deposit("user123", money.MustParse("100.50"), "JPY", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+		}
+	})
+
+	t.Run("unsupported currency", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		_, apiErr := env.service.Deposit("user123", money.FromMajor(1), "XYZ", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Unsupported currency", apiErr.Message)
+		}
+	})
+
+	t.Run("opening a second wallet in the same currency conflicts", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+
+		_, apiErr := env.service.OpenWallet("user123", "usd")
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, http.StatusConflict, apiErr.Code)
+		}
+	})
+
+	t.Run("opens a wallet in a new currency", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByUserIDFunc = func(userID, currency string) (*models.Wallet, error) {
+			return nil, gorm.ErrRecordNotFound
+		}
+		var created *models.Wallet
+		env.walletRepo.CreateFunc = func(w *models.Wallet) error {
+			created = w
+			return nil
+		}
+
+		wallet, apiErr := env.service.OpenWallet("user123", "sgd")
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, created, wallet)
+		assert.Equal(t, "SGD", wallet.Currency)
+		assert.Equal(t, money.Zero, wallet.Balance)
 	})
 }
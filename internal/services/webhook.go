@@ -0,0 +1,500 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"wallet/internal/models"
+	"wallet/internal/repositories"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultWebhookMaxAttempts is how many times a delivery is attempted before
+// it is dead-lettered, unless configured otherwise.
+const DefaultWebhookMaxAttempts = 10
+
+// DefaultWebhookTimeout is how long an endpoint has to answer a delivery.
+const DefaultWebhookTimeout = 10 * time.Second
+
+// Webhook request headers. WebhookSignatureHeader carries
+// "t=<unix timestamp>,v1=<hex HMAC-SHA256 of "<timestamp>.<body>">", keyed
+// with the endpoint's secret.
+const (
+	WebhookSignatureHeader = "X-Wallet-Signature"
+	WebhookEventIDHeader   = "X-Wallet-Event-Id"
+	WebhookEventTypeHeader = "X-Wallet-Event-Type"
+	WebhookDeliveryHeader  = "X-Wallet-Delivery-Id"
+)
+
+// webhookBatchSize is how many events are dispatched at a time.
+const webhookBatchSize = 100
+
+// webhookDeliverBatchSize is how many deliveries are claimed at a time.
+const webhookDeliverBatchSize = 10
+
+// webhookDeliveriesLimit is how many deliveries are listed.
+const webhookDeliveriesLimit = 100
+
+// webhookLeaseMargin is how much longer than a request's timeout a claimed
+// delivery is held back from other dispatchers while it is being sent. A
+// dispatcher that dies mid-send leaves the delivery to be retried once the
+// lease runs out.
+const webhookLeaseMargin = 30 * time.Second
+
+// Retries back off exponentially from webhookRetryBase, up to
+// webhookRetryMax between attempts.
+const (
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax  = 6 * time.Hour
+)
+
+// webhookErrorLength caps the error recorded for a failed attempt.
+const webhookErrorLength = 500
+
+type webhookService struct {
+	WebhookRepo repositories.WebhookRepository
+	OutboxRepo  repositories.OutboxRepository
+	Client      *http.Client
+	MaxAttempts int
+}
+
+func NewWebhookService(
+	webhookRepo repositories.WebhookRepository,
+	outboxRepo repositories.OutboxRepository,
+	client *http.Client,
+	maxAttempts int,
+) WebhookService {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultWebhookTimeout}
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultWebhookMaxAttempts
+	}
+	return &webhookService{
+		WebhookRepo: webhookRepo,
+		OutboxRepo:  outboxRepo,
+		Client:      client,
+		MaxAttempts: maxAttempts,
+	}
+}
+
+// WebhookPayload is the body POSTed to an endpoint for an event.
+type WebhookPayload struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// SignWebhook returns the signature header of a delivery body sent at
+// timestamp. Receivers recompute it with their secret to check that a
+// request comes from us and was not altered.
+func SignWebhook(secret string, timestamp time.Time, body []byte) string {
+	unix := strconv.FormatInt(timestamp.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unix))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "t=" + unix + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay returns how long to wait after the given number of
+// failed attempts.
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, webhookRetryMax)
+}
+
+// CreateEndpoint registers a URL to receive the given event types, or every
+// event if none are given. The returned endpoint carries its signing secret,
+// which is not shown again.
+func (s *webhookService) CreateEndpoint(endpointURL string, eventTypes []string) (*models.WebhookEndpoint, *APIError) {
+	parsed, err := url.Parse(endpointURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, NewBadRequestError("Invalid webhook URL")
+	}
+	for _, eventType := range eventTypes {
+		if !slices.Contains(models.EventTypes, eventType) {
+			return nil, NewBadRequestError("Unknown event type " + eventType)
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, NewInternalServerError("Failed to generate webhook secret")
+	}
+
+	endpoint := &models.WebhookEndpoint{
+		ID:         uuid.New().String(),
+		URL:        endpointURL,
+		EventTypes: strings.Join(eventTypes, ","),
+		Secret:     "whsec_" + hex.EncodeToString(secret),
+		Active:     true,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	if err := s.WebhookRepo.CreateEndpoint(endpoint); err != nil {
+		return nil, NewInternalServerError("Failed to create webhook endpoint")
+	}
+	return endpoint, nil
+}
+
+// ListEndpoints returns every endpoint without its secret.
+func (s *webhookService) ListEndpoints() ([]models.WebhookEndpoint, *APIError) {
+	endpoints, err := s.WebhookRepo.FindEndpoints()
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get webhook endpoints")
+	}
+	for i := range endpoints {
+		endpoints[i].Secret = ""
+	}
+	return endpoints, nil
+}
+
+// DisableEndpoint stops events from being delivered to an endpoint. Its
+// pending deliveries are dead-lettered when they come up.
+func (s *webhookService) DisableEndpoint(endpointID string) *APIError {
+	endpoint, apiErr := s.findEndpoint(endpointID)
+	if apiErr != nil {
+		return apiErr
+	}
+	if !endpoint.Active {
+		return nil
+	}
+	endpoint.Active = false
+	endpoint.UpdatedAt = time.Now()
+	if err := s.WebhookRepo.UpdateEndpoint(endpoint); err != nil {
+		return NewInternalServerError("Failed to update webhook endpoint")
+	}
+	return nil
+}
+
+// ListDeliveries returns the latest deliveries, optionally only those with
+// a status or to an endpoint.
+func (s *webhookService) ListDeliveries(status, endpointID string) ([]models.WebhookDelivery, *APIError) {
+	switch status {
+	case "", models.WebhookDeliveryStatusPending, models.WebhookDeliveryStatusDelivered, models.WebhookDeliveryStatusDead:
+	default:
+		return nil, NewBadRequestError("Invalid delivery status")
+	}
+	deliveries, err := s.WebhookRepo.FindDeliveries(status, endpointID, webhookDeliveriesLimit)
+	if err != nil {
+		return nil, NewInternalServerError("Failed to get webhook deliveries")
+	}
+	return deliveries, nil
+}
+
+// Redeliver queues a delivery to be sent again on the next dispatch with a
+// fresh set of attempts, whether it was delivered, dead-lettered or is still
+// being retried.
+func (s *webhookService) Redeliver(deliveryID string) (*models.WebhookDelivery, *APIError) {
+	delivery, err := s.WebhookRepo.FindDeliveryByID(deliveryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Webhook delivery not found")
+		}
+		return nil, NewInternalServerError("Failed to get webhook delivery")
+	}
+	endpoint, apiErr := s.findEndpoint(delivery.EndpointID)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if !endpoint.Active {
+		return nil, NewConflictError("Webhook endpoint is disabled")
+	}
+
+	delivery.Status = models.WebhookDeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	delivery.DeliveredAt = nil
+	delivery.LeaseUntil = nil
+	delivery.UpdatedAt = time.Now()
+	if err := s.WebhookRepo.UpdateDelivery(delivery); err != nil {
+		return nil, NewInternalServerError("Failed to update webhook delivery")
+	}
+	return delivery, nil
+}
+
+// DispatchEvents queues a delivery of every undispatched outbox event to
+// each active endpoint subscribed to it, and returns the number of events
+// dispatched. Events are claimed with SKIP LOCKED, so several dispatchers
+// can run at once.
+func (s *webhookService) DispatchEvents() (int, *APIError) {
+	dispatched := 0
+	for {
+		var count int
+		apiErr := runInTx(s.OutboxRepo.DB(), func(tx *gorm.DB) *APIError {
+			outboxRepo := s.OutboxRepo.WithTx(tx)
+			webhookRepo := s.WebhookRepo.WithTx(tx)
+
+			events, err := outboxRepo.FindUndispatchedForUpdate(webhookBatchSize)
+			if err != nil {
+				return NewInternalServerError("Failed to get outbox events")
+			}
+			if len(events) == 0 {
+				return nil
+			}
+			endpoints, err := webhookRepo.FindEndpoints()
+			if err != nil {
+				return NewInternalServerError("Failed to get webhook endpoints")
+			}
+
+			now := time.Now()
+			var deliveries []models.WebhookDelivery
+			ids := make([]string, len(events))
+			for i, event := range events {
+				ids[i] = event.ID
+				for _, endpoint := range endpoints {
+					if !endpoint.Active || !endpoint.Subscribes(event.Type) {
+						continue
+					}
+					deliveries = append(deliveries, models.WebhookDelivery{
+						ID:            uuid.New().String(),
+						EventID:       event.ID,
+						EndpointID:    endpoint.ID,
+						EventType:     event.Type,
+						Status:        models.WebhookDeliveryStatusPending,
+						NextAttemptAt: now,
+						CreatedAt:     now,
+						UpdatedAt:     now,
+					})
+				}
+			}
+			if err := webhookRepo.CreateDeliveries(deliveries); err != nil {
+				return NewInternalServerError("Failed to create webhook deliveries")
+			}
+			if err := outboxRepo.MarkDispatched(ids, now); err != nil {
+				return NewInternalServerError("Failed to mark outbox events dispatched")
+			}
+			count = len(events)
+			return nil
+		})
+		if apiErr != nil {
+			return dispatched, apiErr
+		}
+		dispatched += count
+		if count < webhookBatchSize {
+			return dispatched, nil
+		}
+	}
+}
+
+// DeliverDue sends the deliveries whose next attempt is due and returns the
+// number delivered. A failed attempt is retried with exponential backoff
+// until the delivery runs out of attempts and is dead-lettered.
+func (s *webhookService) DeliverDue() (int, *APIError) {
+	delivered := 0
+	for {
+		deliveries, apiErr := s.claimDue()
+		if apiErr != nil {
+			return delivered, apiErr
+		}
+		count, apiErr := s.deliver(deliveries)
+		delivered += count
+		if apiErr != nil || len(deliveries) < webhookDeliverBatchSize {
+			return delivered, apiErr
+		}
+	}
+}
+
+// deliver sends claimed deliveries one at a time and returns the number
+// delivered. Each delivery's lease is renewed just before it is sent, so a
+// slow batch never outlives the leases of the deliveries still to be sent.
+func (s *webhookService) deliver(deliveries []models.WebhookDelivery) (int, *APIError) {
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	eventIDs := make([]string, len(deliveries))
+	for i, delivery := range deliveries {
+		eventIDs[i] = delivery.EventID
+	}
+	found, err := s.OutboxRepo.FindByIDs(eventIDs)
+	if err != nil {
+		return 0, NewInternalServerError("Failed to get outbox events")
+	}
+	events := make(map[string]*models.OutboxEvent, len(found))
+	for i := range found {
+		events[found[i].ID] = &found[i]
+	}
+	endpoints := make(map[string]*models.WebhookEndpoint)
+
+	delivered := 0
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			endpoint, err = s.WebhookRepo.FindEndpointByID(delivery.EndpointID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return delivered, NewInternalServerError("Failed to get webhook endpoint")
+			}
+			endpoints[delivery.EndpointID] = endpoint
+		}
+
+		// Skip the delivery if it was redelivered or claimed by another
+		// dispatcher since this one claimed it
+		leaseUntil := *delivery.LeaseUntil
+		renewed := s.leaseUntil(time.Now())
+		delivery.LeaseUntil = &renewed
+		delivery.NextAttemptAt = renewed
+		leased, err := s.WebhookRepo.UpdateLeasedDelivery(delivery, leaseUntil)
+		if err != nil {
+			return delivered, NewInternalServerError("Failed to update webhook delivery")
+		}
+		if !leased {
+			continue
+		}
+
+		now := time.Now()
+		delivery.UpdatedAt = now
+		switch event := events[delivery.EventID]; {
+		case endpoint == nil || !endpoint.Active:
+			delivery.Status = models.WebhookDeliveryStatusDead
+			delivery.LastError = "Webhook endpoint is disabled"
+		case event == nil:
+			delivery.Status = models.WebhookDeliveryStatusDead
+			delivery.LastError = "Event not found"
+		default:
+			delivery.Attempts++
+			statusCode, err := s.send(endpoint, event, delivery, now)
+			delivery.LastStatusCode = statusCode
+			delivery.LastError = ""
+			switch {
+			case err == nil:
+				delivery.Status = models.WebhookDeliveryStatusDelivered
+				delivery.DeliveredAt = &now
+			case delivery.Attempts >= s.MaxAttempts:
+				delivery.Status = models.WebhookDeliveryStatusDead
+				delivery.LastError = truncate(err.Error(), webhookErrorLength)
+			default:
+				delivery.NextAttemptAt = now.Add(webhookRetryDelay(delivery.Attempts))
+				delivery.LastError = truncate(err.Error(), webhookErrorLength)
+			}
+		}
+		delivery.LeaseUntil = nil
+		leased, err = s.WebhookRepo.UpdateLeasedDelivery(delivery, renewed)
+		if err != nil {
+			return delivered, NewInternalServerError("Failed to update webhook delivery")
+		}
+		if leased && delivery.Status == models.WebhookDeliveryStatusDelivered {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+// claimDue locks the deliveries that are due and leases them, pushing their
+// next attempt back to the end of the lease, so that no other dispatcher
+// sends them while this one does. The lock is only held while claiming, not
+// during the requests.
+func (s *webhookService) claimDue() ([]models.WebhookDelivery, *APIError) {
+	var deliveries []models.WebhookDelivery
+	apiErr := runInTx(s.WebhookRepo.DB(), func(tx *gorm.DB) *APIError {
+		webhookRepo := s.WebhookRepo.WithTx(tx)
+
+		now := time.Now()
+		var err error
+		deliveries, err = webhookRepo.FindDueDeliveriesForUpdate(now, webhookDeliverBatchSize)
+		if err != nil {
+			return NewInternalServerError("Failed to get webhook deliveries")
+		}
+		leaseUntil := s.leaseUntil(now)
+		for i := range deliveries {
+			deliveries[i].LeaseUntil = &leaseUntil
+			deliveries[i].NextAttemptAt = leaseUntil
+			if err := webhookRepo.UpdateDelivery(&deliveries[i]); err != nil {
+				return NewInternalServerError("Failed to update webhook delivery")
+			}
+		}
+		return nil
+	})
+	return deliveries, apiErr
+}
+
+// leaseUntil returns when a lease taken at now runs out: after the longest
+// a request can take, plus webhookLeaseMargin. It is rounded to the
+// microsecond that Postgres stores, so that it can be matched exactly.
+func (s *webhookService) leaseUntil(now time.Time) time.Time {
+	timeout := s.Client.Timeout
+	if timeout <= 0 {
+		timeout = DefaultWebhookTimeout
+	}
+	return now.Add(timeout + webhookLeaseMargin).Truncate(time.Microsecond)
+}
+
+// send POSTs an event to an endpoint and returns the response status. Any
+// status other than 2xx is an error.
+func (s *webhookService) send(endpoint *models.WebhookEndpoint, event *models.OutboxEvent, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	body, err := json.Marshal(WebhookPayload{
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      json.RawMessage(event.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, now, body))
+	req.Header.Set(WebhookEventIDHeader, event.ID)
+	req.Header.Set(WebhookEventTypeHeader, event.Type)
+	req.Header.Set(WebhookDeliveryHeader, delivery.ID)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (s *webhookService) findEndpoint(endpointID string) (*models.WebhookEndpoint, *APIError) {
+	endpoint, err := s.WebhookRepo.FindEndpointByID(endpointID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewNotFoundError("Webhook endpoint not found")
+		}
+		return nil, NewInternalServerError("Failed to get webhook endpoint")
+	}
+	return endpoint, nil
+}
+
+// truncate shortens s to at most n bytes, without splitting a character.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
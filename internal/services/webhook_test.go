@@ -0,0 +1,319 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/money"
+	"wallet/internal/repositories"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func newTestWebhookService(env *testEnv, webhookRepo *repositories.MockWebhookRepository) *webhookService {
+	webhookRepo.DBFunc = env.walletRepo.DBFunc
+	env.outboxRepo.DBFunc = env.walletRepo.DBFunc
+	return NewWebhookService(webhookRepo, env.outboxRepo, nil, 3).(*webhookService)
+}
+
+func TestWalletService_PublishesEvents(t *testing.T) {
+	t.Run("a deposit writes its event in the same transaction", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+
+		_, apiErr := env.service.Deposit("user123", money.FromMajor(10), "USD", Idempotency{})
+
+		assert.Nil(t, apiErr)
+		if assert.Len(t, env.outboxRepo.Events, 1) {
+			event := env.outboxRepo.Events[0]
+			assert.Equal(t, models.EventDepositCompleted, event.Type)
+			var transaction models.Transaction
+			assert.NoError(t, json.Unmarshal([]byte(event.Payload), &transaction))
+			assert.Equal(t, money.FromMajor(10), transaction.Amount)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+
+	t.Run("a failed event write rolls the operation back", func(t *testing.T) {
+		env := newTestEnv(t)
+		defer env.db.Close()
+
+		env.walletRepo.FindByUserIDForUpdateFunc = func(userID, currency string) (*models.Wallet, error) {
+			return &models.Wallet{ID: "wallet1", UserID: userID, Currency: currency}, nil
+		}
+		env.outboxRepo.CreateFunc = func(event *models.OutboxEvent) error {
+			return errors.New("db error")
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectRollback()
+
+		_, apiErr := env.service.Deposit("user123", money.FromMajor(10), "USD", Idempotency{})
+
+		if assert.NotNil(t, apiErr) {
+			assert.Equal(t, "Failed to record event", apiErr.Message)
+		}
+		assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+	})
+}
+
+func TestWebhookService_CreateEndpoint(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+	service := newTestWebhookService(env, &repositories.MockWebhookRepository{})
+
+	endpoint, apiErr := service.CreateEndpoint("https://example.com/hooks", []string{models.EventDepositCompleted, models.EventTransferCompleted})
+	assert.Nil(t, apiErr)
+	assert.Regexp(t, "^whsec_[0-9a-f]{64}$", endpoint.Secret)
+	assert.Equal(t, "deposit.completed,transfer.completed", endpoint.EventTypes)
+	assert.True(t, endpoint.Active)
+
+	_, apiErr = service.CreateEndpoint("ftp://example.com", nil)
+	if assert.NotNil(t, apiErr) {
+		assert.Equal(t, "Invalid webhook URL", apiErr.Message)
+	}
+	_, apiErr = service.CreateEndpoint("https://example.com", []string{"deposit.started"})
+	if assert.NotNil(t, apiErr) {
+		assert.Equal(t, http.StatusBadRequest, apiErr.Code)
+	}
+}
+
+func TestWebhookService_DispatchEvents(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+	webhookRepo := &repositories.MockWebhookRepository{}
+	service := newTestWebhookService(env, webhookRepo)
+
+	env.outboxRepo.FindUndispatchedForUpdateFunc = func(limit int) ([]models.OutboxEvent, error) {
+		return []models.OutboxEvent{
+			{ID: "event1", Type: models.EventDepositCompleted},
+			{ID: "event2", Type: models.EventHoldPlaced},
+		}, nil
+	}
+	webhookRepo.FindEndpointsFunc = func() ([]models.WebhookEndpoint, error) {
+		return []models.WebhookEndpoint{
+			{ID: "all", Active: true},
+			{ID: "deposits", EventTypes: models.EventDepositCompleted, Active: true},
+			{ID: "disabled", Active: false},
+		}, nil
+	}
+	var queued []string
+	webhookRepo.CreateDeliveriesFunc = func(deliveries []models.WebhookDelivery) error {
+		for _, delivery := range deliveries {
+			assert.Equal(t, models.WebhookDeliveryStatusPending, delivery.Status)
+			queued = append(queued, delivery.EventID+"/"+delivery.EndpointID)
+		}
+		return nil
+	}
+	var dispatched []string
+	env.outboxRepo.MarkDispatchedFunc = func(ids []string, at time.Time) error {
+		dispatched = ids
+		return nil
+	}
+	env.sqlMock.ExpectBegin()
+	env.sqlMock.ExpectCommit()
+
+	count, apiErr := service.DispatchEvents()
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, 2, count)
+	assert.Equal(t, []string{"event1/all", "event1/deposits", "event2/all"}, queued)
+	assert.Equal(t, []string{"event1", "event2"}, dispatched)
+	assert.NoError(t, env.sqlMock.ExpectationsWereMet())
+}
+
+func TestWebhookService_DeliverDue(t *testing.T) {
+	event := models.OutboxEvent{
+		ID:        "event1",
+		Type:      models.EventDepositCompleted,
+		Payload:   `{"id":"tx1"}`,
+		CreatedAt: time.Date(2025, time.September, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	setup := func(t *testing.T, handler http.HandlerFunc, attempts int) (*webhookService, *repositories.MockWebhookRepository, *models.WebhookDelivery) {
+		server := httptest.NewServer(handler)
+		t.Cleanup(server.Close)
+
+		env := newTestEnv(t)
+		t.Cleanup(func() { env.db.Close() })
+		webhookRepo := &repositories.MockWebhookRepository{}
+		service := newTestWebhookService(env, webhookRepo)
+
+		webhookRepo.FindDueDeliveriesForUpdateFunc = func(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+			return []models.WebhookDelivery{{
+				ID:         "delivery1",
+				EventID:    event.ID,
+				EndpointID: "endpoint1",
+				EventType:  event.Type,
+				Status:     models.WebhookDeliveryStatusPending,
+				Attempts:   attempts,
+			}}, nil
+		}
+		webhookRepo.FindEndpointByIDFunc = func(id string) (*models.WebhookEndpoint, error) {
+			return &models.WebhookEndpoint{ID: id, URL: server.URL, Secret: "whsec_test", Active: true}, nil
+		}
+		env.outboxRepo.FindByIDsFunc = func(ids []string) ([]models.OutboxEvent, error) {
+			assert.Equal(t, []string{event.ID}, ids)
+			return []models.OutboxEvent{event}, nil
+		}
+		var claimed time.Time
+		webhookRepo.UpdateDeliveryFunc = func(delivery *models.WebhookDelivery) error {
+			claimed = *delivery.LeaseUntil
+			return nil
+		}
+		// The lease is renewed before the request, then released with the result
+		updated := &models.WebhookDelivery{}
+		webhookRepo.UpdateLeasedDeliveryFunc = func(delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error) {
+			if delivery.LeaseUntil != nil {
+				assert.Equal(t, claimed, leaseUntil)
+				claimed = *delivery.LeaseUntil
+				return true, nil
+			}
+			assert.Equal(t, claimed, leaseUntil)
+			*updated = *delivery
+			return true, nil
+		}
+		env.sqlMock.ExpectBegin()
+		env.sqlMock.ExpectCommit()
+		return service, webhookRepo, updated
+	}
+
+	t.Run("posts the signed event and marks the delivery delivered", func(t *testing.T) {
+		service, _, updated := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			signature := r.Header.Get(WebhookSignatureHeader)
+			var timestamp int64
+			_, err := fmt.Sscanf(signature, "t=%d,", &timestamp)
+			assert.NoError(t, err)
+			assert.Equal(t, SignWebhook("whsec_test", time.Unix(timestamp, 0), body), signature)
+			assert.Equal(t, "event1", r.Header.Get(WebhookEventIDHeader))
+			assert.Equal(t, "delivery1", r.Header.Get(WebhookDeliveryHeader))
+			assert.JSONEq(t, `{"id":"event1","type":"deposit.completed","created_at":"2025-09-01T12:00:00Z","data":{"id":"tx1"}}`, string(body))
+			w.WriteHeader(http.StatusNoContent)
+		}, 0)
+
+		delivered, apiErr := service.DeliverDue()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 1, delivered)
+		assert.Equal(t, models.WebhookDeliveryStatusDelivered, updated.Status)
+		assert.Equal(t, 1, updated.Attempts)
+		assert.Equal(t, http.StatusNoContent, updated.LastStatusCode)
+		assert.NotNil(t, updated.DeliveredAt)
+	})
+
+	t.Run("retries a failed delivery with backoff", func(t *testing.T) {
+		service, _, updated := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}, 1)
+
+		delivered, apiErr := service.DeliverDue()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 0, delivered)
+		assert.Equal(t, models.WebhookDeliveryStatusPending, updated.Status)
+		assert.Equal(t, 2, updated.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, updated.LastStatusCode)
+		assert.WithinDuration(t, time.Now().Add(time.Minute), updated.NextAttemptAt, 5*time.Second)
+	})
+
+	t.Run("dead-letters a delivery out of attempts", func(t *testing.T) {
+		service, _, updated := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}, 2)
+
+		_, apiErr := service.DeliverDue()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, models.WebhookDeliveryStatusDead, updated.Status)
+		assert.Equal(t, 3, updated.Attempts)
+		assert.Equal(t, "endpoint responded with status 502", updated.LastError)
+	})
+
+	t.Run("does not send a delivery that lost its lease", func(t *testing.T) {
+		service, webhookRepo, updated := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("a redelivered delivery must not be sent by the old claim")
+		}, 0)
+		webhookRepo.UpdateLeasedDeliveryFunc = func(delivery *models.WebhookDelivery, leaseUntil time.Time) (bool, error) {
+			return false, nil
+		}
+
+		delivered, apiErr := service.DeliverDue()
+
+		assert.Nil(t, apiErr)
+		assert.Equal(t, 0, delivered)
+		assert.Empty(t, updated.ID)
+	})
+
+	t.Run("leases a delivery for longer than a request can take", func(t *testing.T) {
+		service, _, _ := setup(t, func(w http.ResponseWriter, r *http.Request) {}, 0)
+
+		now := time.Now()
+		assert.True(t, service.leaseUntil(now).After(now.Add(service.Client.Timeout)))
+	})
+}
+
+func TestWebhookService_Redeliver(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.db.Close()
+	webhookRepo := &repositories.MockWebhookRepository{}
+	service := newTestWebhookService(env, webhookRepo)
+
+	webhookRepo.FindDeliveryByIDFunc = func(id string) (*models.WebhookDelivery, error) {
+		return &models.WebhookDelivery{ID: id, EndpointID: "endpoint1", Status: models.WebhookDeliveryStatusDead, Attempts: 3}, nil
+	}
+	active := true
+	webhookRepo.FindEndpointByIDFunc = func(id string) (*models.WebhookEndpoint, error) {
+		return &models.WebhookEndpoint{ID: id, Active: active}, nil
+	}
+
+	delivery, apiErr := service.Redeliver("delivery1")
+
+	assert.Nil(t, apiErr)
+	assert.Equal(t, models.WebhookDeliveryStatusPending, delivery.Status)
+	assert.Equal(t, 0, delivery.Attempts)
+	assert.WithinDuration(t, time.Now(), delivery.NextAttemptAt, time.Second)
+	assert.Nil(t, delivery.LeaseUntil)
+
+	active = false
+	_, apiErr = service.Redeliver("delivery1")
+	if assert.NotNil(t, apiErr) {
+		assert.Equal(t, http.StatusConflict, apiErr.Code)
+	}
+
+	webhookRepo.FindDeliveryByIDFunc = func(id string) (*models.WebhookDelivery, error) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	_, apiErr = service.Redeliver("missing")
+	if assert.NotNil(t, apiErr) {
+		assert.Equal(t, http.StatusNotFound, apiErr.Code)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	assert.Equal(t, "short", truncate("short", 10))
+	assert.Equal(t, "abc", truncate("abcdef", 3))
+	// "é" is two bytes, which are not split
+	assert.Equal(t, "ab", truncate("abé", 3))
+	assert.Equal(t, "abé", truncate("abé", 4))
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	assert.Equal(t, 30*time.Second, webhookRetryDelay(1))
+	assert.Equal(t, time.Minute, webhookRetryDelay(2))
+	assert.Equal(t, 4*time.Minute, webhookRetryDelay(4))
+	assert.Equal(t, 6*time.Hour, webhookRetryDelay(20))
+}
@@ -0,0 +1,16 @@
+package mock
+
+import "wallet/internal/sms"
+
+// MockSender is a mock implementation of Sender interface
+type MockSender struct {
+	sms.Sender
+	SendFunc func(phone, message string) error
+}
+
+func (m *MockSender) Send(phone, message string) error {
+	if m.SendFunc != nil {
+		return m.SendFunc(phone, message)
+	}
+	return nil
+}
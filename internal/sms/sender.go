@@ -0,0 +1,21 @@
+package sms
+
+import "log"
+
+// Sender sends a text message to a phone number in E.164 form.
+type Sender interface {
+	Send(phone, message string) error
+}
+
+// LogSender writes messages to the log instead of sending them, for local
+// development and tests.
+type LogSender struct{}
+
+func NewLogSender() *LogSender {
+	return &LogSender{}
+}
+
+func (s *LogSender) Send(phone, message string) error {
+	log.Printf("sms to %s: %s", phone, message)
+	return nil
+}